@@ -1,13 +1,19 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"net/http"
 
 	_ "github.com/jackc/pgx/v5/stdlib" // PostgreSQL driver
 
+	"github.com/faizalom/go-api/internal/authz"
 	"github.com/faizalom/go-api/internal/config"
+	"github.com/faizalom/go-api/internal/jwtkeys"
+	"github.com/faizalom/go-api/internal/password"
+	"github.com/faizalom/go-api/internal/repository"
 	"github.com/faizalom/go-api/internal/router"
+	"github.com/faizalom/go-api/internal/service"
 	"github.com/faizalom/go-api/pkg/logger"
 )
 
@@ -18,6 +24,21 @@ func main() {
 		logger.Error.Fatalf("Could not load configuration: %v", err)
 	}
 
+	keys, err := jwtkeys.Load(config.App.JWT)
+	if err != nil {
+		logger.Error.Fatalf("Could not load JWT keys: %v", err)
+	}
+
+	policy, err := authz.LoadPolicy(config.App.Authz.PolicyFile)
+	if err != nil {
+		logger.Error.Fatalf("Could not load authorization policy: %v", err)
+	}
+
+	passwords, err := password.LoadPolicy(config.App.PasswordPolicy)
+	if err != nil {
+		logger.Error.Fatalf("Could not load password policy: %v", err)
+	}
+
 	//============================================================================
 	// Database Connection
 	//============================================================================
@@ -33,11 +54,18 @@ func main() {
 	}
 	logger.Info.Println("Successfully connected to the database.")
 
+	//============================================================================
+	// Background Jobs
+	//============================================================================
+
+	purger := service.NewDeletedUserPurger(repository.NewUserRepository(db), config.App.DeletedUsers)
+	go purger.Run(context.Background())
+
 	//============================================================================
 
 	logger.Info.Println("Starting the workout API server...")
 
-	r := router.New(db)
+	r := router.New(db, keys, policy, passwords)
 	addr := config.App.Server.Port
 	logger.Info.Printf("Server is listening on http://localhost%s", addr)
 
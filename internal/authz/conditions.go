@@ -0,0 +1,36 @@
+package authz
+
+import (
+	"context"
+
+	"github.com/faizalom/go-api/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// Relationships answers questions about how users relate to each other.
+type Relationships interface {
+	IsCoachOf(ctx context.Context, coachID, athleteID uuid.UUID) (bool, error)
+}
+
+type condition func(ctx context.Context, rel Relationships, subject *model.CustomClaims, resource Resource) (bool, error)
+
+// conditions are the names usable in a rule's conditions list.
+var conditions = map[string]condition{
+	// owner holds when the subject owns the resource.
+	"owner": func(ctx context.Context, rel Relationships, subject *model.CustomClaims, resource Resource) (bool, error) {
+		return resource.OwnerID != "" && resource.OwnerID == subject.Subject, nil
+	},
+	// coach_of_owner holds when the subject coaches the resource's owner.
+	"coach_of_owner": func(ctx context.Context, rel Relationships, subject *model.CustomClaims, resource Resource) (bool, error) {
+		coachID, err := uuid.Parse(subject.Subject)
+		if err != nil {
+			return false, nil
+		}
+		athleteID, err := uuid.Parse(resource.OwnerID)
+		if err != nil {
+			return false, nil
+		}
+		return rel.IsCoachOf(ctx, coachID, athleteID)
+	},
+}
@@ -0,0 +1,26 @@
+package authz
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// forbiddenResponse is the body sent with a 403.
+type forbiddenResponse struct {
+	Error  string `json:"error"`
+	Reason string `json:"reason"`
+	Action string `json:"action,omitempty"`
+	Rule   string `json:"rule,omitempty"`
+}
+
+// WriteForbidden responds with 403 and a JSON body describing why the action was denied.
+func WriteForbidden(w http.ResponseWriter, action string, d Decision) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(forbiddenResponse{
+		Error:  "forbidden",
+		Reason: d.Reason,
+		Action: action,
+		Rule:   d.Rule,
+	})
+}
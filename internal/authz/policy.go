@@ -0,0 +1,136 @@
+package authz
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/faizalom/go-api/internal/model"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+)
+
+// Rule grants or denies actions on a resource type to subjects holding any
+// of Roles (or to every subject when Roles is empty), provided all of the
+// named Conditions hold.
+type Rule struct {
+	Name       string   `yaml:"name"`
+	Effect     string   `yaml:"effect"`
+	Actions    []string `yaml:"actions"`
+	Resource   string   `yaml:"resource"`
+	Roles      []string `yaml:"roles"`
+	Conditions []string `yaml:"conditions"`
+}
+
+// Policy is a set of rules. Deny rules take precedence over allow rules and
+// anything not explicitly allowed is denied.
+type Policy struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// LoadPolicy reads and validates a YAML policy file.
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePolicy(data)
+}
+
+// ParsePolicy parses and validates a YAML policy document.
+func ParsePolicy(data []byte) (*Policy, error) {
+	var p Policy
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+
+	for i, rule := range p.Rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("policy rule %d has no name", i)
+		}
+		if rule.Effect != EffectAllow && rule.Effect != EffectDeny {
+			return nil, fmt.Errorf("policy rule %q has invalid effect %q", rule.Name, rule.Effect)
+		}
+		if len(rule.Actions) == 0 || rule.Resource == "" {
+			return nil, fmt.Errorf("policy rule %q needs actions and a resource", rule.Name)
+		}
+		for _, c := range rule.Conditions {
+			if _, ok := conditions[c]; !ok {
+				return nil, fmt.Errorf("policy rule %q has unknown condition %q", rule.Name, c)
+			}
+		}
+	}
+
+	return &p, nil
+}
+
+// PolicyAuthorizer evaluates requests against a Policy.
+type PolicyAuthorizer struct {
+	policy        *Policy
+	relationships Relationships
+}
+
+func NewPolicyAuthorizer(policy *Policy, relationships Relationships) Authorizer {
+	return &PolicyAuthorizer{policy: policy, relationships: relationships}
+}
+
+// Authorize checks deny rules first, then allow rules. Callers using an API
+// key are additionally limited to the key's scopes, and impersonators may not
+// perform the actions in impersonationDenied.
+func (a *PolicyAuthorizer) Authorize(ctx context.Context, subject *model.CustomClaims, action string, resource Resource) (Decision, error) {
+	if subject == nil || subject.Subject == "" {
+		return Decision{Reason: ReasonUnauthenticated}, nil
+	}
+	if subject.Scopes != nil && !slices.Contains(subject.Scopes, action) {
+		return Decision{Reason: ReasonInsufficientScope}, nil
+	}
+	if subject.Actor != nil && slices.Contains(impersonationDenied, action) {
+		return Decision{Reason: ReasonImpersonated}, nil
+	}
+
+	for _, effect := range []string{EffectDeny, EffectAllow} {
+		for _, rule := range a.policy.Rules {
+			if rule.Effect != effect {
+				continue
+			}
+			matched, err := a.matches(ctx, rule, subject, action, resource)
+			if err != nil {
+				return Decision{}, err
+			}
+			if !matched {
+				continue
+			}
+			if effect == EffectDeny {
+				return Decision{Reason: ReasonDeniedByRule, Rule: rule.Name}, nil
+			}
+			return Decision{Allowed: true, Reason: ReasonAllowed, Rule: rule.Name}, nil
+		}
+	}
+
+	return Decision{Reason: ReasonNoMatchingRule}, nil
+}
+
+func (a *PolicyAuthorizer) matches(ctx context.Context, rule Rule, subject *model.CustomClaims, action string, resource Resource) (bool, error) {
+	if rule.Resource != "*" && rule.Resource != resource.Type {
+		return false, nil
+	}
+	if !slices.Contains(rule.Actions, action) && !slices.Contains(rule.Actions, "*") {
+		return false, nil
+	}
+	if len(rule.Roles) > 0 && !subject.HasAnyRole(rule.Roles...) {
+		return false, nil
+	}
+	for _, name := range rule.Conditions {
+		ok, err := conditions[name](ctx, a.relationships, subject, resource)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
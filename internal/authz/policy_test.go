@@ -0,0 +1,166 @@
+package authz
+
+import (
+	"context"
+	"testing"
+
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeRelationships map[[2]uuid.UUID]bool
+
+func (f fakeRelationships) IsCoachOf(ctx context.Context, coachID, athleteID uuid.UUID) (bool, error) {
+	return f[[2]uuid.UUID{coachID, athleteID}], nil
+}
+
+func subject(id uuid.UUID, roles ...string) *model.CustomClaims {
+	return &model.CustomClaims{Roles: roles, RegisteredClaims: jwt.RegisteredClaims{Subject: id.String()}}
+}
+
+// impersonated returns the claims of actor acting as id.
+func impersonated(id, actor uuid.UUID, roles ...string) *model.CustomClaims {
+	claims := subject(id, roles...)
+	claims.Actor = &model.ActorClaim{Subject: actor.String()}
+	return claims
+}
+
+func TestPolicyAuthorizer_ShippedPolicy(t *testing.T) {
+	policy, err := LoadPolicy("../../configs/policies.yaml")
+	require.NoError(t, err)
+
+	admin, coach, athlete, other := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	a := NewPolicyAuthorizer(policy, fakeRelationships{{coach, athlete}: true})
+
+	tests := []struct {
+		name     string
+		subject  *model.CustomClaims
+		action   string
+		resource Resource
+		allowed  bool
+		reason   string
+	}{
+		{"admin lists users", subject(admin, model.RoleAdmin), ActionUserList, Resource{Type: ResourceUser}, true, ReasonAllowed},
+		{"admin deletes user", subject(admin, model.RoleAdmin), ActionUserDelete, UserResource(athlete.String()), true, ReasonAllowed},
+		{"athlete lists users", subject(athlete, model.RoleAthlete), ActionUserList, Resource{Type: ResourceUser}, false, ReasonNoMatchingRule},
+		{"athlete reads self", subject(athlete, model.RoleAthlete), ActionUserRead, UserResource(athlete.String()), true, ReasonAllowed},
+		{"athlete updates self", subject(athlete, model.RoleAthlete), ActionUserUpdate, UserResource(athlete.String()), true, ReasonAllowed},
+		{"athlete changes own roles", subject(athlete, model.RoleAthlete), ActionUserUpdateRoles, UserResource(athlete.String()), false, ReasonNoMatchingRule},
+		{"athlete changes own password", subject(athlete, model.RoleAthlete), ActionUserChangePassword, UserResource(athlete.String()), true, ReasonAllowed},
+		{"admin changes other's password", subject(admin, model.RoleAdmin), ActionUserChangePassword, UserResource(athlete.String()), false, ReasonNoMatchingRule},
+		{"athlete manages own mfa", subject(athlete, model.RoleAthlete), ActionUserManageMFA, UserResource(athlete.String()), true, ReasonAllowed},
+		{"admin manages other's mfa", subject(admin, model.RoleAdmin), ActionUserManageMFA, UserResource(athlete.String()), false, ReasonNoMatchingRule},
+		{"athlete deletes self", subject(athlete, model.RoleAthlete), ActionUserDelete, UserResource(athlete.String()), false, ReasonNoMatchingRule},
+		{"athlete reads other", subject(athlete, model.RoleAthlete), ActionUserRead, UserResource(other.String()), false, ReasonNoMatchingRule},
+		{"coach reads own athlete", subject(coach, model.RoleCoach), ActionUserRead, UserResource(athlete.String()), true, ReasonAllowed},
+		{"coach updates own athlete", subject(coach, model.RoleCoach), ActionUserUpdate, UserResource(athlete.String()), false, ReasonNoMatchingRule},
+		{"coach reads other athlete", subject(coach, model.RoleCoach), ActionUserRead, UserResource(other.String()), false, ReasonNoMatchingRule},
+		{"non-coach with relationship", subject(coach, model.RoleAthlete), ActionUserRead, UserResource(athlete.String()), false, ReasonNoMatchingRule},
+		{"admin manages api keys", subject(admin, model.RoleAdmin), ActionAPIKeyCreate, Resource{Type: ResourceAPIKey}, true, ReasonAllowed},
+		{"admin unlocks user", subject(admin, model.RoleAdmin), ActionUserUnlock, UserResource(athlete.String()), true, ReasonAllowed},
+		{"user unlocks self", subject(athlete, model.RoleAthlete), ActionUserUnlock, UserResource(athlete.String()), false, ReasonNoMatchingRule},
+		{"admin restores user", subject(admin, model.RoleAdmin), ActionUserRestore, UserResource(athlete.String()), true, ReasonAllowed},
+		{"user restores self", subject(athlete, model.RoleAthlete), ActionUserRestore, UserResource(athlete.String()), false, ReasonNoMatchingRule},
+		{"admin impersonates user", subject(admin, model.RoleAdmin), ActionUserImpersonate, UserResource(athlete.String()), true, ReasonAllowed},
+		{"coach impersonates athlete", subject(coach, model.RoleCoach), ActionUserImpersonate, UserResource(athlete.String()), false, ReasonNoMatchingRule},
+		{"impersonator changes password", impersonated(athlete, admin, model.RoleAthlete), ActionUserChangePassword, UserResource(athlete.String()), false, ReasonImpersonated},
+		{"impersonator manages mfa", impersonated(athlete, admin, model.RoleAthlete), ActionUserManageMFA, UserResource(athlete.String()), false, ReasonImpersonated},
+		{"impersonator deletes admin's target", impersonated(other, admin, model.RoleAdmin), ActionUserDelete, UserResource(athlete.String()), false, ReasonImpersonated},
+		{"impersonator impersonates again", impersonated(other, admin, model.RoleAdmin), ActionUserImpersonate, UserResource(athlete.String()), false, ReasonImpersonated},
+		{"impersonator updates user", impersonated(athlete, admin, model.RoleAthlete), ActionUserUpdate, UserResource(athlete.String()), true, ReasonAllowed},
+		{"user updates own email", subject(athlete, model.RoleAthlete), ActionUserUpdateEmail, UserResource(athlete.String()), true, ReasonAllowed},
+		{"impersonator updates email", impersonated(athlete, admin, model.RoleAthlete), ActionUserUpdateEmail, UserResource(athlete.String()), false, ReasonImpersonated},
+		{"impersonator updates roles", impersonated(other, admin, model.RoleAdmin), ActionUserUpdateRoles, UserResource(athlete.String()), false, ReasonImpersonated},
+		{"impersonator lists api keys", impersonated(other, admin, model.RoleAdmin), ActionAPIKeyList, Resource{Type: ResourceAPIKey}, false, ReasonImpersonated},
+		{"impersonator creates api key", impersonated(other, admin, model.RoleAdmin), ActionAPIKeyCreate, Resource{Type: ResourceAPIKey}, false, ReasonImpersonated},
+		{"impersonator revokes api key", impersonated(other, admin, model.RoleAdmin), ActionAPIKeyRevoke, Resource{Type: ResourceAPIKey}, false, ReasonImpersonated},
+		{"admin reads metrics", subject(admin, model.RoleAdmin), ActionMetricsRead, Resource{Type: ResourceMetrics}, true, ReasonAllowed},
+		{"coach reads metrics", subject(coach, model.RoleCoach), ActionMetricsRead, Resource{Type: ResourceMetrics}, false, ReasonNoMatchingRule},
+		{"coach manages api keys", subject(coach, model.RoleCoach), ActionAPIKeyCreate, Resource{Type: ResourceAPIKey}, false, ReasonNoMatchingRule},
+		{"anonymous", nil, ActionUserRead, UserResource(athlete.String()), false, ReasonUnauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := a.Authorize(context.Background(), tt.subject, tt.action, tt.resource)
+			require.NoError(t, err)
+			assert.Equal(t, tt.allowed, d.Allowed)
+			assert.Equal(t, tt.reason, d.Reason)
+		})
+	}
+}
+
+func TestPolicyAuthorizer_DenyWins(t *testing.T) {
+	policy, err := ParsePolicy([]byte(`
+rules:
+  - name: everyone-reads
+    effect: allow
+    resource: user
+    actions: ["*"]
+  - name: no-self-delete
+    effect: deny
+    resource: user
+    actions: [user:delete]
+    conditions: [owner]
+`))
+	require.NoError(t, err)
+	a := NewPolicyAuthorizer(policy, nil)
+	id := uuid.New()
+
+	d, err := a.Authorize(context.Background(), subject(id), ActionUserDelete, UserResource(id.String()))
+	require.NoError(t, err)
+	assert.False(t, d.Allowed)
+	assert.Equal(t, ReasonDeniedByRule, d.Reason)
+	assert.Equal(t, "no-self-delete", d.Rule)
+
+	d, err = a.Authorize(context.Background(), subject(id), ActionUserDelete, UserResource(uuid.NewString()))
+	require.NoError(t, err)
+	assert.True(t, d.Allowed)
+	assert.Equal(t, "everyone-reads", d.Rule)
+}
+
+func TestPolicyAuthorizer_Scopes(t *testing.T) {
+	policy, err := LoadPolicy("../../configs/policies.yaml")
+	require.NoError(t, err)
+	a := NewPolicyAuthorizer(policy, nil)
+
+	admin := subject(uuid.New(), model.RoleAdmin)
+	admin.Scopes = []string{ActionUserList}
+
+	d, err := a.Authorize(context.Background(), admin, ActionUserList, Resource{Type: ResourceUser})
+	require.NoError(t, err)
+	assert.True(t, d.Allowed)
+
+	// The key's owner may delete users, but the key was not granted that.
+	d, err = a.Authorize(context.Background(), admin, ActionUserDelete, UserResource(uuid.NewString()))
+	require.NoError(t, err)
+	assert.False(t, d.Allowed)
+	assert.Equal(t, ReasonInsufficientScope, d.Reason)
+
+	// Scopes never grant more than the owner's roles allow.
+	athlete := subject(uuid.New(), model.RoleAthlete)
+	athlete.Scopes = []string{ActionUserList}
+	d, err = a.Authorize(context.Background(), athlete, ActionUserList, Resource{Type: ResourceUser})
+	require.NoError(t, err)
+	assert.False(t, d.Allowed)
+	assert.Equal(t, ReasonNoMatchingRule, d.Reason)
+}
+
+func TestParsePolicy_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"missing name":      "rules:\n  - effect: allow\n    resource: user\n    actions: [user:read]\n",
+		"bad effect":        "rules:\n  - name: r\n    effect: maybe\n    resource: user\n    actions: [user:read]\n",
+		"no actions":        "rules:\n  - name: r\n    effect: allow\n    resource: user\n",
+		"unknown condition": "rules:\n  - name: r\n    effect: allow\n    resource: user\n    actions: [user:read]\n    conditions: [friend_of_owner]\n",
+	}
+
+	for name, doc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParsePolicy([]byte(doc))
+			assert.Error(t, err)
+		})
+	}
+}
@@ -1,7 +1,11 @@
 package config
 
 import (
+	"database/sql"
+	"fmt"
 	"os"
+	"path/filepath"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -13,13 +17,203 @@ var App Config
 type Config struct {
 	Server struct {
 		Port string `yaml:"port"`
+		// ClientIPHeader names a header set by a trusted reverse proxy that
+		// holds the client IP, e.g. "X-Real-IP". When empty the connection's
+		// address is used.
+		ClientIPHeader string `yaml:"client_ip_header"`
 	} `yaml:"server"`
-	JWT struct {
-		Secret string `yaml:"secret"`
-	} `yaml:"jwt"`
-	Database struct {
-		DSN string `yaml:"dsn"`
-	} `yaml:"database"`
+	JWT      JWTConfig      `yaml:"jwt"`
+	Database DatabaseConfig `yaml:"database"`
+	Authz    struct {
+		// PolicyFile is the YAML authorization policy, relative to this file.
+		PolicyFile string `yaml:"policy_file"`
+	} `yaml:"authz"`
+	OIDC struct {
+		Providers []OIDCProvider `yaml:"providers"`
+	} `yaml:"oidc"`
+	Mail              MailConfig              `yaml:"mail"`
+	PasswordReset     PasswordResetConfig     `yaml:"password_reset"`
+	PasswordPolicy    PasswordPolicyConfig    `yaml:"password_policy"`
+	EmailVerification EmailVerificationConfig `yaml:"email_verification"`
+	MFA               MFAConfig               `yaml:"mfa"`
+	LoginProtection   LoginProtectionConfig   `yaml:"login_protection"`
+	Impersonation     ImpersonationConfig     `yaml:"impersonation"`
+	DeletedUsers      DeletedUsersConfig      `yaml:"deleted_users"`
+	UserImport        UserImportConfig        `yaml:"user_import"`
+}
+
+// DatabaseConfig holds the database connection and transaction settings.
+type DatabaseConfig struct {
+	DSN string `yaml:"dsn"`
+	// IsolationLevel is the default isolation level of transactions:
+	// "read_committed", "repeatable_read" or "serializable". Operations that
+	// need a stricter one ask for it.
+	IsolationLevel string `yaml:"isolation_level"`
+	// MaxTxRetries is how many times a transaction is run again after it
+	// failed to serialize with a concurrent one or deadlocked. A negative
+	// value never retries.
+	MaxTxRetries int `yaml:"max_tx_retries"`
+}
+
+var isolationLevels = map[string]sql.IsolationLevel{
+	"read_committed":  sql.LevelReadCommitted,
+	"repeatable_read": sql.LevelRepeatableRead,
+	"serializable":    sql.LevelSerializable,
+}
+
+// TxIsolation is the isolation level IsolationLevel names.
+func (c DatabaseConfig) TxIsolation() sql.IsolationLevel {
+	return isolationLevels[c.IsolationLevel]
+}
+
+// MailConfig selects how outgoing email is delivered.
+type MailConfig struct {
+	From string `yaml:"from"`
+	// Driver is "log" to write messages to the log or "file" to write them
+	// to Dir, one file per message.
+	Driver string `yaml:"driver"`
+	// Dir is where the file driver writes messages, relative to this file.
+	Dir string `yaml:"dir"`
+}
+
+// PasswordResetConfig holds the settings of the forgotten password flow.
+type PasswordResetConfig struct {
+	// TokenTTL is how long a reset link stays valid.
+	TokenTTL time.Duration `yaml:"token_ttl"`
+	// URL is the page of the client app the reset link points to; the token
+	// is appended as the "token" query parameter.
+	URL string `yaml:"url"`
+}
+
+// EmailVerificationConfig holds the settings of email address verification.
+type EmailVerificationConfig struct {
+	// TokenTTL is how long a verification link stays valid.
+	TokenTTL time.Duration `yaml:"token_ttl"`
+	// URL is where the verification link points, normally this API's
+	// /auth/verify endpoint; the token is appended as the "token" query
+	// parameter.
+	URL string `yaml:"url"`
+	// Required blocks password sign-in until the user's email is verified.
+	Required bool `yaml:"required"`
+}
+
+// MFAConfig holds the settings of TOTP two-factor authentication.
+type MFAConfig struct {
+	// Issuer labels the account in authenticator apps.
+	Issuer string `yaml:"issuer"`
+	// ChallengeTTL is how long the user has to enter a code after
+	// signing in with their password.
+	ChallengeTTL time.Duration `yaml:"challenge_ttl"`
+	// MaxAttempts is how many codes may be tried against one challenge
+	// before the user has to sign in again.
+	MaxAttempts int `yaml:"max_attempts"`
+}
+
+// LoginProtectionConfig holds the settings that slow down password guessing.
+type LoginProtectionConfig struct {
+	// EmailFreeAttempts and IPFreeAttempts are how many failed logins for
+	// one email, or from one client IP, are allowed before backoff starts.
+	EmailFreeAttempts int `yaml:"email_free_attempts"`
+	IPFreeAttempts    int `yaml:"ip_free_attempts"`
+	// BaseBackoff is the first delay; it doubles with every further failure
+	// up to MaxBackoff.
+	BaseBackoff time.Duration `yaml:"base_backoff"`
+	MaxBackoff  time.Duration `yaml:"max_backoff"`
+	// ResetAfter is how long failures are remembered without a new one.
+	ResetAfter time.Duration `yaml:"reset_after"`
+	// LockoutThreshold is the number of consecutive failed logins after
+	// which the account is locked for LockoutDuration. Each further failure
+	// doubles the lockout, up to MaxLockoutDuration. A negative threshold
+	// disables lockouts.
+	LockoutThreshold   int           `yaml:"lockout_threshold"`
+	LockoutDuration    time.Duration `yaml:"lockout_duration"`
+	MaxLockoutDuration time.Duration `yaml:"max_lockout_duration"`
+}
+
+// ImpersonationConfig holds the settings for admins acting as other users.
+type ImpersonationConfig struct {
+	// TokenTTL is how long an impersonation token stays valid. It cannot be
+	// refreshed.
+	TokenTTL time.Duration `yaml:"token_ttl"`
+}
+
+// DeletedUsersConfig holds the settings for keeping soft-deleted users.
+type DeletedUsersConfig struct {
+	// RetentionDays is how long deleted users can be restored before they
+	// are purged for good. A negative value keeps them forever.
+	RetentionDays int `yaml:"retention_days"`
+	// PurgeInterval is how often to look for users to purge.
+	PurgeInterval time.Duration `yaml:"purge_interval"`
+}
+
+// UserImportConfig holds the settings for importing users in bulk.
+type UserImportConfig struct {
+	// MaxRows is the most rows one import may hold; rows past it are not
+	// read.
+	MaxRows int `yaml:"max_rows"`
+}
+
+// JWTConfig holds the settings used to issue and verify tokens.
+type JWTConfig struct {
+	// Secret is used for HS256 when no asymmetric Keys are configured.
+	Secret string `yaml:"secret"`
+	// SigningKeyID selects which of Keys new tokens are signed with.
+	SigningKeyID string `yaml:"signing_key_id"`
+	// Keys lists every key tokens are accepted from. Keep a retired key here
+	// until the tokens it signed have expired, then remove it.
+	Keys []JWTKey `yaml:"keys"`
+	// Issuer is stamped into the iss claim and required on incoming tokens.
+	Issuer string `yaml:"issuer"`
+	// Audience is stamped into the aud claim; incoming tokens must carry at
+	// least one of these values.
+	Audience []string `yaml:"audience"`
+	// Leeway is the clock skew tolerated when checking exp, nbf and iat.
+	Leeway time.Duration `yaml:"leeway"`
+	// AccessTokenTTL is how long an issued access token stays valid.
+	AccessTokenTTL time.Duration `yaml:"access_token_ttl"`
+	// RefreshTokenTTL is how long a refresh token can be exchanged for a new pair.
+	RefreshTokenTTL time.Duration `yaml:"refresh_token_ttl"`
+	// RevocationCacheTTL is how long a "not revoked" lookup is cached in memory.
+	RevocationCacheTTL time.Duration `yaml:"revocation_cache_ttl"`
+}
+
+// JWTKey describes an asymmetric key loaded from PEM files.
+type JWTKey struct {
+	ID string `yaml:"id"`
+	// Algorithm is one of RS256, ES256 or EdDSA.
+	Algorithm string `yaml:"algorithm"`
+	// PrivateKeyFile is only required for the key named by SigningKeyID.
+	PrivateKeyFile string `yaml:"private_key_file"`
+	// PublicKeyFile may be omitted when PrivateKeyFile is set.
+	PublicKeyFile string `yaml:"public_key_file"`
+}
+
+// OIDCProvider configures sign-in through an OpenID Connect provider. The
+// provider's endpoints are discovered from Issuer.
+type OIDCProvider struct {
+	// Name identifies the provider in URLs, e.g. /auth/oidc/{name}/login.
+	Name         string `yaml:"name"`
+	Issuer       string `yaml:"issuer"`
+	ClientID     string `yaml:"client_id"`
+	ClientSecret string `yaml:"client_secret"`
+	// RedirectURL is our callback URL as registered with the provider.
+	RedirectURL string `yaml:"redirect_url"`
+	// Scopes requested in addition to "openid". Defaults to email and profile.
+	Scopes []string `yaml:"scopes"`
+}
+
+// PasswordPolicyConfig defines the rules new passwords must satisfy.
+// Passwords are always limited to 72 bytes, the most bcrypt can hash.
+type PasswordPolicyConfig struct {
+	// MinLength is the minimum number of characters.
+	MinLength     int  `yaml:"min_length"`
+	RequireUpper  bool `yaml:"require_upper"`
+	RequireLower  bool `yaml:"require_lower"`
+	RequireDigit  bool `yaml:"require_digit"`
+	RequireSymbol bool `yaml:"require_symbol"`
+	// CommonPasswordsFile lists passwords that are rejected outright, one per
+	// line, relative to this file.
+	CommonPasswordsFile string `yaml:"common_passwords_file"`
 }
 
 // Load reads the configuration file from the given path and unmarshals it.
@@ -33,5 +227,121 @@ func Load(path string) error {
 		return err
 	}
 
+	App.setDefaults()
+	App.resolvePaths(filepath.Dir(path))
+
+	if _, ok := isolationLevels[App.Database.IsolationLevel]; !ok {
+		return fmt.Errorf("unknown database.isolation_level %q", App.Database.IsolationLevel)
+	}
+
 	return nil
 }
+
+// setDefaults fills in values that were left empty in the configuration file.
+func (c *Config) setDefaults() {
+	if c.Database.IsolationLevel == "" {
+		c.Database.IsolationLevel = "read_committed"
+	}
+	if c.Database.MaxTxRetries == 0 {
+		c.Database.MaxTxRetries = 3
+	}
+	if c.JWT.AccessTokenTTL == 0 {
+		c.JWT.AccessTokenTTL = 15 * time.Minute
+	}
+	if c.JWT.RefreshTokenTTL == 0 {
+		c.JWT.RefreshTokenTTL = 30 * 24 * time.Hour
+	}
+	if c.JWT.RevocationCacheTTL == 0 {
+		c.JWT.RevocationCacheTTL = 30 * time.Second
+	}
+	if c.Authz.PolicyFile == "" {
+		c.Authz.PolicyFile = "policies.yaml"
+	}
+	if c.Mail.Driver == "" {
+		c.Mail.Driver = "log"
+	}
+	if c.Mail.Dir == "" {
+		c.Mail.Dir = "mail"
+	}
+	if c.PasswordPolicy.MinLength == 0 {
+		c.PasswordPolicy.MinLength = 10
+	}
+	if c.PasswordPolicy.CommonPasswordsFile == "" {
+		c.PasswordPolicy.CommonPasswordsFile = "common-passwords.txt"
+	}
+	if c.PasswordReset.TokenTTL == 0 {
+		c.PasswordReset.TokenTTL = time.Hour
+	}
+	if c.EmailVerification.TokenTTL == 0 {
+		c.EmailVerification.TokenTTL = 24 * time.Hour
+	}
+	if c.MFA.Issuer == "" {
+		c.MFA.Issuer = "Workout API"
+	}
+	if c.MFA.ChallengeTTL == 0 {
+		c.MFA.ChallengeTTL = 5 * time.Minute
+	}
+	if c.MFA.MaxAttempts == 0 {
+		c.MFA.MaxAttempts = 5
+	}
+	if c.LoginProtection.EmailFreeAttempts == 0 {
+		c.LoginProtection.EmailFreeAttempts = 5
+	}
+	if c.LoginProtection.IPFreeAttempts == 0 {
+		c.LoginProtection.IPFreeAttempts = 20
+	}
+	if c.LoginProtection.BaseBackoff == 0 {
+		c.LoginProtection.BaseBackoff = time.Second
+	}
+	if c.LoginProtection.MaxBackoff == 0 {
+		c.LoginProtection.MaxBackoff = 15 * time.Minute
+	}
+	if c.LoginProtection.ResetAfter == 0 {
+		c.LoginProtection.ResetAfter = time.Hour
+	}
+	if c.LoginProtection.LockoutThreshold == 0 {
+		c.LoginProtection.LockoutThreshold = 10
+	}
+	if c.LoginProtection.LockoutDuration == 0 {
+		c.LoginProtection.LockoutDuration = 15 * time.Minute
+	}
+	if c.LoginProtection.MaxLockoutDuration == 0 {
+		c.LoginProtection.MaxLockoutDuration = 24 * time.Hour
+	}
+	if c.Impersonation.TokenTTL == 0 {
+		c.Impersonation.TokenTTL = 15 * time.Minute
+	}
+	if c.DeletedUsers.RetentionDays == 0 {
+		c.DeletedUsers.RetentionDays = 30
+	}
+	if c.DeletedUsers.PurgeInterval == 0 {
+		c.DeletedUsers.PurgeInterval = time.Hour
+	}
+	if c.UserImport.MaxRows == 0 {
+		c.UserImport.MaxRows = 1000
+	}
+	for i := range c.OIDC.Providers {
+		if len(c.OIDC.Providers[i].Scopes) == 0 {
+			c.OIDC.Providers[i].Scopes = []string{"email", "profile"}
+		}
+	}
+}
+
+// resolvePaths makes file paths in the configuration relative to the
+// directory the configuration file lives in.
+func (c *Config) resolvePaths(dir string) {
+	c.Authz.PolicyFile = resolvePath(dir, c.Authz.PolicyFile)
+	c.Mail.Dir = resolvePath(dir, c.Mail.Dir)
+	c.PasswordPolicy.CommonPasswordsFile = resolvePath(dir, c.PasswordPolicy.CommonPasswordsFile)
+	for i := range c.JWT.Keys {
+		c.JWT.Keys[i].PrivateKeyFile = resolvePath(dir, c.JWT.Keys[i].PrivateKeyFile)
+		c.JWT.Keys[i].PublicKeyFile = resolvePath(dir, c.JWT.Keys[i].PublicKeyFile)
+	}
+}
+
+func resolvePath(dir, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
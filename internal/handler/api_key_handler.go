@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/faizalom/go-api/internal/service"
+	"github.com/faizalom/go-api/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+type APIKeyHandler struct {
+	service service.IAPIKeyService
+}
+
+func NewAPIKeyHandler(s service.IAPIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{service: s}
+}
+
+// Create issues a new API key. The key itself is only returned in this response.
+func (h *APIKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req model.NewAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Name == "" || req.UserID == uuid.Nil {
+		http.Error(w, "Name and user_id are required", http.StatusBadRequest)
+		return
+	}
+
+	created, err := h.service.Create(r.Context(), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, ierr.ErrInvalidScope), errors.Is(err, ierr.ErrUserNotFound):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			logger.Error.Printf("Could not create api key: %v", err)
+			writeServerError(w, err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// List returns every API key without the key values.
+func (h *APIKeyHandler) List(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.service.List(r.Context())
+	if err != nil {
+		logger.Error.Printf("Could not list api keys: %v", err)
+		writeServerError(w, err)
+		return
+	}
+	if keys == nil {
+		keys = []*model.APIKey{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(keys)
+}
+
+// Revoke disables an API key.
+func (h *APIKeyHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid api key ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.Revoke(r.Context(), id); err != nil {
+		if errors.Is(err, ierr.ErrAPIKeyNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		logger.Error.Printf("Could not revoke api key %s: %v", id, err)
+		writeServerError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
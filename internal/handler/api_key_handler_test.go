@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/faizalom/go-api/internal/service/mocks"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAPIKeyHandler_Create(t *testing.T) {
+	mockAPIKeyService := new(mocks.MockAPIKeyService)
+	apiKeyHandler := NewAPIKeyHandler(mockAPIKeyService)
+
+	reqBody := &model.NewAPIKeyRequest{UserID: uuid.New(), Name: "ci", Scopes: []string{"user:read"}}
+	jsonBody, _ := json.Marshal(reqBody)
+	req, err := http.NewRequest("POST", "/api-keys", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created := &model.CreatedAPIKey{APIKey: model.APIKey{ID: uuid.New(), UserID: reqBody.UserID, Name: "ci", KeyHash: "secret-hash"}, Key: "ak_abcd_secret"}
+	mockAPIKeyService.On("Create", mock.Anything, reqBody).Return(created, nil)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(apiKeyHandler.Create)
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"key":"ak_abcd_secret"`)
+	assert.NotContains(t, rr.Body.String(), "secret-hash")
+	mockAPIKeyService.AssertExpectations(t)
+}
+
+func TestAPIKeyHandler_Create_InvalidScope(t *testing.T) {
+	mockAPIKeyService := new(mocks.MockAPIKeyService)
+	apiKeyHandler := NewAPIKeyHandler(mockAPIKeyService)
+
+	jsonBody, _ := json.Marshal(&model.NewAPIKeyRequest{UserID: uuid.New(), Name: "ci", Scopes: []string{"user:fly"}})
+	req, err := http.NewRequest("POST", "/api-keys", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockAPIKeyService.On("Create", mock.Anything, mock.AnythingOfType("*model.NewAPIKeyRequest")).Return(nil, ierr.ErrInvalidScope)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(apiKeyHandler.Create)
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestAPIKeyHandler_List(t *testing.T) {
+	mockAPIKeyService := new(mocks.MockAPIKeyService)
+	apiKeyHandler := NewAPIKeyHandler(mockAPIKeyService)
+
+	req, err := http.NewRequest("GET", "/api-keys", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockAPIKeyService.On("List", mock.Anything).Return([]*model.APIKey{{ID: uuid.New(), Name: "ci", KeyHash: "secret-hash"}}, nil)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(apiKeyHandler.List)
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NotContains(t, rr.Body.String(), "secret-hash")
+	mockAPIKeyService.AssertExpectations(t)
+}
+
+func TestAPIKeyHandler_Revoke(t *testing.T) {
+	mockAPIKeyService := new(mocks.MockAPIKeyService)
+	apiKeyHandler := NewAPIKeyHandler(mockAPIKeyService)
+
+	known, unknown := uuid.New(), uuid.New()
+	mockAPIKeyService.On("Revoke", mock.Anything, known).Return(nil)
+	mockAPIKeyService.On("Revoke", mock.Anything, unknown).Return(ierr.ErrAPIKeyNotFound)
+
+	tests := []struct {
+		id   string
+		want int
+	}{
+		{id: known.String(), want: http.StatusNoContent},
+		{id: unknown.String(), want: http.StatusNotFound},
+		{id: "not-a-uuid", want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		req, err := http.NewRequest("DELETE", "/api-keys/"+tt.id, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetPathValue("id", tt.id)
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(apiKeyHandler.Revoke)
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, tt.want, rr.Code, tt.id)
+	}
+}
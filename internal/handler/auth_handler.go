@@ -1,46 +1,145 @@
 package handler
 
 import (
+	"encoding/json"
+	"errors"
+	"math"
 	"net/http"
-	"time"
+	"strconv"
 
-	"github.com/faizalom/go-api/internal/config"
-	"github.com/faizalom/go-api/internal/model" // Our new model package
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/middleware"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/faizalom/go-api/internal/service"
 	"github.com/faizalom/go-api/pkg/logger"
 
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
-// LoginHandler simulates a user login and generates a JWT.
-func LoginHandler(w http.ResponseWriter, r *http.Request) {
-	// In a real application, you would validate username/password here.
-	// For this example, we'll assume authentication is successful.
-
-	// Create the custom claims
-	claims := model.CustomClaims{
-		Name:  "John Doe",
-		Email: "john.doe@example.com",
-		RegisteredClaims: jwt.RegisteredClaims{
-			// Use the user's ID as the subject
-			Subject: "user-12345",
-			// Set token expiration
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 24)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
-	}
-
-	// Create a new token object, specifying signing method and the claims
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	// Sign and get the complete encoded token as a string using the secret from config
-	tokenString, err := token.SignedString([]byte(config.App.JWT.Secret))
+type AuthHandler struct {
+	service service.IAuthService
+}
+
+func NewAuthHandler(s service.IAuthService) *AuthHandler {
+	return &AuthHandler{service: s}
+}
+
+// Login authenticates a user by email and password and returns a token pair,
+// or an MFA token to complete at /auth/mfa/verify if the user has two-factor
+// authentication enabled. Throttled and locked out logins get a 429 with a
+// Retry-After header.
+func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var req model.LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Email == "" || req.Password == "" {
+		http.Error(w, "Email and password are required", http.StatusBadRequest)
+		return
+	}
+	req.Client = clientInfo(r)
+
+	resp, err := h.service.Login(r.Context(), &req)
 	if err != nil {
-		logger.Error.Printf("Could not sign token: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		switch {
+		case errors.Is(err, ierr.ErrInvalidCredentials):
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		case errors.Is(err, ierr.ErrTooManyLoginAttempts):
+			writeTooManyLoginAttempts(w, err)
+		case errors.Is(err, ierr.ErrUserInactive), errors.Is(err, ierr.ErrEmailNotVerified):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			logger.Error.Printf("Could not log in user: %v", err)
+			writeServerError(w, err)
+		}
 		return
 	}
 
-	// Send the token back to the client
 	w.Header().Set("Content-Type", "application/json")
-	w.Write([]byte(`{"token":"` + tokenString + `"}`))
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// writeTooManyLoginAttempts writes the 429 response for a throttled login,
+// with a Retry-After header if err says when to try again.
+func writeTooManyLoginAttempts(w http.ResponseWriter, err error) {
+	var retry *ierr.RetryAfterError
+	if errors.As(err, &retry) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retry.RetryAfter.Seconds()))))
+	}
+	http.Error(w, ierr.ErrTooManyLoginAttempts.Error(), http.StatusTooManyRequests)
+}
+
+// Refresh rotates a refresh token and returns a new token pair.
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req model.RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.RefreshToken == "" {
+		http.Error(w, "Refresh token is required", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := h.service.Refresh(r.Context(), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, ierr.ErrInvalidRefreshToken), errors.Is(err, ierr.ErrRefreshTokenReused):
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		case errors.Is(err, ierr.ErrUserInactive):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			logger.Error.Printf("Could not refresh token: %v", err)
+			writeServerError(w, err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Unlock lifts a login lockout on the user in the path and forgets their
+// failed logins.
+func (h *AuthHandler) Unlock(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.Unlock(r.Context(), id); err != nil {
+		switch {
+		case errors.Is(err, ierr.ErrUserNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			logger.Error.Printf("Could not unlock user %s: %v", id, err)
+			writeServerError(w, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Logout revokes the caller's access token and ends their session.
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserClaimsKey).(*model.CustomClaims)
+	if !ok {
+		http.Error(w, "Internal Server Error: could not retrieve user claims", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.service.Logout(r.Context(), claims); err != nil {
+		logger.Error.Printf("Could not log out user %s: %v", claims.Subject, err)
+		writeServerError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
 }
@@ -0,0 +1,201 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/middleware"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/faizalom/go-api/internal/service/mocks"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAuthHandler_Login(t *testing.T) {
+	mockAuthService := new(mocks.MockAuthService)
+	authHandler := NewAuthHandler(mockAuthService)
+
+	reqBody := &model.LoginRequest{
+		Email:    "test@example.com",
+		Password: "password",
+	}
+	jsonBody, _ := json.Marshal(reqBody)
+	req, err := http.NewRequest("POST", "/login", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockAuthService.On("Login", mock.Anything, reqBody).Return(&model.TokenResponse{Token: "token", RefreshToken: "refresh-token", TokenType: "Bearer", ExpiresIn: 900}, nil)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(authHandler.Login)
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"token":"token","refresh_token":"refresh-token","token_type":"Bearer","expires_in":900}`, rr.Body.String())
+	mockAuthService.AssertExpectations(t)
+}
+
+func TestAuthHandler_Login_InvalidCredentials(t *testing.T) {
+	mockAuthService := new(mocks.MockAuthService)
+	authHandler := NewAuthHandler(mockAuthService)
+
+	jsonBody, _ := json.Marshal(&model.LoginRequest{Email: "test@example.com", Password: "wrong"})
+	req, err := http.NewRequest("POST", "/login", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockAuthService.On("Login", mock.Anything, mock.AnythingOfType("*model.LoginRequest")).Return(nil, ierr.ErrInvalidCredentials)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(authHandler.Login)
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	mockAuthService.AssertExpectations(t)
+}
+
+func TestAuthHandler_Login_MissingFields(t *testing.T) {
+	mockAuthService := new(mocks.MockAuthService)
+	authHandler := NewAuthHandler(mockAuthService)
+
+	req, err := http.NewRequest("POST", "/login", bytes.NewBufferString(`{"email":"test@example.com"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(authHandler.Login)
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	mockAuthService.AssertNotCalled(t, "Login", mock.Anything, mock.Anything)
+}
+
+func TestAuthHandler_Login_TooManyAttempts(t *testing.T) {
+	mockAuthService := new(mocks.MockAuthService)
+	authHandler := NewAuthHandler(mockAuthService)
+
+	jsonBody, _ := json.Marshal(&model.LoginRequest{Email: "test@example.com", Password: "wrong"})
+	req, err := http.NewRequest("POST", "/login", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.RemoteAddr = "192.0.2.1:51234"
+
+	mockAuthService.On("Login", mock.Anything, &model.LoginRequest{Email: "test@example.com", Password: "wrong", Client: model.ClientInfo{IP: "192.0.2.1"}}).
+		Return(nil, &ierr.RetryAfterError{Err: ierr.ErrTooManyLoginAttempts, RetryAfter: 1500 * time.Millisecond})
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(authHandler.Login)
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "2", rr.Header().Get("Retry-After"))
+	mockAuthService.AssertExpectations(t)
+}
+
+func TestAuthHandler_Refresh(t *testing.T) {
+	mockAuthService := new(mocks.MockAuthService)
+	authHandler := NewAuthHandler(mockAuthService)
+
+	reqBody := &model.RefreshRequest{RefreshToken: "refresh-token"}
+	jsonBody, _ := json.Marshal(reqBody)
+	req, err := http.NewRequest("POST", "/token/refresh", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockAuthService.On("Refresh", mock.Anything, reqBody).Return(&model.TokenResponse{Token: "token", RefreshToken: "new-refresh-token"}, nil)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(authHandler.Refresh)
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	mockAuthService.AssertExpectations(t)
+}
+
+func TestAuthHandler_Refresh_Reused(t *testing.T) {
+	mockAuthService := new(mocks.MockAuthService)
+	authHandler := NewAuthHandler(mockAuthService)
+
+	req, err := http.NewRequest("POST", "/token/refresh", bytes.NewBufferString(`{"refresh_token":"old"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockAuthService.On("Refresh", mock.Anything, mock.AnythingOfType("*model.RefreshRequest")).Return(nil, ierr.ErrRefreshTokenReused)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(authHandler.Refresh)
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	mockAuthService.AssertExpectations(t)
+}
+
+func TestAuthHandler_Unlock(t *testing.T) {
+	mockAuthService := new(mocks.MockAuthService)
+	authHandler := NewAuthHandler(mockAuthService)
+
+	userID := uuid.New()
+	missingID := uuid.New()
+	mockAuthService.On("Unlock", mock.Anything, userID).Return(nil)
+	mockAuthService.On("Unlock", mock.Anything, missingID).Return(ierr.ErrUserNotFound)
+
+	tests := []struct {
+		name string
+		id   string
+		want int
+	}{
+		{name: "unlocked", id: userID.String(), want: http.StatusNoContent},
+		{name: "not found", id: missingID.String(), want: http.StatusNotFound},
+		{name: "invalid id", id: "not-a-uuid", want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("POST", "/users/"+tt.id+"/unlock", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.SetPathValue("id", tt.id)
+
+			rr := httptest.NewRecorder()
+			handler := http.HandlerFunc(authHandler.Unlock)
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.want, rr.Code)
+		})
+	}
+}
+
+func TestAuthHandler_Logout(t *testing.T) {
+	mockAuthService := new(mocks.MockAuthService)
+	authHandler := NewAuthHandler(mockAuthService)
+
+	claims := &model.CustomClaims{}
+	req, err := http.NewRequest("POST", "/logout", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserClaimsKey, claims))
+
+	mockAuthService.On("Logout", mock.Anything, claims).Return(nil)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(authHandler.Logout)
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	mockAuthService.AssertExpectations(t)
+}
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/faizalom/go-api/internal/authz"
+	"github.com/faizalom/go-api/internal/middleware"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/faizalom/go-api/pkg/logger"
+)
+
+// authorize checks whether the caller may perform action on resource. When
+// they may not, it writes the error response and returns false.
+func authorize(w http.ResponseWriter, r *http.Request, a authz.Authorizer, action string, resource authz.Resource) bool {
+	decision, ok := decide(w, r, a, action, resource)
+	if !ok {
+		return false
+	}
+	if !decision.Allowed {
+		authz.WriteForbidden(w, action, decision)
+		return false
+	}
+	return true
+}
+
+// decide asks whether the caller may perform action on resource, for when
+// the answer only matters later on. It writes the error response and returns
+// false if the authorizer fails.
+func decide(w http.ResponseWriter, r *http.Request, a authz.Authorizer, action string, resource authz.Resource) (authz.Decision, bool) {
+	claims, _ := r.Context().Value(middleware.UserClaimsKey).(*model.CustomClaims)
+
+	decision, err := a.Authorize(r.Context(), claims, action, resource)
+	if err != nil {
+		logger.Error.Printf("Could not authorize %s: %v", action, err)
+		writeServerError(w, err)
+		return authz.Decision{}, false
+	}
+	return decision, true
+}
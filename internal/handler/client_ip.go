@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/faizalom/go-api/internal/config"
+	"github.com/faizalom/go-api/internal/model"
+)
+
+// maxUserAgentLength bounds the user agent stored with a session.
+const maxUserAgentLength = 512
+
+// clientInfo describes the client a request came from.
+func clientInfo(r *http.Request) model.ClientInfo {
+	userAgent := r.UserAgent()
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = strings.ToValidUTF8(userAgent[:maxUserAgentLength], "")
+	}
+	return model.ClientInfo{IP: clientIP(r), UserAgent: userAgent}
+}
+
+// clientIP returns the address a request came from. Behind a reverse proxy,
+// config.App.Server.ClientIPHeader names the header the proxy sets; for a
+// list such as X-Forwarded-For the last entry, added by the proxy itself, is
+// used, since earlier ones can be set by the client.
+func clientIP(r *http.Request) string {
+	if header := config.App.Server.ClientIPHeader; header != "" {
+		if value := r.Header.Get(header); value != "" {
+			entries := strings.Split(value, ",")
+			return strings.TrimSpace(entries[len(entries)-1])
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
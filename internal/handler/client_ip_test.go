@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/faizalom/go-api/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientIP(t *testing.T) {
+	defer func() { config.App.Server.ClientIPHeader = "" }()
+
+	tests := []struct {
+		name       string
+		header     string
+		value      string
+		remoteAddr string
+		want       string
+	}{
+		{name: "remote address", remoteAddr: "192.0.2.1:51234", want: "192.0.2.1"},
+		{name: "ipv6 remote address", remoteAddr: "[2001:db8::1]:51234", want: "2001:db8::1"},
+		{name: "header ignored when not configured", value: "198.51.100.7", remoteAddr: "192.0.2.1:51234", want: "192.0.2.1"},
+		{name: "proxy header", header: "X-Real-IP", value: "198.51.100.7", remoteAddr: "192.0.2.1:51234", want: "198.51.100.7"},
+		{name: "last forwarded entry", header: "X-Forwarded-For", value: "203.0.113.9, 198.51.100.7", remoteAddr: "192.0.2.1:51234", want: "198.51.100.7"},
+		{name: "missing proxy header", header: "X-Real-IP", remoteAddr: "192.0.2.1:51234", want: "192.0.2.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.App.Server.ClientIPHeader = tt.header
+
+			req, err := http.NewRequest("POST", "/login", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.RemoteAddr = tt.remoteAddr
+			if tt.value != "" {
+				req.Header.Set("X-Real-IP", tt.value)
+				req.Header.Set("X-Forwarded-For", tt.value)
+			}
+
+			assert.Equal(t, tt.want, clientIP(req))
+		})
+	}
+}
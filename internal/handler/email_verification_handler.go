@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/faizalom/go-api/internal/service"
+	"github.com/faizalom/go-api/pkg/logger"
+)
+
+// resendVerificationMessage is sent whether or not the email is registered.
+const resendVerificationMessage = `{"message": "If an unverified account with that email exists, a verification link has been sent."}`
+
+type EmailVerificationHandler struct {
+	service service.IEmailVerificationService
+}
+
+func NewEmailVerificationHandler(s service.IEmailVerificationService) *EmailVerificationHandler {
+	return &EmailVerificationHandler{service: s}
+}
+
+// Verify confirms the user's email with the token from a verification link.
+func (h *EmailVerificationHandler) Verify(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		http.Error(w, "Token is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.Verify(r.Context(), token); err != nil {
+		if errors.Is(err, ierr.ErrInvalidVerificationToken) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		logger.Error.Printf("Could not verify email: %v", err)
+		writeServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"message": "Your email address has been verified."}`))
+}
+
+// Resend mails a new verification link. Like ForgotPassword, the response
+// does not reveal whether the email has an account.
+func (h *EmailVerificationHandler) Resend(w http.ResponseWriter, r *http.Request) {
+	var req model.ResendVerificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Email == "" {
+		http.Error(w, "Email is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.ResendVerification(r.Context(), &req); err != nil {
+		logger.Error.Printf("Could not resend verification email: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	w.Write([]byte(resendVerificationMessage))
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/faizalom/go-api/internal/service/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestEmailVerificationHandler_Verify(t *testing.T) {
+	mockVerificationService := new(mocks.MockEmailVerificationService)
+	verificationHandler := NewEmailVerificationHandler(mockVerificationService)
+
+	mockVerificationService.On("Verify", mock.Anything, "good").Return(nil)
+	mockVerificationService.On("Verify", mock.Anything, "bad").Return(ierr.ErrInvalidVerificationToken)
+	mockVerificationService.On("Verify", mock.Anything, "broken").Return(errors.New("db down"))
+
+	tests := []struct {
+		token string
+		want  int
+	}{
+		{token: "good", want: http.StatusOK},
+		{token: "bad", want: http.StatusBadRequest},
+		{token: "broken", want: http.StatusInternalServerError},
+		{token: "", want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		req, err := http.NewRequest("GET", "/auth/verify?token="+tt.token, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(verificationHandler.Verify)
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, tt.want, rr.Code, tt.token)
+	}
+}
+
+func TestEmailVerificationHandler_Resend_SameResponse(t *testing.T) {
+	mockVerificationService := new(mocks.MockEmailVerificationService)
+	verificationHandler := NewEmailVerificationHandler(mockVerificationService)
+
+	mockVerificationService.On("ResendVerification", mock.Anything, &model.ResendVerificationRequest{Email: "known@example.com"}).Return(nil)
+	mockVerificationService.On("ResendVerification", mock.Anything, &model.ResendVerificationRequest{Email: "broken@example.com"}).Return(errors.New("smtp down"))
+
+	var bodies []string
+	for _, email := range []string{"known@example.com", "broken@example.com"} {
+		jsonBody, _ := json.Marshal(&model.ResendVerificationRequest{Email: email})
+		req, err := http.NewRequest("POST", "/auth/verify/resend", bytes.NewBuffer(jsonBody))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(verificationHandler.Resend)
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusAccepted, rr.Code, email)
+		bodies = append(bodies, rr.Body.String())
+	}
+
+	assert.Equal(t, bodies[0], bodies[1])
+	mockVerificationService.AssertExpectations(t)
+}
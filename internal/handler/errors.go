@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/faizalom/go-api/internal/ierr"
+)
+
+// serverErrorCode is the status for an error the caller is not to blame
+// for: 503 when the database timed out or could not serialize the request
+// with concurrent ones, so that trying again later may succeed, and 500
+// otherwise.
+func serverErrorCode(err error) int {
+	if errors.Is(err, ierr.ErrSerializationFailure) || errors.Is(err, ierr.ErrQueryTimeout) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// writeServerError writes the response for an error the caller is not to
+// blame for, with the status serverErrorCode gives.
+func writeServerError(w http.ResponseWriter, err error) {
+	code := serverErrorCode(err)
+	if code == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", "1")
+	}
+	http.Error(w, http.StatusText(code), code)
+}
@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/faizalom/go-api/internal/model"
+)
+
+// setUserETag sends the user's version as a strong entity tag, for the
+// client to send back in If-Match when it changes the user.
+func setUserETag(w http.ResponseWriter, user *model.User) {
+	w.Header().Set("ETag", `"`+strconv.Itoa(user.Version)+`"`)
+}
+
+// ifMatchVersion reads the user version the client expects from the
+// If-Match header. It responds with 428 and returns false when there is no
+// such header. "*" matches the current version, as in RFC 9110, and returns
+// model.AnyVersion. A header that is not an ETag we sent matches no version.
+func ifMatchVersion(w http.ResponseWriter, r *http.Request) (int, bool) {
+	tag := strings.TrimSpace(r.Header.Get("If-Match"))
+	if tag == "" {
+		http.Error(w, "If-Match header with the user's ETag is required", http.StatusPreconditionRequired)
+		return 0, false
+	}
+	if tag == "*" {
+		return model.AnyVersion, true
+	}
+	version, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(tag, `"`), `"`))
+	if err != nil || !strings.HasPrefix(tag, `"`) || !strings.HasSuffix(tag, `"`) {
+		return -1, true
+	}
+	return version, true
+}
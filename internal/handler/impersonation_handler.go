@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/middleware"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/faizalom/go-api/internal/service"
+	"github.com/faizalom/go-api/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+type ImpersonationHandler struct {
+	service service.IImpersonationService
+}
+
+func NewImpersonationHandler(s service.IImpersonationService) *ImpersonationHandler {
+	return &ImpersonationHandler{service: s}
+}
+
+// Impersonate issues the caller a short-lived token to act as the user in the
+// path. The body must give a reason, which is kept in the audit trail.
+func (h *ImpersonationHandler) Impersonate(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserClaimsKey).(*model.CustomClaims)
+	if !ok {
+		http.Error(w, "Internal Server Error: could not retrieve user claims", http.StatusInternalServerError)
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var req model.ImpersonationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		http.Error(w, "Reason is required", http.StatusBadRequest)
+		return
+	}
+	req.TargetID = id
+	req.Client = clientInfo(r)
+
+	resp, err := h.service.Impersonate(r.Context(), claims, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, ierr.ErrImpersonationNotAllowed):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case errors.Is(err, ierr.ErrUserNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, ierr.ErrUserInactive):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			logger.Error.Printf("Could not impersonate user %s: %v", id, err)
+			writeServerError(w, err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/middleware"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/faizalom/go-api/internal/service/mocks"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestImpersonationHandler_Impersonate(t *testing.T) {
+	mockImpersonationService := new(mocks.MockImpersonationService)
+	impersonationHandler := NewImpersonationHandler(mockImpersonationService)
+
+	admin := &model.CustomClaims{RegisteredClaims: jwt.RegisteredClaims{Subject: uuid.NewString()}}
+	targetID, missingID, selfID := uuid.New(), uuid.New(), uuid.New()
+	tokens := &model.TokenResponse{Token: "token", TokenType: "Bearer", ExpiresIn: 900}
+	forTarget := func(id uuid.UUID) interface{} {
+		return mock.MatchedBy(func(req *model.ImpersonationRequest) bool {
+			return req.TargetID == id && req.Reason == "ticket 4711" && req.Client.IP == "192.0.2.1"
+		})
+	}
+	mockImpersonationService.On("Impersonate", mock.Anything, admin, forTarget(targetID)).Return(tokens, nil)
+	mockImpersonationService.On("Impersonate", mock.Anything, admin, forTarget(missingID)).Return(nil, ierr.ErrUserNotFound)
+	mockImpersonationService.On("Impersonate", mock.Anything, admin, forTarget(selfID)).Return(nil, ierr.ErrImpersonationNotAllowed)
+
+	tests := []struct {
+		name string
+		id   string
+		body string
+		want int
+	}{
+		{name: "impersonated", id: targetID.String(), body: `{"reason": " ticket 4711 "}`, want: http.StatusOK},
+		{name: "not found", id: missingID.String(), body: `{"reason": "ticket 4711"}`, want: http.StatusNotFound},
+		{name: "not allowed", id: selfID.String(), body: `{"reason": "ticket 4711"}`, want: http.StatusForbidden},
+		{name: "missing reason", id: targetID.String(), body: `{}`, want: http.StatusBadRequest},
+		{name: "invalid id", id: "not-a-uuid", body: `{"reason": "ticket 4711"}`, want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("POST", "/admin/impersonate/"+tt.id, bytes.NewBufferString(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.SetPathValue("id", tt.id)
+			req.RemoteAddr = "192.0.2.1:51234"
+			req = req.WithContext(context.WithValue(req.Context(), middleware.UserClaimsKey, admin))
+
+			rr := httptest.NewRecorder()
+			handler := http.HandlerFunc(impersonationHandler.Impersonate)
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.want, rr.Code)
+			if tt.want == http.StatusOK {
+				assert.JSONEq(t, `{"token":"token","token_type":"Bearer","expires_in":900}`, rr.Body.String())
+			}
+		})
+	}
+}
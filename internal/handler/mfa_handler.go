@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/faizalom/go-api/internal/service"
+	"github.com/faizalom/go-api/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+type MFAHandler struct {
+	service service.IMFAService
+}
+
+func NewMFAHandler(s service.IMFAService) *MFAHandler {
+	return &MFAHandler{service: s}
+}
+
+// Enroll starts two-factor enrollment for the user in the path and returns
+// the secret to add to an authenticator app.
+func (h *MFAHandler) Enroll(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	enrollment, err := h.service.Enroll(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, ierr.ErrMFAAlreadyEnabled):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, ierr.ErrUserNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			logger.Error.Printf("Could not enroll user %s in two-factor authentication: %v", id, err)
+			writeServerError(w, err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(enrollment)
+}
+
+// Confirm enables two-factor authentication with a first code from the
+// authenticator app and returns the user's recovery codes.
+func (h *MFAHandler) Confirm(w http.ResponseWriter, r *http.Request) {
+	id, req, ok := decodeMFACodeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	codes, err := h.service.Confirm(r.Context(), id, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, ierr.ErrInvalidMFACode):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case errors.Is(err, ierr.ErrMFANotEnrolled), errors.Is(err, ierr.ErrMFAAlreadyEnabled):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			logger.Error.Printf("Could not confirm two-factor authentication for user %s: %v", id, err)
+			writeServerError(w, err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(codes)
+}
+
+// Disable turns off two-factor authentication for the user in the path, who
+// must provide a current code or a recovery code.
+func (h *MFAHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	id, req, ok := decodeMFACodeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Disable(r.Context(), id, req); err != nil {
+		switch {
+		case errors.Is(err, ierr.ErrInvalidMFACode):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case errors.Is(err, ierr.ErrMFANotEnrolled):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			logger.Error.Printf("Could not disable two-factor authentication for user %s: %v", id, err)
+			writeServerError(w, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Verify completes a login that returned an MFA token and returns a token pair.
+// Wrong codes count as failed logins, so it can answer 429 with a
+// Retry-After header like Login.
+func (h *MFAHandler) Verify(w http.ResponseWriter, r *http.Request) {
+	var req model.MFAVerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.MFAToken == "" || req.Code == "" {
+		http.Error(w, "MFA token and code are required", http.StatusBadRequest)
+		return
+	}
+	req.Client = clientInfo(r)
+
+	resp, err := h.service.Verify(r.Context(), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, ierr.ErrInvalidMFAToken), errors.Is(err, ierr.ErrInvalidMFACode):
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		case errors.Is(err, ierr.ErrTooManyLoginAttempts):
+			writeTooManyLoginAttempts(w, err)
+		case errors.Is(err, ierr.ErrUserInactive):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			logger.Error.Printf("Could not verify two-factor code: %v", err)
+			writeServerError(w, err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// decodeMFACodeRequest reads the user ID from the path and the code from the
+// body, writing a 400 response and reporting false if either is missing.
+func decodeMFACodeRequest(w http.ResponseWriter, r *http.Request) (uuid.UUID, *model.MFACodeRequest, bool) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return uuid.Nil, nil, false
+	}
+
+	var req model.MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return uuid.Nil, nil, false
+	}
+
+	if req.Code == "" {
+		http.Error(w, "Code is required", http.StatusBadRequest)
+		return uuid.Nil, nil, false
+	}
+
+	return id, &req, true
+}
@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/faizalom/go-api/internal/service/mocks"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestMFAHandler_Enroll(t *testing.T) {
+	mockMFAService := new(mocks.MockMFAService)
+	mfaHandler := NewMFAHandler(mockMFAService)
+
+	userID := uuid.New()
+	enrolledID := uuid.New()
+	enrollment := &model.MFAEnrollment{Secret: "JBSWY3DPEHPK3PXP", URI: "otpauth://totp/Workout%20API:test@example.com?secret=JBSWY3DPEHPK3PXP"}
+	mockMFAService.On("Enroll", mock.Anything, userID).Return(enrollment, nil)
+	mockMFAService.On("Enroll", mock.Anything, enrolledID).Return(nil, ierr.ErrMFAAlreadyEnabled)
+
+	tests := []struct {
+		name string
+		id   string
+		want int
+	}{
+		{name: "enrolled", id: userID.String(), want: http.StatusOK},
+		{name: "already enabled", id: enrolledID.String(), want: http.StatusConflict},
+		{name: "invalid id", id: "not-a-uuid", want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("POST", "/users/"+tt.id+"/mfa", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.SetPathValue("id", tt.id)
+
+			rr := httptest.NewRecorder()
+			handler := http.HandlerFunc(mfaHandler.Enroll)
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.want, rr.Code)
+			if tt.want == http.StatusOK {
+				var got model.MFAEnrollment
+				assert.NoError(t, json.NewDecoder(rr.Body).Decode(&got))
+				assert.Equal(t, *enrollment, got)
+			}
+		})
+	}
+}
+
+func TestMFAHandler_Confirm(t *testing.T) {
+	mockMFAService := new(mocks.MockMFAService)
+	mfaHandler := NewMFAHandler(mockMFAService)
+
+	userID := uuid.New()
+	codes := &model.MFARecoveryCodes{RecoveryCodes: []string{"aaaa-bbbb-cccc-dddd"}}
+	mockMFAService.On("Confirm", mock.Anything, userID, &model.MFACodeRequest{Code: "123456"}).Return(codes, nil)
+	mockMFAService.On("Confirm", mock.Anything, userID, &model.MFACodeRequest{Code: "000000"}).Return(nil, ierr.ErrInvalidMFACode)
+	mockMFAService.On("Confirm", mock.Anything, userID, &model.MFACodeRequest{Code: "111111"}).Return(nil, ierr.ErrMFANotEnrolled)
+
+	tests := []struct {
+		name string
+		code string
+		want int
+	}{
+		{name: "confirmed", code: "123456", want: http.StatusOK},
+		{name: "wrong code", code: "000000", want: http.StatusForbidden},
+		{name: "not enrolled", code: "111111", want: http.StatusConflict},
+		{name: "missing code", code: "", want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jsonBody, _ := json.Marshal(model.MFACodeRequest{Code: tt.code})
+			req, err := http.NewRequest("POST", "/users/"+userID.String()+"/mfa/confirm", bytes.NewBuffer(jsonBody))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.SetPathValue("id", userID.String())
+
+			rr := httptest.NewRecorder()
+			handler := http.HandlerFunc(mfaHandler.Confirm)
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.want, rr.Code)
+			if tt.want == http.StatusOK {
+				var got model.MFARecoveryCodes
+				assert.NoError(t, json.NewDecoder(rr.Body).Decode(&got))
+				assert.Equal(t, *codes, got)
+			}
+		})
+	}
+}
+
+func TestMFAHandler_Disable(t *testing.T) {
+	mockMFAService := new(mocks.MockMFAService)
+	mfaHandler := NewMFAHandler(mockMFAService)
+
+	userID := uuid.New()
+	mockMFAService.On("Disable", mock.Anything, userID, &model.MFACodeRequest{Code: "123456"}).Return(nil)
+	mockMFAService.On("Disable", mock.Anything, userID, &model.MFACodeRequest{Code: "000000"}).Return(ierr.ErrInvalidMFACode)
+
+	tests := []struct {
+		name string
+		code string
+		want int
+	}{
+		{name: "disabled", code: "123456", want: http.StatusNoContent},
+		{name: "wrong code", code: "000000", want: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jsonBody, _ := json.Marshal(model.MFACodeRequest{Code: tt.code})
+			req, err := http.NewRequest("POST", "/users/"+userID.String()+"/mfa/disable", bytes.NewBuffer(jsonBody))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.SetPathValue("id", userID.String())
+
+			rr := httptest.NewRecorder()
+			handler := http.HandlerFunc(mfaHandler.Disable)
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.want, rr.Code)
+		})
+	}
+}
+
+func TestMFAHandler_Verify(t *testing.T) {
+	mockMFAService := new(mocks.MockMFAService)
+	mfaHandler := NewMFAHandler(mockMFAService)
+
+	tokens := &model.TokenResponse{Token: "access", RefreshToken: "refresh", TokenType: "Bearer", ExpiresIn: 900}
+	mockMFAService.On("Verify", mock.Anything, &model.MFAVerifyRequest{MFAToken: "mfa-token", Code: "123456"}).Return(tokens, nil)
+	mockMFAService.On("Verify", mock.Anything, &model.MFAVerifyRequest{MFAToken: "mfa-token", Code: "000000"}).Return(nil, ierr.ErrInvalidMFACode)
+	mockMFAService.On("Verify", mock.Anything, &model.MFAVerifyRequest{MFAToken: "expired", Code: "123456"}).Return(nil, ierr.ErrInvalidMFAToken)
+	mockMFAService.On("Verify", mock.Anything, &model.MFAVerifyRequest{MFAToken: "throttled", Code: "123456"}).Return(nil, &ierr.RetryAfterError{Err: ierr.ErrTooManyLoginAttempts, RetryAfter: 90 * time.Second})
+
+	tests := []struct {
+		name  string
+		token string
+		code  string
+		want  int
+	}{
+		{name: "verified", token: "mfa-token", code: "123456", want: http.StatusOK},
+		{name: "wrong code", token: "mfa-token", code: "000000", want: http.StatusUnauthorized},
+		{name: "expired token", token: "expired", code: "123456", want: http.StatusUnauthorized},
+		{name: "throttled", token: "throttled", code: "123456", want: http.StatusTooManyRequests},
+		{name: "missing code", token: "mfa-token", code: "", want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jsonBody, _ := json.Marshal(model.MFAVerifyRequest{MFAToken: tt.token, Code: tt.code})
+			req, err := http.NewRequest("POST", "/auth/mfa/verify", bytes.NewBuffer(jsonBody))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			handler := http.HandlerFunc(mfaHandler.Verify)
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.want, rr.Code)
+			if tt.want == http.StatusTooManyRequests {
+				assert.Equal(t, "90", rr.Header().Get("Retry-After"))
+			}
+			if tt.want == http.StatusOK {
+				var got model.TokenResponse
+				assert.NoError(t, json.NewDecoder(rr.Body).Decode(&got))
+				assert.Equal(t, *tokens, got)
+			}
+		})
+	}
+}
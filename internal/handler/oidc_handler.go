@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/oidc"
+	"github.com/faizalom/go-api/internal/service"
+	"github.com/faizalom/go-api/pkg/logger"
+)
+
+// oidcCookieMaxAge bounds how long a user may take to sign in at the provider.
+const oidcCookieMaxAge = 10 * 60
+
+type OIDCHandler struct {
+	service   service.IOIDCService
+	providers map[string]*oidc.Provider
+}
+
+func NewOIDCHandler(s service.IOIDCService, providers ...*oidc.Provider) *OIDCHandler {
+	h := &OIDCHandler{service: s, providers: make(map[string]*oidc.Provider, len(providers))}
+	for _, p := range providers {
+		h.providers[p.Name()] = p
+	}
+	return h
+}
+
+// Login redirects the browser to the provider's sign-in page. The state,
+// nonce and PKCE verifier are kept in a short-lived cookie for the callback.
+func (h *OIDCHandler) Login(w http.ResponseWriter, r *http.Request) {
+	provider, ok := h.providers[r.PathValue("provider")]
+	if !ok {
+		http.Error(w, "Unknown identity provider", http.StatusNotFound)
+		return
+	}
+
+	var values [3]string
+	for i := range values {
+		v, err := oidc.RandomString()
+		if err != nil {
+			logger.Error.Printf("Could not generate oidc state: %v", err)
+			writeServerError(w, err)
+			return
+		}
+		values[i] = v
+	}
+	state, nonce, verifier := values[0], values[1], values[2]
+
+	authURL, err := provider.AuthCodeURL(r.Context(), state, nonce, oidc.CodeChallenge(verifier))
+	if err != nil {
+		logger.Error.Printf("Could not start %s sign-in: %v", provider.Name(), err)
+		http.Error(w, "Identity provider unavailable", http.StatusBadGateway)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcCookieName(provider),
+		Value:    strings.Join(values[:], "."),
+		Path:     "/",
+		MaxAge:   oidcCookieMaxAge,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// Callback completes the sign-in: it checks the state, exchanges the code
+// and returns our own token pair, like Login does.
+func (h *OIDCHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	provider, ok := h.providers[r.PathValue("provider")]
+	if !ok {
+		http.Error(w, "Unknown identity provider", http.StatusNotFound)
+		return
+	}
+
+	cookie, err := r.Cookie(oidcCookieName(provider))
+	// The cookie is only good for one attempt.
+	http.SetCookie(w, &http.Cookie{Name: oidcCookieName(provider), Path: "/", MaxAge: -1, HttpOnly: true})
+
+	query := r.URL.Query()
+	if e := query.Get("error"); e != "" {
+		http.Error(w, "Sign-in failed at the identity provider: "+e, http.StatusUnauthorized)
+		return
+	}
+
+	var values []string
+	if err == nil {
+		values = strings.Split(cookie.Value, ".")
+	}
+	if len(values) != 3 || query.Get("state") != values[0] || query.Get("code") == "" {
+		http.Error(w, "Invalid or expired sign-in state", http.StatusBadRequest)
+		return
+	}
+	nonce, verifier := values[1], values[2]
+
+	identity, err := provider.Exchange(r.Context(), query.Get("code"), verifier, nonce)
+	if err != nil {
+		logger.Error.Printf("Could not complete %s sign-in: %v", provider.Name(), err)
+		if errors.Is(err, oidc.ErrDiscovery) {
+			http.Error(w, "Identity provider unavailable", http.StatusBadGateway)
+			return
+		}
+		http.Error(w, "Could not verify sign-in with the identity provider", http.StatusUnauthorized)
+		return
+	}
+
+	resp, err := h.service.Login(r.Context(), identity, clientInfo(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, ierr.ErrIdentityNoEmail):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, ierr.ErrIdentityUnverified):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, ierr.ErrUserInactive), errors.Is(err, ierr.ErrEmailNotVerified):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			logger.Error.Printf("Could not sign in %s identity %s: %v", provider.Name(), identity.Subject, err)
+			writeServerError(w, err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func oidcCookieName(p *oidc.Provider) string {
+	return "oidc_" + p.Name()
+}
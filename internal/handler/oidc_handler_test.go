@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/faizalom/go-api/internal/oidc"
+	"github.com/faizalom/go-api/internal/oidc/oidctest"
+	"github.com/faizalom/go-api/internal/service/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const oidcCallbackURL = "http://localhost:8080/api/v1/auth/oidc/test/callback"
+
+// startOIDCLogin runs the Login handler and follows the redirect through the
+// fake provider, returning the callback request the browser would make.
+func startOIDCLogin(t *testing.T, h *OIDCHandler, fake *oidctest.Provider) *http.Request {
+	t.Helper()
+
+	req := httptest.NewRequest("GET", "/auth/oidc/test/login", nil)
+	req.SetPathValue("provider", "test")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(h.Login).ServeHTTP(rr, req)
+	require.Equal(t, http.StatusFound, rr.Code)
+
+	cookies := rr.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.True(t, cookies[0].HttpOnly)
+
+	callback, err := fake.Authorize(rr.Header().Get("Location"))
+	require.NoError(t, err)
+
+	callbackReq := httptest.NewRequest("GET", callback.String(), nil)
+	callbackReq.SetPathValue("provider", "test")
+	callbackReq.AddCookie(cookies[0])
+	return callbackReq
+}
+
+func TestOIDCHandler_LoginAndCallback(t *testing.T) {
+	fake := oidctest.NewProvider(t)
+	mockOIDCService := new(mocks.MockOIDCService)
+	h := NewOIDCHandler(mockOIDCService, oidc.NewProvider(fake.Config("test", oidcCallbackURL), http.DefaultClient))
+
+	mockOIDCService.On("Login", mock.Anything, mock.MatchedBy(func(i *model.ExternalIdentity) bool {
+		return i.Provider == "test" && i.Subject == fake.Subject && i.Email == fake.Email && i.EmailVerified
+	}), model.ClientInfo{IP: "192.0.2.1"}).Return(&model.TokenResponse{Token: "token", RefreshToken: "refresh-token", TokenType: "Bearer", ExpiresIn: 900}, nil)
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(h.Callback).ServeHTTP(rr, startOIDCLogin(t, h, fake))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var resp model.TokenResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Equal(t, "token", resp.Token)
+	mockOIDCService.AssertExpectations(t)
+}
+
+func TestOIDCHandler_Callback_StateMismatch(t *testing.T) {
+	fake := oidctest.NewProvider(t)
+	mockOIDCService := new(mocks.MockOIDCService)
+	h := NewOIDCHandler(mockOIDCService, oidc.NewProvider(fake.Config("test", oidcCallbackURL), http.DefaultClient))
+
+	req := startOIDCLogin(t, h, fake)
+	q := req.URL.Query()
+	q.Set("state", "forged")
+	req.URL.RawQuery = q.Encode()
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(h.Callback).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	mockOIDCService.AssertNotCalled(t, "Login", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestOIDCHandler_Callback_MissingCookie(t *testing.T) {
+	fake := oidctest.NewProvider(t)
+	mockOIDCService := new(mocks.MockOIDCService)
+	h := NewOIDCHandler(mockOIDCService, oidc.NewProvider(fake.Config("test", oidcCallbackURL), http.DefaultClient))
+
+	req := startOIDCLogin(t, h, fake)
+	req.Header.Del("Cookie")
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(h.Callback).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestOIDCHandler_UnknownProvider(t *testing.T) {
+	h := NewOIDCHandler(new(mocks.MockOIDCService))
+
+	req := httptest.NewRequest("GET", "/auth/oidc/nope/login", nil)
+	req.SetPathValue("provider", "nope")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(h.Login).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/faizalom/go-api/internal/service"
+	"github.com/faizalom/go-api/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// forgotPasswordMessage is sent whether or not the email is registered.
+const forgotPasswordMessage = `{"message": "If an account with that email exists, a password reset link has been sent."}`
+
+type PasswordHandler struct {
+	service service.IPasswordService
+}
+
+func NewPasswordHandler(s service.IPasswordService) *PasswordHandler {
+	return &PasswordHandler{service: s}
+}
+
+// ForgotPassword mails a password reset link. The response is the same for
+// registered and unknown emails, and failures are only logged, so it cannot
+// be used to find out which emails have an account.
+func (h *PasswordHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req model.ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Email == "" {
+		http.Error(w, "Email is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.ForgotPassword(r.Context(), &req); err != nil {
+		logger.Error.Printf("Could not send password reset email: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	w.Write([]byte(forgotPasswordMessage))
+}
+
+// ResetPassword sets a new password using a token from a reset email.
+func (h *PasswordHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req model.ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Token == "" || req.Password == "" {
+		http.Error(w, "Token and password are required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.ResetPassword(r.Context(), &req); err != nil {
+		if errors.Is(err, ierr.ErrInvalidResetToken) || errors.Is(err, ierr.ErrWeakPassword) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		logger.Error.Printf("Could not reset password: %v", err)
+		writeServerError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ChangePassword replaces the password of the user in the path, who must
+// confirm their current password. It signs the user out everywhere.
+func (h *PasswordHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var req model.ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.CurrentPassword == "" || req.NewPassword == "" {
+		http.Error(w, "Current and new password are required", http.StatusBadRequest)
+		return
+	}
+	req.Client = clientInfo(r)
+
+	if err := h.service.ChangePassword(r.Context(), id, &req); err != nil {
+		switch {
+		case errors.Is(err, ierr.ErrWeakPassword):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, ierr.ErrIncorrectPassword):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case errors.Is(err, ierr.ErrTooManyLoginAttempts):
+			writeTooManyLoginAttempts(w, err)
+		case errors.Is(err, ierr.ErrUserNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			logger.Error.Printf("Could not change password for user %s: %v", id, err)
+			writeServerError(w, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
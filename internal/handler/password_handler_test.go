@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/faizalom/go-api/internal/service/mocks"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPasswordHandler_ForgotPassword_SameResponse(t *testing.T) {
+	mockPasswordService := new(mocks.MockPasswordService)
+	passwordHandler := NewPasswordHandler(mockPasswordService)
+
+	mockPasswordService.On("ForgotPassword", mock.Anything, &model.ForgotPasswordRequest{Email: "known@example.com"}).Return(nil)
+	mockPasswordService.On("ForgotPassword", mock.Anything, &model.ForgotPasswordRequest{Email: "unknown@example.com"}).Return(nil)
+	mockPasswordService.On("ForgotPassword", mock.Anything, &model.ForgotPasswordRequest{Email: "broken@example.com"}).Return(errors.New("smtp down"))
+
+	var bodies []string
+	for _, email := range []string{"known@example.com", "unknown@example.com", "broken@example.com"} {
+		jsonBody, _ := json.Marshal(&model.ForgotPasswordRequest{Email: email})
+		req, err := http.NewRequest("POST", "/auth/password/forgot", bytes.NewBuffer(jsonBody))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(passwordHandler.ForgotPassword)
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusAccepted, rr.Code, email)
+		bodies = append(bodies, rr.Body.String())
+	}
+
+	assert.Equal(t, bodies[0], bodies[1])
+	assert.Equal(t, bodies[0], bodies[2])
+	mockPasswordService.AssertExpectations(t)
+}
+
+func TestPasswordHandler_ResetPassword(t *testing.T) {
+	mockPasswordService := new(mocks.MockPasswordService)
+	passwordHandler := NewPasswordHandler(mockPasswordService)
+
+	mockPasswordService.On("ResetPassword", mock.Anything, &model.ResetPasswordRequest{Token: "good", Password: "new-password"}).Return(nil)
+	mockPasswordService.On("ResetPassword", mock.Anything, &model.ResetPasswordRequest{Token: "bad", Password: "new-password"}).Return(ierr.ErrInvalidResetToken)
+	mockPasswordService.On("ResetPassword", mock.Anything, &model.ResetPasswordRequest{Token: "weak", Password: "new-password"}).Return(ierr.ErrWeakPassword)
+
+	tests := []struct {
+		token string
+		want  int
+	}{
+		{token: "good", want: http.StatusNoContent},
+		{token: "bad", want: http.StatusBadRequest},
+		{token: "weak", want: http.StatusBadRequest},
+		{token: "", want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		jsonBody, _ := json.Marshal(&model.ResetPasswordRequest{Token: tt.token, Password: "new-password"})
+		req, err := http.NewRequest("POST", "/auth/password/reset", bytes.NewBuffer(jsonBody))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(passwordHandler.ResetPassword)
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, tt.want, rr.Code, tt.token)
+	}
+}
+
+func TestPasswordHandler_ChangePassword(t *testing.T) {
+	mockPasswordService := new(mocks.MockPasswordService)
+	passwordHandler := NewPasswordHandler(mockPasswordService)
+
+	userID := uuid.New()
+	change := func(newPassword string) *model.ChangePasswordRequest {
+		return &model.ChangePasswordRequest{CurrentPassword: "old-password", NewPassword: newPassword}
+	}
+	mockPasswordService.On("ChangePassword", mock.Anything, userID, change("new-password")).Return(nil)
+	mockPasswordService.On("ChangePassword", mock.Anything, userID, change("weak")).Return(ierr.ErrWeakPassword)
+	mockPasswordService.On("ChangePassword", mock.Anything, userID, change("wrong-current")).Return(ierr.ErrIncorrectPassword)
+	mockPasswordService.On("ChangePassword", mock.Anything, userID, change("missing-user")).Return(ierr.ErrUserNotFound)
+	mockPasswordService.On("ChangePassword", mock.Anything, userID, change("throttled")).Return(&ierr.RetryAfterError{Err: ierr.ErrTooManyLoginAttempts, RetryAfter: time.Minute})
+
+	tests := []struct {
+		name        string
+		id          string
+		newPassword string
+		want        int
+	}{
+		{name: "changed", id: userID.String(), newPassword: "new-password", want: http.StatusNoContent},
+		{name: "weak password", id: userID.String(), newPassword: "weak", want: http.StatusBadRequest},
+		{name: "wrong current password", id: userID.String(), newPassword: "wrong-current", want: http.StatusForbidden},
+		{name: "unknown user", id: userID.String(), newPassword: "missing-user", want: http.StatusNotFound},
+		{name: "throttled", id: userID.String(), newPassword: "throttled", want: http.StatusTooManyRequests},
+		{name: "missing new password", id: userID.String(), newPassword: "", want: http.StatusBadRequest},
+		{name: "invalid id", id: "not-a-uuid", newPassword: "new-password", want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jsonBody, _ := json.Marshal(change(tt.newPassword))
+			req, err := http.NewRequest("PUT", "/users/"+tt.id+"/password", bytes.NewBuffer(jsonBody))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.SetPathValue("id", tt.id)
+
+			rr := httptest.NewRecorder()
+			handler := http.HandlerFunc(passwordHandler.ChangePassword)
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.want, rr.Code)
+		})
+	}
+}
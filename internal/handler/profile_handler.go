@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 
@@ -19,10 +20,17 @@ func ProfileHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Use the custom claims
-	userID := claims.Subject
-	userName := claims.Name
-	userEmail := claims.Email
+	resp := model.ProfileResponse{
+		Message: fmt.Sprintf("Hello, %s (%s)", claims.Name, claims.Email),
+		UserID:  claims.Subject,
+	}
+
+	// Show who is really signed in when an admin is impersonating the user
+	if claims.Actor != nil {
+		resp.ImpersonatedBy = &model.ProfileActor{UserID: claims.Actor.Subject, Email: claims.Actor.Email}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, `{"message": "Hello, %s (%s)", "user_id": "%s"}`, userName, userEmail, userID)
+	json.NewEncoder(w).Encode(resp)
 }
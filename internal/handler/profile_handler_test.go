@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/faizalom/go-api/internal/middleware"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfileHandler(t *testing.T) {
+	user := model.CustomClaims{Name: "test user", Email: "test@example.com", RegisteredClaims: jwt.RegisteredClaims{Subject: "user-id"}}
+	impersonated := user
+	impersonated.Actor = &model.ActorClaim{Subject: "admin-id", Email: "admin@example.com"}
+	// Names and emails are escaped rather than becoming part of the JSON.
+	injected := model.CustomClaims{Name: `x", "user_id": "admin-id`, Email: `"test@example.com\`, RegisteredClaims: jwt.RegisteredClaims{Subject: "user-id"}}
+	injected.Actor = &model.ActorClaim{Subject: "admin-id", Email: `a"}, "role": "admin`}
+
+	tests := []struct {
+		name   string
+		claims *model.CustomClaims
+		want   string
+	}{
+		{name: "user", claims: &user, want: `{"message": "Hello, test user (test@example.com)", "user_id": "user-id"}`},
+		{name: "impersonated", claims: &impersonated, want: `{"message": "Hello, test user (test@example.com)", "user_id": "user-id", "impersonated_by": {"user_id": "admin-id", "email": "admin@example.com"}}`},
+		{name: "injected", claims: &injected, want: `{"message": "Hello, x\", \"user_id\": \"admin-id (\"test@example.com\\)", "user_id": "user-id", "impersonated_by": {"user_id": "admin-id", "email": "a\"}, \"role\": \"admin"}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/profile", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = req.WithContext(context.WithValue(req.Context(), middleware.UserClaimsKey, tt.claims))
+
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(ProfileHandler).ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.JSONEq(t, tt.want, rr.Body.String())
+		})
+	}
+}
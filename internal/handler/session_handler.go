@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/middleware"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/faizalom/go-api/internal/service"
+	"github.com/faizalom/go-api/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+type SessionHandler struct {
+	service service.ISessionService
+}
+
+func NewSessionHandler(s service.ISessionService) *SessionHandler {
+	return &SessionHandler{service: s}
+}
+
+// List returns the caller's active sessions, marking the one the request was
+// made from.
+func (h *SessionHandler) List(w http.ResponseWriter, r *http.Request) {
+	claims, userID, ok := sessionCaller(w, r)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.service.List(r.Context(), userID, claims.SessionID)
+	if err != nil {
+		logger.Error.Printf("Could not list sessions of user %s: %v", userID, err)
+		writeServerError(w, err)
+		return
+	}
+	if sessions == nil {
+		sessions = []*model.Session{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(sessions)
+}
+
+// Revoke signs the caller out of one of their sessions.
+func (h *SessionHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	_, userID, ok := sessionCaller(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.Revoke(r.Context(), userID, id); err != nil {
+		switch {
+		case errors.Is(err, ierr.ErrSessionNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			logger.Error.Printf("Could not revoke session %s of user %s: %v", id, userID, err)
+			writeServerError(w, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sessionCaller returns the caller's claims and user ID, writing an error
+// response and reporting false if they are missing. API keys and
+// impersonation tokens, which belong to no session, may not manage sessions.
+func sessionCaller(w http.ResponseWriter, r *http.Request) (*model.CustomClaims, uuid.UUID, bool) {
+	claims, ok := r.Context().Value(middleware.UserClaimsKey).(*model.CustomClaims)
+	if !ok {
+		http.Error(w, "Internal Server Error: could not retrieve user claims", http.StatusInternalServerError)
+		return nil, uuid.Nil, false
+	}
+	if claims.SessionID == "" {
+		http.Error(w, "Sessions can only be managed from a signed-in session", http.StatusForbidden)
+		return nil, uuid.Nil, false
+	}
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, uuid.Nil, false
+	}
+	return claims, userID, true
+}
@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/middleware"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/faizalom/go-api/internal/service/mocks"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func withSessionClaims(req *http.Request, userID, sessionID uuid.UUID) *http.Request {
+	claims := &model.CustomClaims{
+		SessionID:        sessionID.String(),
+		RegisteredClaims: jwt.RegisteredClaims{Subject: userID.String()},
+	}
+	return req.WithContext(context.WithValue(req.Context(), middleware.UserClaimsKey, claims))
+}
+
+func TestSessionHandler_List(t *testing.T) {
+	mockSessionService := new(mocks.MockSessionService)
+	sessionHandler := NewSessionHandler(mockSessionService)
+
+	userID, sessionID := uuid.New(), uuid.New()
+	sessions := []*model.Session{{ID: sessionID, UserAgent: "curl/8.5.0", IPAddress: "192.0.2.1", Current: true}}
+	mockSessionService.On("List", mock.Anything, userID, sessionID.String()).Return(sessions, nil)
+
+	req, err := http.NewRequest("GET", "/sessions", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = withSessionClaims(req, userID, sessionID)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(sessionHandler.List)
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var got []*model.Session
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&got))
+	assert.Len(t, got, 1)
+	assert.Equal(t, sessionID, got[0].ID)
+	assert.True(t, got[0].Current)
+	mockSessionService.AssertExpectations(t)
+}
+
+func TestSessionHandler_List_Timeout(t *testing.T) {
+	mockSessionService := new(mocks.MockSessionService)
+	sessionHandler := NewSessionHandler(mockSessionService)
+
+	userID, sessionID := uuid.New(), uuid.New()
+	mockSessionService.On("List", mock.Anything, userID, sessionID.String()).Return(nil, &ierr.DBError{Kind: ierr.ErrQueryTimeout, Err: assert.AnError})
+
+	req := withSessionClaims(httptest.NewRequest("GET", "/sessions", nil), userID, sessionID)
+	rr := httptest.NewRecorder()
+	sessionHandler.List(rr, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Equal(t, "1", rr.Header().Get("Retry-After"))
+}
+
+func TestSessionHandler_List_APIKey(t *testing.T) {
+	mockSessionService := new(mocks.MockSessionService)
+	sessionHandler := NewSessionHandler(mockSessionService)
+
+	req, err := http.NewRequest("GET", "/sessions", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := &model.CustomClaims{Scopes: []string{"user:read"}, RegisteredClaims: jwt.RegisteredClaims{Subject: uuid.New().String()}}
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserClaimsKey, claims))
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(sessionHandler.List)
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	mockSessionService.AssertNotCalled(t, "List", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestSessionHandler_Revoke(t *testing.T) {
+	mockSessionService := new(mocks.MockSessionService)
+	sessionHandler := NewSessionHandler(mockSessionService)
+
+	userID, currentID := uuid.New(), uuid.New()
+	sessionID, missingID := uuid.New(), uuid.New()
+	mockSessionService.On("Revoke", mock.Anything, userID, sessionID).Return(nil)
+	mockSessionService.On("Revoke", mock.Anything, userID, missingID).Return(ierr.ErrSessionNotFound)
+
+	tests := []struct {
+		name string
+		id   string
+		want int
+	}{
+		{name: "revoked", id: sessionID.String(), want: http.StatusNoContent},
+		{name: "not found", id: missingID.String(), want: http.StatusNotFound},
+		{name: "invalid id", id: "not-a-uuid", want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("DELETE", "/sessions/"+tt.id, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.SetPathValue("id", tt.id)
+			req = withSessionClaims(req, userID, currentID)
+
+			rr := httptest.NewRecorder()
+			handler := http.HandlerFunc(sessionHandler.Revoke)
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.want, rr.Code)
+		})
+	}
+}
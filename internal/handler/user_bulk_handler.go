@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/faizalom/go-api/internal/authz"
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/faizalom/go-api/internal/service"
+	"github.com/faizalom/go-api/pkg/logger"
+)
+
+type UserBulkHandler struct {
+	service    service.IUserBulkService
+	authorizer authz.Authorizer
+}
+
+func NewUserBulkHandler(s service.IUserBulkService, a authz.Authorizer) *UserBulkHandler {
+	return &UserBulkHandler{service: s, authorizer: a}
+}
+
+// ImportUsers handles the HTTP request for creating users from a CSV or
+// NDJSON body, as told by the Content-Type header. With ?atomic=true they
+// are all created or none are.
+func (h *UserBulkHandler) ImportUsers(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != model.UserCSVType && mediaType != model.UserNDJSONType {
+		http.Error(w, "Content-Type must be "+model.UserCSVType+" or "+model.UserNDJSONType, http.StatusUnsupportedMediaType)
+		return
+	}
+	req := &model.UserImportRequest{Format: mediaType, Body: r.Body}
+	if v := r.URL.Query().Get("atomic"); v != "" {
+		atomic, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, "atomic must be true or false", http.StatusBadRequest)
+			return
+		}
+		req.Atomic = atomic
+	}
+
+	report, err := h.service.ImportUsers(r.Context(), req)
+	if err != nil {
+		if errors.Is(err, ierr.ErrInvalidImport) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
+
+// ExportUsers handles the HTTP request for downloading every user as CSV,
+// or as NDJSON with ?format=ndjson. The users are streamed as they are read.
+func (h *UserBulkHandler) ExportUsers(w http.ResponseWriter, r *http.Request) {
+	format, ext := model.UserCSVType, "csv"
+	switch r.URL.Query().Get("format") {
+	case "", "csv":
+	case "ndjson":
+		format, ext = model.UserNDJSONType, "ndjson"
+	default:
+		http.Error(w, "format must be csv or ndjson", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", format)
+	w.Header().Set("Content-Disposition", `attachment; filename="users.`+ext+`"`)
+	out := &writeTracker{w: w}
+	if err := h.service.ExportUsers(r.Context(), format, out); err != nil {
+		if !out.wrote {
+			writeServerError(w, err)
+			return
+		}
+		// The status has been sent, so break off the response rather than
+		// let a partial export pass for a complete one.
+		logger.Error.Printf("Could not finish user export: %v", err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// RunBatch handles the HTTP request for creating, updating and deleting
+// users in one transaction. The caller must be allowed every operation, or
+// none is run. The body and the number of operations are limited before
+// any is looked at.
+func (h *UserBulkHandler) RunBatch(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, model.MaxUserBatchBytes)
+	var req model.UserBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("Request body must not exceed %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if n := len(req.Operations); n == 0 || n > model.MaxUserBatchSize {
+		http.Error(w, fmt.Sprintf("a batch holds 1 to %d operations", model.MaxUserBatchSize), http.StatusBadRequest)
+		return
+	}
+
+	for i := range req.Operations {
+		op := &req.Operations[i]
+		action, resource := authz.ActionUserCreate, authz.Resource{Type: authz.ResourceUser}
+		switch op.Op {
+		case model.BatchOpCreate:
+		case model.BatchOpUpdate:
+			action, resource = authz.ActionUserUpdate, authz.UserResource(op.ID.String())
+		case model.BatchOpDelete:
+			action, resource = authz.ActionUserDelete, authz.UserResource(op.ID.String())
+		default:
+			http.Error(w, fmt.Sprintf("operation %d: unknown op %q", i, op.Op), http.StatusBadRequest)
+			return
+		}
+		if !authorize(w, r, h.authorizer, action, resource) {
+			return
+		}
+		if op.Op == model.BatchOpUpdate {
+			rolesDecision, emailDecision, ok := decideEdit(w, r, h.authorizer, resource)
+			if !ok {
+				return
+			}
+			op.Rights = editRightsOf(rolesDecision, emailDecision)
+		}
+	}
+
+	resp, err := h.service.RunBatch(r.Context(), &req)
+	if err != nil {
+		if errors.Is(err, ierr.ErrInvalidBatch) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeServerError(w, err)
+		return
+	}
+	for i := range resp.Results {
+		setBatchResultCode(&resp.Results[i])
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// setBatchResultCode fills in the status code and error message the
+// operation would have got from its own endpoint.
+func setBatchResultCode(result *model.UserBatchResult) {
+	switch result.Status {
+	case model.BatchOpSucceeded:
+		result.Code = map[string]int{
+			model.BatchOpCreate: http.StatusCreated,
+			model.BatchOpUpdate: http.StatusOK,
+			model.BatchOpDelete: http.StatusNoContent,
+		}[result.Op]
+		return
+	case model.BatchOpFailed:
+	default:
+		return
+	}
+
+	err := result.Err
+	result.Error = err.Error()
+	switch {
+	case errors.Is(err, ierr.ErrUserNotFound):
+		result.Code = http.StatusNotFound
+	case errors.Is(err, ierr.ErrRolesNotAllowed), errors.Is(err, ierr.ErrEmailNotAllowed):
+		result.Code = http.StatusForbidden
+	case errors.Is(err, ierr.ErrInvalidUser), errors.Is(err, ierr.ErrInvalidRole), errors.Is(err, ierr.ErrWeakPassword), errors.Is(err, ierr.ErrInvalidBatch):
+		result.Code = http.StatusBadRequest
+	case errors.Is(err, ierr.ErrUserAlreadyExists):
+		result.Code = http.StatusConflict
+	case errors.Is(err, ierr.ErrVersionMismatch):
+		result.Code = http.StatusPreconditionFailed
+	default:
+		logger.Error.Printf("Could not run operation %d of user batch: %v", result.Index, err)
+		result.Code = serverErrorCode(err)
+		result.Error = http.StatusText(result.Code)
+	}
+}
+
+// writeTracker tells whether anything has been written through it.
+type writeTracker struct {
+	w     io.Writer
+	wrote bool
+}
+
+func (t *writeTracker) Write(p []byte) (int, error) {
+	t.wrote = true
+	return t.w.Write(p)
+}
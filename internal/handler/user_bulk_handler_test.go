@@ -0,0 +1,210 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/middleware"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/faizalom/go-api/internal/service/mocks"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestUserBulkHandler_ImportUsers(t *testing.T) {
+	mockBulkService := new(mocks.MockUserBulkService)
+	bulkHandler := NewUserBulkHandler(mockBulkService, testAuthorizer)
+
+	report := &model.UserImportReport{Atomic: true, Committed: true, Created: 1, Rows: []model.UserImportRow{{Line: 2, Status: model.ImportRowCreated, Email: "ann@example.com"}}}
+	mockBulkService.On("ImportUsers", mock.Anything, mock.MatchedBy(func(req *model.UserImportRequest) bool {
+		return req.Format == model.UserCSVType && req.Atomic
+	})).Return(report, nil)
+
+	req := httptest.NewRequest("POST", "/users/import?atomic=true", bytes.NewBufferString("name,email,password\nAnn,ann@example.com,secret\n"))
+	req.Header.Set("Content-Type", "text/csv; charset=utf-8")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(bulkHandler.ImportUsers).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"atomic":true,"committed":true,"created":1,"skipped":0,"failed":0,"rows":[{"line":2,"status":"created","email":"ann@example.com"}]}`, rr.Body.String())
+	mockBulkService.AssertExpectations(t)
+}
+
+func TestUserBulkHandler_ImportUsers_Errors(t *testing.T) {
+	mockBulkService := new(mocks.MockUserBulkService)
+	bulkHandler := NewUserBulkHandler(mockBulkService, testAuthorizer)
+
+	mockBulkService.On("ImportUsers", mock.Anything, mock.Anything).Return(nil, ierr.ErrInvalidImport)
+
+	tests := []struct {
+		name        string
+		url         string
+		contentType string
+		code        int
+	}{
+		{"unsupported content type", "/users/import", "application/json", http.StatusUnsupportedMediaType},
+		{"invalid atomic", "/users/import?atomic=maybe", model.UserNDJSONType, http.StatusBadRequest},
+		{"invalid import", "/users/import", model.UserNDJSONType, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.url, bytes.NewBufferString(""))
+			req.Header.Set("Content-Type", tt.contentType)
+			rr := httptest.NewRecorder()
+			bulkHandler.ImportUsers(rr, req)
+			assert.Equal(t, tt.code, rr.Code)
+		})
+	}
+}
+
+func TestUserBulkHandler_ExportUsers(t *testing.T) {
+	mockBulkService := new(mocks.MockUserBulkService)
+	bulkHandler := NewUserBulkHandler(mockBulkService, testAuthorizer)
+
+	mockBulkService.On("ExportUsers", mock.Anything, model.UserNDJSONType, mock.Anything).Return(`{"name":"Ann"}`+"\n", nil)
+
+	req := httptest.NewRequest("GET", "/users/export?format=ndjson", nil)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(bulkHandler.ExportUsers).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, model.UserNDJSONType, rr.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="users.ndjson"`, rr.Header().Get("Content-Disposition"))
+	assert.Equal(t, `{"name":"Ann"}`+"\n", rr.Body.String())
+}
+
+func TestUserBulkHandler_ExportUsers_Errors(t *testing.T) {
+	failure := errors.New("connection lost")
+
+	t.Run("invalid format", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		NewUserBulkHandler(new(mocks.MockUserBulkService), testAuthorizer).ExportUsers(rr, httptest.NewRequest("GET", "/users/export?format=xml", nil))
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("before writing", func(t *testing.T) {
+		mockBulkService := new(mocks.MockUserBulkService)
+		mockBulkService.On("ExportUsers", mock.Anything, model.UserCSVType, mock.Anything).Return(nil, failure)
+
+		rr := httptest.NewRecorder()
+		NewUserBulkHandler(mockBulkService, testAuthorizer).ExportUsers(rr, httptest.NewRequest("GET", "/users/export", nil))
+		assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	})
+
+	t.Run("after writing", func(t *testing.T) {
+		mockBulkService := new(mocks.MockUserBulkService)
+		mockBulkService.On("ExportUsers", mock.Anything, model.UserCSVType, mock.Anything).Return("id,name\n", failure)
+
+		rr := httptest.NewRecorder()
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+			NewUserBulkHandler(mockBulkService, testAuthorizer).ExportUsers(rr, httptest.NewRequest("GET", "/users/export", nil))
+		})
+	})
+}
+
+func TestUserBulkHandler_RunBatch(t *testing.T) {
+	mockBulkService := new(mocks.MockUserBulkService)
+	bulkHandler := NewUserBulkHandler(mockBulkService, testAuthorizer)
+
+	userID := uuid.New()
+	resp := &model.UserBatchResponse{Results: []model.UserBatchResult{
+		{Index: 0, Op: model.BatchOpCreate, Status: model.BatchOpRolledBack},
+		{Index: 1, Op: model.BatchOpUpdate, Status: model.BatchOpFailed, Err: ierr.ErrVersionMismatch},
+		{Index: 2, Op: model.BatchOpDelete, Status: model.BatchOpNotRun},
+	}}
+	mockBulkService.On("RunBatch", mock.Anything, mock.MatchedBy(func(req *model.UserBatchRequest) bool {
+		// Admins may change roles and emails.
+		return len(req.Operations) == 3 && req.Operations[1].Rights == model.UserEditRights{Roles: true, Email: true}
+	})).Return(resp, nil)
+
+	body := `{"operations":[
+		{"op":"create","user":{"name":"Ann","email":"ann@example.com","password":"secret"}},
+		{"op":"update","id":"` + userID.String() + `","version":2,"user":{"name":"Bob","email":"bob@example.com","roles":["admin"]}},
+		{"op":"delete","id":"` + uuid.NewString() + `","version":1}
+	]}`
+	req := httptest.NewRequest("POST", "/users:batch", bytes.NewBufferString(body))
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserClaimsKey, &model.CustomClaims{
+		Roles:            []string{model.RoleAdmin},
+		RegisteredClaims: jwt.RegisteredClaims{Subject: uuid.NewString()},
+	}))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(bulkHandler.RunBatch).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"committed":false,"results":[
+		{"index":0,"op":"create","status":"rolled_back"},
+		{"index":1,"op":"update","status":"failed","code":412,"error":"user has changed since it was read"},
+		{"index":2,"op":"delete","status":"not_run"}
+	]}`, rr.Body.String())
+	mockBulkService.AssertExpectations(t)
+}
+
+func TestUserBulkHandler_RunBatch_Forbidden(t *testing.T) {
+	mockBulkService := new(mocks.MockUserBulkService)
+	bulkHandler := NewUserBulkHandler(mockBulkService, testAuthorizer)
+
+	// Athletes may update themselves, but not delete anyone.
+	selfID := uuid.New()
+	body := `{"operations":[
+		{"op":"update","id":"` + selfID.String() + `","version":1,"user":{"name":"Me","email":"me@example.com","roles":["athlete"]}},
+		{"op":"delete","id":"` + selfID.String() + `","version":1}
+	]}`
+	req := httptest.NewRequest("POST", "/users:batch", bytes.NewBufferString(body))
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserClaimsKey, &model.CustomClaims{
+		Roles:            []string{model.RoleAthlete},
+		RegisteredClaims: jwt.RegisteredClaims{Subject: selfID.String()},
+	}))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(bulkHandler.RunBatch).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"action":"user:delete"`)
+	mockBulkService.AssertNotCalled(t, "RunBatch", mock.Anything, mock.Anything)
+}
+
+func TestUserBulkHandler_RunBatch_Size(t *testing.T) {
+	mockBulkService := new(mocks.MockUserBulkService)
+	bulkHandler := NewUserBulkHandler(mockBulkService, testAuthorizer)
+
+	tooMany := `{"operations":[` + strings.Repeat(`{"op":"create"},`, model.MaxUserBatchSize) + `{"op":"create"}]}`
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{name: "no operations", body: `{"operations":[]}`, want: http.StatusBadRequest},
+		{name: "too many operations", body: tooMany, want: http.StatusBadRequest},
+		{name: "body too large", body: `{"operations":[{"op":"create","user":{"name":"` + strings.Repeat("x", model.MaxUserBatchBytes) + `"}}]}`, want: http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/users:batch", bytes.NewBufferString(tt.body))
+			// Without claims authorizing would fail, so these are refused first.
+			rr := httptest.NewRecorder()
+			bulkHandler.RunBatch(rr, req)
+
+			assert.Equal(t, tt.want, rr.Code)
+		})
+	}
+	mockBulkService.AssertNotCalled(t, "RunBatch", mock.Anything, mock.Anything)
+}
+
+func TestUserBulkHandler_RunBatch_UnknownOp(t *testing.T) {
+	mockBulkService := new(mocks.MockUserBulkService)
+	bulkHandler := NewUserBulkHandler(mockBulkService, testAuthorizer)
+
+	req := httptest.NewRequest("POST", "/users:batch", bytes.NewBufferString(`{"operations":[{"op":"merge"}]}`))
+	rr := httptest.NewRecorder()
+	bulkHandler.RunBatch(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	mockBulkService.AssertNotCalled(t, "RunBatch", mock.Anything, mock.Anything)
+}
@@ -2,8 +2,17 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/faizalom/go-api/internal/authz"
 	"github.com/faizalom/go-api/internal/ierr"
 	"github.com/faizalom/go-api/internal/model"
 	"github.com/faizalom/go-api/internal/service"
@@ -12,11 +21,12 @@ import (
 )
 
 type UserHandler struct {
-	service service.IUserService
+	service    service.IUserService
+	authorizer authz.Authorizer
 }
 
-func NewUserHandler(s service.IUserService) *UserHandler {
-	return &UserHandler{service: s}
+func NewUserHandler(s service.IUserService, a authz.Authorizer) *UserHandler {
+	return &UserHandler{service: s, authorizer: a}
 }
 
 // CreateUser handles the HTTP request for creating a new user.
@@ -27,20 +37,22 @@ func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// In a real app, you would add validation for the request here.
-
 	createdUser, err := h.service.CreateUser(r.Context(), &req)
 	if err != nil {
-		// A more robust implementation would check the type of error
-		if err == ierr.ErrUserAlreadyExists {
+		if errors.Is(err, ierr.ErrUserAlreadyExists) {
 			http.Error(w, err.Error(), http.StatusConflict)
 			return
 		}
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		if errors.Is(err, ierr.ErrInvalidUser) || errors.Is(err, ierr.ErrInvalidRole) || errors.Is(err, ierr.ErrWeakPassword) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeServerError(w, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	setUserETag(w, createdUser)
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(createdUser)
 }
@@ -56,20 +68,22 @@ func (h *UserHandler) GetUserByID(w http.ResponseWriter, r *http.Request) {
 
 	user, err := h.service.GetUserByID(r.Context(), id)
 	if err != nil {
-		if err == ierr.ErrUserNotFound {
+		if errors.Is(err, ierr.ErrUserNotFound) {
 			http.Error(w, err.Error(), http.StatusNotFound)
 			return
 		}
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		writeServerError(w, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	setUserETag(w, user)
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(user)
 }
 
-// UpdateUser handles the HTTP request for updating a user.
+// UpdateUser handles the HTTP request for replacing a user's name, email
+// and roles. The If-Match header must hold the user's current ETag.
 func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 	id, err := uuid.Parse(idStr)
@@ -77,6 +91,10 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid user ID", http.StatusBadRequest)
 		return
 	}
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
 
 	var req model.UpdateUserRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -84,22 +102,115 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := h.service.UpdateUser(r.Context(), id, &req)
+	// Changing roles or email are separate, more privileged actions than
+	// updating a profile.
+	rolesDecision, emailDecision, ok := decideEdit(w, r, h.authorizer, authz.UserResource(id.String()))
+	if !ok {
+		return
+	}
+
+	user, err := h.service.UpdateUser(r.Context(), id, version, &req, editRightsOf(rolesDecision, emailDecision))
 	if err != nil {
-		if err == ierr.ErrUserNotFound {
-			http.Error(w, err.Error(), http.StatusNotFound)
-			return
-		}
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		writeUserEditError(w, err, rolesDecision, emailDecision)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	setUserETag(w, user)
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(user)
 }
 
-// DeleteUser handles the HTTP request for deleting a user.
+// acceptPatch lists the patch formats PatchUser accepts.
+const acceptPatch = model.MergePatchType + ", " + model.JSONPatchType
+
+// PatchUser handles the HTTP request for patching a user with a JSON Merge
+// Patch or a JSON Patch, as told by the Content-Type header. The If-Match
+// header must hold the user's current ETag.
+func (h *UserHandler) PatchUser(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != model.MergePatchType && mediaType != model.JSONPatchType {
+		w.Header().Set("Accept-Patch", acceptPatch)
+		http.Error(w, "Content-Type must be one of "+acceptPatch, http.StatusUnsupportedMediaType)
+		return
+	}
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	rolesDecision, emailDecision, ok := decideEdit(w, r, h.authorizer, authz.UserResource(id.String()))
+	if !ok {
+		return
+	}
+
+	patch := &model.UserPatch{Type: mediaType, Patch: body}
+	user, err := h.service.PatchUser(r.Context(), id, version, patch, editRightsOf(rolesDecision, emailDecision))
+	if err != nil {
+		writeUserEditError(w, err, rolesDecision, emailDecision)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	setUserETag(w, user)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(user)
+}
+
+// decideEdit decides whether the caller may change the roles and the email
+// of the user resource. It responds and returns false if no decision could
+// be made.
+func decideEdit(w http.ResponseWriter, r *http.Request, authorizer authz.Authorizer, resource authz.Resource) (roles, email authz.Decision, ok bool) {
+	roles, ok = decide(w, r, authorizer, authz.ActionUserUpdateRoles, resource)
+	if !ok {
+		return roles, email, false
+	}
+	email, ok = decide(w, r, authorizer, authz.ActionUserUpdateEmail, resource)
+	return roles, email, ok
+}
+
+// editRightsOf returns what the decisions of decideEdit allow changing.
+func editRightsOf(roles, email authz.Decision) model.UserEditRights {
+	return model.UserEditRights{Roles: roles.Allowed, Email: email.Allowed}
+}
+
+// writeUserEditError responds to an error from updating or patching a user.
+// rolesDecision and emailDecision explain why the caller may not change the
+// user's roles or email.
+func writeUserEditError(w http.ResponseWriter, err error, rolesDecision, emailDecision authz.Decision) {
+	switch {
+	case errors.Is(err, ierr.ErrUserNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ierr.ErrRolesNotAllowed):
+		authz.WriteForbidden(w, authz.ActionUserUpdateRoles, rolesDecision)
+	case errors.Is(err, ierr.ErrEmailNotAllowed):
+		authz.WriteForbidden(w, authz.ActionUserUpdateEmail, emailDecision)
+	case errors.Is(err, ierr.ErrInvalidPatch), errors.Is(err, ierr.ErrInvalidUser), errors.Is(err, ierr.ErrInvalidRole):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ierr.ErrPatchTestFailed), errors.Is(err, ierr.ErrUserAlreadyExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ierr.ErrVersionMismatch):
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+	default:
+		writeServerError(w, err)
+	}
+}
+
+// DeleteUser handles the HTTP request for deleting a user. The If-Match
+// header must hold the user's current ETag.
 func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 	id, err := uuid.Parse(idStr)
@@ -107,29 +218,171 @@ func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid user ID", http.StatusBadRequest)
 		return
 	}
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
 
-	err = h.service.DeleteUser(r.Context(), id)
+	err = h.service.DeleteUser(r.Context(), id, version)
 	if err != nil {
-		if err == ierr.ErrUserNotFound {
+		if errors.Is(err, ierr.ErrUserNotFound) {
 			http.Error(w, err.Error(), http.StatusNotFound)
 			return
 		}
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		if errors.Is(err, ierr.ErrVersionMismatch) {
+			http.Error(w, err.Error(), http.StatusPreconditionFailed)
+			return
+		}
+		writeServerError(w, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// ListUsers handles the HTTP request for listing all users.
+// RestoreUser handles the HTTP request for restoring a deleted user.
+func (h *UserHandler) RestoreUser(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.service.RestoreUser(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, ierr.ErrUserNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, ierr.ErrUserAlreadyExists):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			writeServerError(w, err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	setUserETag(w, user)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(user)
+}
+
+// ListDeletedUsers handles the HTTP request for listing deleted users a page
+// at a time, most recently deleted first by default.
+func (h *UserHandler) ListDeletedUsers(w http.ResponseWriter, r *http.Request) {
+	req, err := parseUserListRequest(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.Deleted = true
+	if r.URL.Query().Get("sort") == "" {
+		req.Sort = model.UserSortDeletedAt
+	}
+	h.writeUserPage(w, r, req)
+}
+
+// ListUsers handles the HTTP request for listing users a page at a time.
 func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
-	users, err := h.service.ListUsers(r.Context())
+	req, err := parseUserListRequest(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.writeUserPage(w, r, req)
+}
+
+// writeUserPage responds with the page of users requested.
+func (h *UserHandler) writeUserPage(w http.ResponseWriter, r *http.Request, req *model.UserListRequest) {
+	page, err := h.service.ListUsers(r.Context(), req)
+	if err != nil {
+		if errors.Is(err, ierr.ErrInvalidSort) || errors.Is(err, ierr.ErrInvalidCursor) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(page)
+}
+
+// SearchUsers handles the HTTP request for searching users by name and
+// email.
+func (h *UserHandler) SearchUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit, err := parseLimit(query)
 	if err != nil {
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req := &model.UserSearchRequest{Query: query.Get("q"), Limit: limit, Cursor: query.Get("cursor")}
+
+	page, err := h.service.SearchUsers(r.Context(), req)
+	if err != nil {
+		if errors.Is(err, ierr.ErrInvalidSearch) || errors.Is(err, ierr.ErrInvalidCursor) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeServerError(w, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(users)
+	json.NewEncoder(w).Encode(page)
+}
+
+// parseUserListRequest reads the query parameters of GET /users. A sort
+// field prefixed with "-" sorts in descending order; the default is
+// "-created_at", newest first.
+func parseUserListRequest(query url.Values) (*model.UserListRequest, error) {
+	req := &model.UserListRequest{
+		Sort:        model.UserSortCreatedAt,
+		Desc:        true,
+		EmailDomain: strings.TrimPrefix(query.Get("email_domain"), "@"),
+		Cursor:      query.Get("cursor"),
+	}
+	if sort := query.Get("sort"); sort != "" {
+		req.Sort, req.Desc = strings.TrimPrefix(sort, "-"), strings.HasPrefix(sort, "-")
+	}
+	limit, err := parseLimit(query)
+	if err != nil {
+		return nil, err
+	}
+	req.Limit = limit
+	if v := query.Get("is_active"); v != "" {
+		active, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("is_active must be true or false")
+		}
+		req.IsActive = &active
+	}
+	for name, dst := range map[string]**time.Time{"created_after": &req.CreatedAfter, "created_before": &req.CreatedBefore} {
+		if v := query.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return nil, fmt.Errorf("%s must be an RFC 3339 timestamp", name)
+			}
+			*dst = &t
+		}
+	}
+	return req, nil
+}
+
+// parseLimit reads the page size from the limit query parameter. It returns
+// 0, for the default, when there is none.
+func parseLimit(query url.Values) (int, error) {
+	v := query.Get("limit")
+	if v == "" {
+		return 0, nil
+	}
+	limit, err := strconv.Atoi(v)
+	if err != nil || limit < 1 {
+		return 0, fmt.Errorf("limit must be a positive integer")
+	}
+	return limit, nil
 }
@@ -2,13 +2,20 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/faizalom/go-api/internal/authz"
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/middleware"
 	"github.com/faizalom/go-api/internal/model"
 	"github.com/faizalom/go-api/internal/service/mocks"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -16,7 +23,7 @@ import (
 
 func TestUserHandler_CreateUser(t *testing.T) {
 	mockUserService := new(mocks.MockUserService)
-	userHandler := NewUserHandler(mockUserService)
+	userHandler := NewUserHandler(mockUserService, testAuthorizer)
 
 	reqBody := &model.NewUserRequest{
 		Name:     "test user",
@@ -40,9 +47,49 @@ func TestUserHandler_CreateUser(t *testing.T) {
 	mockUserService.AssertExpectations(t)
 }
 
+func TestUserHandler_CreateUser_WeakPassword(t *testing.T) {
+	mockUserService := new(mocks.MockUserService)
+	userHandler := NewUserHandler(mockUserService, testAuthorizer)
+
+	jsonBody, _ := json.Marshal(&model.NewUserRequest{Name: "test user", Email: "test@example.com", Password: "short"})
+	req, err := http.NewRequest("POST", "/users", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockUserService.On("CreateUser", mock.Anything, mock.AnythingOfType("*model.NewUserRequest")).Return((*model.User)(nil), ierr.ErrWeakPassword)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(userHandler.CreateUser)
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), ierr.ErrWeakPassword.Error())
+}
+
+func TestUserHandler_CreateUser_Invalid(t *testing.T) {
+	mockUserService := new(mocks.MockUserService)
+	userHandler := NewUserHandler(mockUserService, testAuthorizer)
+
+	jsonBody, _ := json.Marshal(&model.NewUserRequest{Email: "test@example.com", Password: "password123"})
+	req, err := http.NewRequest("POST", "/users", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockUserService.On("CreateUser", mock.Anything, mock.AnythingOfType("*model.NewUserRequest")).Return((*model.User)(nil), fmt.Errorf("%w: name is required", ierr.ErrInvalidUser))
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(userHandler.CreateUser)
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "name is required")
+}
+
 func TestUserHandler_GetUserByID(t *testing.T) {
 	mockUserService := new(mocks.MockUserService)
-	userHandler := NewUserHandler(mockUserService)
+	userHandler := NewUserHandler(mockUserService, testAuthorizer)
 
 	userID := uuid.New()
 	req, err := http.NewRequest("GET", "/users/"+userID.String(), nil)
@@ -51,24 +98,59 @@ func TestUserHandler_GetUserByID(t *testing.T) {
 	}
 	req.SetPathValue("id", userID.String())
 
-	mockUserService.On("GetUserByID", mock.Anything, userID).Return(&model.User{}, nil)
+	mockUserService.On("GetUserByID", mock.Anything, userID).Return(&model.User{Version: 7}, nil)
 
 	rr := httptest.NewRecorder()
 	handler := http.HandlerFunc(userHandler.GetUserByID)
 	handler.ServeHTTP(rr, req)
 
 	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, `"7"`, rr.Header().Get("ETag"))
 	mockUserService.AssertExpectations(t)
 }
 
+func TestUserHandler_GetUserByID_Errors(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		wantCode       int
+		wantRetryAfter string
+	}{
+		{"not found", ierr.ErrUserNotFound, http.StatusNotFound, ""},
+		{"timeout", &ierr.DBError{Kind: ierr.ErrQueryTimeout, Err: assert.AnError}, http.StatusServiceUnavailable, "1"},
+		{"serialization failure", &ierr.DBError{Kind: ierr.ErrSerializationFailure, Err: assert.AnError}, http.StatusServiceUnavailable, "1"},
+		{"other", assert.AnError, http.StatusInternalServerError, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockUserService := new(mocks.MockUserService)
+			userHandler := NewUserHandler(mockUserService, testAuthorizer)
+
+			userID := uuid.New()
+			req := httptest.NewRequest("GET", "/users/"+userID.String(), nil)
+			req.SetPathValue("id", userID.String())
+
+			mockUserService.On("GetUserByID", mock.Anything, userID).Return((*model.User)(nil), tt.err)
+
+			rr := httptest.NewRecorder()
+			userHandler.GetUserByID(rr, req)
+
+			assert.Equal(t, tt.wantCode, rr.Code)
+			assert.Equal(t, tt.wantRetryAfter, rr.Header().Get("Retry-After"))
+		})
+	}
+}
+
 func TestUserHandler_UpdateUser(t *testing.T) {
 	mockUserService := new(mocks.MockUserService)
-	userHandler := NewUserHandler(mockUserService)
+	userHandler := NewUserHandler(mockUserService, testAuthorizer)
 
 	userID := uuid.New()
 	reqBody := &model.UpdateUserRequest{
-		Name:  stringPtr("updated name"),
-		Email: stringPtr("updated@example.com"),
+		Name:  "updated name",
+		Email: "updated@example.com",
+		Roles: []string{model.RoleAthlete},
 	}
 	jsonBody, _ := json.Marshal(reqBody)
 
@@ -77,20 +159,141 @@ func TestUserHandler_UpdateUser(t *testing.T) {
 		t.Fatal(err)
 	}
 	req.SetPathValue("id", userID.String())
+	req.Header.Set("If-Match", `"7"`)
 
-	mockUserService.On("UpdateUser", mock.Anything, userID, mock.AnythingOfType("*model.UpdateUserRequest")).Return(&model.User{}, nil)
+	mockUserService.On("UpdateUser", mock.Anything, userID, 7, mock.AnythingOfType("*model.UpdateUserRequest"), model.UserEditRights{}).Return(&model.User{Version: 8}, nil)
 
 	rr := httptest.NewRecorder()
 	handler := http.HandlerFunc(userHandler.UpdateUser)
 	handler.ServeHTTP(rr, req)
 
 	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, `"8"`, rr.Header().Get("ETag"))
 	mockUserService.AssertExpectations(t)
 }
 
+func TestUserHandler_UpdateUser_RolesRequireAdmin(t *testing.T) {
+	mockUserService := new(mocks.MockUserService)
+	userHandler := NewUserHandler(mockUserService, testAuthorizer)
+
+	userID := uuid.New()
+	jsonBody, _ := json.Marshal(&model.UpdateUserRequest{Name: "name", Email: "user@example.com", Roles: []string{model.RoleAdmin}})
+
+	req, err := http.NewRequest("PUT", "/users/"+userID.String(), bytes.NewBuffer(jsonBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("id", userID.String())
+	req.Header.Set("If-Match", `"1"`)
+	claims := &model.CustomClaims{
+		Roles:            []string{model.RoleAthlete},
+		RegisteredClaims: jwt.RegisteredClaims{Subject: userID.String()},
+	}
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserClaimsKey, claims))
+
+	// Only the service knows whether the roles actually change.
+	mockUserService.On("UpdateUser", mock.Anything, userID, 1, mock.AnythingOfType("*model.UpdateUserRequest"), model.UserEditRights{Email: true}).Return(nil, ierr.ErrRolesNotAllowed)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(userHandler.UpdateUser)
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.JSONEq(t, `{"error":"forbidden","reason":"no_matching_rule","action":"user:update_roles"}`, rr.Body.String())
+	mockUserService.AssertExpectations(t)
+}
+
+func TestUserHandler_UpdateUser_EmailWhileImpersonating(t *testing.T) {
+	mockUserService := new(mocks.MockUserService)
+	userHandler := NewUserHandler(mockUserService, testAuthorizer)
+
+	userID := uuid.New()
+	jsonBody, _ := json.Marshal(&model.UpdateUserRequest{Name: "name", Email: "attacker@example.com", Roles: []string{model.RoleAthlete}})
+
+	req := httptest.NewRequest("PUT", "/users/"+userID.String(), bytes.NewBuffer(jsonBody))
+	req.SetPathValue("id", userID.String())
+	req.Header.Set("If-Match", `"1"`)
+	claims := &model.CustomClaims{
+		Roles:            []string{model.RoleAthlete},
+		Actor:            &model.ActorClaim{Subject: uuid.NewString()},
+		RegisteredClaims: jwt.RegisteredClaims{Subject: userID.String()},
+	}
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserClaimsKey, claims))
+
+	mockUserService.On("UpdateUser", mock.Anything, userID, 1, mock.AnythingOfType("*model.UpdateUserRequest"), model.UserEditRights{}).Return(nil, ierr.ErrEmailNotAllowed)
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(userHandler.UpdateUser).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.JSONEq(t, `{"error":"forbidden","reason":"impersonated","action":"user:update_email"}`, rr.Body.String())
+	mockUserService.AssertExpectations(t)
+}
+
+func TestUserHandler_PatchUser(t *testing.T) {
+	mockUserService := new(mocks.MockUserService)
+	userHandler := NewUserHandler(mockUserService, testAuthorizer)
+
+	userID := uuid.New()
+	patch := &model.UserPatch{Type: model.MergePatchType, Patch: []byte(`{"name":"new name"}`)}
+	mockUserService.On("PatchUser", mock.Anything, userID, 4, patch, model.UserEditRights{}).Return(&model.User{Name: "new name", Version: 5}, nil)
+
+	req := httptest.NewRequest("PATCH", "/users/"+userID.String(), bytes.NewBufferString(`{"name":"new name"}`))
+	req.SetPathValue("id", userID.String())
+	req.Header.Set("Content-Type", "application/merge-patch+json; charset=utf-8")
+	req.Header.Set("If-Match", `"4"`)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(userHandler.PatchUser)
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, `"5"`, rr.Header().Get("ETag"))
+	mockUserService.AssertExpectations(t)
+}
+
+func TestUserHandler_PatchUser_Errors(t *testing.T) {
+	mockUserService := new(mocks.MockUserService)
+	userHandler := NewUserHandler(mockUserService, testAuthorizer)
+
+	userID := uuid.New()
+	mockUserService.On("PatchUser", mock.Anything, userID, 1, mock.Anything, model.UserEditRights{}).Return(nil, ierr.ErrInvalidPatch)
+	mockUserService.On("PatchUser", mock.Anything, userID, 2, mock.Anything, model.UserEditRights{}).Return(nil, ierr.ErrPatchTestFailed)
+	mockUserService.On("PatchUser", mock.Anything, userID, 3, mock.Anything, model.UserEditRights{}).Return(nil, ierr.ErrVersionMismatch)
+
+	tests := []struct {
+		name        string
+		contentType string
+		ifMatch     string
+		code        int
+	}{
+		{"unsupported content type", "application/json", `"1"`, http.StatusUnsupportedMediaType},
+		{"without If-Match", model.JSONPatchType, "", http.StatusPreconditionRequired},
+		{"invalid patch", model.JSONPatchType, `"1"`, http.StatusBadRequest},
+		{"test failed", model.JSONPatchType, `"2"`, http.StatusConflict},
+		{"stale ETag", model.MergePatchType, `"3"`, http.StatusPreconditionFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("PATCH", "/users/"+userID.String(), bytes.NewBufferString(`[]`))
+			req.SetPathValue("id", userID.String())
+			req.Header.Set("Content-Type", tt.contentType)
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			rr := httptest.NewRecorder()
+			userHandler.PatchUser(rr, req)
+			assert.Equal(t, tt.code, rr.Code)
+			if tt.code == http.StatusUnsupportedMediaType {
+				assert.Equal(t, "application/merge-patch+json, application/json-patch+json", rr.Header().Get("Accept-Patch"))
+			}
+		})
+	}
+}
+
 func TestUserHandler_DeleteUser(t *testing.T) {
 	mockUserService := new(mocks.MockUserService)
-	userHandler := NewUserHandler(mockUserService)
+	userHandler := NewUserHandler(mockUserService, testAuthorizer)
 
 	userID := uuid.New()
 	req, err := http.NewRequest("DELETE", "/users/"+userID.String(), nil)
@@ -98,8 +301,9 @@ func TestUserHandler_DeleteUser(t *testing.T) {
 		t.Fatal(err)
 	}
 	req.SetPathValue("id", userID.String())
+	req.Header.Set("If-Match", `"3"`)
 
-	mockUserService.On("DeleteUser", mock.Anything, userID).Return(nil)
+	mockUserService.On("DeleteUser", mock.Anything, userID, 3).Return(nil)
 
 	rr := httptest.NewRecorder()
 	handler := http.HandlerFunc(userHandler.DeleteUser)
@@ -111,23 +315,165 @@ func TestUserHandler_DeleteUser(t *testing.T) {
 
 func TestUserHandler_ListUsers(t *testing.T) {
 	mockUserService := new(mocks.MockUserService)
-	userHandler := NewUserHandler(mockUserService)
+	userHandler := NewUserHandler(mockUserService, testAuthorizer)
 
-	req, err := http.NewRequest("GET", "/users", nil)
+	req, err := http.NewRequest("GET", "/users?limit=20&cursor=abc&sort=email&is_active=false&email_domain=@Example.com&created_after=2024-01-01T00:00:00Z", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	mockUserService.On("ListUsers", mock.Anything).Return([]*model.User{}, nil)
+	after := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	inactive := false
+	want := &model.UserListRequest{
+		IsActive:     &inactive,
+		EmailDomain:  "Example.com",
+		CreatedAfter: &after,
+		Sort:         model.UserSortEmail,
+		Limit:        20,
+		Cursor:       "abc",
+	}
+	mockUserService.On("ListUsers", mock.Anything, want).Return(&model.UserPage{Users: []*model.User{}, NextCursor: "next"}, nil)
 
 	rr := httptest.NewRecorder()
 	handler := http.HandlerFunc(userHandler.ListUsers)
 	handler.ServeHTTP(rr, req)
 
 	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"users": [], "next_cursor": "next"}`, rr.Body.String())
+	mockUserService.AssertExpectations(t)
+}
+
+func TestUserHandler_ListUsers_BadRequest(t *testing.T) {
+	mockUserService := new(mocks.MockUserService)
+	userHandler := NewUserHandler(mockUserService, testAuthorizer)
+
+	for _, query := range []string{"limit=0", "limit=ten", "is_active=maybe", "created_before=yesterday"} {
+		t.Run(query, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/users?"+query, nil)
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(userHandler.ListUsers).ServeHTTP(rr, req)
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+		})
+	}
+
+	mockUserService.On("ListUsers", mock.Anything, mock.Anything).Return(nil, ierr.ErrInvalidSort)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(userHandler.ListUsers).ServeHTTP(rr, httptest.NewRequest("GET", "/users?sort=password_hash", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestUserHandler_Preconditions(t *testing.T) {
+	mockUserService := new(mocks.MockUserService)
+	userHandler := NewUserHandler(mockUserService, testAuthorizer)
+
+	userID := uuid.New()
+	mockUserService.On("UpdateUser", mock.Anything, userID, 3, mock.Anything, model.UserEditRights{}).Return(nil, ierr.ErrVersionMismatch)
+	mockUserService.On("UpdateUser", mock.Anything, userID, -1, mock.Anything, model.UserEditRights{}).Return(nil, ierr.ErrVersionMismatch)
+	mockUserService.On("DeleteUser", mock.Anything, userID, 3).Return(ierr.ErrVersionMismatch)
+	mockUserService.On("UpdateUser", mock.Anything, userID, model.AnyVersion, mock.Anything, model.UserEditRights{}).Return(&model.User{ID: userID, Version: 4}, nil)
+	mockUserService.On("DeleteUser", mock.Anything, userID, model.AnyVersion).Return(nil)
+
+	tests := []struct {
+		name    string
+		method  string
+		handler http.HandlerFunc
+		ifMatch string
+		code    int
+	}{
+		{"update without If-Match", "PUT", userHandler.UpdateUser, "", http.StatusPreconditionRequired},
+		{"update with stale ETag", "PUT", userHandler.UpdateUser, `"3"`, http.StatusPreconditionFailed},
+		{"update with weak ETag", "PUT", userHandler.UpdateUser, `W/"3"`, http.StatusPreconditionFailed},
+		{"delete without If-Match", "DELETE", userHandler.DeleteUser, "", http.StatusPreconditionRequired},
+		{"delete with stale ETag", "DELETE", userHandler.DeleteUser, `"3"`, http.StatusPreconditionFailed},
+		{"update with any ETag", "PUT", userHandler.UpdateUser, `*`, http.StatusOK},
+		{"delete with any ETag", "DELETE", userHandler.DeleteUser, `*`, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/users/"+userID.String(), bytes.NewBufferString(`{"name":"new"}`))
+			req.SetPathValue("id", userID.String())
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			rr := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rr, req)
+			assert.Equal(t, tt.code, rr.Code)
+		})
+	}
+}
+
+// testAuthorizer enforces the policy shipped in configs/policies.yaml.
+var testAuthorizer = func() authz.Authorizer {
+	policy, err := authz.LoadPolicy("../../configs/policies.yaml")
+	if err != nil {
+		panic(err)
+	}
+	return authz.NewPolicyAuthorizer(policy, nil)
+}()
+
+func TestUserHandler_SearchUsers(t *testing.T) {
+	mockUserService := new(mocks.MockUserService)
+	userHandler := NewUserHandler(mockUserService, testAuthorizer)
+
+	id := uuid.New()
+	page := &model.UserSearchPage{Results: []*model.UserSearchResult{
+		{User: &model.User{ID: id, Name: "Ann"}, Rank: 0.5, Highlights: model.UserHighlights{Name: "<mark>Ann</mark>"}},
+	}}
+	mockUserService.On("SearchUsers", mock.Anything, &model.UserSearchRequest{Query: "ann", Limit: 5}).Return(page, nil)
+	mockUserService.On("SearchUsers", mock.Anything, &model.UserSearchRequest{}).Return(nil, ierr.ErrInvalidSearch)
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(userHandler.SearchUsers).ServeHTTP(rr, httptest.NewRequest("GET", "/users/search?q=ann&limit=5", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var body model.UserSearchPage
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+	assert.Equal(t, id, body.Results[0].User.ID)
+	assert.Equal(t, "<mark>Ann</mark>", body.Results[0].Highlights.Name)
+
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(userHandler.SearchUsers).ServeHTTP(rr, httptest.NewRequest("GET", "/users/search", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
 	mockUserService.AssertExpectations(t)
 }
 
-func stringPtr(s string) *string {
-	return &s
+func TestUserHandler_RestoreUser(t *testing.T) {
+	mockUserService := new(mocks.MockUserService)
+	userHandler := NewUserHandler(mockUserService, testAuthorizer)
+
+	restored, taken, missing := uuid.New(), uuid.New(), uuid.New()
+	mockUserService.On("RestoreUser", mock.Anything, restored).Return(&model.User{ID: restored}, nil)
+	mockUserService.On("RestoreUser", mock.Anything, taken).Return(nil, ierr.ErrUserAlreadyExists)
+	mockUserService.On("RestoreUser", mock.Anything, missing).Return(nil, ierr.ErrUserNotFound)
+
+	tests := []struct {
+		id   string
+		code int
+	}{
+		{restored.String(), http.StatusOK},
+		{taken.String(), http.StatusConflict},
+		{missing.String(), http.StatusNotFound},
+		{"not-a-uuid", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/users/"+tt.id+"/restore", nil)
+		req.SetPathValue("id", tt.id)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(userHandler.RestoreUser).ServeHTTP(rr, req)
+		assert.Equal(t, tt.code, rr.Code, tt.id)
+	}
+}
+
+func TestUserHandler_ListDeletedUsers(t *testing.T) {
+	mockUserService := new(mocks.MockUserService)
+	userHandler := NewUserHandler(mockUserService, testAuthorizer)
+
+	mockUserService.On("ListUsers", mock.Anything, &model.UserListRequest{Deleted: true, Sort: model.UserSortDeletedAt, Desc: true}).
+		Return(&model.UserPage{Users: []*model.User{}}, nil)
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(userHandler.ListDeletedUsers).ServeHTTP(rr, httptest.NewRequest("GET", "/users/deleted", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	mockUserService.AssertExpectations(t)
 }
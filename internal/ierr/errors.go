@@ -1,8 +1,93 @@
 package ierr
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
 
 var (
-	ErrUserAlreadyExists = errors.New("user with this email already exists")
-	ErrUserNotFound      = errors.New("user not found")
+	ErrUserAlreadyExists  = errors.New("user with this email already exists")
+	ErrUserNotFound       = errors.New("user not found")
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	ErrUserInactive       = errors.New("user account is inactive")
+	ErrInvalidRole        = errors.New("unknown role")
+	ErrVersionMismatch    = errors.New("user has changed since it was read")
+	ErrInvalidUser        = errors.New("invalid user")
+	ErrRolesNotAllowed    = errors.New("roles can only be changed by admins")
+	ErrEmailNotAllowed    = errors.New("email cannot be changed by this caller")
+	ErrInvalidPatch       = errors.New("invalid patch")
+	ErrPatchTestFailed    = errors.New("patch test operation failed")
+
+	ErrInvalidSort   = errors.New("unknown sort field")
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidSearch = errors.New("search query must be 1 to 200 characters")
+	ErrInvalidImport = errors.New("invalid import")
+	ErrInvalidBatch  = errors.New("invalid batch")
+
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+	ErrInvalidRefreshToken  = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused   = errors.New("refresh token has already been used")
+
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrInvalidAPIKey  = errors.New("invalid, expired or revoked api key")
+	ErrInvalidScope   = errors.New("unknown scope")
+
+	ErrIdentityNotFound   = errors.New("identity not found")
+	ErrIdentityLinked     = errors.New("identity is already linked to a user")
+	ErrIdentityNoEmail    = errors.New("identity provider did not return an email address")
+	ErrIdentityUnverified = errors.New("an account with this email already exists and the provider has not verified the email")
+
+	ErrResetTokenNotFound = errors.New("password reset token not found")
+	ErrInvalidResetToken  = errors.New("invalid or expired password reset token")
+
+	ErrWeakPassword      = errors.New("password does not meet the password policy")
+	ErrIncorrectPassword = errors.New("current password is incorrect")
+
+	ErrVerificationTokenNotFound = errors.New("email verification token not found")
+	ErrInvalidVerificationToken  = errors.New("invalid or expired email verification token")
+	ErrEmailNotVerified          = errors.New("email address has not been verified")
+
+	ErrMFANotEnrolled       = errors.New("two-factor authentication is not enabled")
+	ErrMFAAlreadyEnabled    = errors.New("two-factor authentication is already enabled")
+	ErrInvalidMFACode       = errors.New("invalid two-factor authentication code")
+	ErrMFAChallengeNotFound = errors.New("mfa challenge not found")
+	ErrInvalidMFAToken      = errors.New("invalid or expired mfa token")
+
+	ErrTooManyLoginAttempts = errors.New("too many failed login attempts, try again later")
+
+	ErrSessionNotFound = errors.New("session not found")
+
+	ErrImpersonationNotAllowed = errors.New("impersonation is not allowed for this caller or user")
+
+	// Database errors, which the repositories wrap in a DBError.
+	ErrUniqueViolation      = errors.New("value already exists")
+	ErrForeignKeyViolation  = errors.New("referenced row does not exist")
+	ErrCheckViolation       = errors.New("value violates a check constraint")
+	ErrNotNullViolation     = errors.New("required value is missing")
+	ErrSerializationFailure = errors.New("transaction conflicted with a concurrent one")
+	ErrQueryTimeout         = errors.New("database query timed out")
 )
+
+// RetryAfterError tells the caller how long to wait before trying again.
+type RetryAfterError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *RetryAfterError) Error() string { return e.Err.Error() }
+
+func (e *RetryAfterError) Unwrap() error { return e.Err }
+
+// DBError is a database error of the kind Kind, one of the database errors
+// above. Constraint names the constraint it violates, if any, and Err is the
+// driver's error.
+type DBError struct {
+	Kind       error
+	Constraint string
+	Err        error
+}
+
+func (e *DBError) Error() string { return fmt.Sprintf("%v: %v", e.Kind, e.Err) }
+
+func (e *DBError) Unwrap() []error { return []error{e.Kind, e.Err} }
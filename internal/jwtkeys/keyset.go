@@ -0,0 +1,243 @@
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/faizalom/go-api/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrUnknownKeyID       = errors.New("unknown signing key id")
+	ErrUnexpectedAlg      = errors.New("unexpected signing algorithm")
+	ErrUnsupportedAlg     = errors.New("unsupported signing algorithm")
+	ErrMissingSigningKey  = errors.New("signing key has no private key")
+	ErrNoVerificationKeys = errors.New("no jwt secret or keys configured")
+)
+
+// Key is a single signing or verification key.
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+	// Private is nil for keys that are only kept around for verification.
+	Private crypto.PrivateKey
+	Public  crypto.PublicKey
+}
+
+// KeySet signs new tokens with one key and verifies tokens against any of
+// the configured keys, selected by the "kid" header. Without asymmetric keys
+// it falls back to HS256 with the shared secret.
+type KeySet struct {
+	signing *Key
+	keys    map[string]*Key
+	secret  []byte
+}
+
+// NewHMAC returns a KeySet that signs and verifies with HS256 and a shared secret.
+func NewHMAC(secret string) *KeySet {
+	return &KeySet{secret: []byte(secret)}
+}
+
+// New builds a KeySet from already parsed keys. signingKeyID must name a key
+// that has a private key.
+func New(signingKeyID string, keys ...*Key) (*KeySet, error) {
+	ks := &KeySet{keys: make(map[string]*Key, len(keys))}
+	for _, k := range keys {
+		ks.keys[k.ID] = k
+	}
+
+	signing, ok := ks.keys[signingKeyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKeyID, signingKeyID)
+	}
+	if signing.Private == nil {
+		return nil, fmt.Errorf("%w: %q", ErrMissingSigningKey, signingKeyID)
+	}
+	ks.signing = signing
+
+	return ks, nil
+}
+
+// Load builds a KeySet from the JWT configuration, reading PEM files from disk.
+func Load(cfg config.JWTConfig) (*KeySet, error) {
+	if len(cfg.Keys) == 0 {
+		if cfg.Secret == "" {
+			return nil, ErrNoVerificationKeys
+		}
+		return NewHMAC(cfg.Secret), nil
+	}
+
+	keys := make([]*Key, 0, len(cfg.Keys))
+	for _, kc := range cfg.Keys {
+		k, err := loadKey(kc)
+		if err != nil {
+			return nil, fmt.Errorf("loading jwt key %q: %w", kc.ID, err)
+		}
+		keys = append(keys, k)
+	}
+
+	return New(cfg.SigningKeyID, keys...)
+}
+
+// Sign serializes the claims into a token signed with the current signing key.
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	if ks.signing == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(ks.secret)
+	}
+
+	token := jwt.NewWithClaims(ks.signing.Method, claims)
+	token.Header["kid"] = ks.signing.ID
+	return token.SignedString(ks.signing.Private)
+}
+
+// Keyfunc returns the key a token should be verified with. It is meant to be
+// passed to jwt.Parse and friends.
+func (ks *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	if ks.signing == nil {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, ErrUnexpectedAlg
+		}
+		return ks.secret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	k, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKeyID, kid)
+	}
+	if token.Method.Alg() != k.Method.Alg() {
+		return nil, ErrUnexpectedAlg
+	}
+	return k.Public, nil
+}
+
+// Algorithms lists the algorithms tokens may be signed with, for jwt.WithValidMethods.
+func (ks *KeySet) Algorithms() []string {
+	if ks.signing == nil {
+		return []string{jwt.SigningMethodHS256.Alg()}
+	}
+
+	seen := make(map[string]bool)
+	var algs []string
+	for _, k := range ks.keys {
+		if alg := k.Method.Alg(); !seen[alg] {
+			seen[alg] = true
+			algs = append(algs, alg)
+		}
+	}
+	return algs
+}
+
+// loadKey reads and parses the PEM files of a configured key.
+func loadKey(kc config.JWTKey) (*Key, error) {
+	k := &Key{ID: kc.ID}
+
+	switch kc.Algorithm {
+	case "RS256":
+		k.Method = jwt.SigningMethodRS256
+	case "ES256":
+		k.Method = jwt.SigningMethodES256
+	case "EdDSA":
+		k.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedAlg, kc.Algorithm)
+	}
+
+	if kc.PrivateKeyFile != "" {
+		data, err := os.ReadFile(kc.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if err := k.parsePrivate(data); err != nil {
+			return nil, err
+		}
+	}
+
+	if kc.PublicKeyFile != "" {
+		data, err := os.ReadFile(kc.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if err := k.parsePublic(data); err != nil {
+			return nil, err
+		}
+	}
+
+	if k.Public == nil {
+		return nil, errors.New("either private_key_file or public_key_file is required")
+	}
+
+	return k, nil
+}
+
+func (k *Key) parsePrivate(data []byte) error {
+	switch k.Method {
+	case jwt.SigningMethodRS256:
+		priv, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+		if err != nil {
+			return err
+		}
+		k.Private, k.Public = priv, &priv.PublicKey
+	case jwt.SigningMethodES256:
+		priv, err := jwt.ParseECPrivateKeyFromPEM(data)
+		if err != nil {
+			return err
+		}
+		if priv.Curve != elliptic.P256() {
+			return errors.New("ES256 requires a P-256 key")
+		}
+		k.Private, k.Public = priv, &priv.PublicKey
+	default:
+		parsed, err := jwt.ParseEdPrivateKeyFromPEM(data)
+		if err != nil {
+			return err
+		}
+		priv, ok := parsed.(ed25519.PrivateKey)
+		if !ok {
+			return errors.New("EdDSA requires an Ed25519 key")
+		}
+		k.Private, k.Public = priv, priv.Public()
+	}
+	return nil
+}
+
+func (k *Key) parsePublic(data []byte) error {
+	var (
+		pub crypto.PublicKey
+		err error
+	)
+	switch k.Method {
+	case jwt.SigningMethodRS256:
+		pub, err = jwt.ParseRSAPublicKeyFromPEM(data)
+	case jwt.SigningMethodES256:
+		var ecPub *ecdsa.PublicKey
+		ecPub, err = jwt.ParseECPublicKeyFromPEM(data)
+		if err == nil && ecPub.Curve != elliptic.P256() {
+			err = errors.New("ES256 requires a P-256 key")
+		}
+		pub = ecPub
+	default:
+		pub, err = jwt.ParseEdPublicKeyFromPEM(data)
+	}
+	if err != nil {
+		return err
+	}
+
+	if k.Public != nil && !publicKeysEqual(k.Public, pub) {
+		return errors.New("public key does not match private key")
+	}
+	k.Public = pub
+	return nil
+}
+
+func publicKeysEqual(a, b crypto.PublicKey) bool {
+	eq, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && eq.Equal(b)
+}
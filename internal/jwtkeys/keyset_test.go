@@ -0,0 +1,188 @@
+package jwtkeys
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/faizalom/go-api/internal/config"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeKeyPair writes a PKCS#8 private key and a PKIX public key to dir.
+func writeKeyPair(t *testing.T, dir, name string, priv interface{}, pub interface{}) (string, string) {
+	t.Helper()
+
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	require.NoError(t, err)
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	require.NoError(t, err)
+
+	privPath := filepath.Join(dir, name+".pem")
+	pubPath := filepath.Join(dir, name+".pub.pem")
+	require.NoError(t, os.WriteFile(privPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}), 0o600))
+	require.NoError(t, os.WriteFile(pubPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), 0o644))
+	return privPath, pubPath
+}
+
+func parse(ks *KeySet, tokenString string) error {
+	_, err := jwt.Parse(tokenString, ks.Keyfunc, jwt.WithValidMethods(ks.Algorithms()))
+	return err
+}
+
+func TestLoad_Algorithms(t *testing.T) {
+	dir := t.TempDir()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	edPub, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	rsaPriv, _ := writeKeyPair(t, dir, "rsa", rsaKey, &rsaKey.PublicKey)
+	ecPriv, _ := writeKeyPair(t, dir, "ec", ecKey, &ecKey.PublicKey)
+	edPrivPath, _ := writeKeyPair(t, dir, "ed", edPriv, edPub)
+
+	tests := []struct {
+		alg  string
+		file string
+		kty  string
+	}{
+		{alg: "RS256", file: rsaPriv, kty: "RSA"},
+		{alg: "ES256", file: ecPriv, kty: "EC"},
+		{alg: "EdDSA", file: edPrivPath, kty: "OKP"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.alg, func(t *testing.T) {
+			ks, err := Load(config.JWTConfig{
+				SigningKeyID: "k1",
+				Keys:         []config.JWTKey{{ID: "k1", Algorithm: tt.alg, PrivateKeyFile: tt.file}},
+			})
+			require.NoError(t, err)
+
+			tokenString, err := ks.Sign(jwt.RegisteredClaims{Subject: "user"})
+			require.NoError(t, err)
+
+			token, _, err := jwt.NewParser().ParseUnverified(tokenString, &jwt.RegisteredClaims{})
+			require.NoError(t, err)
+			assert.Equal(t, "k1", token.Header["kid"])
+			assert.Equal(t, tt.alg, token.Method.Alg())
+			assert.NoError(t, parse(ks, tokenString))
+
+			jwks := ks.JWKS()
+			require.Len(t, jwks.Keys, 1)
+			assert.Equal(t, tt.kty, jwks.Keys[0].Kty)
+			assert.Equal(t, "k1", jwks.Keys[0].Kid)
+			assert.Equal(t, tt.alg, jwks.Keys[0].Alg)
+		})
+	}
+}
+
+func TestLoad_Rotation(t *testing.T) {
+	dir := t.TempDir()
+
+	oldPub, oldPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	newPub, newPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	oldPrivPath, oldPubPath := writeKeyPair(t, dir, "old", oldPriv, oldPub)
+	newPrivPath, _ := writeKeyPair(t, dir, "new", newPriv, newPub)
+
+	// Step 1: only the old key exists.
+	before, err := Load(config.JWTConfig{
+		SigningKeyID: "old",
+		Keys:         []config.JWTKey{{ID: "old", Algorithm: "EdDSA", PrivateKeyFile: oldPrivPath}},
+	})
+	require.NoError(t, err)
+	oldToken, err := before.Sign(jwt.RegisteredClaims{Subject: "user"})
+	require.NoError(t, err)
+
+	// Step 2: the new key signs, the old key is kept for verification only.
+	during, err := Load(config.JWTConfig{
+		SigningKeyID: "new",
+		Keys: []config.JWTKey{
+			{ID: "old", Algorithm: "EdDSA", PublicKeyFile: oldPubPath},
+			{ID: "new", Algorithm: "EdDSA", PrivateKeyFile: newPrivPath},
+		},
+	})
+	require.NoError(t, err)
+	newToken, err := during.Sign(jwt.RegisteredClaims{Subject: "user"})
+	require.NoError(t, err)
+	assert.NoError(t, parse(during, oldToken))
+	assert.NoError(t, parse(during, newToken))
+	assert.Len(t, during.JWKS().Keys, 2)
+
+	// Step 3: the old key is removed.
+	after, err := Load(config.JWTConfig{
+		SigningKeyID: "new",
+		Keys:         []config.JWTKey{{ID: "new", Algorithm: "EdDSA", PrivateKeyFile: newPrivPath}},
+	})
+	require.NoError(t, err)
+	assert.ErrorIs(t, parse(after, oldToken), ErrUnknownKeyID)
+	assert.NoError(t, parse(after, newToken))
+}
+
+func TestLoad_Errors(t *testing.T) {
+	dir := t.TempDir()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, pubPath := writeKeyPair(t, dir, "ed", priv, pub)
+
+	_, err = Load(config.JWTConfig{})
+	assert.ErrorIs(t, err, ErrNoVerificationKeys)
+
+	_, err = Load(config.JWTConfig{
+		SigningKeyID: "k1",
+		Keys:         []config.JWTKey{{ID: "k1", Algorithm: "HS512", PublicKeyFile: pubPath}},
+	})
+	assert.ErrorIs(t, err, ErrUnsupportedAlg)
+
+	_, err = Load(config.JWTConfig{
+		SigningKeyID: "k1",
+		Keys:         []config.JWTKey{{ID: "k1", Algorithm: "EdDSA", PublicKeyFile: pubPath}},
+	})
+	assert.ErrorIs(t, err, ErrMissingSigningKey)
+
+	_, err = Load(config.JWTConfig{
+		SigningKeyID: "missing",
+		Keys:         []config.JWTKey{{ID: "k1", Algorithm: "EdDSA", PublicKeyFile: pubPath}},
+	})
+	assert.ErrorIs(t, err, ErrUnknownKeyID)
+}
+
+func TestKeySet_RejectsHMACWhenAsymmetric(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	ks, err := New("k1", &Key{ID: "k1", Method: jwt.SigningMethodEdDSA, Private: priv, Public: pub})
+	require.NoError(t, err)
+
+	// A token signed with the public key bytes as an HMAC secret must not verify.
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{Subject: "user"})
+	token.Header["kid"] = "k1"
+	tokenString, err := token.SignedString([]byte(pub))
+	require.NoError(t, err)
+
+	assert.Error(t, parse(ks, tokenString))
+}
+
+func TestNewHMAC(t *testing.T) {
+	ks := NewHMAC("secret")
+
+	tokenString, err := ks.Sign(jwt.RegisteredClaims{Subject: "user"})
+	require.NoError(t, err)
+
+	assert.NoError(t, parse(ks, tokenString))
+	assert.Error(t, parse(NewHMAC("other"), tokenString))
+	assert.Empty(t, ks.JWKS().Keys)
+}
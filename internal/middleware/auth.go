@@ -2,10 +2,13 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strings"
 
 	"github.com/faizalom/go-api/internal/config"
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/jwtkeys"
 	"github.com/faizalom/go-api/internal/model"
 	"github.com/faizalom/go-api/pkg/logger"
 
@@ -16,47 +19,172 @@ type contextKey string
 
 const UserClaimsKey contextKey = "userClaims"
 
-// AuthMiddleware verifies the JWT token from the Authorization header.
-func AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// 1. Get the Authorization header
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			logger.Error.Println("Authorization header is missing")
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
-
-		// 2. The header should be in the format "Bearer <token>"
-		headerParts := strings.Split(authHeader, " ")
-		if len(headerParts) != 2 || strings.ToLower(headerParts[0]) != "bearer" {
-			logger.Error.Println("Authorization header format is not Bearer {token}")
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
-		tokenString := headerParts[1]
-
-		// 3. Parse and validate the token
-		claims := &model.CustomClaims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			// Check the signing method
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, http.ErrAbortHandler
-			}
-			// Use secret from config
-			return []byte(config.App.JWT.Secret), nil
+// RevocationChecker reports whether a token ID, or a session ID, has been
+// revoked before its expiry.
+type RevocationChecker interface {
+	IsRevoked(ctx context.Context, id string) (bool, error)
+}
+
+// APIKeyAuthenticator resolves an API key to the claims of the user it acts as.
+type APIKeyAuthenticator interface {
+	Authenticate(ctx context.Context, key string) (*model.CustomClaims, error)
+}
+
+// NewAuthMiddleware returns a middleware that verifies the JWT token from the
+// Authorization header against keys and rejects tokens that have been revoked,
+// or whose session (the sid claim) has been revoked.
+// Issuer, audience and leeway are taken from config.App.JWT. Machine clients
+// may instead send an API key as "Authorization: ApiKey <key>" or in the
+// X-API-Key header.
+func NewAuthMiddleware(keys *jwtkeys.KeySet, revocations, sessions RevocationChecker, apiKeys APIKeyAuthenticator) func(http.Handler) http.Handler {
+	parser := newParser(keys)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// 0. API keys populate the same claims as access tokens
+			if key := apiKeyFromRequest(r); key != "" {
+				claims, err := apiKeys.Authenticate(r.Context(), key)
+				if err != nil {
+					if errors.Is(err, ierr.ErrInvalidAPIKey) || errors.Is(err, ierr.ErrUserInactive) {
+						logger.Error.Printf("Invalid api key: %v", err)
+						w.Header().Set("WWW-Authenticate", `ApiKey error="invalid_key"`)
+						http.Error(w, "Unauthorized", http.StatusUnauthorized)
+						return
+					}
+					logger.Error.Printf("Could not check api key: %v", err)
+					http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+					return
+				}
+				logCaller(r, claims)
+				next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), UserClaimsKey, claims)))
+				return
+			}
+
+			// 1. Get the Authorization header
+			authHeader := r.Header.Get("Authorization")
+			if authHeader == "" {
+				logger.Error.Println("Authorization header is missing")
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			// 2. The header should be in the format "Bearer <token>"
+			headerParts := strings.Split(authHeader, " ")
+			if len(headerParts) != 2 || strings.ToLower(headerParts[0]) != "bearer" {
+				logger.Error.Println("Authorization header format is not Bearer {token}")
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			tokenString := headerParts[1]
+
+			// 3. Parse and validate the token
+			claims := &model.CustomClaims{}
+			// The key is picked by the token's kid header; only the algorithms
+			// of the configured keys are accepted.
+			token, err := parser.ParseWithClaims(tokenString, claims, keys.Keyfunc)
+
+			if err != nil || !token.Valid {
+				reason := rejectionReason(err)
+				logger.Error.Printf("Invalid token (%s): %v", reason, err)
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token", error_description="`+reason+`"`)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			// 4. Reject tokens without an ID or that have been revoked (e.g. by logout)
+			if claims.ID == "" {
+				logger.Error.Println("Token has no jti claim")
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			revoked, err := revocations.IsRevoked(r.Context(), claims.ID)
+			if err != nil {
+				logger.Error.Printf("Could not check token revocation: %v", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			if revoked {
+				logger.Error.Printf("Token %s has been revoked", claims.ID)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			// 5. Reject tokens of sessions the user has signed out of remotely
+			if claims.SessionID != "" {
+				revoked, err := sessions.IsRevoked(r.Context(), claims.SessionID)
+				if err != nil {
+					logger.Error.Printf("Could not check session revocation: %v", err)
+					http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+					return
+				}
+				if revoked {
+					logger.Error.Printf("Session %s of token %s has been revoked", claims.SessionID, claims.ID)
+					http.Error(w, "Unauthorized", http.StatusUnauthorized)
+					return
+				}
+			}
+
+			// 6. Token is valid. Add claims to the context for downstream handlers
+			logCaller(r, claims)
+			ctx := context.WithValue(r.Context(), UserClaimsKey, claims)
+
+			// 7. Call the next handler with the new context
+			next.ServeHTTP(w, r.WithContext(ctx))
 		})
+	}
+}
 
-		if err != nil || !token.Valid {
-			logger.Error.Printf("Invalid token: %v", err)
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
+// apiKeyFromRequest returns the API key sent with the request, if any.
+func apiKeyFromRequest(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	scheme, key, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if ok && strings.EqualFold(scheme, "ApiKey") {
+		return key
+	}
+	return ""
+}
 
-		// 4. Token is valid. Add claims to the context for downstream handlers
-		ctx := context.WithValue(r.Context(), UserClaimsKey, claims)
+// newParser builds a JWT parser that enforces the configured algorithms,
+// issuer, audience and clock skew leeway.
+func newParser(keys *jwtkeys.KeySet) *jwt.Parser {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(keys.Algorithms()),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(config.App.JWT.Leeway),
+	}
+	if config.App.JWT.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(config.App.JWT.Issuer))
+	}
+	if len(config.App.JWT.Audience) > 0 {
+		opts = append(opts, jwt.WithAudience(config.App.JWT.Audience...))
+	}
+	return jwt.NewParser(opts...)
+}
 
-		// 5. Call the next handler with the new context
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+// rejectionReason maps a token validation error to a short reason suitable for
+// logs and the WWW-Authenticate header.
+func rejectionReason(err error) string {
+	switch {
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return "token is malformed"
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid), errors.Is(err, jwt.ErrTokenUnverifiable):
+		return "signature could not be verified"
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return "token has expired"
+	case errors.Is(err, jwt.ErrTokenNotValidYet), errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		return "token is not valid yet"
+	case errors.Is(err, jwt.ErrTokenInvalidIssuer):
+		return "token has an unexpected issuer"
+	case errors.Is(err, jwt.ErrTokenInvalidAudience):
+		return "token has an unexpected audience"
+	case errors.Is(err, jwt.ErrTokenRequiredClaimMissing):
+		return "token is missing a required claim"
+	default:
+		return "token is invalid"
+	}
 }
@@ -0,0 +1,186 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/faizalom/go-api/internal/config"
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/jwtkeys"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeRevocations map[string]bool
+
+func (f fakeRevocations) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	return f[jti], nil
+}
+
+type fakeAPIKeys map[string]*model.CustomClaims
+
+func (f fakeAPIKeys) Authenticate(ctx context.Context, key string) (*model.CustomClaims, error) {
+	claims, ok := f[key]
+	if !ok {
+		return nil, ierr.ErrInvalidAPIKey
+	}
+	return claims, nil
+}
+
+var testKeys = jwtkeys.NewHMAC("test-secret")
+
+func signTestToken(t *testing.T, claims *model.CustomClaims) string {
+	t.Helper()
+	tokenString, err := testKeys.Sign(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tokenString
+}
+
+func serveWithToken(auth func(http.Handler) http.Handler, tokenString string) *httptest.ResponseRecorder {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	req := httptest.NewRequest("GET", "/profile", nil)
+	req.Header.Set("Authorization", "Bearer "+tokenString)
+	rr := httptest.NewRecorder()
+	auth(next).ServeHTTP(rr, req)
+	return rr
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	auth := NewAuthMiddleware(testKeys, fakeRevocations{"revoked": true}, fakeRevocations{"signed-out": true}, fakeAPIKeys{})
+
+	claimsWithID := func(jti string) *model.CustomClaims {
+		return &model.CustomClaims{
+			SessionID: "session",
+			RegisteredClaims: jwt.RegisteredClaims{
+				ID:        jti,
+				Subject:   "user",
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		claims *model.CustomClaims
+		want   int
+	}{
+		{name: "valid token", claims: claimsWithID("active"), want: http.StatusOK},
+		{name: "revoked token", claims: claimsWithID("revoked"), want: http.StatusUnauthorized},
+		{name: "token without jti", claims: claimsWithID(""), want: http.StatusUnauthorized},
+		{name: "revoked session", claims: func() *model.CustomClaims {
+			claims := claimsWithID("active")
+			claims.SessionID = "signed-out"
+			return claims
+		}(), want: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := serveWithToken(auth, signTestToken(t, tt.claims))
+			assert.Equal(t, tt.want, rr.Code)
+		})
+	}
+}
+
+func TestAuthMiddleware_MissingHeader(t *testing.T) {
+	auth := NewAuthMiddleware(testKeys, fakeRevocations{}, fakeRevocations{}, fakeAPIKeys{})
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	rr := httptest.NewRecorder()
+	auth(next).ServeHTTP(rr, httptest.NewRequest("GET", "/profile", nil))
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestAuthMiddleware_IssuerAudienceLeeway(t *testing.T) {
+	config.App.JWT.Issuer = "go-api-test"
+	config.App.JWT.Audience = []string{"workout-api-test"}
+	config.App.JWT.Leeway = 30 * time.Second
+	defer func() { config.App.JWT = config.JWTConfig{} }()
+
+	auth := NewAuthMiddleware(testKeys, fakeRevocations{}, fakeRevocations{}, fakeAPIKeys{})
+
+	claims := func(iss string, aud []string, expiresIn time.Duration) *model.CustomClaims {
+		return &model.CustomClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				ID:        "jti",
+				Issuer:    iss,
+				Audience:  aud,
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+			},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		claims *model.CustomClaims
+		want   int
+		reason string
+	}{
+		{name: "matching issuer and audience", claims: claims("go-api-test", []string{"workout-api-test"}, time.Minute), want: http.StatusOK},
+		{name: "one of several audiences", claims: claims("go-api-test", []string{"other", "workout-api-test"}, time.Minute), want: http.StatusOK},
+		{name: "expired within leeway", claims: claims("go-api-test", []string{"workout-api-test"}, -10*time.Second), want: http.StatusOK},
+		{name: "expired beyond leeway", claims: claims("go-api-test", []string{"workout-api-test"}, -time.Minute), want: http.StatusUnauthorized, reason: "token has expired"},
+		{name: "wrong issuer", claims: claims("go-api-prod", []string{"workout-api-test"}, time.Minute), want: http.StatusUnauthorized, reason: "token has an unexpected issuer"},
+		{name: "wrong audience", claims: claims("go-api-test", []string{"workout-api-prod"}, time.Minute), want: http.StatusUnauthorized, reason: "token has an unexpected audience"},
+		{name: "missing audience", claims: claims("go-api-test", nil, time.Minute), want: http.StatusUnauthorized, reason: "token is missing a required claim"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := serveWithToken(auth, signTestToken(t, tt.claims))
+			assert.Equal(t, tt.want, rr.Code)
+			if tt.reason != "" {
+				assert.Contains(t, rr.Header().Get("WWW-Authenticate"), tt.reason)
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware_APIKey(t *testing.T) {
+	botClaims := &model.CustomClaims{Scopes: []string{"user:read"}, RegisteredClaims: jwt.RegisteredClaims{Subject: "bot"}}
+	auth := NewAuthMiddleware(testKeys, fakeRevocations{}, fakeRevocations{}, fakeAPIKeys{"ak_good": botClaims})
+
+	var got *model.CustomClaims
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = r.Context().Value(UserClaimsKey).(*model.CustomClaims)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		want   int
+	}{
+		{name: "authorization header", header: "Authorization", value: "ApiKey ak_good", want: http.StatusOK},
+		{name: "scheme is case-insensitive", header: "Authorization", value: "apikey ak_good", want: http.StatusOK},
+		{name: "x-api-key header", header: "X-API-Key", value: "ak_good", want: http.StatusOK},
+		{name: "unknown key", header: "X-API-Key", value: "ak_bad", want: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = nil
+			req := httptest.NewRequest("GET", "/profile", nil)
+			req.Header.Set(tt.header, tt.value)
+			rr := httptest.NewRecorder()
+			auth(next).ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.want, rr.Code)
+			if tt.want == http.StatusOK {
+				assert.Equal(t, botClaims, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/faizalom/go-api/internal/authz"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/faizalom/go-api/pkg/logger"
+)
+
+// Authorize asks the authorizer whether the caller may perform action on the
+// resource described by the request. It must run after the auth middleware.
+func Authorize(a authz.Authorizer, action string, resource func(r *http.Request) authz.Resource) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := r.Context().Value(UserClaimsKey).(*model.CustomClaims)
+			if !ok {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			decision, err := a.Authorize(r.Context(), claims, action, resource(r))
+			if err != nil {
+				logger.Error.Printf("Could not authorize %s for user %s: %v", action, claims.Subject, err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			if !decision.Allowed {
+				logger.Error.Printf("User %s denied %s on %s %s: %s", claims.Subject, action, r.Method, r.URL.Path, decision.Reason)
+				authz.WriteForbidden(w, action, decision)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// UserCollection describes the users collection as a whole.
+func UserCollection(r *http.Request) authz.Resource {
+	return authz.Resource{Type: authz.ResourceUser}
+}
+
+// UserFromPath describes the user whose ID is the {id} path value.
+func UserFromPath(r *http.Request) authz.Resource {
+	return authz.UserResource(r.PathValue("id"))
+}
+
+// APIKeyCollection describes the API keys as a whole.
+func APIKeyCollection(r *http.Request) authz.Resource {
+	return authz.Resource{Type: authz.ResourceAPIKey}
+}
+
+// Metrics describes the server's runtime counters.
+func Metrics(r *http.Request) authz.Resource {
+	return authz.Resource{Type: authz.ResourceMetrics}
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/faizalom/go-api/internal/authz"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func serveWithClaims(h http.Handler, claims *model.CustomClaims, req *http.Request) int {
+	if claims != nil {
+		req = req.WithContext(context.WithValue(req.Context(), UserClaimsKey, claims))
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr.Code
+}
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+})
+
+type fakeAuthorizer struct {
+	decision authz.Decision
+	got      authz.Resource
+}
+
+func (f *fakeAuthorizer) Authorize(ctx context.Context, subject *model.CustomClaims, action string, resource authz.Resource) (authz.Decision, error) {
+	f.got = resource
+	return f.decision, nil
+}
+
+func TestAuthorize(t *testing.T) {
+	claims := &model.CustomClaims{RegisteredClaims: jwt.RegisteredClaims{Subject: "me"}}
+
+	t.Run("allowed", func(t *testing.T) {
+		a := &fakeAuthorizer{decision: authz.Decision{Allowed: true}}
+		mux := http.NewServeMux()
+		mux.Handle("GET /users/{id}", Chain(okHandler, Authorize(a, authz.ActionUserRead, UserFromPath)))
+
+		assert.Equal(t, http.StatusOK, serveWithClaims(mux, claims, httptest.NewRequest("GET", "/users/42", nil)))
+		assert.Equal(t, authz.UserResource("42"), a.got)
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		a := &fakeAuthorizer{decision: authz.Decision{Reason: authz.ReasonDeniedByRule, Rule: "no-deletes"}}
+		h := Chain(okHandler, Authorize(a, authz.ActionUserDelete, UserCollection))
+
+		req := httptest.NewRequest("DELETE", "/users", nil)
+		req = req.WithContext(context.WithValue(req.Context(), UserClaimsKey, claims))
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+		assert.JSONEq(t, `{"error":"forbidden","reason":"denied_by_rule","action":"user:delete","rule":"no-deletes"}`, rr.Body.String())
+	})
+}
@@ -1,27 +1,61 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"time"
 
+	"github.com/faizalom/go-api/internal/model"
 	"github.com/faizalom/go-api/pkg/logger" // Assumes 'workout-api' is your module name
 )
 
-// LoggingMiddleware logs the incoming HTTP request & its duration.
+const requestLogKey contextKey = "requestLog"
+
+// requestLog collects what later middleware learns about a request, such as
+// the authenticated caller, for LoggingMiddleware to log once it is done.
+type requestLog struct {
+	claims *model.CustomClaims
+}
+
+// LoggingMiddleware logs the incoming HTTP request & its duration, and who
+// made it once the request has been authenticated.
 func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Start the timer
 		start := time.Now()
 
 		// Call the next handler in the chain
-		next.ServeHTTP(w, r)
+		entry := &requestLog{}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestLogKey, entry)))
 
 		// Log the request details
 		logger.Info.Printf(
-			"%s %s %s",
+			"%s %s %s%s",
 			r.Method,
 			r.RequestURI,
 			time.Since(start),
+			entry.caller(),
 		)
 	})
 }
+
+// caller describes the authenticated caller, naming the real actor when
+// someone is impersonating the user.
+func (l *requestLog) caller() string {
+	switch {
+	case l.claims == nil:
+		return ""
+	case l.claims.Actor != nil:
+		return " user=" + l.claims.Subject + " actor=" + l.claims.Actor.Subject
+	default:
+		return " user=" + l.claims.Subject
+	}
+}
+
+// logCaller records the authenticated caller for LoggingMiddleware, if it
+// wraps the request.
+func logCaller(r *http.Request, claims *model.CustomClaims) {
+	if entry, ok := r.Context().Value(requestLogKey).(*requestLog); ok {
+		entry.claims = claims
+	}
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/faizalom/go-api/pkg/logger"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoggingMiddleware_LogsActor(t *testing.T) {
+	var buf bytes.Buffer
+	logger.Info = log.New(&buf, "", 0)
+	defer logger.Init()
+
+	auth := NewAuthMiddleware(testKeys, fakeRevocations{}, fakeRevocations{}, fakeAPIKeys{})
+	claims := &model.CustomClaims{
+		Actor: &model.ActorClaim{Subject: "admin"},
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "jti",
+			Subject:   "user",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req := httptest.NewRequest("GET", "/profile", nil)
+	req.Header.Set("Authorization", "Bearer "+signTestToken(t, claims))
+	Chain(next, LoggingMiddleware, auth).ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Contains(t, buf.String(), "GET /profile")
+	assert.Contains(t, buf.String(), "user=user actor=admin")
+}
@@ -0,0 +1,38 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIKey represents a key a machine client authenticates with. Only the hash
+// of the key is stored; Prefix identifies it in listings and logs.
+type APIKey struct {
+	ID         uuid.UUID  `json:"id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// NewAPIKeyRequest defines the data required to create an API key.
+type NewAPIKeyRequest struct {
+	// UserID is the user the key acts as.
+	UserID uuid.UUID `json:"user_id"`
+	Name   string    `json:"name"`
+	// Scopes lists the actions the key may perform, e.g. "user:read".
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// CreatedAPIKey is returned once when a key is created; Key is never shown again.
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
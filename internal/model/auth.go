@@ -0,0 +1,37 @@
+package model
+
+// LoginRequest defines the credentials required to log in.
+type LoginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	// Client describes where the request came from, for throttling and the
+	// session record. It is never read from a request body.
+	Client ClientInfo `json:"-"`
+}
+
+// TokenResponse is returned to the client after a successful login or refresh.
+// When the user has two-factor authentication enabled, login returns only an
+// MFAToken instead, to be exchanged for the tokens at /auth/mfa/verify.
+type TokenResponse struct {
+	Token        string `json:"token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	TokenType    string `json:"token_type,omitempty"`
+	// ExpiresIn is the lifetime of Token, or of MFAToken, in seconds.
+	ExpiresIn   int64  `json:"expires_in"`
+	MFARequired bool   `json:"mfa_required,omitempty"`
+	MFAToken    string `json:"mfa_token,omitempty"`
+}
+
+// ProfileResponse is returned by /profile for the caller's token.
+// ImpersonatedBy is set when an admin is impersonating the user.
+type ProfileResponse struct {
+	Message        string        `json:"message"`
+	UserID         string        `json:"user_id"`
+	ImpersonatedBy *ProfileActor `json:"impersonated_by,omitempty"`
+}
+
+// ProfileActor identifies who is really signed in.
+type ProfileActor struct {
+	UserID string `json:"user_id"`
+	Email  string `json:"email"`
+}
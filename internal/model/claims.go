@@ -6,5 +6,21 @@ import "github.com/golang-jwt/jwt/v5"
 type CustomClaims struct {
 	Name  string `json:"name"`
 	Email string `json:"email"`
+	// Roles are the user's roles at the time the token was issued.
+	Roles []string `json:"roles,omitempty"`
+	// Scopes limits the actions the caller may perform. It is only set for
+	// API keys; access tokens are limited by the user's roles alone.
+	Scopes []string `json:"scopes,omitempty"`
+	// SessionID identifies the login (refresh token family) the token was issued for.
+	SessionID string `json:"sid,omitempty"`
+	// Actor is set when someone else is acting as the subject, e.g. a support
+	// admin impersonating the user (the RFC 8693 act claim).
+	Actor *ActorClaim `json:"act,omitempty"`
 	jwt.RegisteredClaims
 }
+
+// ActorClaim identifies who is really making requests with a token.
+type ActorClaim struct {
+	Subject string `json:"sub"`
+	Email   string `json:"email,omitempty"`
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ImpersonationRequest asks for a token to act as another user.
+type ImpersonationRequest struct {
+	// Reason is recorded in the audit trail, e.g. a support ticket.
+	Reason string `json:"reason"`
+	// TargetID and Client are never read from a request body.
+	TargetID uuid.UUID  `json:"-"`
+	Client   ClientInfo `json:"-"`
+}
+
+// ImpersonationAudit records an impersonation token being issued.
+type ImpersonationAudit struct {
+	ID        uuid.UUID
+	ActorID   uuid.UUID
+	TargetID  uuid.UUID
+	TokenID   string
+	Reason    string
+	IPAddress string
+	UserAgent string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
@@ -0,0 +1,64 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MFA is a user's TOTP second factor. It only takes effect once the user has
+// confirmed it with a first code.
+type MFA struct {
+	UserID      uuid.UUID
+	Secret      string
+	ConfirmedAt *time.Time
+	// LastUsedStep is the TOTP time step of the last accepted code.
+	LastUsedStep int64
+	CreatedAt    time.Time
+}
+
+// Enabled reports whether the second factor is required at login.
+func (m *MFA) Enabled() bool {
+	return m.ConfirmedAt != nil
+}
+
+// MFAChallenge is issued by a password login when the user has two-factor
+// authentication enabled and redeemed at /auth/mfa/verify. Only the hash of
+// the token is stored.
+type MFAChallenge struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	TokenHash string
+	ExpiresAt time.Time
+	Attempts  int
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// MFAEnrollment is returned when a user starts enrolling an authenticator.
+type MFAEnrollment struct {
+	Secret string `json:"secret"`
+	// URI is the otpauth:// URI to show as a QR code.
+	URI string `json:"otpauth_uri"`
+}
+
+// MFACodeRequest carries a code from the user's authenticator app, or one of
+// their recovery codes where allowed.
+type MFACodeRequest struct {
+	Code string `json:"code"`
+}
+
+// MFARecoveryCodes is returned once, when two-factor authentication is
+// enabled. Each code can be used once instead of an authenticator code.
+type MFARecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// MFAVerifyRequest completes a login with the token it returned and a code
+// from the user's authenticator app or a recovery code.
+type MFAVerifyRequest struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code"`
+	// Client describes where the request came from, for the session record.
+	Client ClientInfo `json:"-"`
+}
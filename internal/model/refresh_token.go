@@ -0,0 +1,24 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RefreshToken represents a persisted refresh token. Only the hash of the
+// opaque token handed to the client is ever stored.
+type RefreshToken struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	FamilyID  uuid.UUID
+	TokenHash string
+	ExpiresAt time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// RefreshRequest defines the data required to rotate a refresh token.
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
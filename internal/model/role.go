@@ -0,0 +1,32 @@
+package model
+
+import "slices"
+
+// Roles a user can hold.
+const (
+	RoleAdmin   = "admin"
+	RoleCoach   = "coach"
+	RoleAthlete = "athlete"
+)
+
+// DefaultRoles are assigned to users created without explicit roles.
+var DefaultRoles = []string{RoleAthlete}
+
+// ValidRole reports whether role is one of the known roles.
+func ValidRole(role string) bool {
+	switch role {
+	case RoleAdmin, RoleCoach, RoleAthlete:
+		return true
+	}
+	return false
+}
+
+// HasAnyRole reports whether the claims carry at least one of roles.
+func (c *CustomClaims) HasAnyRole(roles ...string) bool {
+	for _, role := range roles {
+		if slices.Contains(c.Roles, role) {
+			return true
+		}
+	}
+	return false
+}
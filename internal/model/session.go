@@ -0,0 +1,27 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session is one login of a user, from which a refresh token family and its
+// access tokens descend. Its ID is the family ID and the tokens' sid claim.
+type Session struct {
+	ID         uuid.UUID  `json:"id"`
+	UserID     uuid.UUID  `json:"-"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"-"`
+	// Current marks the session the request was made from.
+	Current bool `json:"current"`
+}
+
+// ClientInfo describes the client a login comes from.
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
@@ -2,31 +2,174 @@ package model
 
 import (
 	"time"
+
 	"github.com/google/uuid"
 )
 
 // User represents a user record in the database.
 // This is the struct that will be returned in API responses.
+// EmailVerifiedAt is nil until the user confirms they own Email.
+// LockedUntil is set while password login is locked after repeated failures.
+// DeletedAt is only set on users listed from the deleted users.
+// Version counts the changes to the user; it is sent as the ETag header.
 type User struct {
-	ID        uuid.UUID `json:"id"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	IsActive  bool      `json:"is_active"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID               uuid.UUID  `json:"id"`
+	Name             string     `json:"name"`
+	Email            string     `json:"email"`
+	Roles            []string   `json:"roles"`
+	IsActive         bool       `json:"is_active"`
+	EmailVerifiedAt  *time.Time `json:"email_verified_at"`
+	FailedLoginCount int        `json:"-"`
+	LockedUntil      *time.Time `json:"locked_until"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+	DeletedAt        *time.Time `json:"deleted_at,omitempty"`
+	Version          int        `json:"-"`
 }
 
+// AnyVersion stands for whatever version a user is at, for a client that
+// sends If-Match: *. Real versions start at 1.
+const AnyVersion = 0
+
 // NewUserRequest defines the data required to create a new user.
 type NewUserRequest struct {
 	Name     string `json:"name"`
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	// Roles defaults to DefaultRoles when empty.
+	Roles []string `json:"roles,omitempty"`
+	// EmailVerified marks the email as already verified, e.g. by an identity
+	// provider. It is never read from a request body.
+	EmailVerified bool `json:"-"`
 }
 
-// UpdateUserRequest defines the data allowed for updating a user.
-// We use pointers to distinguish between a field not being provided
-// and a field being provided with an empty value.
+// UpdateUserRequest holds the fields of a user that can be changed. It
+// replaces all of them, so every field is required; the other fields of
+// the user are read-only.
 type UpdateUserRequest struct {
-	Name  *string `json:"name,omitempty"`
-	Email *string `json:"email,omitempty"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	// Roles can only be changed by admins.
+	Roles []string `json:"roles"`
+}
+
+// UserEditRights tells which of the guarded fields of UpdateUserRequest an
+// edit may change.
+type UserEditRights struct {
+	Roles bool
+	Email bool
+}
+
+// Patch formats accepted by PATCH /users/{id}.
+const (
+	MergePatchType = "application/merge-patch+json"
+	JSONPatchType  = "application/json-patch+json"
+)
+
+// UserPatch is a patch of the user's JSON representation, in one of the
+// patch formats. It may only change the fields of UpdateUserRequest.
+type UserPatch struct {
+	Type  string
+	Patch []byte
+}
+
+// User list sort fields, used in the sort query parameter of GET /users.
+const (
+	UserSortCreatedAt = "created_at"
+	UserSortUpdatedAt = "updated_at"
+	UserSortName      = "name"
+	UserSortEmail     = "email"
+
+	// UserSortDeletedAt can only be used to list deleted users.
+	UserSortDeletedAt = "deleted_at"
+
+	// UserSortRank orders search results by relevance. It cannot be used to
+	// list users.
+	UserSortRank = "rank"
+)
+
+// Page sizes of GET /users and GET /users/search.
+const (
+	DefaultUserPageSize = 50
+	MaxUserPageSize     = 200
+)
+
+// UserListRequest defines the filters, sort order and page requested from
+// GET /users. Nil or empty filters match every user.
+type UserListRequest struct {
+	// Deleted lists soft-deleted users instead of current ones.
+	Deleted       bool
+	IsActive      *bool
+	EmailDomain   string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	// Sort is one of the UserSort fields, defaulting to UserSortCreatedAt.
+	Sort string
+	Desc bool
+	// Limit defaults to DefaultUserPageSize and is capped at MaxUserPageSize.
+	Limit int
+	// Cursor is the NextCursor of the previous page.
+	Cursor string
+}
+
+// UserListQuery is a UserListRequest with its cursor decoded, as passed to
+// the repository. After is nil for the first page.
+type UserListQuery struct {
+	UserListRequest
+	After *UserCursor
+}
+
+// UserCursor is the position of the last user on a page, in the sort order
+// the page was listed in.
+type UserCursor struct {
+	Sort  string    `json:"s"`
+	Desc  bool      `json:"d"`
+	Value string    `json:"v"`
+	ID    uuid.UUID `json:"id"`
+}
+
+// UserPage is a page of users. NextCursor is empty on the last page.
+type UserPage struct {
+	Users      []*User `json:"users"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
+
+// UserSearchRequest defines a search of users by name and email, from
+// GET /users/search.
+type UserSearchRequest struct {
+	Query string
+	// Limit defaults to DefaultUserPageSize and is capped at MaxUserPageSize.
+	Limit int
+	// Cursor is the NextCursor of the previous page.
+	Cursor string
+}
+
+// UserSearchQuery is a UserSearchRequest with its cursor decoded, as passed
+// to the repository. After is nil for the first page.
+type UserSearchQuery struct {
+	UserSearchRequest
+	After *UserCursor
+}
+
+// UserSearchResult is a user matching a search, with its relevance and the
+// matched words of its name and email wrapped in <mark> tags.
+type UserSearchResult struct {
+	User       *User          `json:"user"`
+	Rank       float64        `json:"rank"`
+	Highlights UserHighlights `json:"highlights"`
+}
+
+// UserHighlights are the user's name and email as safe HTML: they are
+// HTML-escaped, apart from the <mark> tags around matched words, so they can
+// be rendered as they are.
+type UserHighlights struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// UserSearchPage is a page of search results, most relevant first.
+// NextCursor is empty on the last page.
+type UserSearchPage struct {
+	Results    []*UserSearchResult `json:"results"`
+	NextCursor string              `json:"next_cursor,omitempty"`
 }
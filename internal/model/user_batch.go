@@ -0,0 +1,69 @@
+package model
+
+import (
+	"encoding/json"
+
+	"github.com/google/uuid"
+)
+
+// Operations of a user batch.
+const (
+	BatchOpCreate = "create"
+	BatchOpUpdate = "update"
+	BatchOpDelete = "delete"
+)
+
+// MaxUserBatchSize is the most operations one batch may hold.
+const MaxUserBatchSize = 100
+
+// MaxUserBatchBytes is the largest batch request body read.
+const MaxUserBatchBytes = 1 << 20
+
+// Statuses of a UserBatchResult.
+const (
+	BatchOpSucceeded = "succeeded"
+	BatchOpFailed    = "failed"
+	// BatchOpRolledBack means the operation succeeded, but the batch was
+	// rolled back because of another one.
+	BatchOpRolledBack = "rolled_back"
+	// BatchOpNotRun means an earlier operation failed first.
+	BatchOpNotRun = "not_run"
+)
+
+// UserBatchRequest holds operations to run in order, all or none of them.
+type UserBatchRequest struct {
+	Operations []UserBatchOperation `json:"operations"`
+}
+
+// UserBatchOperation is one step of a batch. Create takes User as a
+// NewUserRequest; update takes it as an UpdateUserRequest and, like delete,
+// needs the ID and current Version of the user, as the ETag gives it.
+type UserBatchOperation struct {
+	Op      string          `json:"op"`
+	ID      uuid.UUID       `json:"id,omitempty"`
+	Version int             `json:"version,omitempty"`
+	User    json.RawMessage `json:"user,omitempty"`
+	// Rights tells which guarded fields an update may change.
+	Rights UserEditRights `json:"-"`
+}
+
+// UserBatchResult is the outcome of one operation, Index being its place in
+// the batch. User is the created or updated user; Err why it failed. Code
+// and Error are the HTTP status and message the operation alone would have
+// got.
+type UserBatchResult struct {
+	Index  int    `json:"index"`
+	Op     string `json:"op"`
+	Status string `json:"status"`
+	Code   int    `json:"code,omitempty"`
+	User   *User  `json:"user,omitempty"`
+	Error  string `json:"error,omitempty"`
+	Err    error  `json:"-"`
+}
+
+// UserBatchResponse reports on a batch. Committed is false when it was
+// rolled back.
+type UserBatchResponse struct {
+	Committed bool              `json:"committed"`
+	Results   []UserBatchResult `json:"results"`
+}
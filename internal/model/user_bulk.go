@@ -0,0 +1,54 @@
+package model
+
+import (
+	"io"
+
+	"github.com/google/uuid"
+)
+
+// Formats users are imported and exported in, as media types.
+const (
+	UserCSVType    = "text/csv"
+	UserNDJSONType = "application/x-ndjson"
+)
+
+// Statuses of a row in a UserImportReport.
+const (
+	ImportRowCreated = "created"
+	// ImportRowSkipped means a user with the row's email already exists.
+	ImportRowSkipped = "skipped"
+	ImportRowFailed  = "failed"
+	// ImportRowRolledBack means the user was created, but an atomic import
+	// was rolled back because of another row.
+	ImportRowRolledBack = "rolled_back"
+)
+
+// UserImportRequest holds users to create, one per CSV record or NDJSON
+// line of Body. When Atomic is set they are created in one transaction,
+// which rolls back if any row fails; otherwise each row stands on its own.
+type UserImportRequest struct {
+	Format string
+	Body   io.Reader
+	Atomic bool
+}
+
+// UserImportRow is the outcome of one row of an import. Line is where the
+// row starts in the request body, counting from 1.
+type UserImportRow struct {
+	Line   int        `json:"line"`
+	Status string     `json:"status"`
+	Email  string     `json:"email,omitempty"`
+	ID     *uuid.UUID `json:"id,omitempty"`
+	Error  string     `json:"error,omitempty"`
+}
+
+// UserImportReport tells how an import went. Committed is false when an
+// atomic import was rolled back.
+type UserImportReport struct {
+	Atomic    bool            `json:"atomic"`
+	Committed bool            `json:"committed"`
+	Created   int             `json:"created"`
+	Skipped   int             `json:"skipped"`
+	Failed    int             `json:"failed"`
+	Rows      []UserImportRow `json:"rows"`
+}
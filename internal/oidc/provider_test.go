@@ -0,0 +1,94 @@
+package oidc_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/faizalom/go-api/internal/oidc"
+	"github.com/faizalom/go-api/internal/oidc/oidctest"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const redirectURL = "http://localhost:8080/api/v1/auth/oidc/test/callback"
+
+// authorize starts a login and returns the code the fake provider issued.
+func authorize(t *testing.T, fake *oidctest.Provider, p *oidc.Provider, nonce, verifier string) string {
+	t.Helper()
+
+	authURL, err := p.AuthCodeURL(context.Background(), "state-1", nonce, oidc.CodeChallenge(verifier))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(authURL, fake.Issuer()+"/authorize?"))
+
+	callback, err := fake.Authorize(authURL)
+	require.NoError(t, err)
+	assert.Equal(t, "state-1", callback.Query().Get("state"))
+	return callback.Query().Get("code")
+}
+
+func TestProvider_Exchange(t *testing.T) {
+	fake := oidctest.NewProvider(t)
+	p := oidc.NewProvider(fake.Config("test", redirectURL), http.DefaultClient)
+
+	code := authorize(t, fake, p, "nonce-1", "verifier-1")
+	identity, err := p.Exchange(context.Background(), code, "verifier-1", "nonce-1")
+
+	require.NoError(t, err)
+	assert.Equal(t, "test", identity.Provider)
+	assert.Equal(t, fake.Subject, identity.Subject)
+	assert.Equal(t, fake.Email, identity.Email)
+	assert.True(t, identity.EmailVerified)
+	assert.Equal(t, fake.Name, identity.Name)
+
+	// Codes are single use.
+	_, err = p.Exchange(context.Background(), code, "verifier-1", "nonce-1")
+	assert.ErrorIs(t, err, oidc.ErrExchange)
+}
+
+func TestProvider_Exchange_Rejected(t *testing.T) {
+	tests := []struct {
+		name     string
+		tamper   func(jwt.MapClaims)
+		verifier string
+		nonce    string
+		want     error
+	}{
+		{name: "wrong code verifier", verifier: "other-verifier", nonce: "nonce-1", want: oidc.ErrExchange},
+		{name: "wrong nonce", verifier: "verifier-1", nonce: "other-nonce", want: oidc.ErrNonceMismatch},
+		{name: "wrong audience", tamper: func(c jwt.MapClaims) { c["aud"] = "other-client" }, verifier: "verifier-1", nonce: "nonce-1", want: oidc.ErrInvalidIDToken},
+		{name: "wrong issuer", tamper: func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }, verifier: "verifier-1", nonce: "nonce-1", want: oidc.ErrInvalidIDToken},
+		{name: "expired", tamper: func(c jwt.MapClaims) { c["exp"] = c["iat"].(int64) - 600 }, verifier: "verifier-1", nonce: "nonce-1", want: oidc.ErrInvalidIDToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := oidctest.NewProvider(t)
+			fake.Tamper = tt.tamper
+			p := oidc.NewProvider(fake.Config("test", redirectURL), http.DefaultClient)
+
+			code := authorize(t, fake, p, "nonce-1", "verifier-1")
+			identity, err := p.Exchange(context.Background(), code, tt.verifier, tt.nonce)
+
+			assert.ErrorIs(t, err, tt.want)
+			assert.Nil(t, identity)
+		})
+	}
+}
+
+func TestProvider_DiscoveryIssuerMismatch(t *testing.T) {
+	fake := oidctest.NewProvider(t)
+	cfg := fake.Config("test", redirectURL)
+	cfg.Issuer += "/"
+	p := oidc.NewProvider(cfg, http.DefaultClient)
+
+	_, err := p.AuthCodeURL(context.Background(), "state", "nonce", oidc.CodeChallenge("verifier"))
+	assert.ErrorIs(t, err, oidc.ErrDiscovery)
+}
+
+func TestCodeChallenge(t *testing.T) {
+	// BASE64URL(SHA256(verifier)) without padding, as computed with openssl.
+	assert.Equal(t, "PCFQr7HqURtympb1J5HDUo6kYM0R1JBpkSgx12DmpI8", oidc.CodeChallenge("dBjftJeZ4CVP-mJ92K9CrGEq9ESaFjgr8_u3MkM_Kk0"))
+}
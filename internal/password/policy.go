@@ -0,0 +1,99 @@
+// Package password enforces the configured password policy.
+package password
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/faizalom/go-api/internal/config"
+	"github.com/faizalom/go-api/internal/ierr"
+)
+
+// MaxBytes is the longest password bcrypt can hash; longer ones would be
+// silently truncated.
+const MaxBytes = 72
+
+// Policy validates new passwords against the configured rules and a list of
+// common passwords.
+type Policy struct {
+	cfg    config.PasswordPolicyConfig
+	common map[string]struct{}
+}
+
+// NewPolicy returns a policy that also rejects the given common passwords,
+// compared case-insensitively.
+func NewPolicy(cfg config.PasswordPolicyConfig, common ...string) *Policy {
+	p := &Policy{cfg: cfg, common: make(map[string]struct{}, len(common))}
+	for _, pw := range common {
+		p.common[strings.ToLower(pw)] = struct{}{}
+	}
+	return p
+}
+
+// LoadPolicy returns the policy for cfg with the common passwords read from
+// cfg.CommonPasswordsFile. Blank lines and lines starting with # are ignored.
+func LoadPolicy(cfg config.PasswordPolicyConfig) (*Policy, error) {
+	f, err := os.Open(cfg.CommonPasswordsFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var common []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		common = append(common, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return NewPolicy(cfg, common...), nil
+}
+
+// Validate returns an error wrapping ierr.ErrWeakPassword that describes the
+// first rule the password breaks.
+func (p *Policy) Validate(password string) error {
+	if n := utf8.RuneCountInString(password); n < p.cfg.MinLength {
+		return fmt.Errorf("%w: must be at least %d characters", ierr.ErrWeakPassword, p.cfg.MinLength)
+	}
+	if len(password) > MaxBytes {
+		return fmt.Errorf("%w: must be at most %d bytes", ierr.ErrWeakPassword, MaxBytes)
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsLetter(r):
+			symbol = true
+		}
+	}
+	switch {
+	case p.cfg.RequireUpper && !upper:
+		return fmt.Errorf("%w: must contain an uppercase letter", ierr.ErrWeakPassword)
+	case p.cfg.RequireLower && !lower:
+		return fmt.Errorf("%w: must contain a lowercase letter", ierr.ErrWeakPassword)
+	case p.cfg.RequireDigit && !digit:
+		return fmt.Errorf("%w: must contain a digit", ierr.ErrWeakPassword)
+	case p.cfg.RequireSymbol && !symbol:
+		return fmt.Errorf("%w: must contain a symbol", ierr.ErrWeakPassword)
+	}
+
+	if _, ok := p.common[strings.ToLower(password)]; ok {
+		return fmt.Errorf("%w: is too common", ierr.ErrWeakPassword)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package password
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/faizalom/go-api/internal/config"
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy_Validate(t *testing.T) {
+	policy := NewPolicy(config.PasswordPolicyConfig{
+		MinLength:     10,
+		RequireUpper:  true,
+		RequireLower:  true,
+		RequireDigit:  true,
+		RequireSymbol: true,
+	}, "Password123!")
+
+	tests := []struct {
+		name     string
+		password string
+		wantErr  string
+	}{
+		{name: "valid", password: "Correct-Horse-9"},
+		{name: "valid with multibyte characters", password: "Grüße-aus-Köln-1"},
+		{name: "empty", password: "", wantErr: "at least 10 characters"},
+		{name: "too short", password: "Ab1!", wantErr: "at least 10 characters"},
+		{name: "too long", password: "Aa1!" + strings.Repeat("x", 69), wantErr: "at most 72 bytes"},
+		{name: "no uppercase", password: "correct-horse-9", wantErr: "uppercase"},
+		{name: "no lowercase", password: "CORRECT-HORSE-9", wantErr: "lowercase"},
+		{name: "no digit", password: "Correct-Horse-!", wantErr: "digit"},
+		{name: "no symbol", password: "CorrectHorse9", wantErr: "symbol"},
+		{name: "common, any case", password: "pASSWORD123!", wantErr: "too common"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Validate(tt.password)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ierr.ErrWeakPassword)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestPolicy_Validate_OptionalClasses(t *testing.T) {
+	policy := NewPolicy(config.PasswordPolicyConfig{MinLength: 8})
+
+	assert.NoError(t, policy.Validate("lowercaseonly"))
+	assert.ErrorIs(t, policy.Validate("short"), ierr.ErrWeakPassword)
+}
+
+func TestLoadPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "common.txt")
+	require.NoError(t, os.WriteFile(path, []byte("# comment\n\nWelcome123\n  qwerty12345  \n"), 0o600))
+
+	policy, err := LoadPolicy(config.PasswordPolicyConfig{MinLength: 8, CommonPasswordsFile: path})
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, policy.Validate("welcome123"), ierr.ErrWeakPassword)
+	assert.ErrorIs(t, policy.Validate("QWERTY12345"), ierr.ErrWeakPassword)
+	assert.NoError(t, policy.Validate("# comment here"))
+}
+
+func TestLoadPolicy_MissingFile(t *testing.T) {
+	_, err := LoadPolicy(config.PasswordPolicyConfig{CommonPasswordsFile: filepath.Join(t.TempDir(), "missing.txt")})
+	assert.Error(t, err)
+}
+
+func TestLoadPolicy_BundledList(t *testing.T) {
+	policy, err := LoadPolicy(config.PasswordPolicyConfig{MinLength: 8, CommonPasswordsFile: "../../configs/common-passwords.txt"})
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, policy.Validate("Password1"), ierr.ErrWeakPassword)
+	assert.ErrorIs(t, policy.Validate("Qwerty123"), ierr.ErrWeakPassword)
+}
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/model"
+
+	"github.com/google/uuid"
+)
+
+type APIKeyRepository struct {
+	DB *sql.DB
+}
+
+func NewAPIKeyRepository(db *sql.DB) IAPIKeyRepository {
+	return &APIKeyRepository{DB: db}
+}
+
+// Create stores a new API key.
+func (r *APIKeyRepository) Create(ctx context.Context, key *model.APIKey) error {
+	query := `
+		INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, string_to_array($5, ','), $6)
+		RETURNING id, created_at
+	`
+	return mapError(conn(ctx, r.DB).QueryRowContext(ctx, query, key.UserID, key.Name, key.Prefix, key.KeyHash, joinTextArray(key.Scopes), key.ExpiresAt).Scan(&key.ID, &key.CreatedAt))
+}
+
+// GetByHash retrieves an API key by the hash of its value.
+func (r *APIKeyRepository) GetByHash(ctx context.Context, keyHash string) (*model.APIKey, error) {
+	query := `
+		SELECT id, user_id, name, prefix, key_hash, array_to_string(scopes, ','), last_used_at, expires_at, revoked_at, created_at
+		FROM api_keys
+		WHERE key_hash = $1
+	`
+	key, err := scanAPIKey(conn(ctx, r.DB).QueryRowContext(ctx, query, keyHash))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ierr.ErrAPIKeyNotFound
+		}
+		return nil, mapError(err)
+	}
+	return key, nil
+}
+
+// List retrieves every API key, newest first.
+func (r *APIKeyRepository) List(ctx context.Context) ([]*model.APIKey, error) {
+	query := `
+		SELECT id, user_id, name, prefix, key_hash, array_to_string(scopes, ','), last_used_at, expires_at, revoked_at, created_at
+		FROM api_keys
+		ORDER BY created_at DESC
+	`
+	rows, err := conn(ctx, r.DB).QueryContext(ctx, query)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	var keys []*model.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, mapError(err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, mapError(rows.Err())
+}
+
+// Revoke marks an API key as revoked. Revoking an already revoked key is a no-op.
+func (r *APIKeyRepository) Revoke(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE api_keys
+		SET revoked_at = COALESCE(revoked_at, NOW())
+		WHERE id = $1
+	`
+	result, err := conn(ctx, r.DB).ExecContext(ctx, query, id)
+	if err != nil {
+		return mapError(err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return mapError(err)
+	}
+	if rows == 0 {
+		return ierr.ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// TouchLastUsed records that the key has just been used. To keep busy keys
+// from writing on every request it only updates once a minute.
+func (r *APIKeyRepository) TouchLastUsed(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE api_keys
+		SET last_used_at = NOW()
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')
+	`
+	_, err := conn(ctx, r.DB).ExecContext(ctx, query, id)
+	return mapError(err)
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAPIKey(row rowScanner) (*model.APIKey, error) {
+	key := &model.APIKey{}
+	var scopes string
+	err := row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.KeyHash, &scopes, &key.LastUsedAt, &key.ExpiresAt, &key.RevokedAt, &key.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	key.Scopes = splitTextArray(scopes)
+	return key, nil
+}
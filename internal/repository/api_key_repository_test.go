@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+var apiKeyColumns = []string{"id", "user_id", "name", "prefix", "key_hash", "scopes", "last_used_at", "expires_at", "revoked_at", "created_at"}
+
+func TestAPIKeyRepository_Create(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewAPIKeyRepository(db)
+
+	now := time.Now()
+	key := &model.APIKey{
+		UserID:  uuid.New(),
+		Name:    "ci",
+		Prefix:  "abcd1234",
+		KeyHash: "key_hash",
+		Scopes:  []string{"user:list", "user:read"},
+	}
+	newUUID := uuid.New()
+
+	mock.ExpectQuery(`INSERT INTO api_keys`).
+		WithArgs(key.UserID, key.Name, key.Prefix, key.KeyHash, "user:list,user:read", key.ExpiresAt).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(newUUID, now))
+
+	err = repo.Create(context.Background(), key)
+
+	assert.NoError(t, err)
+	assert.Equal(t, newUUID, key.ID)
+	assert.Equal(t, now, key.CreatedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAPIKeyRepository_GetByHash(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewAPIKeyRepository(db)
+
+	now := time.Now()
+	key := &model.APIKey{
+		ID:        uuid.New(),
+		UserID:    uuid.New(),
+		Name:      "ci",
+		Prefix:    "abcd1234",
+		KeyHash:   "key_hash",
+		Scopes:    []string{"user:read"},
+		CreatedAt: now,
+	}
+
+	rows := sqlmock.NewRows(apiKeyColumns).
+		AddRow(key.ID, key.UserID, key.Name, key.Prefix, key.KeyHash, "user:read", nil, nil, nil, key.CreatedAt)
+
+	mock.ExpectQuery(`SELECT (.+) FROM api_keys WHERE key_hash = \$1`).
+		WithArgs(key.KeyHash).
+		WillReturnRows(rows)
+
+	found, err := repo.GetByHash(context.Background(), key.KeyHash)
+
+	assert.NoError(t, err)
+	assert.Equal(t, key, found)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAPIKeyRepository_GetByHash_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewAPIKeyRepository(db)
+
+	mock.ExpectQuery(`SELECT (.+) FROM api_keys WHERE key_hash = \$1`).
+		WithArgs("missing").
+		WillReturnRows(sqlmock.NewRows(apiKeyColumns))
+
+	found, err := repo.GetByHash(context.Background(), "missing")
+
+	assert.ErrorIs(t, err, ierr.ErrAPIKeyNotFound)
+	assert.Nil(t, found)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAPIKeyRepository_List(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewAPIKeyRepository(db)
+
+	now := time.Now()
+	rows := sqlmock.NewRows(apiKeyColumns).
+		AddRow(uuid.New(), uuid.New(), "ci", "abcd1234", "hash1", "user:read", now, nil, nil, now).
+		AddRow(uuid.New(), uuid.New(), "old", "efgh5678", "hash2", "", nil, nil, now, now)
+
+	mock.ExpectQuery(`SELECT (.+) FROM api_keys ORDER BY created_at DESC`).WillReturnRows(rows)
+
+	keys, err := repo.List(context.Background())
+
+	assert.NoError(t, err)
+	assert.Len(t, keys, 2)
+	assert.Equal(t, []string{"user:read"}, keys[0].Scopes)
+	assert.Equal(t, []string{}, keys[1].Scopes)
+	assert.NotNil(t, keys[1].RevokedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAPIKeyRepository_Revoke(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewAPIKeyRepository(db)
+
+	id := uuid.New()
+	mock.ExpectExec(`UPDATE api_keys SET revoked_at`).WithArgs(id).WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, repo.Revoke(context.Background(), id))
+
+	mock.ExpectExec(`UPDATE api_keys SET revoked_at`).WithArgs(id).WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.ErrorIs(t, repo.Revoke(context.Background(), id), ierr.ErrAPIKeyNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+)
+
+type CoachAthleteRepository struct {
+	DB *sql.DB
+}
+
+func NewCoachAthleteRepository(db *sql.DB) ICoachAthleteRepository {
+	return &CoachAthleteRepository{DB: db}
+}
+
+// IsCoachOf reports whether coachID coaches athleteID.
+func (r *CoachAthleteRepository) IsCoachOf(ctx context.Context, coachID, athleteID uuid.UUID) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM coach_athletes WHERE coach_id = $1 AND athlete_id = $2)`
+	var ok bool
+	if err := conn(ctx, r.DB).QueryRowContext(ctx, query, coachID, athleteID).Scan(&ok); err != nil {
+		return false, mapError(err)
+	}
+	return ok, nil
+}
@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoachAthleteRepository_IsCoachOf(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewCoachAthleteRepository(db)
+
+	coachID, athleteID := uuid.New(), uuid.New()
+
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM coach_athletes WHERE coach_id = \$1 AND athlete_id = \$2\)`).
+		WithArgs(coachID, athleteID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	ok, err := repo.IsCoachOf(context.Background(), coachID, athleteID)
+
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/model"
+
+	"github.com/google/uuid"
+)
+
+type EmailVerificationTokenRepository struct {
+	DB *sql.DB
+}
+
+func NewEmailVerificationTokenRepository(db *sql.DB) IEmailVerificationTokenRepository {
+	return &EmailVerificationTokenRepository{DB: db}
+}
+
+// Create stores a new email verification token.
+func (r *EmailVerificationTokenRepository) Create(ctx context.Context, token *model.EmailVerificationToken) error {
+	query := `
+		INSERT INTO email_verification_tokens (user_id, email, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+	return mapError(conn(ctx, r.DB).QueryRowContext(ctx, query, token.UserID, token.Email, token.TokenHash, token.ExpiresAt).Scan(&token.ID, &token.CreatedAt))
+}
+
+// GetByHash retrieves an email verification token by the hash of its value.
+func (r *EmailVerificationTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*model.EmailVerificationToken, error) {
+	query := `
+		SELECT id, user_id, email, token_hash, expires_at, used_at, created_at
+		FROM email_verification_tokens
+		WHERE token_hash = $1
+	`
+	token := &model.EmailVerificationToken{}
+	err := conn(ctx, r.DB).QueryRowContext(ctx, query, tokenHash).Scan(&token.ID, &token.UserID, &token.Email, &token.TokenHash, &token.ExpiresAt, &token.UsedAt, &token.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ierr.ErrVerificationTokenNotFound
+		}
+		return nil, mapError(err)
+	}
+	return token, nil
+}
+
+// MarkUsed consumes a token. It reports false when the token had already
+// been used, so that concurrent requests cannot both redeem it.
+func (r *EmailVerificationTokenRepository) MarkUsed(ctx context.Context, id uuid.UUID) (bool, error) {
+	query := `
+		UPDATE email_verification_tokens
+		SET used_at = NOW()
+		WHERE id = $1 AND used_at IS NULL
+	`
+	result, err := conn(ctx, r.DB).ExecContext(ctx, query, id)
+	if err != nil {
+		return false, mapError(err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, mapError(err)
+	}
+	return rows == 1, nil
+}
+
+// InvalidateForUser consumes every outstanding token of a user.
+func (r *EmailVerificationTokenRepository) InvalidateForUser(ctx context.Context, userID uuid.UUID) error {
+	query := `
+		UPDATE email_verification_tokens
+		SET used_at = NOW()
+		WHERE user_id = $1 AND used_at IS NULL
+	`
+	_, err := conn(ctx, r.DB).ExecContext(ctx, query, userID)
+	return mapError(err)
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmailVerificationTokenRepository_Create(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewEmailVerificationTokenRepository(db)
+
+	now := time.Now()
+	token := &model.EmailVerificationToken{UserID: uuid.New(), Email: "test@example.com", TokenHash: "token_hash", ExpiresAt: now.Add(time.Hour)}
+	newUUID := uuid.New()
+
+	mock.ExpectQuery(`INSERT INTO email_verification_tokens`).
+		WithArgs(token.UserID, token.Email, token.TokenHash, token.ExpiresAt).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(newUUID, now))
+
+	err = repo.Create(context.Background(), token)
+
+	assert.NoError(t, err)
+	assert.Equal(t, newUUID, token.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEmailVerificationTokenRepository_GetByHash(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewEmailVerificationTokenRepository(db)
+
+	now := time.Now()
+	token := &model.EmailVerificationToken{ID: uuid.New(), UserID: uuid.New(), Email: "test@example.com", TokenHash: "token_hash", ExpiresAt: now.Add(time.Hour), CreatedAt: now}
+	columns := []string{"id", "user_id", "email", "token_hash", "expires_at", "used_at", "created_at"}
+
+	mock.ExpectQuery(`SELECT (.+) FROM email_verification_tokens WHERE token_hash = \$1`).
+		WithArgs(token.TokenHash).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(token.ID, token.UserID, token.Email, token.TokenHash, token.ExpiresAt, nil, token.CreatedAt))
+
+	found, err := repo.GetByHash(context.Background(), token.TokenHash)
+	assert.NoError(t, err)
+	assert.Equal(t, token, found)
+
+	mock.ExpectQuery(`SELECT (.+) FROM email_verification_tokens WHERE token_hash = \$1`).
+		WithArgs("missing").
+		WillReturnRows(sqlmock.NewRows(columns))
+
+	found, err = repo.GetByHash(context.Background(), "missing")
+	assert.ErrorIs(t, err, ierr.ErrVerificationTokenNotFound)
+	assert.Nil(t, found)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEmailVerificationTokenRepository_MarkUsed(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewEmailVerificationTokenRepository(db)
+
+	id := uuid.New()
+	mock.ExpectExec(`UPDATE email_verification_tokens SET used_at = NOW\(\) WHERE id = \$1 AND used_at IS NULL`).
+		WithArgs(id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE email_verification_tokens SET used_at = NOW\(\) WHERE id = \$1 AND used_at IS NULL`).
+		WithArgs(id).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	used, err := repo.MarkUsed(context.Background(), id)
+	assert.NoError(t, err)
+	assert.True(t, used)
+
+	used, err = repo.MarkUsed(context.Background(), id)
+	assert.NoError(t, err)
+	assert.False(t, used)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// pgErrorKinds maps Postgres error codes (SQLSTATEs) to the database errors
+// in ierr.
+var pgErrorKinds = map[string]error{
+	"23505": ierr.ErrUniqueViolation,
+	"23503": ierr.ErrForeignKeyViolation,
+	"23514": ierr.ErrCheckViolation,
+	"23502": ierr.ErrNotNullViolation,
+	"40001": ierr.ErrSerializationFailure, // serialization_failure
+	"40P01": ierr.ErrSerializationFailure, // deadlock_detected
+	"57014": ierr.ErrQueryTimeout,         // query_canceled, e.g. by statement_timeout
+	"55P03": ierr.ErrQueryTimeout,         // lock_not_available, e.g. by lock_timeout
+}
+
+// mapError wraps a Postgres error in an ierr.DBError, so that callers can
+// tell its kind with errors.Is. Other errors, and Postgres errors of other
+// kinds, are returned as they are.
+func mapError(err error) error {
+	var dbErr *ierr.DBError
+	if err == nil || errors.As(err, &dbErr) {
+		return err
+	}
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+	kind, ok := pgErrorKinds[pgErr.Code]
+	if !ok {
+		return err
+	}
+	return &ierr.DBError{Kind: kind, Constraint: pgErr.ConstraintName, Err: err}
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMapError(t *testing.T) {
+	tests := []struct {
+		code string
+		want error
+	}{
+		{"23505", ierr.ErrUniqueViolation},
+		{"23503", ierr.ErrForeignKeyViolation},
+		{"23514", ierr.ErrCheckViolation},
+		{"23502", ierr.ErrNotNullViolation},
+		{"40001", ierr.ErrSerializationFailure},
+		{"40P01", ierr.ErrSerializationFailure},
+		{"57014", ierr.ErrQueryTimeout},
+		{"55P03", ierr.ErrQueryTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			pgErr := &pgconn.PgError{Code: tt.code, ConstraintName: "some_constraint"}
+			err := mapError(pgErr)
+
+			assert.ErrorIs(t, err, tt.want)
+			assert.ErrorIs(t, err, pgErr)
+			var dbErr *ierr.DBError
+			if assert.ErrorAs(t, err, &dbErr) {
+				assert.Equal(t, "some_constraint", dbErr.Constraint)
+			}
+			// Mapping again changes nothing.
+			assert.Same(t, err, mapError(err))
+		})
+	}
+}
+
+func TestMapError_Unmapped(t *testing.T) {
+	pgErr := &pgconn.PgError{Code: "42P01"}
+	other := errors.New("connection reset")
+
+	assert.NoError(t, mapError(nil))
+	assert.Same(t, error(pgErr), mapError(pgErr))
+	assert.Same(t, other, mapError(other))
+	assert.Equal(t, sql.ErrNoRows, mapError(sql.ErrNoRows))
+}
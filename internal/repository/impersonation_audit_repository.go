@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/faizalom/go-api/internal/model"
+)
+
+type ImpersonationAuditRepository struct {
+	DB *sql.DB
+}
+
+func NewImpersonationAuditRepository(db *sql.DB) IImpersonationAuditRepository {
+	return &ImpersonationAuditRepository{DB: db}
+}
+
+// Create records an impersonation token being issued.
+func (r *ImpersonationAuditRepository) Create(ctx context.Context, entry *model.ImpersonationAudit) error {
+	query := `
+		INSERT INTO impersonation_audit (actor_id, target_id, token_id, reason, ip_address, user_agent, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`
+	return mapError(conn(ctx, r.DB).QueryRowContext(ctx, query, entry.ActorID, entry.TargetID, entry.TokenID, entry.Reason, entry.IPAddress, entry.UserAgent, entry.ExpiresAt).Scan(&entry.ID, &entry.CreatedAt))
+}
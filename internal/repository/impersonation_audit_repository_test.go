@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImpersonationAuditRepository_Create(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewImpersonationAuditRepository(db)
+
+	now := time.Now()
+	entry := &model.ImpersonationAudit{
+		ActorID:   uuid.New(),
+		TargetID:  uuid.New(),
+		TokenID:   "jti",
+		Reason:    "ticket 4711",
+		IPAddress: "192.0.2.1",
+		UserAgent: "curl/8.5.0",
+		ExpiresAt: now.Add(15 * time.Minute),
+	}
+	newUUID := uuid.New()
+
+	mock.ExpectQuery(`INSERT INTO impersonation_audit`).
+		WithArgs(entry.ActorID, entry.TargetID, entry.TokenID, entry.Reason, entry.IPAddress, entry.UserAgent, entry.ExpiresAt).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(newUUID, now))
+
+	err = repo.Create(context.Background(), entry)
+
+	assert.NoError(t, err)
+	assert.Equal(t, newUUID, entry.ID)
+	assert.Equal(t, now, entry.CreatedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
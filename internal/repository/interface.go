@@ -2,17 +2,113 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/faizalom/go-api/internal/model"
 
 	"github.com/google/uuid"
 )
 
+// ITxManager runs functions in a database transaction; see
+// TxManager.WithinTx.
+type ITxManager interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error, opts ...TxOption) error
+}
+
 type IUserRepository interface {
 	Create(ctx context.Context, user *model.User, passwordHash string) (*model.User, error)
 	GetByID(ctx context.Context, id uuid.UUID) (*model.User, error)
 	GetByEmail(ctx context.Context, email string) (*model.User, string, error)
-	Update(ctx context.Context, id uuid.UUID, user *model.User) error
-	Delete(ctx context.Context, id uuid.UUID) error
-	List(ctx context.Context) ([]*model.User, error)
+	Update(ctx context.Context, id uuid.UUID, user *model.User) (bool, error)
+	Delete(ctx context.Context, id uuid.UUID, version int) (bool, error)
+	GetDeletedByID(ctx context.Context, id uuid.UUID) (*model.User, error)
+	Restore(ctx context.Context, id uuid.UUID) (bool, error)
+	PurgeDeleted(ctx context.Context, before time.Time) (int64, error)
+	List(ctx context.Context, q model.UserListQuery) ([]*model.User, error)
+	Search(ctx context.Context, q model.UserSearchQuery) ([]*model.UserSearchResult, error)
+	UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error
+	GetPasswordHash(ctx context.Context, id uuid.UUID) (string, error)
+	MarkEmailVerified(ctx context.Context, id uuid.UUID, email string) (bool, error)
+	RecordLoginFailure(ctx context.Context, id uuid.UUID) (int, error)
+	LockUntil(ctx context.Context, id uuid.UUID, until time.Time) error
+	ResetLoginFailures(ctx context.Context, id uuid.UUID) error
+}
+
+type IRefreshTokenRepository interface {
+	Create(ctx context.Context, token *model.RefreshToken) error
+	GetByHash(ctx context.Context, tokenHash string) (*model.RefreshToken, error)
+	Revoke(ctx context.Context, id uuid.UUID) (bool, error)
+	RevokeFamily(ctx context.Context, familyID uuid.UUID) error
+	RevokeAllForUser(ctx context.Context, userID uuid.UUID) error
+}
+
+type ISessionRepository interface {
+	Create(ctx context.Context, session *model.Session) error
+	ListActive(ctx context.Context, userID uuid.UUID) ([]*model.Session, error)
+	Touch(ctx context.Context, id uuid.UUID) error
+	Revoke(ctx context.Context, id, userID uuid.UUID) (bool, error)
+	RevokeAllForUser(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error)
+	IsRevoked(ctx context.Context, id uuid.UUID) (bool, error)
+}
+
+type IImpersonationAuditRepository interface {
+	Create(ctx context.Context, entry *model.ImpersonationAudit) error
+}
+
+type IRevokedTokenRepository interface {
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+type ICoachAthleteRepository interface {
+	IsCoachOf(ctx context.Context, coachID, athleteID uuid.UUID) (bool, error)
+}
+
+type IAPIKeyRepository interface {
+	Create(ctx context.Context, key *model.APIKey) error
+	GetByHash(ctx context.Context, keyHash string) (*model.APIKey, error)
+	List(ctx context.Context) ([]*model.APIKey, error)
+	Revoke(ctx context.Context, id uuid.UUID) error
+	TouchLastUsed(ctx context.Context, id uuid.UUID) error
+}
+
+type IUserIdentityRepository interface {
+	Create(ctx context.Context, identity *model.UserIdentity) error
+	GetByProviderSubject(ctx context.Context, provider, subject string) (*model.UserIdentity, error)
+}
+
+type IPasswordResetTokenRepository interface {
+	Create(ctx context.Context, token *model.PasswordResetToken) error
+	GetByHash(ctx context.Context, tokenHash string) (*model.PasswordResetToken, error)
+	MarkUsed(ctx context.Context, id uuid.UUID) (bool, error)
+	InvalidateForUser(ctx context.Context, userID uuid.UUID) error
+}
+
+type IEmailVerificationTokenRepository interface {
+	Create(ctx context.Context, token *model.EmailVerificationToken) error
+	GetByHash(ctx context.Context, tokenHash string) (*model.EmailVerificationToken, error)
+	MarkUsed(ctx context.Context, id uuid.UUID) (bool, error)
+	InvalidateForUser(ctx context.Context, userID uuid.UUID) error
+}
+
+type IMFARepository interface {
+	Get(ctx context.Context, userID uuid.UUID) (*model.MFA, error)
+	Enroll(ctx context.Context, userID uuid.UUID, secret string) (bool, error)
+	Confirm(ctx context.Context, userID uuid.UUID) error
+	UseStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error)
+	Delete(ctx context.Context, userID uuid.UUID) error
+}
+
+type IMFARecoveryCodeRepository interface {
+	ReplaceForUser(ctx context.Context, userID uuid.UUID, codeHashes []string) error
+	Use(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error)
+	DeleteForUser(ctx context.Context, userID uuid.UUID) error
+}
+
+type IMFAChallengeRepository interface {
+	Create(ctx context.Context, challenge *model.MFAChallenge) error
+	GetByHash(ctx context.Context, tokenHash string) (*model.MFAChallenge, error)
+	RecordAttempt(ctx context.Context, id uuid.UUID) (int, error)
+	MarkUsed(ctx context.Context, id uuid.UUID) (bool, error)
 }
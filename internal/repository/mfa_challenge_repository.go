@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/model"
+
+	"github.com/google/uuid"
+)
+
+type MFAChallengeRepository struct {
+	DB *sql.DB
+}
+
+func NewMFAChallengeRepository(db *sql.DB) IMFAChallengeRepository {
+	return &MFAChallengeRepository{DB: db}
+}
+
+// Create stores a new MFA challenge.
+func (r *MFAChallengeRepository) Create(ctx context.Context, challenge *model.MFAChallenge) error {
+	query := `
+		INSERT INTO mfa_challenges (user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at
+	`
+	return mapError(conn(ctx, r.DB).QueryRowContext(ctx, query, challenge.UserID, challenge.TokenHash, challenge.ExpiresAt).Scan(&challenge.ID, &challenge.CreatedAt))
+}
+
+// GetByHash retrieves an MFA challenge by the hash of its token.
+func (r *MFAChallengeRepository) GetByHash(ctx context.Context, tokenHash string) (*model.MFAChallenge, error) {
+	query := `
+		SELECT id, user_id, token_hash, expires_at, attempts, used_at, created_at
+		FROM mfa_challenges
+		WHERE token_hash = $1
+	`
+	challenge := &model.MFAChallenge{}
+	err := conn(ctx, r.DB).QueryRowContext(ctx, query, tokenHash).Scan(&challenge.ID, &challenge.UserID, &challenge.TokenHash, &challenge.ExpiresAt, &challenge.Attempts, &challenge.UsedAt, &challenge.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ierr.ErrMFAChallengeNotFound
+		}
+		return nil, mapError(err)
+	}
+	return challenge, nil
+}
+
+// RecordAttempt counts a code tried against a challenge and returns the
+// number of attempts so far, including this one.
+func (r *MFAChallengeRepository) RecordAttempt(ctx context.Context, id uuid.UUID) (int, error) {
+	query := `
+		UPDATE mfa_challenges
+		SET attempts = attempts + 1
+		WHERE id = $1
+		RETURNING attempts
+	`
+	var attempts int
+	err := conn(ctx, r.DB).QueryRowContext(ctx, query, id).Scan(&attempts)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ierr.ErrMFAChallengeNotFound
+		}
+		return 0, mapError(err)
+	}
+	return attempts, nil
+}
+
+// MarkUsed consumes a challenge. It reports false when the challenge had
+// already been used, so that concurrent requests cannot both redeem it.
+func (r *MFAChallengeRepository) MarkUsed(ctx context.Context, id uuid.UUID) (bool, error) {
+	query := `
+		UPDATE mfa_challenges
+		SET used_at = NOW()
+		WHERE id = $1 AND used_at IS NULL
+	`
+	result, err := conn(ctx, r.DB).ExecContext(ctx, query, id)
+	if err != nil {
+		return false, mapError(err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, mapError(err)
+	}
+	return rows == 1, nil
+}
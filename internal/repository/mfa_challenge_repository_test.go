@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMFAChallengeRepository_Create(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewMFAChallengeRepository(db)
+
+	now := time.Now()
+	challenge := &model.MFAChallenge{UserID: uuid.New(), TokenHash: "token_hash", ExpiresAt: now.Add(5 * time.Minute)}
+	newUUID := uuid.New()
+
+	mock.ExpectQuery(`INSERT INTO mfa_challenges`).
+		WithArgs(challenge.UserID, challenge.TokenHash, challenge.ExpiresAt).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(newUUID, now))
+
+	err = repo.Create(context.Background(), challenge)
+
+	assert.NoError(t, err)
+	assert.Equal(t, newUUID, challenge.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMFAChallengeRepository_GetByHash(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewMFAChallengeRepository(db)
+
+	now := time.Now()
+	challenge := &model.MFAChallenge{ID: uuid.New(), UserID: uuid.New(), TokenHash: "token_hash", ExpiresAt: now.Add(5 * time.Minute), Attempts: 2, CreatedAt: now}
+	columns := []string{"id", "user_id", "token_hash", "expires_at", "attempts", "used_at", "created_at"}
+
+	mock.ExpectQuery(`SELECT (.+) FROM mfa_challenges WHERE token_hash = \$1`).
+		WithArgs(challenge.TokenHash).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(challenge.ID, challenge.UserID, challenge.TokenHash, challenge.ExpiresAt, challenge.Attempts, nil, challenge.CreatedAt))
+
+	found, err := repo.GetByHash(context.Background(), challenge.TokenHash)
+	assert.NoError(t, err)
+	assert.Equal(t, challenge, found)
+
+	mock.ExpectQuery(`SELECT (.+) FROM mfa_challenges WHERE token_hash = \$1`).
+		WithArgs("missing").
+		WillReturnRows(sqlmock.NewRows(columns))
+
+	found, err = repo.GetByHash(context.Background(), "missing")
+	assert.ErrorIs(t, err, ierr.ErrMFAChallengeNotFound)
+	assert.Nil(t, found)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMFAChallengeRepository_RecordAttempt(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewMFAChallengeRepository(db)
+
+	id := uuid.New()
+	mock.ExpectQuery(`UPDATE mfa_challenges SET attempts = attempts \+ 1 WHERE id = \$1 RETURNING attempts`).
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"attempts"}).AddRow(3))
+
+	attempts, err := repo.RecordAttempt(context.Background(), id)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMFAChallengeRepository_MarkUsed(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewMFAChallengeRepository(db)
+
+	id := uuid.New()
+	mock.ExpectExec(`UPDATE mfa_challenges SET used_at = NOW\(\) WHERE id = \$1 AND used_at IS NULL`).
+		WithArgs(id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE mfa_challenges SET used_at = NOW\(\) WHERE id = \$1 AND used_at IS NULL`).
+		WithArgs(id).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	used, err := repo.MarkUsed(context.Background(), id)
+	assert.NoError(t, err)
+	assert.True(t, used)
+
+	used, err = repo.MarkUsed(context.Background(), id)
+	assert.NoError(t, err)
+	assert.False(t, used)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+)
+
+type MFARecoveryCodeRepository struct {
+	DB *sql.DB
+}
+
+func NewMFARecoveryCodeRepository(db *sql.DB) IMFARecoveryCodeRepository {
+	return &MFARecoveryCodeRepository{DB: db}
+}
+
+// ReplaceForUser stores a new set of recovery code hashes for a user and
+// discards the old ones, in a single statement.
+func (r *MFARecoveryCodeRepository) ReplaceForUser(ctx context.Context, userID uuid.UUID, codeHashes []string) error {
+	query := `
+		WITH deleted AS (
+			DELETE FROM mfa_recovery_codes WHERE user_id = $1
+		)
+		INSERT INTO mfa_recovery_codes (user_id, code_hash)
+		SELECT $1, unnest(string_to_array($2, ','))
+	`
+	_, err := conn(ctx, r.DB).ExecContext(ctx, query, userID, joinTextArray(codeHashes))
+	return mapError(err)
+}
+
+// Use consumes one of a user's recovery codes. It reports false when the
+// code does not exist or was already used.
+func (r *MFARecoveryCodeRepository) Use(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error) {
+	query := `
+		UPDATE mfa_recovery_codes
+		SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`
+	result, err := conn(ctx, r.DB).ExecContext(ctx, query, userID, codeHash)
+	if err != nil {
+		return false, mapError(err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, mapError(err)
+	}
+	return rows == 1, nil
+}
+
+// DeleteForUser removes all of a user's recovery codes.
+func (r *MFARecoveryCodeRepository) DeleteForUser(ctx context.Context, userID uuid.UUID) error {
+	query := `DELETE FROM mfa_recovery_codes WHERE user_id = $1`
+	_, err := conn(ctx, r.DB).ExecContext(ctx, query, userID)
+	return mapError(err)
+}
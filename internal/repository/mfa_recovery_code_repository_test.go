@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMFARecoveryCodeRepository_ReplaceForUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewMFARecoveryCodeRepository(db)
+
+	userID := uuid.New()
+	mock.ExpectExec(`WITH deleted AS \( DELETE FROM mfa_recovery_codes WHERE user_id = \$1 \) INSERT INTO mfa_recovery_codes`).
+		WithArgs(userID, "hash1,hash2").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	err = repo.ReplaceForUser(context.Background(), userID, []string{"hash1", "hash2"})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMFARecoveryCodeRepository_Use(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewMFARecoveryCodeRepository(db)
+
+	userID := uuid.New()
+	mock.ExpectExec(`UPDATE mfa_recovery_codes SET used_at = NOW\(\) WHERE user_id = \$1 AND code_hash = \$2 AND used_at IS NULL`).
+		WithArgs(userID, "hash1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE mfa_recovery_codes SET used_at = NOW\(\) WHERE user_id = \$1 AND code_hash = \$2 AND used_at IS NULL`).
+		WithArgs(userID, "hash1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	used, err := repo.Use(context.Background(), userID, "hash1")
+	assert.NoError(t, err)
+	assert.True(t, used)
+
+	used, err = repo.Use(context.Background(), userID, "hash1")
+	assert.NoError(t, err)
+	assert.False(t, used)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMFARecoveryCodeRepository_DeleteForUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewMFARecoveryCodeRepository(db)
+
+	userID := uuid.New()
+	mock.ExpectExec(`DELETE FROM mfa_recovery_codes WHERE user_id = \$1`).
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 10))
+
+	assert.NoError(t, repo.DeleteForUser(context.Background(), userID))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/model"
+
+	"github.com/google/uuid"
+)
+
+type MFARepository struct {
+	DB *sql.DB
+}
+
+func NewMFARepository(db *sql.DB) IMFARepository {
+	return &MFARepository{DB: db}
+}
+
+// Get retrieves a user's second factor, confirmed or not.
+func (r *MFARepository) Get(ctx context.Context, userID uuid.UUID) (*model.MFA, error) {
+	query := `
+		SELECT user_id, secret, confirmed_at, last_used_step, created_at
+		FROM user_mfa
+		WHERE user_id = $1
+	`
+	mfa := &model.MFA{}
+	err := conn(ctx, r.DB).QueryRowContext(ctx, query, userID).Scan(&mfa.UserID, &mfa.Secret, &mfa.ConfirmedAt, &mfa.LastUsedStep, &mfa.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ierr.ErrMFANotEnrolled
+		}
+		return nil, mapError(err)
+	}
+	return mfa, nil
+}
+
+// Enroll stores a new unconfirmed secret for a user, replacing any earlier
+// unconfirmed one. It reports false, and changes nothing, when the user
+// already has a confirmed second factor.
+func (r *MFARepository) Enroll(ctx context.Context, userID uuid.UUID, secret string) (bool, error) {
+	query := `
+		INSERT INTO user_mfa (user_id, secret)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, last_used_step = 0, created_at = NOW()
+		WHERE user_mfa.confirmed_at IS NULL
+	`
+	result, err := conn(ctx, r.DB).ExecContext(ctx, query, userID, secret)
+	if err != nil {
+		return false, mapError(err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, mapError(err)
+	}
+	return rows == 1, nil
+}
+
+// Confirm turns on a user's second factor.
+func (r *MFARepository) Confirm(ctx context.Context, userID uuid.UUID) error {
+	query := `
+		UPDATE user_mfa
+		SET confirmed_at = NOW()
+		WHERE user_id = $1 AND confirmed_at IS NULL
+	`
+	_, err := conn(ctx, r.DB).ExecContext(ctx, query, userID)
+	return mapError(err)
+}
+
+// UseStep records that the code for a time step was accepted. It reports
+// false when a code for that or a later step was already used, so that a
+// code cannot be replayed.
+func (r *MFARepository) UseStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	query := `
+		UPDATE user_mfa
+		SET last_used_step = $2
+		WHERE user_id = $1 AND last_used_step < $2
+	`
+	result, err := conn(ctx, r.DB).ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return false, mapError(err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, mapError(err)
+	}
+	return rows == 1, nil
+}
+
+// Delete removes a user's second factor.
+func (r *MFARepository) Delete(ctx context.Context, userID uuid.UUID) error {
+	query := `DELETE FROM user_mfa WHERE user_id = $1`
+	_, err := conn(ctx, r.DB).ExecContext(ctx, query, userID)
+	return mapError(err)
+}
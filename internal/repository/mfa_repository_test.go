@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMFARepository_Get(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewMFARepository(db)
+
+	now := time.Now()
+	mfa := &model.MFA{UserID: uuid.New(), Secret: "JBSWY3DPEHPK3PXP", ConfirmedAt: &now, LastUsedStep: 42, CreatedAt: now}
+	columns := []string{"user_id", "secret", "confirmed_at", "last_used_step", "created_at"}
+
+	mock.ExpectQuery(`SELECT (.+) FROM user_mfa WHERE user_id = \$1`).
+		WithArgs(mfa.UserID).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(mfa.UserID, mfa.Secret, mfa.ConfirmedAt, mfa.LastUsedStep, mfa.CreatedAt))
+
+	found, err := repo.Get(context.Background(), mfa.UserID)
+	assert.NoError(t, err)
+	assert.Equal(t, mfa, found)
+
+	missing := uuid.New()
+	mock.ExpectQuery(`SELECT (.+) FROM user_mfa WHERE user_id = \$1`).
+		WithArgs(missing).
+		WillReturnRows(sqlmock.NewRows(columns))
+
+	found, err = repo.Get(context.Background(), missing)
+	assert.ErrorIs(t, err, ierr.ErrMFANotEnrolled)
+	assert.Nil(t, found)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMFARepository_Enroll(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewMFARepository(db)
+
+	userID := uuid.New()
+	mock.ExpectExec(`INSERT INTO user_mfa (.+) ON CONFLICT \(user_id\) DO UPDATE (.+) WHERE user_mfa.confirmed_at IS NULL`).
+		WithArgs(userID, "SECRET").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO user_mfa`).
+		WithArgs(userID, "SECRET").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	enrolled, err := repo.Enroll(context.Background(), userID, "SECRET")
+	assert.NoError(t, err)
+	assert.True(t, enrolled)
+
+	// Already confirmed.
+	enrolled, err = repo.Enroll(context.Background(), userID, "SECRET")
+	assert.NoError(t, err)
+	assert.False(t, enrolled)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMFARepository_UseStep(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewMFARepository(db)
+
+	userID := uuid.New()
+	mock.ExpectExec(`UPDATE user_mfa SET last_used_step = \$2 WHERE user_id = \$1 AND last_used_step < \$2`).
+		WithArgs(userID, int64(100)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE user_mfa SET last_used_step = \$2 WHERE user_id = \$1 AND last_used_step < \$2`).
+		WithArgs(userID, int64(100)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	used, err := repo.UseStep(context.Background(), userID, 100)
+	assert.NoError(t, err)
+	assert.True(t, used)
+
+	used, err = repo.UseStep(context.Background(), userID, 100)
+	assert.NoError(t, err)
+	assert.False(t, used)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMFARepository_ConfirmAndDelete(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewMFARepository(db)
+
+	userID := uuid.New()
+	mock.ExpectExec(`UPDATE user_mfa SET confirmed_at = NOW\(\) WHERE user_id = \$1 AND confirmed_at IS NULL`).
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM user_mfa WHERE user_id = \$1`).
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, repo.Confirm(context.Background(), userID))
+	assert.NoError(t, repo.Delete(context.Background(), userID))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,17 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockImpersonationAuditRepository struct {
+	mock.Mock
+}
+
+func (m *MockImpersonationAuditRepository) Create(ctx context.Context, entry *model.ImpersonationAudit) error {
+	args := m.Called(ctx, entry)
+	return args.Error(0)
+}
@@ -0,0 +1,41 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockMFARepository struct {
+	mock.Mock
+}
+
+func (m *MockMFARepository) Get(ctx context.Context, userID uuid.UUID) (*model.MFA, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.MFA), args.Error(1)
+}
+
+func (m *MockMFARepository) Enroll(ctx context.Context, userID uuid.UUID, secret string) (bool, error) {
+	args := m.Called(ctx, userID, secret)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockMFARepository) Confirm(ctx context.Context, userID uuid.UUID) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockMFARepository) UseStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	args := m.Called(ctx, userID, step)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockMFARepository) Delete(ctx context.Context, userID uuid.UUID) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
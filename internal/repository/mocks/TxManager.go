@@ -0,0 +1,21 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/faizalom/go-api/internal/repository"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockTxManager struct {
+	mock.Mock
+}
+
+// WithinTx runs fn without a transaction, unless the mock returns an error.
+func (m *MockTxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error, opts ...repository.TxOption) error {
+	args := m.Called(ctx)
+	if err := args.Error(0); err != nil {
+		return err
+	}
+	return fn(ctx)
+}
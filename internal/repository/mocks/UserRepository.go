@@ -2,6 +2,7 @@ package mocks
 
 import (
 	"context"
+	"time"
 
 	"github.com/faizalom/go-api/internal/model"
 	"github.com/google/uuid"
@@ -27,17 +28,70 @@ func (m *MockUserRepository) GetByEmail(ctx context.Context, email string) (*mod
 	return args.Get(0).(*model.User), args.String(1), args.Error(2)
 }
 
-func (m *MockUserRepository) Update(ctx context.Context, id uuid.UUID, user *model.User) error {
+func (m *MockUserRepository) Update(ctx context.Context, id uuid.UUID, user *model.User) (bool, error) {
 	args := m.Called(ctx, id, user)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockUserRepository) Delete(ctx context.Context, id uuid.UUID, version int) (bool, error) {
+	args := m.Called(ctx, id, version)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockUserRepository) List(ctx context.Context, q model.UserListQuery) ([]*model.User, error) {
+	args := m.Called(ctx, q)
+	return args.Get(0).([]*model.User), args.Error(1)
+}
+
+func (m *MockUserRepository) GetDeletedByID(ctx context.Context, id uuid.UUID) (*model.User, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+func (m *MockUserRepository) Restore(ctx context.Context, id uuid.UUID) (bool, error) {
+	args := m.Called(ctx, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockUserRepository) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	args := m.Called(ctx, before)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockUserRepository) Search(ctx context.Context, q model.UserSearchQuery) ([]*model.UserSearchResult, error) {
+	args := m.Called(ctx, q)
+	return args.Get(0).([]*model.UserSearchResult), args.Error(1)
+}
+
+func (m *MockUserRepository) UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	args := m.Called(ctx, id, passwordHash)
 	return args.Error(0)
 }
 
-func (m *MockUserRepository) Delete(ctx context.Context, id uuid.UUID) error {
+func (m *MockUserRepository) GetPasswordHash(ctx context.Context, id uuid.UUID) (string, error) {
+	args := m.Called(ctx, id)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockUserRepository) MarkEmailVerified(ctx context.Context, id uuid.UUID, email string) (bool, error) {
+	args := m.Called(ctx, id, email)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockUserRepository) RecordLoginFailure(ctx context.Context, id uuid.UUID) (int, error) {
 	args := m.Called(ctx, id)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockUserRepository) LockUntil(ctx context.Context, id uuid.UUID, until time.Time) error {
+	args := m.Called(ctx, id, until)
 	return args.Error(0)
 }
 
-func (m *MockUserRepository) List(ctx context.Context) ([]*model.User, error) {
-	args := m.Called(ctx)
-	return args.Get(0).([]*model.User), args.Error(1)
+func (m *MockUserRepository) ResetLoginFailures(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
 }
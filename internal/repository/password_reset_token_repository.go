@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/model"
+
+	"github.com/google/uuid"
+)
+
+type PasswordResetTokenRepository struct {
+	DB *sql.DB
+}
+
+func NewPasswordResetTokenRepository(db *sql.DB) IPasswordResetTokenRepository {
+	return &PasswordResetTokenRepository{DB: db}
+}
+
+// Create stores a new password reset token.
+func (r *PasswordResetTokenRepository) Create(ctx context.Context, token *model.PasswordResetToken) error {
+	query := `
+		INSERT INTO password_reset_tokens (user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at
+	`
+	return mapError(conn(ctx, r.DB).QueryRowContext(ctx, query, token.UserID, token.TokenHash, token.ExpiresAt).Scan(&token.ID, &token.CreatedAt))
+}
+
+// GetByHash retrieves a password reset token by the hash of its value.
+func (r *PasswordResetTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*model.PasswordResetToken, error) {
+	query := `
+		SELECT id, user_id, token_hash, expires_at, used_at, created_at
+		FROM password_reset_tokens
+		WHERE token_hash = $1
+	`
+	token := &model.PasswordResetToken{}
+	err := conn(ctx, r.DB).QueryRowContext(ctx, query, tokenHash).Scan(&token.ID, &token.UserID, &token.TokenHash, &token.ExpiresAt, &token.UsedAt, &token.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ierr.ErrResetTokenNotFound
+		}
+		return nil, mapError(err)
+	}
+	return token, nil
+}
+
+// MarkUsed consumes a token. It reports false when the token had already
+// been used, so that concurrent requests cannot both redeem it.
+func (r *PasswordResetTokenRepository) MarkUsed(ctx context.Context, id uuid.UUID) (bool, error) {
+	query := `
+		UPDATE password_reset_tokens
+		SET used_at = NOW()
+		WHERE id = $1 AND used_at IS NULL
+	`
+	result, err := conn(ctx, r.DB).ExecContext(ctx, query, id)
+	if err != nil {
+		return false, mapError(err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, mapError(err)
+	}
+	return rows == 1, nil
+}
+
+// InvalidateForUser consumes every outstanding token of a user.
+func (r *PasswordResetTokenRepository) InvalidateForUser(ctx context.Context, userID uuid.UUID) error {
+	query := `
+		UPDATE password_reset_tokens
+		SET used_at = NOW()
+		WHERE user_id = $1 AND used_at IS NULL
+	`
+	_, err := conn(ctx, r.DB).ExecContext(ctx, query, userID)
+	return mapError(err)
+}
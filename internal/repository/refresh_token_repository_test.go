@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefreshTokenRepository_Create(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewRefreshTokenRepository(db)
+
+	now := time.Now()
+	token := &model.RefreshToken{
+		UserID:    uuid.New(),
+		FamilyID:  uuid.New(),
+		TokenHash: "token_hash",
+		ExpiresAt: now.Add(time.Hour),
+	}
+	newUUID := uuid.New()
+
+	mock.ExpectQuery(`INSERT INTO refresh_tokens`).
+		WithArgs(token.UserID, token.FamilyID, token.TokenHash, token.ExpiresAt).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(newUUID, now))
+
+	err = repo.Create(context.Background(), token)
+
+	assert.NoError(t, err)
+	assert.Equal(t, newUUID, token.ID)
+	assert.Equal(t, now, token.CreatedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRefreshTokenRepository_GetByHash(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewRefreshTokenRepository(db)
+
+	now := time.Now()
+	token := &model.RefreshToken{
+		ID:        uuid.New(),
+		UserID:    uuid.New(),
+		FamilyID:  uuid.New(),
+		TokenHash: "token_hash",
+		ExpiresAt: now.Add(time.Hour),
+		CreatedAt: now,
+	}
+
+	rows := sqlmock.NewRows([]string{"id", "user_id", "family_id", "token_hash", "expires_at", "revoked_at", "created_at"}).
+		AddRow(token.ID, token.UserID, token.FamilyID, token.TokenHash, token.ExpiresAt, nil, token.CreatedAt)
+
+	mock.ExpectQuery(`SELECT (.+) FROM refresh_tokens WHERE token_hash = \$1`).
+		WithArgs(token.TokenHash).
+		WillReturnRows(rows)
+
+	found, err := repo.GetByHash(context.Background(), token.TokenHash)
+
+	assert.NoError(t, err)
+	assert.Equal(t, token, found)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRefreshTokenRepository_GetByHash_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewRefreshTokenRepository(db)
+
+	mock.ExpectQuery(`SELECT (.+) FROM refresh_tokens`).
+		WithArgs("missing").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	found, err := repo.GetByHash(context.Background(), "missing")
+
+	assert.ErrorIs(t, err, ierr.ErrRefreshTokenNotFound)
+	assert.Nil(t, found)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRefreshTokenRepository_Revoke(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewRefreshTokenRepository(db)
+
+	id := uuid.New()
+
+	mock.ExpectExec(`UPDATE refresh_tokens SET revoked_at = NOW\(\) WHERE id = \$1 AND revoked_at IS NULL`).
+		WithArgs(id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE refresh_tokens`).
+		WithArgs(id).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	revoked, err := repo.Revoke(context.Background(), id)
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+
+	revoked, err = repo.Revoke(context.Background(), id)
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRefreshTokenRepository_RevokeFamily(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewRefreshTokenRepository(db)
+
+	familyID := uuid.New()
+
+	mock.ExpectExec(`UPDATE refresh_tokens SET revoked_at = NOW\(\) WHERE family_id = \$1`).
+		WithArgs(familyID).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	err = repo.RevokeFamily(context.Background(), familyID)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRefreshTokenRepository_RevokeAllForUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewRefreshTokenRepository(db)
+
+	userID := uuid.New()
+
+	mock.ExpectExec(`UPDATE refresh_tokens SET revoked_at = NOW\(\) WHERE user_id = \$1`).
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	err = repo.RevokeAllForUser(context.Background(), userID)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type RevokedTokenRepository struct {
+	DB *sql.DB
+}
+
+func NewRevokedTokenRepository(db *sql.DB) IRevokedTokenRepository {
+	return &RevokedTokenRepository{DB: db}
+}
+
+// Revoke records a token ID as revoked until the token would have expired anyway.
+func (r *RevokedTokenRepository) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	query := `
+		INSERT INTO revoked_tokens (jti, expires_at)
+		VALUES ($1, $2)
+		ON CONFLICT (jti) DO NOTHING
+	`
+	_, err := conn(ctx, r.DB).ExecContext(ctx, query, jti, expiresAt)
+	return mapError(err)
+}
+
+// IsRevoked reports whether a token ID has been revoked.
+func (r *RevokedTokenRepository) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)`
+	var revoked bool
+	if err := conn(ctx, r.DB).QueryRowContext(ctx, query, jti).Scan(&revoked); err != nil {
+		return false, mapError(err)
+	}
+	return revoked, nil
+}
+
+// DeleteExpired removes revocations for tokens that have expired on their own.
+func (r *RevokedTokenRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	query := `DELETE FROM revoked_tokens WHERE expires_at < NOW()`
+	result, err := conn(ctx, r.DB).ExecContext(ctx, query)
+	if err != nil {
+		return 0, mapError(err)
+	}
+	return result.RowsAffected()
+}
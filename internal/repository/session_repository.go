@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/faizalom/go-api/internal/model"
+
+	"github.com/google/uuid"
+)
+
+type SessionRepository struct {
+	DB *sql.DB
+}
+
+func NewSessionRepository(db *sql.DB) ISessionRepository {
+	return &SessionRepository{DB: db}
+}
+
+// Create stores a new session. The ID must be set by the caller, since it is
+// shared with the session's refresh token family.
+func (r *SessionRepository) Create(ctx context.Context, session *model.Session) error {
+	query := `
+		INSERT INTO sessions (id, user_id, user_agent, ip_address)
+		VALUES ($1, $2, $3, $4)
+		RETURNING created_at, last_used_at
+	`
+	return mapError(conn(ctx, r.DB).QueryRowContext(ctx, query, session.ID, session.UserID, session.UserAgent, session.IPAddress).Scan(&session.CreatedAt, &session.LastUsedAt))
+}
+
+// ListActive returns a user's sessions that have not been revoked and still
+// hold a usable refresh token, most recently used first.
+func (r *SessionRepository) ListActive(ctx context.Context, userID uuid.UUID) ([]*model.Session, error) {
+	query := `
+		SELECT s.id, s.user_id, s.user_agent, s.ip_address, s.created_at, s.last_used_at, s.revoked_at
+		FROM sessions s
+		WHERE s.user_id = $1 AND s.revoked_at IS NULL
+			AND EXISTS (
+				SELECT 1 FROM refresh_tokens t
+				WHERE t.family_id = s.id AND t.revoked_at IS NULL AND t.expires_at > NOW()
+			)
+		ORDER BY s.last_used_at DESC
+	`
+	rows, err := conn(ctx, r.DB).QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	var sessions []*model.Session
+	for rows.Next() {
+		session := &model.Session{}
+		if err := rows.Scan(&session.ID, &session.UserID, &session.UserAgent, &session.IPAddress, &session.CreatedAt, &session.LastUsedAt, &session.RevokedAt); err != nil {
+			return nil, mapError(err)
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, mapError(rows.Err())
+}
+
+// Touch records that a session's refresh token was just rotated.
+func (r *SessionRepository) Touch(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE sessions SET last_used_at = NOW() WHERE id = $1`
+	_, err := conn(ctx, r.DB).ExecContext(ctx, query, id)
+	return mapError(err)
+}
+
+// Revoke ends one of a user's sessions. It reports false when the session
+// does not exist, belongs to someone else or was already revoked.
+func (r *SessionRepository) Revoke(ctx context.Context, id, userID uuid.UUID) (bool, error) {
+	query := `
+		UPDATE sessions
+		SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`
+	result, err := conn(ctx, r.DB).ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return false, mapError(err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, mapError(err)
+	}
+	return rows == 1, nil
+}
+
+// RevokeAllForUser ends every session of a user that is not revoked yet and
+// returns their IDs.
+func (r *SessionRepository) RevokeAllForUser(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	query := `
+		UPDATE sessions
+		SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL
+		RETURNING id
+	`
+	rows, err := conn(ctx, r.DB).QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, mapError(err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, mapError(rows.Err())
+}
+
+// IsRevoked reports whether a session has been revoked. Unknown sessions,
+// such as those started before sessions were recorded, are not revoked.
+func (r *SessionRepository) IsRevoked(ctx context.Context, id uuid.UUID) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM sessions WHERE id = $1 AND revoked_at IS NOT NULL)`
+	var revoked bool
+	if err := conn(ctx, r.DB).QueryRowContext(ctx, query, id).Scan(&revoked); err != nil {
+		return false, mapError(err)
+	}
+	return revoked, nil
+}
@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionRepository_Create(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewSessionRepository(db)
+
+	now := time.Now()
+	session := &model.Session{ID: uuid.New(), UserID: uuid.New(), UserAgent: "curl/8.5.0", IPAddress: "192.0.2.1"}
+
+	mock.ExpectQuery(`INSERT INTO sessions`).
+		WithArgs(session.ID, session.UserID, session.UserAgent, session.IPAddress).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at", "last_used_at"}).AddRow(now, now))
+
+	err = repo.Create(context.Background(), session)
+
+	assert.NoError(t, err)
+	assert.Equal(t, now, session.CreatedAt)
+	assert.Equal(t, now, session.LastUsedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSessionRepository_Create_Error(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewSessionRepository(db)
+	session := &model.Session{ID: uuid.New(), UserID: uuid.New()}
+
+	mock.ExpectQuery(`INSERT INTO sessions`).
+		WillReturnError(&pgconn.PgError{Code: "57014"})
+
+	err = repo.Create(context.Background(), session)
+
+	assert.ErrorIs(t, err, ierr.ErrQueryTimeout)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSessionRepository_ListActive(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewSessionRepository(db)
+
+	now := time.Now()
+	userID := uuid.New()
+	session := &model.Session{ID: uuid.New(), UserID: userID, UserAgent: "curl/8.5.0", IPAddress: "192.0.2.1", CreatedAt: now, LastUsedAt: now}
+	columns := []string{"id", "user_id", "user_agent", "ip_address", "created_at", "last_used_at", "revoked_at"}
+
+	mock.ExpectQuery(`SELECT (.+) FROM sessions s WHERE s.user_id = \$1 AND s.revoked_at IS NULL AND EXISTS`).
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(session.ID, session.UserID, session.UserAgent, session.IPAddress, session.CreatedAt, session.LastUsedAt, nil))
+
+	sessions, err := repo.ListActive(context.Background(), userID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []*model.Session{session}, sessions)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSessionRepository_Touch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewSessionRepository(db)
+
+	id := uuid.New()
+	mock.ExpectExec(`UPDATE sessions SET last_used_at = NOW\(\) WHERE id = \$1`).
+		WithArgs(id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = repo.Touch(context.Background(), id)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSessionRepository_Revoke(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewSessionRepository(db)
+
+	id, userID := uuid.New(), uuid.New()
+	mock.ExpectExec(`UPDATE sessions SET revoked_at = NOW\(\) WHERE id = \$1 AND user_id = \$2 AND revoked_at IS NULL`).
+		WithArgs(id, userID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE sessions SET revoked_at = NOW\(\) WHERE id = \$1 AND user_id = \$2 AND revoked_at IS NULL`).
+		WithArgs(id, userID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	revoked, err := repo.Revoke(context.Background(), id, userID)
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+
+	revoked, err = repo.Revoke(context.Background(), id, userID)
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSessionRepository_RevokeAllForUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewSessionRepository(db)
+
+	userID, first, second := uuid.New(), uuid.New(), uuid.New()
+	mock.ExpectQuery(`UPDATE sessions SET revoked_at = NOW\(\) WHERE user_id = \$1 AND revoked_at IS NULL RETURNING id`).
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(first).AddRow(second))
+
+	ids, err := repo.RevokeAllForUser(context.Background(), userID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{first, second}, ids)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSessionRepository_IsRevoked(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewSessionRepository(db)
+
+	id := uuid.New()
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM sessions WHERE id = \$1 AND revoked_at IS NOT NULL\)`).
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	revoked, err := repo.IsRevoked(context.Background(), id)
+
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
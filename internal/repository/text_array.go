@@ -0,0 +1,18 @@
+package repository
+
+import "strings"
+
+// joinTextArray and splitTextArray move TEXT[] columns across the driver as a
+// comma-separated string (see string_to_array and array_to_string in the
+// queries), which every database/sql driver handles alike. Elements must not
+// contain commas.
+func joinTextArray(values []string) string {
+	return strings.Join(values, ",")
+}
+
+func splitTextArray(values string) []string {
+	if values == "" {
+		return []string{}
+	}
+	return strings.Split(values, ",")
+}
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/faizalom/go-api/internal/ierr"
+)
+
+// DBTX is what repositories run their queries against: the database, or the
+// transaction a context carries.
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type txKey struct{}
+
+// transaction is the transaction WithinTx stores in its context, with the
+// functions to run once it commits.
+type transaction struct {
+	tx          *sql.Tx
+	afterCommit []func(ctx context.Context)
+}
+
+// TxOption changes how WithinTx runs a transaction.
+type TxOption func(*txOptions)
+
+type txOptions struct {
+	isolation  sql.IsolationLevel
+	maxRetries int
+}
+
+// Isolation runs the transaction at the given isolation level.
+func Isolation(level sql.IsolationLevel) TxOption {
+	return func(o *txOptions) { o.isolation = level }
+}
+
+// MaxRetries sets how many times the transaction may be retried. Functions
+// that cannot safely run twice pass 0.
+func MaxRetries(n int) TxOption {
+	return func(o *txOptions) { o.maxRetries = n }
+}
+
+type TxManager struct {
+	DB *sql.DB
+	// Isolation and MaxRetries are the defaults for WithinTx's options.
+	Isolation  sql.IsolationLevel
+	MaxRetries int
+}
+
+func NewTxManager(db *sql.DB, isolation sql.IsolationLevel, maxRetries int) ITxManager {
+	return &TxManager{DB: db, Isolation: isolation, MaxRetries: maxRetries}
+}
+
+// retryBackoff is how long WithinTx waits before its first retry, doubling
+// with each further one.
+var retryBackoff = 10 * time.Millisecond
+
+// WithinTx runs fn in a transaction, which repositories use for queries
+// made with the context fn is given. The transaction commits if fn returns
+// nil and rolls back otherwise. If it fails to serialize with a concurrent
+// one or deadlocks, fn is run again in a new transaction, so it must not
+// keep state between runs. Called with a context that already carries a
+// transaction, fn joins it and opts are ignored.
+func (m *TxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error, opts ...TxOption) error {
+	if _, ok := ctx.Value(txKey{}).(*transaction); ok {
+		return fn(ctx)
+	}
+
+	o := txOptions{isolation: m.Isolation, maxRetries: m.MaxRetries}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	backoff := retryBackoff
+	for attempt := 0; ; attempt++ {
+		err := m.runTx(ctx, fn, &sql.TxOptions{Isolation: o.isolation})
+		if err == nil || attempt >= o.maxRetries || !isRetryable(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (m *TxManager) runTx(ctx context.Context, fn func(ctx context.Context) error, opts *sql.TxOptions) error {
+	tx, err := m.DB.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	t := &transaction{tx: tx}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, txKey{}, t)); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return mapError(err)
+	}
+	for _, f := range t.afterCommit {
+		f(ctx)
+	}
+	return nil
+}
+
+// isRetryable reports whether err means the transaction may succeed if run
+// again: it failed to serialize with a concurrent one, or deadlocked.
+func isRetryable(err error) bool {
+	return errors.Is(mapError(err), ierr.ErrSerializationFailure)
+}
+
+// AfterCommit runs fn once the transaction ctx carries has committed, and
+// not at all if it rolls back. Outside a transaction fn runs right away.
+// Side effects such as sending email belong there, so that they do not
+// happen for changes that were never saved. fn is given a context without
+// the transaction.
+func AfterCommit(ctx context.Context, fn func(ctx context.Context)) {
+	if t, ok := ctx.Value(txKey{}).(*transaction); ok {
+		t.afterCommit = append(t.afterCommit, fn)
+		return
+	}
+	fn(ctx)
+}
+
+// conn returns the transaction ctx carries, or db outside a transaction.
+func conn(ctx context.Context, db *sql.DB) DBTX {
+	if t, ok := ctx.Value(txKey{}).(*transaction); ok {
+		return t.tx
+	}
+	return db
+}
@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTxManager_WithinTx_Commit(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	txManager := NewTxManager(db, sql.LevelReadCommitted, 0)
+	repo := NewUserRepository(db)
+	userID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE users SET is_active`).WithArgs(userID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE users\s+SET failed_login_count = 0`).WithArgs(userID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	var committed bool
+	err = txManager.WithinTx(context.Background(), func(ctx context.Context) error {
+		AfterCommit(ctx, func(context.Context) { committed = true })
+		if _, err := conn(ctx, db).ExecContext(ctx, `UPDATE users SET is_active = false WHERE id = $1`, userID); err != nil {
+			return err
+		}
+		// A nested call joins the transaction.
+		return txManager.WithinTx(ctx, func(ctx context.Context) error {
+			assert.False(t, committed)
+			return repo.ResetLoginFailures(ctx, userID)
+		})
+	})
+
+	assert.NoError(t, err)
+	assert.True(t, committed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTxManager_WithinTx_Rollback(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	txManager := NewTxManager(db, sql.LevelReadCommitted, 0)
+	failure := errors.New("failure")
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	var committed bool
+	err = txManager.WithinTx(context.Background(), func(ctx context.Context) error {
+		AfterCommit(ctx, func(context.Context) { committed = true })
+		return failure
+	})
+
+	assert.ErrorIs(t, err, failure)
+	assert.False(t, committed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTxManager_WithinTx_RetriesSerializationFailure(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	retryBackoff = time.Millisecond
+	defer func() { retryBackoff = 10 * time.Millisecond }()
+
+	txManager := NewTxManager(db, sql.LevelReadCommitted, 3)
+	userID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE users SET is_active`).WithArgs(userID).WillReturnError(&pgconn.PgError{Code: "40001"})
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE users SET is_active`).WithArgs(userID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	var runs, commits int
+	err = txManager.WithinTx(context.Background(), func(ctx context.Context) error {
+		runs++
+		AfterCommit(ctx, func(context.Context) { commits++ })
+		_, err := conn(ctx, db).ExecContext(ctx, `UPDATE users SET is_active = false WHERE id = $1`, userID)
+		return err
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, runs)
+	assert.Equal(t, 1, commits)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTxManager_WithinTx_RetryLimit(t *testing.T) {
+	deadlock := &pgconn.PgError{Code: "40P01"}
+	tests := []struct {
+		name     string
+		opts     []TxOption
+		fail     error
+		wantRuns int
+	}{
+		{"default", nil, deadlock, 3},
+		{"no retries", []TxOption{MaxRetries(0)}, deadlock, 1},
+		{"other error", nil, &pgconn.PgError{Code: "23505"}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+			retryBackoff = time.Millisecond
+			defer func() { retryBackoff = 10 * time.Millisecond }()
+
+			txManager := NewTxManager(db, sql.LevelReadCommitted, 2)
+			for i := 0; i < tt.wantRuns; i++ {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			}
+
+			var runs int
+			err = txManager.WithinTx(context.Background(), func(ctx context.Context) error {
+				runs++
+				return tt.fail
+			}, tt.opts...)
+
+			assert.ErrorIs(t, err, tt.fail)
+			assert.Equal(t, tt.wantRuns, runs)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestAfterCommit_OutsideTx(t *testing.T) {
+	var ran bool
+	AfterCommit(context.Background(), func(context.Context) { ran = true })
+	assert.True(t, ran)
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/model"
+)
+
+type UserIdentityRepository struct {
+	DB *sql.DB
+}
+
+func NewUserIdentityRepository(db *sql.DB) IUserIdentityRepository {
+	return &UserIdentityRepository{DB: db}
+}
+
+// Create links an external identity to a user. It fails with
+// ierr.ErrIdentityLinked if the identity is already linked to one.
+func (r *UserIdentityRepository) Create(ctx context.Context, identity *model.UserIdentity) error {
+	query := `
+		INSERT INTO user_identities (user_id, provider, subject, email)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+	err := conn(ctx, r.DB).QueryRowContext(ctx, query, identity.UserID, identity.Provider, identity.Subject, identity.Email).Scan(&identity.ID, &identity.CreatedAt)
+	err = mapError(err)
+	if errors.Is(err, ierr.ErrUniqueViolation) {
+		return ierr.ErrIdentityLinked
+	}
+	return err
+}
+
+// GetByProviderSubject retrieves the identity a provider knows by subject.
+func (r *UserIdentityRepository) GetByProviderSubject(ctx context.Context, provider, subject string) (*model.UserIdentity, error) {
+	query := `
+		SELECT id, user_id, provider, subject, COALESCE(email, ''), created_at
+		FROM user_identities
+		WHERE provider = $1 AND subject = $2
+	`
+	identity := &model.UserIdentity{}
+	err := conn(ctx, r.DB).QueryRowContext(ctx, query, provider, subject).Scan(&identity.ID, &identity.UserID, &identity.Provider, &identity.Subject, &identity.Email, &identity.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ierr.ErrIdentityNotFound
+		}
+		return nil, mapError(err)
+	}
+	return identity, nil
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserIdentityRepository_Create(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewUserIdentityRepository(db)
+
+	now := time.Now()
+	identity := &model.UserIdentity{UserID: uuid.New(), Provider: "google", Subject: "1234", Email: "test@example.com"}
+	newUUID := uuid.New()
+
+	mock.ExpectQuery(`INSERT INTO user_identities`).
+		WithArgs(identity.UserID, identity.Provider, identity.Subject, identity.Email).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(newUUID, now))
+
+	err = repo.Create(context.Background(), identity)
+
+	assert.NoError(t, err)
+	assert.Equal(t, newUUID, identity.ID)
+	assert.Equal(t, now, identity.CreatedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserIdentityRepository_Create_AlreadyLinked(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewUserIdentityRepository(db)
+	identity := &model.UserIdentity{UserID: uuid.New(), Provider: "google", Subject: "1234", Email: "test@example.com"}
+
+	mock.ExpectQuery(`INSERT INTO user_identities`).
+		WithArgs(identity.UserID, identity.Provider, identity.Subject, identity.Email).
+		WillReturnError(&pgconn.PgError{Code: "23505", ConstraintName: "user_identities_provider_subject_key"})
+
+	err = repo.Create(context.Background(), identity)
+
+	assert.ErrorIs(t, err, ierr.ErrIdentityLinked)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserIdentityRepository_GetByProviderSubject(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewUserIdentityRepository(db)
+
+	identity := &model.UserIdentity{ID: uuid.New(), UserID: uuid.New(), Provider: "google", Subject: "1234", Email: "test@example.com", CreatedAt: time.Now()}
+	rows := sqlmock.NewRows([]string{"id", "user_id", "provider", "subject", "email", "created_at"}).
+		AddRow(identity.ID, identity.UserID, identity.Provider, identity.Subject, identity.Email, identity.CreatedAt)
+
+	mock.ExpectQuery(`SELECT (.+) FROM user_identities WHERE provider = \$1 AND subject = \$2`).
+		WithArgs("google", "1234").
+		WillReturnRows(rows)
+
+	found, err := repo.GetByProviderSubject(context.Background(), "google", "1234")
+
+	assert.NoError(t, err)
+	assert.Equal(t, identity, found)
+
+	mock.ExpectQuery(`SELECT (.+) FROM user_identities`).
+		WithArgs("google", "missing").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "provider", "subject", "email", "created_at"}))
+
+	found, err = repo.GetByProviderSubject(context.Background(), "google", "missing")
+
+	assert.ErrorIs(t, err, ierr.ErrIdentityNotFound)
+	assert.Nil(t, found)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -3,6 +3,11 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/faizalom/go-api/internal/ierr"
 	"github.com/faizalom/go-api/internal/model"
@@ -18,16 +23,28 @@ func NewUserRepository(db *sql.DB) IUserRepository {
 	return &UserRepository{DB: db}
 }
 
-// Create inserts a new user record into the database.
+// userEmailError is mapError for statements that set a user's email, which
+// turns a unique violation, since only the email of a current user can cause
+// one, into ierr.ErrUserAlreadyExists.
+func userEmailError(err error) error {
+	err = mapError(err)
+	if errors.Is(err, ierr.ErrUniqueViolation) {
+		return ierr.ErrUserAlreadyExists
+	}
+	return err
+}
+
+// Create inserts a new user record into the database. It fails with
+// ierr.ErrUserAlreadyExists if a current user has the email.
 func (r *UserRepository) Create(ctx context.Context, user *model.User, passwordHash string) (*model.User, error) {
 	query := `
-		INSERT INTO users (name, email, password_hash)
-		VALUES ($1, $2, $3)
-		RETURNING id, created_at, updated_at
+		INSERT INTO users (name, email, password_hash, roles, email_verified_at)
+		VALUES ($1, $2, $3, string_to_array($4, ','), $5)
+		RETURNING id, created_at, updated_at, version
 	`
-	err := r.DB.QueryRowContext(ctx, query, user.Name, user.Email, passwordHash).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+	err := conn(ctx, r.DB).QueryRowContext(ctx, query, user.Name, user.Email, passwordHash, joinTextArray(user.Roles), user.EmailVerifiedAt).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt, &user.Version)
 	if err != nil {
-		return nil, err
+		return nil, userEmailError(err)
 	}
 	return user, nil
 }
@@ -35,88 +52,376 @@ func (r *UserRepository) Create(ctx context.Context, user *model.User, passwordH
 // GetByID retrieves a single user by their ID.
 func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.User, error) {
 	query := `
-		SELECT id, name, email, is_active, created_at, updated_at
+		SELECT id, name, email, array_to_string(roles, ','), is_active, email_verified_at, failed_login_count, locked_until, created_at, updated_at, version
 		FROM users
 		WHERE id = $1 AND deleted_at IS NULL
 	`
 	user := &model.User{}
-	err := r.DB.QueryRowContext(ctx, query, id).Scan(&user.ID, &user.Name, &user.Email, &user.IsActive, &user.CreatedAt, &user.UpdatedAt)
+	var roles string
+	err := conn(ctx, r.DB).QueryRowContext(ctx, query, id).Scan(&user.ID, &user.Name, &user.Email, &roles, &user.IsActive, &user.EmailVerifiedAt, &user.FailedLoginCount, &user.LockedUntil, &user.CreatedAt, &user.UpdatedAt, &user.Version)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ierr.ErrUserNotFound
 		}
-		return nil, err
+		return nil, mapError(err)
 	}
+	user.Roles = splitTextArray(roles)
 	return user, nil
 }
 
 // GetByEmail retrieves a single user by their email.
 func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*model.User, string, error) {
 	query := `
-		SELECT id, name, email, password_hash, is_active, created_at, updated_at
+		SELECT id, name, email, password_hash, array_to_string(roles, ','), is_active, email_verified_at, failed_login_count, locked_until, created_at, updated_at, version
 		FROM users
 		WHERE email = $1 AND deleted_at IS NULL
 	`
 	user := &model.User{}
-	var passwordHash string
-	err := r.DB.QueryRowContext(ctx, query, email).Scan(&user.ID, &user.Name, &user.Email, &passwordHash, &user.IsActive, &user.CreatedAt, &user.UpdatedAt)
+	var passwordHash, roles string
+	err := conn(ctx, r.DB).QueryRowContext(ctx, query, email).Scan(&user.ID, &user.Name, &user.Email, &passwordHash, &roles, &user.IsActive, &user.EmailVerifiedAt, &user.FailedLoginCount, &user.LockedUntil, &user.CreatedAt, &user.UpdatedAt, &user.Version)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, "", ierr.ErrUserNotFound
 		}
-		return nil, "", err
+		return nil, "", mapError(err)
 	}
+	user.Roles = splitTextArray(roles)
 	return user, passwordHash, nil
 }
 
-// Update modifies an existing user record.
-func (r *UserRepository) Update(ctx context.Context, id uuid.UUID, user *model.User) error {
+// Update modifies an existing user record, provided it is still at
+// user.Version, and sets the user's new version and update time. It reports
+// false when the user has changed or been deleted since it was read.
+// Changing the email clears its verification, and taking another current
+// user's email fails with ierr.ErrUserAlreadyExists.
+func (r *UserRepository) Update(ctx context.Context, id uuid.UUID, user *model.User) (bool, error) {
 	query := `
 		UPDATE users
-		SET name = $1, email = $2, updated_at = NOW()
-		WHERE id = $3 AND deleted_at IS NULL
+		SET name = $1, email = $2, roles = string_to_array($3, ','), updated_at = NOW(),
+			email_verified_at = CASE WHEN email = $2 THEN email_verified_at END
+		WHERE id = $4 AND version = $5 AND deleted_at IS NULL
+		RETURNING version, updated_at
 	`
-	_, err := r.DB.ExecContext(ctx, query, user.Name, user.Email, id)
-	return err
+	err := conn(ctx, r.DB).QueryRowContext(ctx, query, user.Name, user.Email, joinTextArray(user.Roles), id, user.Version).Scan(&user.Version, &user.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, userEmailError(err)
+	}
+	return true, nil
 }
 
-// Delete marks a user as deleted (soft delete).
-func (r *UserRepository) Delete(ctx context.Context, id uuid.UUID) error {
+// UpdatePassword replaces a user's password hash.
+func (r *UserRepository) UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error {
 	query := `
 		UPDATE users
-		SET deleted_at = NOW(), updated_at = NOW()
+		SET password_hash = $1, updated_at = NOW()
+		WHERE id = $2 AND deleted_at IS NULL
+	`
+	_, err := conn(ctx, r.DB).ExecContext(ctx, query, passwordHash, id)
+	return mapError(err)
+}
+
+// MarkEmailVerified records that the user owns email. It reports false when
+// the user's email is no longer that address.
+func (r *UserRepository) MarkEmailVerified(ctx context.Context, id uuid.UUID, email string) (bool, error) {
+	query := `
+		UPDATE users
+		SET email_verified_at = COALESCE(email_verified_at, NOW()), updated_at = NOW()
+		WHERE id = $1 AND email = $2 AND deleted_at IS NULL
+	`
+	result, err := conn(ctx, r.DB).ExecContext(ctx, query, id, email)
+	if err != nil {
+		return false, mapError(err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, mapError(err)
+	}
+	return rows == 1, nil
+}
+
+// GetPasswordHash retrieves a user's password hash.
+func (r *UserRepository) GetPasswordHash(ctx context.Context, id uuid.UUID) (string, error) {
+	query := `SELECT password_hash FROM users WHERE id = $1 AND deleted_at IS NULL`
+	var passwordHash string
+	err := conn(ctx, r.DB).QueryRowContext(ctx, query, id).Scan(&passwordHash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", ierr.ErrUserNotFound
+		}
+		return "", mapError(err)
+	}
+	return passwordHash, nil
+}
+
+// RecordLoginFailure counts a failed password login and returns the number
+// of consecutive failures.
+func (r *UserRepository) RecordLoginFailure(ctx context.Context, id uuid.UUID) (int, error) {
+	query := `
+		UPDATE users
+		SET failed_login_count = failed_login_count + 1
 		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING failed_login_count
 	`
-	_, err := r.DB.ExecContext(ctx, query, id)
-	return err
+	var count int
+	err := conn(ctx, r.DB).QueryRowContext(ctx, query, id).Scan(&count)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ierr.ErrUserNotFound
+		}
+		return 0, mapError(err)
+	}
+	return count, nil
+}
+
+// LockUntil blocks password login for a user until the given time.
+func (r *UserRepository) LockUntil(ctx context.Context, id uuid.UUID, until time.Time) error {
+	query := `
+		UPDATE users
+		SET locked_until = $1
+		WHERE id = $2 AND deleted_at IS NULL
+	`
+	_, err := conn(ctx, r.DB).ExecContext(ctx, query, until, id)
+	return mapError(err)
+}
+
+// ResetLoginFailures clears a user's failed login count and any lockout.
+func (r *UserRepository) ResetLoginFailures(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE users
+		SET failed_login_count = 0, locked_until = NULL
+		WHERE id = $1 AND deleted_at IS NULL
+	`
+	_, err := conn(ctx, r.DB).ExecContext(ctx, query, id)
+	return mapError(err)
+}
+
+// Delete marks a user as deleted (soft delete), provided it is still at the
+// given version. It reports false when the user has changed or been deleted
+// since it was read.
+func (r *UserRepository) Delete(ctx context.Context, id uuid.UUID, version int) (bool, error) {
+	query := `
+		UPDATE users
+		SET deleted_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND version = $2 AND deleted_at IS NULL
+	`
+	result, err := conn(ctx, r.DB).ExecContext(ctx, query, id, version)
+	if err != nil {
+		return false, mapError(err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, mapError(err)
+	}
+	return rows == 1, nil
 }
 
-// List retrieves a list of users from the database.
-func (r *UserRepository) List(ctx context.Context) ([]*model.User, error) {
+// GetDeletedByID retrieves a single soft-deleted user by their ID.
+func (r *UserRepository) GetDeletedByID(ctx context.Context, id uuid.UUID) (*model.User, error) {
 	query := `
-		SELECT id, name, email, is_active, created_at, updated_at
+		SELECT id, name, email, array_to_string(roles, ','), is_active, email_verified_at, failed_login_count, locked_until, created_at, updated_at, deleted_at, version
 		FROM users
-		WHERE deleted_at IS NULL
-		ORDER BY created_at DESC
+		WHERE id = $1 AND deleted_at IS NOT NULL
 	`
-	rows, err := r.DB.QueryContext(ctx, query)
+	user := &model.User{}
+	var roles string
+	err := conn(ctx, r.DB).QueryRowContext(ctx, query, id).Scan(&user.ID, &user.Name, &user.Email, &roles, &user.IsActive, &user.EmailVerifiedAt, &user.FailedLoginCount, &user.LockedUntil, &user.CreatedAt, &user.UpdatedAt, &user.DeletedAt, &user.Version)
 	if err != nil {
-		return nil, err
+		if err == sql.ErrNoRows {
+			return nil, ierr.ErrUserNotFound
+		}
+		return nil, mapError(err)
+	}
+	user.Roles = splitTextArray(roles)
+	return user, nil
+}
+
+// Restore undoes the soft delete of a user. It reports false when the user
+// is not deleted, or when another current user has taken their email; if
+// that happens concurrently, it fails with ierr.ErrUserAlreadyExists.
+func (r *UserRepository) Restore(ctx context.Context, id uuid.UUID) (bool, error) {
+	query := `
+		UPDATE users
+		SET deleted_at = NULL, updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NOT NULL
+			AND NOT EXISTS (SELECT 1 FROM users other WHERE other.email = users.email AND other.deleted_at IS NULL)
+	`
+	result, err := conn(ctx, r.DB).ExecContext(ctx, query, id)
+	if err != nil {
+		return false, userEmailError(err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, mapError(err)
+	}
+	return rows == 1, nil
+}
+
+// PurgeDeleted permanently deletes the users soft-deleted before the given
+// time, with everything that belongs to them, and returns how many there
+// were.
+func (r *UserRepository) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	query := `DELETE FROM users WHERE deleted_at < $1`
+	result, err := conn(ctx, r.DB).ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, mapError(err)
+	}
+	return result.RowsAffected()
+}
+
+// userSortColumns maps the sort fields users can be listed by to their
+// columns, and whether they hold timestamps. Sort fields are never
+// interpolated into SQL otherwise.
+var userSortColumns = map[string]struct {
+	column    string
+	timestamp bool
+}{
+	model.UserSortCreatedAt: {"created_at", true},
+	model.UserSortUpdatedAt: {"updated_at", true},
+	model.UserSortName:      {"name", false},
+	model.UserSortEmail:     {"email", false},
+	model.UserSortDeletedAt: {"deleted_at", true},
+}
+
+// List retrieves a page of users matching the query's filters, in its sort
+// order, starting after its cursor. Ties are broken by ID, so that paging
+// with a cursor never skips or repeats a user. Deleted users are only listed,
+// and can only be sorted by deletion time, when the query asks for them.
+func (r *UserRepository) List(ctx context.Context, q model.UserListQuery) ([]*model.User, error) {
+	sort, ok := userSortColumns[q.Sort]
+	if !ok || (q.Sort == model.UserSortDeletedAt && !q.Deleted) {
+		return nil, ierr.ErrInvalidSort
+	}
+	order, cmp := "ASC", ">"
+	if q.Desc {
+		order, cmp = "DESC", "<"
+	}
+
+	where := []string{"deleted_at IS NULL"}
+	if q.Deleted {
+		where = []string{"deleted_at IS NOT NULL"}
+	}
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if q.IsActive != nil {
+		where = append(where, "is_active = "+arg(*q.IsActive))
+	}
+	if q.EmailDomain != "" {
+		where = append(where, "lower(split_part(email, '@', 2)) = lower("+arg(q.EmailDomain)+")")
+	}
+	if q.CreatedAfter != nil {
+		where = append(where, "created_at >= "+arg(*q.CreatedAfter))
+	}
+	if q.CreatedBefore != nil {
+		where = append(where, "created_at < "+arg(*q.CreatedBefore))
+	}
+	if q.After != nil {
+		var value any = q.After.Value
+		if sort.timestamp {
+			t, err := time.Parse(time.RFC3339Nano, q.After.Value)
+			if err != nil {
+				return nil, ierr.ErrInvalidCursor
+			}
+			value = t
+		}
+		where = append(where, fmt.Sprintf("(%s, id) %s (%s, %s)", sort.column, cmp, arg(value), arg(q.After.ID)))
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, name, email, array_to_string(roles, ','), is_active, email_verified_at, failed_login_count, locked_until, created_at, updated_at, deleted_at
+		FROM users
+		WHERE %s
+		ORDER BY %s %s, id %s
+		LIMIT %s
+	`, strings.Join(where, " AND "), sort.column, order, order, arg(q.Limit))
+	rows, err := conn(ctx, r.DB).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, mapError(err)
 	}
 	defer rows.Close()
 
 	var users []*model.User
 	for rows.Next() {
 		user := &model.User{}
-		if err := rows.Scan(&user.ID, &user.Name, &user.Email, &user.IsActive, &user.CreatedAt, &user.UpdatedAt); err != nil {
-			return nil, err
+		var roles string
+		if err := rows.Scan(&user.ID, &user.Name, &user.Email, &roles, &user.IsActive, &user.EmailVerifiedAt, &user.FailedLoginCount, &user.LockedUntil, &user.CreatedAt, &user.UpdatedAt, &user.DeletedAt); err != nil {
+			return nil, mapError(err)
 		}
+		user.Roles = splitTextArray(roles)
 		users = append(users, user)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 
 	return users, nil
-}
\ No newline at end of file
+}
+
+// Search finds users whose name or email match the query, either as words
+// (full-text search) or approximately (trigram similarity), most relevant
+// first. Ties are broken by ID, so that paging with a cursor never skips or
+// repeats a user. Only full-text matches are highlighted, and the highlights
+// are HTML-escaped apart from their <mark> tags.
+func (r *UserRepository) Search(ctx context.Context, q model.UserSearchQuery) ([]*model.UserSearchResult, error) {
+	args := []any{q.Query, q.Limit}
+	after := ""
+	if q.After != nil {
+		rank, err := strconv.ParseFloat(q.After.Value, 64)
+		if err != nil {
+			return nil, ierr.ErrInvalidCursor
+		}
+		args = append(args, rank, q.After.ID)
+		after = "WHERE (rank, id) < ($3, $4)"
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, name, email, roles, is_active, email_verified_at, failed_login_count, locked_until, created_at, updated_at, rank,
+			ts_headline('simple', %s, tsq, 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true'),
+			ts_headline('simple', %s, tsq, 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true')
+		FROM (
+			SELECT u.id, u.name, u.email, array_to_string(u.roles, ',') AS roles, u.is_active, u.email_verified_at, u.failed_login_count, u.locked_until, u.created_at, u.updated_at, tsq,
+				(ts_rank(u.search_vector, tsq) + GREATEST(word_similarity($1, u.name), word_similarity($1, u.email)))::float8 AS rank
+			FROM users u, websearch_to_tsquery('simple', $1) tsq
+			WHERE u.deleted_at IS NULL AND (u.search_vector @@ tsq OR $1 <%% u.name OR $1 <%% u.email)
+		) matches
+		%s
+		ORDER BY rank DESC, id DESC
+		LIMIT $2
+	`, escapeHTML("name"), escapeHTML("email"), after)
+	rows, err := conn(ctx, r.DB).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	var results []*model.UserSearchResult
+	for rows.Next() {
+		user := &model.User{}
+		result := &model.UserSearchResult{User: user}
+		var roles string
+		if err := rows.Scan(&user.ID, &user.Name, &user.Email, &roles, &user.IsActive, &user.EmailVerifiedAt, &user.FailedLoginCount, &user.LockedUntil, &user.CreatedAt, &user.UpdatedAt, &result.Rank, &result.Highlights.Name, &result.Highlights.Email); err != nil {
+			return nil, mapError(err)
+		}
+		user.Roles = splitTextArray(roles)
+		results = append(results, result)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, mapError(err)
+	}
+
+	return results, nil
+}
+
+// escapeHTML returns SQL that HTML-escapes the text column, so that the only
+// tags in a ts_headline of it are the ones ts_headline adds. The user's name
+// and email are otherwise copied into the highlights as they are.
+func escapeHTML(column string) string {
+	return fmt.Sprintf(`replace(replace(replace(replace(replace(%s, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;'), '''', '&#39;')`, column)
+}
@@ -2,12 +2,16 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/faizalom/go-api/internal/ierr"
 	"github.com/faizalom/go-api/internal/model"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -24,14 +28,15 @@ func TestUserRepository_Create(t *testing.T) {
 	user := &model.User{
 		Name:  "test user",
 		Email: "test@example.com",
+		Roles: []string{"athlete", "coach"},
 	}
 	passwordHash := "password_hash"
 	newUUID := uuid.New()
 
 	mock.ExpectQuery(`INSERT INTO users`).
-		WithArgs(user.Name, user.Email, passwordHash).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).
-			AddRow(newUUID, now, now))
+		WithArgs(user.Name, user.Email, passwordHash, "athlete,coach", nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "version"}).
+			AddRow(newUUID, now, now, 1))
 
 	createdUser, err := repo.Create(context.Background(), user, passwordHash)
 
@@ -40,6 +45,48 @@ func TestUserRepository_Create(t *testing.T) {
 	assert.Equal(t, newUUID, createdUser.ID)
 	assert.Equal(t, now, createdUser.CreatedAt)
 	assert.Equal(t, now, createdUser.UpdatedAt)
+	assert.Equal(t, 1, createdUser.Version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_Create_EmailTaken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewUserRepository(db)
+	user := &model.User{Name: "test user", Email: "test@example.com", Roles: []string{"athlete"}}
+
+	mock.ExpectQuery(`INSERT INTO users`).
+		WithArgs(user.Name, user.Email, "password_hash", "athlete", nil).
+		WillReturnError(&pgconn.PgError{Code: "23505", ConstraintName: "idx_users_email_active"})
+
+	_, err = repo.Create(context.Background(), user, "password_hash")
+
+	assert.ErrorIs(t, err, ierr.ErrUserAlreadyExists)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_GetByID_Error(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewUserRepository(db)
+	userID := uuid.New()
+
+	mock.ExpectQuery(`SELECT (.+) FROM users WHERE id = \$1`).
+		WithArgs(userID).
+		WillReturnError(&pgconn.PgError{Code: "57014"})
+
+	_, err = repo.GetByID(context.Background(), userID)
+
+	assert.ErrorIs(t, err, ierr.ErrQueryTimeout)
+	assert.NotErrorIs(t, err, ierr.ErrUserNotFound)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
@@ -54,18 +101,21 @@ func TestUserRepository_GetByID(t *testing.T) {
 
 	now := time.Now()
 	user := &model.User{
-		ID:        uuid.New(),
-		Name:      "test user",
-		Email:     "test@example.com",
-		IsActive:  true,
-		CreatedAt: now,
-		UpdatedAt: now,
+		ID:              uuid.New(),
+		Name:            "test user",
+		Email:           "test@example.com",
+		Roles:           []string{"admin"},
+		IsActive:        true,
+		EmailVerifiedAt: &now,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+		Version:         4,
 	}
 
-	rows := sqlmock.NewRows([]string{"id", "name", "email", "is_active", "created_at", "updated_at"}).
-		AddRow(user.ID, user.Name, user.Email, user.IsActive, user.CreatedAt, user.UpdatedAt)
+	rows := sqlmock.NewRows([]string{"id", "name", "email", "roles", "is_active", "email_verified_at", "failed_login_count", "locked_until", "created_at", "updated_at", "version"}).
+		AddRow(user.ID, user.Name, user.Email, "admin", user.IsActive, now, 0, nil, user.CreatedAt, user.UpdatedAt, 4)
 
-	mock.ExpectQuery(`SELECT id, name, email, is_active, created_at, updated_at FROM users WHERE id = \$1`).
+	mock.ExpectQuery(`SELECT id, name, email, array_to_string\(roles, ','\), is_active, email_verified_at, failed_login_count, locked_until, created_at, updated_at, version FROM users WHERE id = \$1`).
 		WithArgs(user.ID).
 		WillReturnRows(rows)
 
@@ -88,19 +138,23 @@ func TestUserRepository_GetByEmail(t *testing.T) {
 
 	now := time.Now()
 	user := &model.User{
-		ID:        uuid.New(),
-		Name:      "test user",
-		Email:     "test@example.com",
-		IsActive:  true,
-		CreatedAt: now,
-		UpdatedAt: now,
+		ID:               uuid.New(),
+		Name:             "test user",
+		Email:            "test@example.com",
+		Roles:            []string{"athlete"},
+		IsActive:         true,
+		FailedLoginCount: 3,
+		LockedUntil:      &now,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+		Version:          2,
 	}
 	passwordHash := "password_hash"
 
-	rows := sqlmock.NewRows([]string{"id", "name", "email", "password_hash", "is_active", "created_at", "updated_at"}).
-		AddRow(user.ID, user.Name, user.Email, passwordHash, user.IsActive, user.CreatedAt, user.UpdatedAt)
+	rows := sqlmock.NewRows([]string{"id", "name", "email", "password_hash", "roles", "is_active", "email_verified_at", "failed_login_count", "locked_until", "created_at", "updated_at", "version"}).
+		AddRow(user.ID, user.Name, user.Email, passwordHash, "athlete", user.IsActive, nil, 3, now, user.CreatedAt, user.UpdatedAt, 2)
 
-	mock.ExpectQuery(`SELECT id, name, email, password_hash, is_active, created_at, updated_at FROM users WHERE email = \$1`).
+	mock.ExpectQuery(`SELECT id, name, email, password_hash, array_to_string\(roles, ','\), is_active, email_verified_at, failed_login_count, locked_until, created_at, updated_at, version FROM users WHERE email = \$1`).
 		WithArgs(user.Email).
 		WillReturnRows(rows)
 
@@ -123,22 +177,58 @@ func TestUserRepository_Update(t *testing.T) {
 	repo := NewUserRepository(db)
 
 	user := &model.User{
-		ID:    uuid.New(),
-		Name:  "updated name",
-		Email: "updated@example.com",
+		ID:      uuid.New(),
+		Name:    "updated name",
+		Email:   "updated@example.com",
+		Roles:   []string{"coach"},
+		Version: 3,
 	}
+	now := time.Now()
 
-	mock.ExpectExec(`UPDATE users`).
-		WithArgs(user.Name, user.Email, user.ID).
+	mock.ExpectQuery(`UPDATE users SET .* WHERE id = \$4 AND version = \$5 AND deleted_at IS NULL RETURNING version, updated_at`).
+		WithArgs(user.Name, user.Email, "coach", user.ID, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "updated_at"}).AddRow(4, now))
+	mock.ExpectQuery(`UPDATE users`).
+		WithArgs(user.Name, user.Email, "coach", user.ID, 4).
+		WillReturnError(sql.ErrNoRows)
+
+	updated, err := repo.Update(context.Background(), user.ID, user)
+
+	assert.NoError(t, err)
+	assert.True(t, updated)
+	assert.Equal(t, 4, user.Version)
+	assert.Equal(t, now, user.UpdatedAt)
+
+	// Someone else has changed the user since.
+	updated, err = repo.Update(context.Background(), user.ID, user)
+
+	assert.NoError(t, err)
+	assert.False(t, updated)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_UpdatePassword(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewUserRepository(db)
+
+	userID := uuid.New()
+
+	mock.ExpectExec(`UPDATE users SET password_hash = \$1`).
+		WithArgs("new_hash", userID).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	err = repo.Update(context.Background(), user.ID, user)
+	err = repo.UpdatePassword(context.Background(), userID, "new_hash")
 
 	assert.NoError(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestUserRepository_Delete(t *testing.T) {
+func TestUserRepository_MarkEmailVerified(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
@@ -149,13 +239,126 @@ func TestUserRepository_Delete(t *testing.T) {
 
 	userID := uuid.New()
 
-	mock.ExpectExec(`UPDATE users`).
+	mock.ExpectExec(`UPDATE users SET email_verified_at = COALESCE\(email_verified_at, NOW\(\)\)`).
+		WithArgs(userID, "test@example.com").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE users SET email_verified_at`).
+		WithArgs(userID, "old@example.com").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	verified, err := repo.MarkEmailVerified(context.Background(), userID, "test@example.com")
+	assert.NoError(t, err)
+	assert.True(t, verified)
+
+	// The user has changed their email since.
+	verified, err = repo.MarkEmailVerified(context.Background(), userID, "old@example.com")
+	assert.NoError(t, err)
+	assert.False(t, verified)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_LoginFailures(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewUserRepository(db)
+
+	userID := uuid.New()
+	until := time.Now().Add(15 * time.Minute)
+
+	mock.ExpectQuery(`UPDATE users SET failed_login_count = failed_login_count \+ 1 WHERE id = \$1 AND deleted_at IS NULL RETURNING failed_login_count`).
 		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"failed_login_count"}).AddRow(10))
+	mock.ExpectExec(`UPDATE users SET locked_until = \$1 WHERE id = \$2`).
+		WithArgs(until, userID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE users SET failed_login_count = 0, locked_until = NULL WHERE id = \$1`).
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	count, err := repo.RecordLoginFailure(context.Background(), userID)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, count)
+
+	assert.NoError(t, repo.LockUntil(context.Background(), userID, until))
+	assert.NoError(t, repo.ResetLoginFailures(context.Background(), userID))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_GetPasswordHash(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewUserRepository(db)
+
+	userID := uuid.New()
+
+	mock.ExpectQuery(`SELECT password_hash FROM users WHERE id = \$1`).
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"password_hash"}).AddRow("hashed_password"))
+
+	hash, err := repo.GetPasswordHash(context.Background(), userID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "hashed_password", hash)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_GetPasswordHash_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewUserRepository(db)
+
+	userID := uuid.New()
+
+	mock.ExpectQuery(`SELECT password_hash FROM users WHERE id = \$1`).
+		WithArgs(userID).
+		WillReturnError(sql.ErrNoRows)
+
+	_, err = repo.GetPasswordHash(context.Background(), userID)
+
+	assert.ErrorIs(t, err, ierr.ErrUserNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_Delete(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewUserRepository(db)
+
+	userID := uuid.New()
+
+	mock.ExpectExec(`UPDATE users SET deleted_at = NOW\(\), updated_at = NOW\(\) WHERE id = \$1 AND version = \$2`).
+		WithArgs(userID, 2).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE users`).
+		WithArgs(userID, 2).
+		WillReturnResult(sqlmock.NewResult(0, 0))
 
-	err = repo.Delete(context.Background(), userID)
+	deleted, err := repo.Delete(context.Background(), userID, 2)
 
 	assert.NoError(t, err)
+	assert.True(t, deleted)
+
+	deleted, err = repo.Delete(context.Background(), userID, 2)
+
+	assert.NoError(t, err)
+	assert.False(t, deleted)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
@@ -174,6 +377,7 @@ func TestUserRepository_List(t *testing.T) {
 			ID:        uuid.New(),
 			Name:      "test user 1",
 			Email:     "test1@example.com",
+			Roles:     []string{"athlete"},
 			IsActive:  true,
 			CreatedAt: now,
 			UpdatedAt: now,
@@ -182,24 +386,219 @@ func TestUserRepository_List(t *testing.T) {
 			ID:        uuid.New(),
 			Name:      "test user 2",
 			Email:     "test2@example.com",
+			Roles:     []string{"admin", "coach"},
 			IsActive:  true,
 			CreatedAt: now,
 			UpdatedAt: now,
 		},
 	}
 
-	rows := sqlmock.NewRows([]string{"id", "name", "email", "is_active", "created_at", "updated_at"})
+	rows := sqlmock.NewRows([]string{"id", "name", "email", "roles", "is_active", "email_verified_at", "failed_login_count", "locked_until", "created_at", "updated_at", "deleted_at"})
 	for _, user := range users {
-		rows.AddRow(user.ID, user.Name, user.Email, user.IsActive, user.CreatedAt, user.UpdatedAt)
+		rows.AddRow(user.ID, user.Name, user.Email, strings.Join(user.Roles, ","), user.IsActive, nil, 0, nil, user.CreatedAt, user.UpdatedAt, nil)
 	}
 
-	mock.ExpectQuery(`SELECT id, name, email, is_active, created_at, updated_at FROM users`).
+	mock.ExpectQuery(`SELECT id, name, email, array_to_string\(roles, ','\), is_active, email_verified_at, failed_login_count, locked_until, created_at, updated_at, deleted_at FROM users WHERE deleted_at IS NULL ORDER BY created_at DESC, id DESC LIMIT \$1`).
+		WithArgs(51).
 		WillReturnRows(rows)
 
-	foundUsers, err := repo.List(context.Background())
+	foundUsers, err := repo.List(context.Background(), model.UserListQuery{UserListRequest: model.UserListRequest{Sort: model.UserSortCreatedAt, Desc: true, Limit: 51}})
 
 	assert.NoError(t, err)
 	assert.NotNil(t, foundUsers)
 	assert.Equal(t, users, foundUsers)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestUserRepository_List_FiltersAndCursor(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewUserRepository(db)
+
+	active := true
+	after := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	lastID := uuid.New()
+	q := model.UserListQuery{
+		UserListRequest: model.UserListRequest{
+			IsActive:     &active,
+			EmailDomain:  "example.com",
+			CreatedAfter: &after,
+			Sort:         model.UserSortCreatedAt,
+			Limit:        11,
+		},
+		After: &model.UserCursor{Sort: model.UserSortCreatedAt, Value: "2024-02-01T10:00:00.123456Z", ID: lastID},
+	}
+	last := time.Date(2024, 2, 1, 10, 0, 0, 123456000, time.UTC)
+
+	mock.ExpectQuery(`FROM users WHERE deleted_at IS NULL AND is_active = \$1 AND lower\(split_part\(email, '@', 2\)\) = lower\(\$2\) AND created_at >= \$3 AND \(created_at, id\) > \(\$4, \$5\) ORDER BY created_at ASC, id ASC LIMIT \$6`).
+		WithArgs(true, "example.com", after, last, lastID, 11).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "roles", "is_active", "email_verified_at", "failed_login_count", "locked_until", "created_at", "updated_at", "deleted_at"}))
+
+	users, err := repo.List(context.Background(), q)
+
+	assert.NoError(t, err)
+	assert.Empty(t, users)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_List_Invalid(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewUserRepository(db)
+
+	_, err = repo.List(context.Background(), model.UserListQuery{UserListRequest: model.UserListRequest{Sort: "password_hash", Limit: 10}})
+	assert.ErrorIs(t, err, ierr.ErrInvalidSort)
+
+	_, err = repo.List(context.Background(), model.UserListQuery{UserListRequest: model.UserListRequest{Sort: model.UserSortDeletedAt, Limit: 10}})
+	assert.ErrorIs(t, err, ierr.ErrInvalidSort)
+
+	_, err = repo.List(context.Background(), model.UserListQuery{
+		UserListRequest: model.UserListRequest{Sort: model.UserSortCreatedAt, Limit: 10},
+		After:           &model.UserCursor{Sort: model.UserSortCreatedAt, Value: "yesterday", ID: uuid.New()},
+	})
+	assert.ErrorIs(t, err, ierr.ErrInvalidCursor)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_Search(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewUserRepository(db)
+
+	now := time.Now()
+	id, lastID := uuid.New(), uuid.New()
+	rows := sqlmock.NewRows([]string{"id", "name", "email", "roles", "is_active", "email_verified_at", "failed_login_count", "locked_until", "created_at", "updated_at", "rank", "name_highlight", "email_highlight"}).
+		AddRow(id, "Ann Smith", "ann@example.com", "athlete", true, nil, 0, nil, now, now, 0.75, "<mark>Ann</mark> Smith", "ann@example.com")
+
+	// The name and email are HTML-escaped before ts_headline adds its tags.
+	mock.ExpectQuery(`ts_headline\('simple', replace\(replace\(replace\(replace\(replace\(name, '&', '&amp;'\), '<', '&lt;'\), '>', '&gt;'\), '"', '&quot;'\), '''', '&#39;'\), tsq, .*`+
+		`FROM users u, websearch_to_tsquery\('simple', \$1\) tsq WHERE u.deleted_at IS NULL AND \(u.search_vector @@ tsq OR \$1 <% u.name OR \$1 <% u.email\) \) matches WHERE \(rank, id\) < \(\$3, \$4\) ORDER BY rank DESC, id DESC LIMIT \$2`).
+		WithArgs("ann", 11, 0.9, lastID).
+		WillReturnRows(rows)
+
+	results, err := repo.Search(context.Background(), model.UserSearchQuery{
+		UserSearchRequest: model.UserSearchRequest{Query: "ann", Limit: 11},
+		After:             &model.UserCursor{Sort: model.UserSortRank, Desc: true, Value: "0.9", ID: lastID},
+	})
+
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, id, results[0].User.ID)
+	assert.Equal(t, []string{"athlete"}, results[0].User.Roles)
+	assert.Equal(t, 0.75, results[0].Rank)
+	assert.Equal(t, "<mark>Ann</mark> Smith", results[0].Highlights.Name)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_List_Deleted(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewUserRepository(db)
+
+	now := time.Now()
+	id := uuid.New()
+	mock.ExpectQuery(`FROM users WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC, id DESC LIMIT \$1`).
+		WithArgs(11).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "roles", "is_active", "email_verified_at", "failed_login_count", "locked_until", "created_at", "updated_at", "deleted_at"}).
+			AddRow(id, "gone", "gone@example.com", "athlete", true, nil, 0, nil, now, now, now))
+
+	users, err := repo.List(context.Background(), model.UserListQuery{UserListRequest: model.UserListRequest{Deleted: true, Sort: model.UserSortDeletedAt, Desc: true, Limit: 11}})
+
+	assert.NoError(t, err)
+	assert.Len(t, users, 1)
+	assert.Equal(t, &now, users[0].DeletedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_GetDeletedByID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewUserRepository(db)
+
+	now := time.Now()
+	id := uuid.New()
+	mock.ExpectQuery(`FROM users WHERE id = \$1 AND deleted_at IS NOT NULL`).
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "roles", "is_active", "email_verified_at", "failed_login_count", "locked_until", "created_at", "updated_at", "deleted_at", "version"}).
+			AddRow(id, "gone", "gone@example.com", "athlete", true, nil, 0, nil, now, now, now, 5))
+	mock.ExpectQuery(`FROM users WHERE id = \$1 AND deleted_at IS NOT NULL`).
+		WithArgs(id).
+		WillReturnError(sql.ErrNoRows)
+
+	user, err := repo.GetDeletedByID(context.Background(), id)
+	assert.NoError(t, err)
+	assert.Equal(t, "gone@example.com", user.Email)
+	assert.Equal(t, &now, user.DeletedAt)
+
+	_, err = repo.GetDeletedByID(context.Background(), id)
+	assert.ErrorIs(t, err, ierr.ErrUserNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_Restore(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewUserRepository(db)
+
+	id := uuid.New()
+	mock.ExpectExec(`UPDATE users SET deleted_at = NULL, updated_at = NOW\(\) WHERE id = \$1 AND deleted_at IS NOT NULL AND NOT EXISTS`).
+		WithArgs(id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE users SET deleted_at = NULL`).
+		WithArgs(id).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	restored, err := repo.Restore(context.Background(), id)
+	assert.NoError(t, err)
+	assert.True(t, restored)
+
+	restored, err = repo.Restore(context.Background(), id)
+	assert.NoError(t, err)
+	assert.False(t, restored)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_PurgeDeleted(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewUserRepository(db)
+
+	before := time.Now().Add(-30 * 24 * time.Hour)
+	mock.ExpectExec(`DELETE FROM users WHERE deleted_at < \$1`).
+		WithArgs(before).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	purged, err := repo.PurgeDeleted(context.Background(), before)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), purged)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -2,28 +2,123 @@ package router
 
 import (
 	"database/sql"
+	"net/http"
+	"time"
 
+	"github.com/faizalom/go-api/internal/authz"
+	"github.com/faizalom/go-api/internal/config"
 	"github.com/faizalom/go-api/internal/handler"
+	"github.com/faizalom/go-api/internal/jwtkeys"
+	"github.com/faizalom/go-api/internal/middleware"
+	"github.com/faizalom/go-api/internal/oidc"
+	"github.com/faizalom/go-api/internal/password"
 	"github.com/faizalom/go-api/internal/repository"
 	"github.com/faizalom/go-api/internal/service"
+	"github.com/faizalom/go-api/pkg/mailer"
 )
 
-func NewDependencies(db *sql.DB) *Handlers {
+func NewDependencies(db *sql.DB, keys *jwtkeys.KeySet, policy *authz.Policy, passwords *password.Policy) *Handlers {
 	// Repositories
 	repoA := repository.NewRepoA(db)
 	repoB := repository.NewRepoB(db)
+	userRepo := repository.NewUserRepository(db)
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db)
+	revokedTokenRepo := repository.NewRevokedTokenRepository(db)
+	coachAthleteRepo := repository.NewCoachAthleteRepository(db)
+	apiKeyRepo := repository.NewAPIKeyRepository(db)
+	userIdentityRepo := repository.NewUserIdentityRepository(db)
+	passwordResetTokenRepo := repository.NewPasswordResetTokenRepository(db)
+	emailVerificationTokenRepo := repository.NewEmailVerificationTokenRepository(db)
+	mfaRepo := repository.NewMFARepository(db)
+	mfaRecoveryCodeRepo := repository.NewMFARecoveryCodeRepository(db)
+	mfaChallengeRepo := repository.NewMFAChallengeRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+	impersonationAuditRepo := repository.NewImpersonationAuditRepository(db)
+	txManager := repository.NewTxManager(db, config.App.Database.TxIsolation(), config.App.Database.MaxTxRetries)
+
+	// Outgoing mail
+	var mail mailer.Mailer = mailer.NewLogMailer(config.App.Mail.From)
+	if config.App.Mail.Driver == "file" {
+		mail = mailer.NewFileMailer(config.App.Mail.From, config.App.Mail.Dir)
+	}
 
 	// Services
 	serviceA := service.NewServiceA(repoA)
 	serviceB := service.NewServiceB(repoB)
+	revocationService := service.NewRevocationService(revokedTokenRepo)
+	sessionService := service.NewSessionService(sessionRepo, refreshTokenRepo)
+	authService := service.NewAuthService(userRepo, refreshTokenRepo, sessionRepo, mfaRepo, mfaChallengeRepo, revocationService, keys, service.NewLoginThrottle(config.App.LoginProtection))
+	mfaService := service.NewMFAService(mfaRepo, mfaRecoveryCodeRepo, mfaChallengeRepo, userRepo, authService)
+	apiKeyService := service.NewAPIKeyService(apiKeyRepo, userRepo)
+	passwordService := service.NewPasswordService(userRepo, passwordResetTokenRepo, sessionService, authService, mail, passwords)
+	emailVerificationService := service.NewEmailVerificationService(userRepo, emailVerificationTokenRepo, mail)
+	userService := service.NewUserService(userRepo, txManager, passwords, emailVerificationService)
+	userBulkService := service.NewUserBulkService(userService, txManager, config.App.UserImport)
+	impersonationService := service.NewImpersonationService(userRepo, impersonationAuditRepo, keys)
+	oidcService := service.NewOIDCService(userIdentityRepo, userRepo, userService, authService)
+
+	// External identity providers
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	var oidcProviders []*oidc.Provider
+	for _, cfg := range config.App.OIDC.Providers {
+		oidcProviders = append(oidcProviders, oidc.NewProvider(cfg, httpClient))
+	}
+
+	// Authorization
+	authorizer := authz.NewPolicyAuthorizer(policy, coachAthleteRepo)
 
 	// Handlers
 	exampleHandler := handler.NewExampleHandler(serviceA, serviceB)
+	authHandler := handler.NewAuthHandler(authService)
+	jwksHandler := handler.NewJWKSHandler(keys)
+	apiKeyHandler := handler.NewAPIKeyHandler(apiKeyService)
+	oidcHandler := handler.NewOIDCHandler(oidcService, oidcProviders...)
+	passwordHandler := handler.NewPasswordHandler(passwordService)
+	emailVerificationHandler := handler.NewEmailVerificationHandler(emailVerificationService)
+	mfaHandler := handler.NewMFAHandler(mfaService)
+	sessionHandler := handler.NewSessionHandler(sessionService)
+	impersonationHandler := handler.NewImpersonationHandler(impersonationService)
+	userBulkHandler := handler.NewUserBulkHandler(userBulkService, authorizer)
 
 	// Assemble all handlers
 	return &Handlers{
-		Login:   handler.LoginHandler,
+		Login:   authHandler.Login,
+		Refresh: authHandler.Refresh,
+		Logout:  authHandler.Logout,
 		Profile: handler.ProfileHandler,
 		Example: exampleHandler.HandleRequest,
+		JWKS:    jwksHandler.ServeJWKS,
+
+		OIDCLogin:    oidcHandler.Login,
+		OIDCCallback: oidcHandler.Callback,
+
+		ForgotPassword: passwordHandler.ForgotPassword,
+		ResetPassword:  passwordHandler.ResetPassword,
+		ChangePassword: passwordHandler.ChangePassword,
+
+		EnrollMFA:  mfaHandler.Enroll,
+		ConfirmMFA: mfaHandler.Confirm,
+		DisableMFA: mfaHandler.Disable,
+		VerifyMFA:  mfaHandler.Verify,
+
+		UnlockUser: authHandler.Unlock,
+
+		ListSessions:  sessionHandler.List,
+		RevokeSession: sessionHandler.Revoke,
+
+		Impersonate: impersonationHandler.Impersonate,
+
+		VerifyEmail:        emailVerificationHandler.Verify,
+		ResendVerification: emailVerificationHandler.Resend,
+
+		CreateAPIKey: apiKeyHandler.Create,
+		ListAPIKeys:  apiKeyHandler.List,
+		RevokeAPIKey: apiKeyHandler.Revoke,
+
+		Authenticate: middleware.NewAuthMiddleware(keys, revocationService, sessionService, apiKeyService),
+		Authorizer:   authorizer,
+		Users:        userService,
+		UserBulk:     userBulkService,
+		UserBatch:    userBulkHandler.RunBatch,
 	}
 }
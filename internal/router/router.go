@@ -2,31 +2,115 @@ package router
 
 import (
 	"database/sql"
+	"expvar"
 	"net/http"
 
+	"github.com/faizalom/go-api/internal/authz"
+	"github.com/faizalom/go-api/internal/jwtkeys"
 	"github.com/faizalom/go-api/internal/middleware"
+	"github.com/faizalom/go-api/internal/password"
+	"github.com/faizalom/go-api/internal/service"
 )
 
-// Handlers now includes the user CRUD handlers.
+// Handlers holds the route handlers and the authentication middleware they share.
 type Handlers struct {
 	Login   http.HandlerFunc
+	Refresh http.HandlerFunc
+	Logout  http.HandlerFunc
 	Profile http.HandlerFunc
 	Example http.HandlerFunc
+	JWKS    http.HandlerFunc
+
+	OIDCLogin    http.HandlerFunc
+	OIDCCallback http.HandlerFunc
+
+	ForgotPassword http.HandlerFunc
+	ResetPassword  http.HandlerFunc
+	ChangePassword http.HandlerFunc
+
+	EnrollMFA  http.HandlerFunc
+	ConfirmMFA http.HandlerFunc
+	DisableMFA http.HandlerFunc
+	VerifyMFA  http.HandlerFunc
+
+	UnlockUser http.HandlerFunc
+
+	ListSessions  http.HandlerFunc
+	RevokeSession http.HandlerFunc
+
+	Impersonate http.HandlerFunc
+
+	VerifyEmail        http.HandlerFunc
+	ResendVerification http.HandlerFunc
+
+	CreateAPIKey http.HandlerFunc
+	ListAPIKeys  http.HandlerFunc
+	RevokeAPIKey http.HandlerFunc
+
+	// Authenticate verifies the caller's token on protected routes.
+	Authenticate func(http.Handler) http.Handler
+	// Authorizer decides what an authenticated caller may do.
+	Authorizer authz.Authorizer
+	// Users is the user service the user routes are served by.
+	Users service.IUserService
+	// UserBulk imports and exports users for the user routes.
+	UserBulk service.IUserBulkService
+	// UserBatch runs a batch of changes to users in one transaction.
+	UserBatch http.HandlerFunc
 }
 
 // New creates and configures a new router, injecting the handlers.
-func New(db *sql.DB) *http.ServeMux {
-	h := NewDependencies(db)
+func New(db *sql.DB, keys *jwtkeys.KeySet, policy *authz.Policy, passwords *password.Policy) *http.ServeMux {
+	h := NewDependencies(db, keys, policy, passwords)
 	mux := http.NewServeMux()
 
+	// Public keys for services that verify our tokens
+	mux.HandleFunc("GET /.well-known/jwks.json", h.JWKS)
+
+	// Runtime counters, such as failed and locked out logins
+	mux.Handle("GET /debug/vars", h.protected(expvar.Handler(), middleware.Authorize(h.Authorizer, authz.ActionMetricsRead, middleware.Metrics)))
+
 	// Create a new router for the /api/v1 prefix
 	apiV1Mux := http.NewServeMux()
-	apiV1Mux.HandleFunc("/login", h.Login)
-	apiV1Mux.Handle("/profile", protected(h.Profile))
-	apiV1Mux.Handle("/example", protected(h.Example))
+	apiV1Mux.HandleFunc("POST /login", h.Login)
+	apiV1Mux.HandleFunc("POST /token/refresh", h.Refresh)
+	apiV1Mux.HandleFunc("POST /auth/password/forgot", h.ForgotPassword)
+	apiV1Mux.HandleFunc("POST /auth/password/reset", h.ResetPassword)
+	apiV1Mux.HandleFunc("GET /auth/verify", h.VerifyEmail)
+	apiV1Mux.HandleFunc("POST /auth/verify/resend", h.ResendVerification)
+	apiV1Mux.HandleFunc("POST /auth/mfa/verify", h.VerifyMFA)
+	apiV1Mux.HandleFunc("GET /auth/oidc/{provider}/login", h.OIDCLogin)
+	apiV1Mux.HandleFunc("GET /auth/oidc/{provider}/callback", h.OIDCCallback)
+	apiV1Mux.Handle("POST /logout", h.protected(h.Logout))
+	apiV1Mux.Handle("/profile", h.protected(h.Profile))
+	apiV1Mux.Handle("/example", h.protected(h.Example))
+	apiV1Mux.Handle("GET /sessions", h.protected(h.ListSessions))
+	apiV1Mux.Handle("DELETE /sessions/{id}", h.protected(h.RevokeSession))
+
+	// API keys for machine clients
+	apiKeys := func(action string, next http.HandlerFunc) http.Handler {
+		return h.protected(next, middleware.Authorize(h.Authorizer, action, middleware.APIKeyCollection))
+	}
+	apiV1Mux.Handle("GET /api-keys", apiKeys(authz.ActionAPIKeyList, h.ListAPIKeys))
+	apiV1Mux.Handle("POST /api-keys", apiKeys(authz.ActionAPIKeyCreate, h.CreateAPIKey))
+	apiV1Mux.Handle("DELETE /api-keys/{id}", apiKeys(authz.ActionAPIKeyRevoke, h.RevokeAPIKey))
 
 	// Mount the user router
-	apiV1Mux.Handle("/users/", http.StripPrefix("/users", protected(NewUserRouter(db))))
+	apiV1Mux.Handle("/users/", http.StripPrefix("/users", h.protected(NewUserRouter(h.Users, h.UserBulk, h.Authorizer))))
+	apiV1Mux.Handle("POST /users:batch", h.protected(h.UserBatch))
+	apiV1Mux.Handle("PUT /users/{id}/password", h.protected(h.ChangePassword, middleware.Authorize(h.Authorizer, authz.ActionUserChangePassword, middleware.UserFromPath)))
+	apiV1Mux.Handle("POST /users/{id}/unlock", h.protected(h.UnlockUser, middleware.Authorize(h.Authorizer, authz.ActionUserUnlock, middleware.UserFromPath)))
+
+	// Two-factor authentication, managed by the user themselves
+	mfa := func(next http.HandlerFunc) http.Handler {
+		return h.protected(next, middleware.Authorize(h.Authorizer, authz.ActionUserManageMFA, middleware.UserFromPath))
+	}
+	apiV1Mux.Handle("POST /users/{id}/mfa", mfa(h.EnrollMFA))
+	apiV1Mux.Handle("POST /users/{id}/mfa/confirm", mfa(h.ConfirmMFA))
+	apiV1Mux.Handle("POST /users/{id}/mfa/disable", mfa(h.DisableMFA))
+
+	// Support staff acting as a user
+	apiV1Mux.Handle("POST /admin/impersonate/{id}", h.protected(h.Impersonate, middleware.Authorize(h.Authorizer, authz.ActionUserImpersonate, middleware.UserFromPath)))
 
 	// Wrap the apiV1Mux in a handler that strips the /api/v1 prefix
 	mux.Handle("/api/v1/", http.StripPrefix("/api/v1", apiV1Mux))
@@ -34,7 +118,10 @@ func New(db *sql.DB) *http.ServeMux {
 	return mux
 }
 
-// protected is a helper that wraps a handler with standard protected-route middleware.
-func protected(h http.Handler) http.Handler {
-	return middleware.Chain(h, middleware.LoggingMiddleware, middleware.AuthMiddleware)
+// protected is a helper that wraps a handler with standard protected-route
+// middleware. Extra middlewares, e.g. middleware.Authorize, run after the
+// caller has been authenticated.
+func (h *Handlers) protected(next http.Handler, extra ...func(http.Handler) http.Handler) http.Handler {
+	middlewares := append([]func(http.Handler) http.Handler{middleware.LoggingMiddleware, h.Authenticate}, extra...)
+	return middleware.Chain(next, middlewares...)
 }
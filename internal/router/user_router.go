@@ -1,24 +1,34 @@
 package router
 
 import (
-	"database/sql"
 	"net/http"
 
+	"github.com/faizalom/go-api/internal/authz"
 	"github.com/faizalom/go-api/internal/handler"
-	"github.com/faizalom/go-api/internal/repository"
+	"github.com/faizalom/go-api/internal/middleware"
 	"github.com/faizalom/go-api/internal/service"
 )
 
-func NewUserRouter(db *sql.DB) http.Handler {
-	userRepo := repository.NewUserRepository(db)
-	userService := service.NewUserService(userRepo)
-	userHandler := handler.NewUserHandler(userService)
+func NewUserRouter(userService service.IUserService, userBulkService service.IUserBulkService, authorizer authz.Authorizer) http.Handler {
+	userHandler := handler.NewUserHandler(userService, authorizer)
+	userBulkHandler := handler.NewUserBulkHandler(userBulkService, authorizer)
+
+	// Who may do what is decided by the authorization policy.
+	can := func(action string, resource func(*http.Request) authz.Resource) func(http.Handler) http.Handler {
+		return middleware.Authorize(authorizer, action, resource)
+	}
 
 	mux := http.NewServeMux()
-	mux.HandleFunc("GET /", userHandler.ListUsers)
-	mux.HandleFunc("POST /", userHandler.CreateUser)
-	mux.HandleFunc("GET /{id}", userHandler.GetUserByID)
-	mux.HandleFunc("PUT /{id}", userHandler.UpdateUser)
-	mux.HandleFunc("DELETE /{id}", userHandler.DeleteUser)
+	mux.Handle("GET /", middleware.Chain(http.HandlerFunc(userHandler.ListUsers), can(authz.ActionUserList, middleware.UserCollection)))
+	mux.Handle("POST /", middleware.Chain(http.HandlerFunc(userHandler.CreateUser), can(authz.ActionUserCreate, middleware.UserCollection)))
+	mux.Handle("GET /search", middleware.Chain(http.HandlerFunc(userHandler.SearchUsers), can(authz.ActionUserList, middleware.UserCollection)))
+	mux.Handle("POST /import", middleware.Chain(http.HandlerFunc(userBulkHandler.ImportUsers), can(authz.ActionUserCreate, middleware.UserCollection)))
+	mux.Handle("GET /export", middleware.Chain(http.HandlerFunc(userBulkHandler.ExportUsers), can(authz.ActionUserList, middleware.UserCollection)))
+	mux.Handle("GET /deleted", middleware.Chain(http.HandlerFunc(userHandler.ListDeletedUsers), can(authz.ActionUserRestore, middleware.UserCollection)))
+	mux.Handle("GET /{id}", middleware.Chain(http.HandlerFunc(userHandler.GetUserByID), can(authz.ActionUserRead, middleware.UserFromPath)))
+	mux.Handle("PUT /{id}", middleware.Chain(http.HandlerFunc(userHandler.UpdateUser), can(authz.ActionUserUpdate, middleware.UserFromPath)))
+	mux.Handle("PATCH /{id}", middleware.Chain(http.HandlerFunc(userHandler.PatchUser), can(authz.ActionUserUpdate, middleware.UserFromPath)))
+	mux.Handle("POST /{id}/restore", middleware.Chain(http.HandlerFunc(userHandler.RestoreUser), can(authz.ActionUserRestore, middleware.UserFromPath)))
+	mux.Handle("DELETE /{id}", middleware.Chain(http.HandlerFunc(userHandler.DeleteUser), can(authz.ActionUserDelete, middleware.UserFromPath)))
 	return mux
 }
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/faizalom/go-api/internal/repository/mocks"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeyService_Create(t *testing.T) {
+	mockRepo := new(mocks.MockAPIKeyRepository)
+	mockUserRepo := new(mocks.MockUserRepository)
+	apiKeyService := NewAPIKeyService(mockRepo, mockUserRepo)
+
+	userID := uuid.New()
+	mockUserRepo.On("GetByID", mock.Anything, userID).Return(&model.User{ID: userID}, nil)
+
+	var stored *model.APIKey
+	mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*model.APIKey")).Run(func(args mock.Arguments) {
+		stored = args.Get(1).(*model.APIKey)
+		stored.ID = uuid.New()
+	}).Return(nil)
+
+	created, err := apiKeyService.Create(context.Background(), &model.NewAPIKeyRequest{UserID: userID, Name: "ci", Scopes: []string{"user:read"}})
+
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(created.Key, "ak_"+stored.Prefix+"_"))
+	assert.Equal(t, hashToken(created.Key), stored.KeyHash)
+	assert.NotContains(t, stored.KeyHash, created.Key)
+	assert.Equal(t, stored.ID, created.ID)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAPIKeyService_Create_InvalidScope(t *testing.T) {
+	apiKeyService := NewAPIKeyService(new(mocks.MockAPIKeyRepository), new(mocks.MockUserRepository))
+
+	_, err := apiKeyService.Create(context.Background(), &model.NewAPIKeyRequest{UserID: uuid.New(), Name: "ci", Scopes: []string{"user:fly"}})
+	assert.ErrorIs(t, err, ierr.ErrInvalidScope)
+
+	_, err = apiKeyService.Create(context.Background(), &model.NewAPIKeyRequest{UserID: uuid.New(), Name: "ci"})
+	assert.ErrorIs(t, err, ierr.ErrInvalidScope)
+}
+
+func TestAPIKeyService_Authenticate(t *testing.T) {
+	mockRepo := new(mocks.MockAPIKeyRepository)
+	mockUserRepo := new(mocks.MockUserRepository)
+	apiKeyService := NewAPIKeyService(mockRepo, mockUserRepo)
+
+	user := &model.User{ID: uuid.New(), Name: "bot", Email: "bot@example.com", Roles: []string{model.RoleAdmin}, IsActive: true}
+	key := &model.APIKey{ID: uuid.New(), UserID: user.ID, Prefix: "abcd", Scopes: []string{"user:list"}}
+
+	mockRepo.On("GetByHash", mock.Anything, hashToken("ak_abcd_secret")).Return(key, nil)
+	mockRepo.On("TouchLastUsed", mock.Anything, key.ID).Return(nil)
+	mockUserRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+
+	claims, err := apiKeyService.Authenticate(context.Background(), "ak_abcd_secret")
+
+	require.NoError(t, err)
+	assert.Equal(t, user.ID.String(), claims.Subject)
+	assert.Equal(t, user.Email, claims.Email)
+	assert.Equal(t, user.Roles, claims.Roles)
+	assert.Equal(t, key.Scopes, claims.Scopes)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAPIKeyService_Authenticate_Rejected(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	userID := uuid.New()
+
+	tests := []struct {
+		name string
+		key  *model.APIKey
+		err  error
+		user *model.User
+		want error
+	}{
+		{name: "unknown", err: ierr.ErrAPIKeyNotFound, want: ierr.ErrInvalidAPIKey},
+		{name: "revoked", key: &model.APIKey{UserID: userID, RevokedAt: &past}, want: ierr.ErrInvalidAPIKey},
+		{name: "expired", key: &model.APIKey{UserID: userID, ExpiresAt: &past}, want: ierr.ErrInvalidAPIKey},
+		{name: "inactive owner", key: &model.APIKey{UserID: userID}, user: &model.User{ID: userID}, want: ierr.ErrUserInactive},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mocks.MockAPIKeyRepository)
+			mockUserRepo := new(mocks.MockUserRepository)
+			apiKeyService := NewAPIKeyService(mockRepo, mockUserRepo)
+
+			mockRepo.On("GetByHash", mock.Anything, mock.Anything).Return(tt.key, tt.err)
+			if tt.user != nil {
+				mockUserRepo.On("GetByID", mock.Anything, userID).Return(tt.user, nil)
+			}
+
+			claims, err := apiKeyService.Authenticate(context.Background(), "ak_abcd_secret")
+
+			assert.ErrorIs(t, err, tt.want)
+			assert.Nil(t, claims)
+			mockRepo.AssertNotCalled(t, "TouchLastUsed", mock.Anything, mock.Anything)
+		})
+	}
+}
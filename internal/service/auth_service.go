@@ -0,0 +1,371 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/faizalom/go-api/internal/config"
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/jwtkeys"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/faizalom/go-api/internal/repository"
+	"github.com/faizalom/go-api/pkg/logger"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// dummyPasswordHash is compared against when no user matches the email so that
+// unknown and known emails take roughly the same time to reject.
+const dummyPasswordHash = "$2a$10$O0fhC4X0eToyN9WxjAYu9.hvn1AQbISaH8h9abKLtxN9MMCsr6fka"
+
+type IAuthService interface {
+	Login(ctx context.Context, req *model.LoginRequest) (*model.TokenResponse, error)
+	Refresh(ctx context.Context, req *model.RefreshRequest) (*model.TokenResponse, error)
+	Logout(ctx context.Context, claims *model.CustomClaims) error
+	IssueTokens(ctx context.Context, user *model.User, client model.ClientInfo) (*model.TokenResponse, error)
+	CompleteLogin(ctx context.Context, user *model.User, client model.ClientInfo) (*model.TokenResponse, error)
+	Unlock(ctx context.Context, userID uuid.UUID) error
+	AllowLogin(user *model.User, client model.ClientInfo) error
+	FailLogin(ctx context.Context, user *model.User, client model.ClientInfo) error
+	ResetLoginFailures(ctx context.Context, user *model.User) error
+}
+
+type AuthService struct {
+	userRepo         repository.IUserRepository
+	refreshTokenRepo repository.IRefreshTokenRepository
+	sessionRepo      repository.ISessionRepository
+	mfaRepo          repository.IMFARepository
+	challengeRepo    repository.IMFAChallengeRepository
+	revocations      IRevocationService
+	keys             *jwtkeys.KeySet
+	throttle         *LoginThrottle
+}
+
+func NewAuthService(userRepo repository.IUserRepository, refreshTokenRepo repository.IRefreshTokenRepository, sessionRepo repository.ISessionRepository, mfaRepo repository.IMFARepository, challengeRepo repository.IMFAChallengeRepository, revocations IRevocationService, keys *jwtkeys.KeySet, throttle *LoginThrottle) IAuthService {
+	return &AuthService{userRepo: userRepo, refreshTokenRepo: refreshTokenRepo, sessionRepo: sessionRepo, mfaRepo: mfaRepo, challengeRepo: challengeRepo, revocations: revocations, keys: keys, throttle: throttle}
+}
+
+// Login verifies the user's credentials and starts a new session.
+// Users with two-factor authentication get an MFA token instead, which
+// IMFAService.Verify exchanges for the tokens once they enter a code.
+//
+// Repeated failures for an email or from an IP are throttled, and an account
+// is locked after config.App.LoginProtection.LockoutThreshold consecutive
+// wrong passwords; both are reported as ierr.ErrTooManyLoginAttempts.
+func (s *AuthService) Login(ctx context.Context, req *model.LoginRequest) (*model.TokenResponse, error) {
+	if err := s.throttle.Allow(req.Email, req.Client.IP); err != nil {
+		return nil, err
+	}
+
+	user, passwordHash, err := s.userRepo.GetByEmail(ctx, req.Email)
+	if err != nil {
+		if !errors.Is(err, ierr.ErrUserNotFound) {
+			return nil, err
+		}
+		// Burn the same amount of time as a real comparison before rejecting.
+		bcrypt.CompareHashAndPassword([]byte(dummyPasswordHash), []byte(req.Password))
+		s.throttle.Fail(req.Email, req.Client.IP)
+		return nil, ierr.ErrInvalidCredentials
+	}
+
+	if err := lockedOut(user); err != nil {
+		return nil, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(req.Password)); err != nil {
+		if err := s.FailLogin(ctx, user, req.Client); err != nil {
+			return nil, err
+		}
+		return nil, ierr.ErrInvalidCredentials
+	}
+
+	return s.signIn(ctx, user, req.Client)
+}
+
+// Refresh exchanges a refresh token for a new token pair. Each refresh token
+// can be used once; presenting one that was already rotated revokes the
+// whole family, since it means the token has leaked.
+func (s *AuthService) Refresh(ctx context.Context, req *model.RefreshRequest) (*model.TokenResponse, error) {
+	stored, err := s.refreshTokenRepo.GetByHash(ctx, hashToken(req.RefreshToken))
+	if err != nil {
+		if errors.Is(err, ierr.ErrRefreshTokenNotFound) {
+			return nil, ierr.ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	if stored.RevokedAt != nil {
+		return nil, s.revokeReusedFamily(ctx, stored)
+	}
+
+	if time.Now().After(stored.ExpiresAt) {
+		return nil, ierr.ErrInvalidRefreshToken
+	}
+
+	revoked, err := s.refreshTokenRepo.Revoke(ctx, stored.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !revoked {
+		// Another request rotated this token between our read and write.
+		return nil, s.revokeReusedFamily(ctx, stored)
+	}
+
+	user, err := s.userRepo.GetByID(ctx, stored.UserID)
+	if err != nil {
+		if errors.Is(err, ierr.ErrUserNotFound) {
+			return nil, ierr.ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+	if !user.IsActive {
+		return nil, ierr.ErrUserInactive
+	}
+
+	if err := s.sessionRepo.Touch(ctx, stored.FamilyID); err != nil {
+		return nil, err
+	}
+	return s.issueTokens(ctx, user, stored.FamilyID)
+}
+
+// Logout revokes the access token the request was made with, its session and
+// every refresh token belonging to the session.
+func (s *AuthService) Logout(ctx context.Context, claims *model.CustomClaims) error {
+	if claims.ID != "" && claims.ExpiresAt != nil {
+		if err := s.revocations.Revoke(ctx, claims.ID, claims.ExpiresAt.Time); err != nil {
+			return err
+		}
+	}
+
+	if sessionID, err := uuid.Parse(claims.SessionID); err == nil {
+		if userID, err := uuid.Parse(claims.Subject); err == nil {
+			if _, err := s.sessionRepo.Revoke(ctx, sessionID, userID); err != nil {
+				return err
+			}
+		}
+		if err := s.refreshTokenRepo.RevokeFamily(ctx, sessionID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// IssueTokens signs in a user who has been authenticated by other means,
+// e.g. an external identity provider. Like Login, it returns an MFA token
+// instead of a token pair when the user has two-factor authentication
+// enabled.
+func (s *AuthService) IssueTokens(ctx context.Context, user *model.User, client model.ClientInfo) (*model.TokenResponse, error) {
+	return s.signIn(ctx, user, client)
+}
+
+// CompleteLogin starts a new session for a user who has passed every factor
+// they have, forgetting their failed logins.
+func (s *AuthService) CompleteLogin(ctx context.Context, user *model.User, client model.ClientInfo) (*model.TokenResponse, error) {
+	if err := canSignIn(user); err != nil {
+		return nil, err
+	}
+	if err := s.ResetLoginFailures(ctx, user); err != nil {
+		return nil, err
+	}
+	return s.startSession(ctx, user, client)
+}
+
+// signIn continues a login once the user's first factor has been checked,
+// however it was: with the user's second factor if they have one, or else
+// by starting a session.
+func (s *AuthService) signIn(ctx context.Context, user *model.User, client model.ClientInfo) (*model.TokenResponse, error) {
+	if err := canSignIn(user); err != nil {
+		return nil, err
+	}
+
+	mfa, err := s.mfaRepo.Get(ctx, user.ID)
+	if err != nil && !errors.Is(err, ierr.ErrMFANotEnrolled) {
+		return nil, err
+	}
+	if mfa != nil && mfa.Enabled() {
+		// The failures are only forgotten once the second factor is right
+		// too, or knowing the password would allow unlimited code guesses.
+		return s.challengeMFA(ctx, user)
+	}
+	return s.CompleteLogin(ctx, user, client)
+}
+
+// Unlock lifts a login lockout and forgets the user's failed logins.
+func (s *AuthService) Unlock(ctx context.Context, userID uuid.UUID) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.userRepo.ResetLoginFailures(ctx, userID); err != nil {
+		return err
+	}
+	s.throttle.Reset(user.Email)
+
+	loginMetrics.Add("unlocks", 1)
+	logger.Info.Printf("Login unlocked for user %s", userID)
+	return nil
+}
+
+// AllowLogin returns an error wrapping ierr.ErrTooManyLoginAttempts while
+// logins for the user, or from the client's IP, are throttled or the
+// account is locked. Login checks this itself; IMFAService.Verify checks it
+// before trying a code.
+func (s *AuthService) AllowLogin(user *model.User, client model.ClientInfo) error {
+	if err := s.throttle.Allow(user.Email, client.IP); err != nil {
+		return err
+	}
+	return lockedOut(user)
+}
+
+// FailLogin records a wrong password or second factor, which counts towards
+// the throttle and the account lockout alike.
+func (s *AuthService) FailLogin(ctx context.Context, user *model.User, client model.ClientInfo) error {
+	s.throttle.Fail(user.Email, client.IP)
+	return s.recordLoginFailure(ctx, user)
+}
+
+// ResetLoginFailures forgets the user's failed logins once they have signed
+// in with every factor they have.
+func (s *AuthService) ResetLoginFailures(ctx context.Context, user *model.User) error {
+	s.throttle.Reset(user.Email)
+	if user.FailedLoginCount > 0 {
+		return s.userRepo.ResetLoginFailures(ctx, user.ID)
+	}
+	return nil
+}
+
+// canSignIn reports why the user may not sign in however they authenticate:
+// the account is inactive, or its email has to be verified first.
+func canSignIn(user *model.User) error {
+	if !user.IsActive {
+		return ierr.ErrUserInactive
+	}
+	if config.App.EmailVerification.Required && user.EmailVerifiedAt == nil {
+		return ierr.ErrEmailNotVerified
+	}
+	return nil
+}
+
+// lockedOut returns an error wrapping ierr.ErrTooManyLoginAttempts while the
+// user's account is locked.
+func lockedOut(user *model.User) error {
+	if user.LockedUntil != nil {
+		if wait := time.Until(*user.LockedUntil); wait > 0 {
+			return &ierr.RetryAfterError{Err: ierr.ErrTooManyLoginAttempts, RetryAfter: wait}
+		}
+	}
+	return nil
+}
+
+// recordLoginFailure counts a wrong password or code against the account and locks
+// it once the lockout threshold is reached. Every further failure doubles the
+// lockout, up to the maximum.
+func (s *AuthService) recordLoginFailure(ctx context.Context, user *model.User) error {
+	cfg := config.App.LoginProtection
+	count, err := s.userRepo.RecordLoginFailure(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if cfg.LockoutThreshold <= 0 || count < cfg.LockoutThreshold {
+		return nil
+	}
+
+	lockFor := cfg.LockoutDuration
+	for i := cfg.LockoutThreshold; i < count && lockFor < cfg.MaxLockoutDuration; i++ {
+		lockFor *= 2
+	}
+	lockFor = min(lockFor, cfg.MaxLockoutDuration)
+	if err := s.userRepo.LockUntil(ctx, user.ID, time.Now().Add(lockFor)); err != nil {
+		return err
+	}
+
+	loginMetrics.Add("lockouts", 1)
+	logger.Error.Printf("Locked login for user %s for %s after %d failed attempts", user.ID, lockFor, count)
+	return nil
+}
+
+// revokeReusedFamily revokes the session of a replayed refresh token and
+// every token in its family.
+func (s *AuthService) revokeReusedFamily(ctx context.Context, stored *model.RefreshToken) error {
+	logger.Error.Printf("Refresh token reuse detected for user %s, revoking family %s", stored.UserID, stored.FamilyID)
+	if _, err := s.sessionRepo.Revoke(ctx, stored.FamilyID, stored.UserID); err != nil {
+		return err
+	}
+	if err := s.refreshTokenRepo.RevokeFamily(ctx, stored.FamilyID); err != nil {
+		return err
+	}
+	return ierr.ErrRefreshTokenReused
+}
+
+// challengeMFA starts the second step of a login. The MFA token is opaque and
+// stored, so it cannot be mistaken for an access token and the number of
+// codes tried against it can be limited.
+func (s *AuthService) challengeMFA(ctx context.Context, user *model.User) (*model.TokenResponse, error) {
+	token, tokenHash, err := generateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.challengeRepo.Create(ctx, &model.MFAChallenge{
+		UserID:    user.ID,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(config.App.MFA.ChallengeTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.TokenResponse{
+		MFARequired: true,
+		MFAToken:    token,
+		ExpiresIn:   int64(config.App.MFA.ChallengeTTL.Seconds()),
+	}, nil
+}
+
+// startSession records a new session for the client and issues the first
+// tokens of its refresh token family.
+func (s *AuthService) startSession(ctx context.Context, user *model.User, client model.ClientInfo) (*model.TokenResponse, error) {
+	session := &model.Session{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		UserAgent: client.UserAgent,
+		IPAddress: client.IP,
+	}
+	if err := s.sessionRepo.Create(ctx, session); err != nil {
+		return nil, err
+	}
+	return s.issueTokens(ctx, user, session.ID)
+}
+
+// issueTokens signs a new access token and persists a new refresh token in the given family.
+func (s *AuthService) issueTokens(ctx context.Context, user *model.User, familyID uuid.UUID) (*model.TokenResponse, error) {
+	accessToken, err := signAccessToken(s.keys, user, familyID)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, refreshTokenHash, err := generateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.refreshTokenRepo.Create(ctx, &model.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: refreshTokenHash,
+		ExpiresAt: time.Now().Add(config.App.JWT.RefreshTokenTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.TokenResponse{
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(config.App.JWT.AccessTokenTTL.Seconds()),
+	}, nil
+}
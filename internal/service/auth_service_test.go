@@ -0,0 +1,490 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/faizalom/go-api/internal/config"
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/jwtkeys"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/faizalom/go-api/internal/repository/mocks"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var testKeys = jwtkeys.NewHMAC("test-secret")
+
+// setTestJWTConfig sets the JWT settings for the rest of the test.
+func setTestJWTConfig(t *testing.T) {
+	t.Helper()
+	jwtConfig := config.App.JWT
+	t.Cleanup(func() { config.App.JWT = jwtConfig })
+	config.App.JWT.AccessTokenTTL = 15 * time.Minute
+	config.App.JWT.RefreshTokenTTL = time.Hour
+	config.App.JWT.Issuer = "go-api-test"
+	config.App.JWT.Audience = []string{"workout-api-test"}
+}
+
+func hashPassword(t *testing.T, password string) string {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(hash)
+}
+
+// withoutMFA returns an MFA repository for users who have not enrolled.
+func withoutMFA() *mocks.MockMFARepository {
+	mfaRepo := new(mocks.MockMFARepository)
+	mfaRepo.On("Get", mock.Anything, mock.Anything).Return(nil, ierr.ErrMFANotEnrolled)
+	return mfaRepo
+}
+
+// withSessions returns a session repository that accepts every call.
+func withSessions() *mocks.MockSessionRepository {
+	sessionRepo := new(mocks.MockSessionRepository)
+	sessionRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+	sessionRepo.On("Touch", mock.Anything, mock.Anything).Return(nil)
+	sessionRepo.On("Revoke", mock.Anything, mock.Anything, mock.Anything).Return(true, nil)
+	return sessionRepo
+}
+
+// testThrottle returns a login throttle that never blocks.
+func testThrottle() *LoginThrottle {
+	return NewLoginThrottle(config.LoginProtectionConfig{EmailFreeAttempts: 1000, IPFreeAttempts: 1000})
+}
+
+func TestAuthService_Login(t *testing.T) {
+	setTestJWTConfig(t)
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+	mockRevokedTokenRepo := new(mocks.MockRevokedTokenRepository)
+	authService := NewAuthService(mockUserRepo, mockRefreshTokenRepo, withSessions(), withoutMFA(), new(mocks.MockMFAChallengeRepository), NewRevocationService(mockRevokedTokenRepo), testKeys, testThrottle())
+
+	user := &model.User{
+		ID:       uuid.New(),
+		Name:     "test user",
+		Email:    "test@example.com",
+		IsActive: true,
+	}
+	mockUserRepo.On("GetByEmail", mock.Anything, user.Email).Return(user, hashPassword(t, "password"), nil)
+	mockRefreshTokenRepo.On("Create", mock.Anything, mock.MatchedBy(func(token *model.RefreshToken) bool {
+		return token.UserID == user.ID && token.FamilyID != uuid.Nil && token.TokenHash != ""
+	})).Return(nil)
+
+	resp, err := authService.Login(context.Background(), &model.LoginRequest{Email: user.Email, Password: "password"})
+
+	assert.NoError(t, err)
+	assert.NotNil(t, resp)
+	assert.NotEmpty(t, resp.RefreshToken)
+
+	claims := &model.CustomClaims{}
+	_, err = jwt.ParseWithClaims(resp.Token, claims, testKeys.Keyfunc)
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID.String(), claims.Subject)
+	assert.Equal(t, user.Name, claims.Name)
+	assert.Equal(t, user.Email, claims.Email)
+	assert.NotEmpty(t, claims.ID)
+	assert.NotEmpty(t, claims.SessionID)
+	assert.Equal(t, "go-api-test", claims.Issuer)
+	assert.Equal(t, jwt.ClaimStrings{"workout-api-test"}, claims.Audience)
+	mockUserRepo.AssertExpectations(t)
+	mockRefreshTokenRepo.AssertExpectations(t)
+}
+
+func TestAuthService_Login_RecordsSession(t *testing.T) {
+	setTestJWTConfig(t)
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+	mockSessionRepo := new(mocks.MockSessionRepository)
+	authService := NewAuthService(mockUserRepo, mockRefreshTokenRepo, mockSessionRepo, withoutMFA(), new(mocks.MockMFAChallengeRepository), NewRevocationService(new(mocks.MockRevokedTokenRepository)), testKeys, testThrottle())
+
+	user := &model.User{ID: uuid.New(), Email: "test@example.com", IsActive: true}
+	client := model.ClientInfo{IP: "192.0.2.1", UserAgent: "curl/8.5.0"}
+	var session *model.Session
+	mockUserRepo.On("GetByEmail", mock.Anything, user.Email).Return(user, hashPassword(t, "password"), nil)
+	mockSessionRepo.On("Create", mock.Anything, mock.MatchedBy(func(s *model.Session) bool {
+		return s.ID != uuid.Nil && s.UserID == user.ID && s.IPAddress == client.IP && s.UserAgent == client.UserAgent
+	})).Run(func(args mock.Arguments) {
+		session = args.Get(1).(*model.Session)
+	}).Return(nil)
+	mockRefreshTokenRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+
+	resp, err := authService.Login(context.Background(), &model.LoginRequest{Email: user.Email, Password: "password", Client: client})
+
+	assert.NoError(t, err)
+	claims := &model.CustomClaims{}
+	_, err = jwt.ParseWithClaims(resp.Token, claims, testKeys.Keyfunc)
+	assert.NoError(t, err)
+	// The session shares its ID with the refresh token family and the sid claim.
+	assert.Equal(t, session.ID.String(), claims.SessionID)
+	mockRefreshTokenRepo.AssertCalled(t, "Create", mock.Anything, mock.MatchedBy(func(token *model.RefreshToken) bool {
+		return token.FamilyID == session.ID
+	}))
+	mockSessionRepo.AssertExpectations(t)
+}
+
+func TestAuthService_Login_WrongPassword(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+	mockRevokedTokenRepo := new(mocks.MockRevokedTokenRepository)
+	authService := NewAuthService(mockUserRepo, mockRefreshTokenRepo, withSessions(), withoutMFA(), new(mocks.MockMFAChallengeRepository), NewRevocationService(mockRevokedTokenRepo), testKeys, testThrottle())
+
+	user := &model.User{ID: uuid.New(), Email: "test@example.com", IsActive: true}
+	mockUserRepo.On("GetByEmail", mock.Anything, user.Email).Return(user, hashPassword(t, "password"), nil)
+	mockUserRepo.On("RecordLoginFailure", mock.Anything, user.ID).Return(1, nil)
+
+	resp, err := authService.Login(context.Background(), &model.LoginRequest{Email: user.Email, Password: "wrong"})
+
+	assert.ErrorIs(t, err, ierr.ErrInvalidCredentials)
+	assert.Nil(t, resp)
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestAuthService_Login_LocksAccount(t *testing.T) {
+	config.App.LoginProtection.LockoutThreshold = 3
+	config.App.LoginProtection.LockoutDuration = 15 * time.Minute
+	config.App.LoginProtection.MaxLockoutDuration = time.Hour
+	defer func() { config.App.LoginProtection = config.LoginProtectionConfig{} }()
+
+	user := &model.User{ID: uuid.New(), Email: "test@example.com", IsActive: true}
+	passwordHash := hashPassword(t, "password")
+
+	tests := []struct {
+		name  string
+		count int
+		want  time.Duration
+	}{
+		{name: "below threshold", count: 2},
+		{name: "at threshold", count: 3, want: 15 * time.Minute},
+		{name: "doubles", count: 4, want: 30 * time.Minute},
+		{name: "capped", count: 8, want: time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockUserRepo := new(mocks.MockUserRepository)
+			authService := NewAuthService(mockUserRepo, new(mocks.MockRefreshTokenRepository), withSessions(), withoutMFA(), new(mocks.MockMFAChallengeRepository), NewRevocationService(new(mocks.MockRevokedTokenRepository)), testKeys, testThrottle())
+
+			var lockedUntil time.Time
+			mockUserRepo.On("GetByEmail", mock.Anything, user.Email).Return(user, passwordHash, nil)
+			mockUserRepo.On("RecordLoginFailure", mock.Anything, user.ID).Return(tt.count, nil)
+			if tt.want > 0 {
+				mockUserRepo.On("LockUntil", mock.Anything, user.ID, mock.AnythingOfType("time.Time")).Run(func(args mock.Arguments) {
+					lockedUntil = args.Get(2).(time.Time)
+				}).Return(nil)
+			}
+
+			start := time.Now()
+			_, err := authService.Login(context.Background(), &model.LoginRequest{Email: user.Email, Password: "wrong"})
+
+			assert.ErrorIs(t, err, ierr.ErrInvalidCredentials)
+			if tt.want > 0 {
+				assert.WithinDuration(t, start.Add(tt.want), lockedUntil, time.Second)
+			} else {
+				mockUserRepo.AssertNotCalled(t, "LockUntil", mock.Anything, mock.Anything, mock.Anything)
+			}
+			mockUserRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthService_Login_Locked(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	authService := NewAuthService(mockUserRepo, new(mocks.MockRefreshTokenRepository), withSessions(), withoutMFA(), new(mocks.MockMFAChallengeRepository), NewRevocationService(new(mocks.MockRevokedTokenRepository)), testKeys, testThrottle())
+
+	lockedUntil := time.Now().Add(10 * time.Minute)
+	user := &model.User{ID: uuid.New(), Email: "test@example.com", IsActive: true, FailedLoginCount: 10, LockedUntil: &lockedUntil}
+	mockUserRepo.On("GetByEmail", mock.Anything, user.Email).Return(user, hashPassword(t, "password"), nil)
+
+	// Even the right password is rejected while the account is locked.
+	resp, err := authService.Login(context.Background(), &model.LoginRequest{Email: user.Email, Password: "password"})
+
+	assert.ErrorIs(t, err, ierr.ErrTooManyLoginAttempts)
+	var retry *ierr.RetryAfterError
+	if assert.ErrorAs(t, err, &retry) {
+		assert.InDelta(t, 10*time.Minute, retry.RetryAfter, float64(time.Second))
+	}
+	assert.Nil(t, resp)
+	mockUserRepo.AssertNotCalled(t, "ResetLoginFailures", mock.Anything, mock.Anything)
+}
+
+func TestAuthService_Login_ResetsFailures(t *testing.T) {
+	setTestJWTConfig(t)
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+	authService := NewAuthService(mockUserRepo, mockRefreshTokenRepo, withSessions(), withoutMFA(), new(mocks.MockMFAChallengeRepository), NewRevocationService(new(mocks.MockRevokedTokenRepository)), testKeys, testThrottle())
+
+	// The lockout has expired.
+	lockedUntil := time.Now().Add(-time.Minute)
+	user := &model.User{ID: uuid.New(), Email: "test@example.com", IsActive: true, FailedLoginCount: 10, LockedUntil: &lockedUntil}
+	mockUserRepo.On("GetByEmail", mock.Anything, user.Email).Return(user, hashPassword(t, "password"), nil)
+	mockUserRepo.On("ResetLoginFailures", mock.Anything, user.ID).Return(nil)
+	mockRefreshTokenRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+
+	resp, err := authService.Login(context.Background(), &model.LoginRequest{Email: user.Email, Password: "password"})
+
+	assert.NoError(t, err)
+	assert.NotEmpty(t, resp.Token)
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestAuthService_Login_Throttled(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	throttle := NewLoginThrottle(config.LoginProtectionConfig{EmailFreeAttempts: 2, IPFreeAttempts: 100, BaseBackoff: time.Minute, MaxBackoff: time.Hour, ResetAfter: time.Hour})
+	authService := NewAuthService(mockUserRepo, new(mocks.MockRefreshTokenRepository), withSessions(), withoutMFA(), new(mocks.MockMFAChallengeRepository), NewRevocationService(new(mocks.MockRevokedTokenRepository)), testKeys, throttle)
+
+	mockUserRepo.On("GetByEmail", mock.Anything, "nobody@example.com").Return(&model.User{}, "", ierr.ErrUserNotFound)
+	req := &model.LoginRequest{Email: "nobody@example.com", Password: "password", Client: model.ClientInfo{IP: "192.0.2.1"}}
+
+	for i := 0; i < 3; i++ {
+		_, err := authService.Login(context.Background(), req)
+		assert.ErrorIs(t, err, ierr.ErrInvalidCredentials)
+	}
+
+	// The email is blocked, regardless of case, without another lookup.
+	_, err := authService.Login(context.Background(), &model.LoginRequest{Email: "Nobody@Example.com", Password: "password", Client: model.ClientInfo{IP: "192.0.2.2"}})
+	assert.ErrorIs(t, err, ierr.ErrTooManyLoginAttempts)
+	var retry *ierr.RetryAfterError
+	if assert.ErrorAs(t, err, &retry) {
+		assert.InDelta(t, time.Minute, retry.RetryAfter, float64(time.Second))
+	}
+	mockUserRepo.AssertNumberOfCalls(t, "GetByEmail", 3)
+}
+
+func TestAuthService_Unlock(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	throttle := NewLoginThrottle(config.LoginProtectionConfig{EmailFreeAttempts: 0, IPFreeAttempts: 100, BaseBackoff: time.Minute, MaxBackoff: time.Hour, ResetAfter: time.Hour})
+	authService := NewAuthService(mockUserRepo, new(mocks.MockRefreshTokenRepository), withSessions(), withoutMFA(), new(mocks.MockMFAChallengeRepository), NewRevocationService(new(mocks.MockRevokedTokenRepository)), testKeys, throttle)
+
+	user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+	missingID := uuid.New()
+	mockUserRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+	mockUserRepo.On("GetByID", mock.Anything, missingID).Return((*model.User)(nil), ierr.ErrUserNotFound)
+	mockUserRepo.On("ResetLoginFailures", mock.Anything, user.ID).Return(nil)
+
+	throttle.Fail(user.Email, "")
+	assert.ErrorIs(t, throttle.Allow(user.Email, ""), ierr.ErrTooManyLoginAttempts)
+
+	assert.NoError(t, authService.Unlock(context.Background(), user.ID))
+	assert.NoError(t, throttle.Allow(user.Email, ""))
+	assert.ErrorIs(t, authService.Unlock(context.Background(), missingID), ierr.ErrUserNotFound)
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestAuthService_Login_UnknownEmail(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+	mockRevokedTokenRepo := new(mocks.MockRevokedTokenRepository)
+	authService := NewAuthService(mockUserRepo, mockRefreshTokenRepo, withSessions(), withoutMFA(), new(mocks.MockMFAChallengeRepository), NewRevocationService(mockRevokedTokenRepo), testKeys, testThrottle())
+
+	mockUserRepo.On("GetByEmail", mock.Anything, "nobody@example.com").Return(&model.User{}, "", ierr.ErrUserNotFound)
+
+	resp, err := authService.Login(context.Background(), &model.LoginRequest{Email: "nobody@example.com", Password: "password"})
+
+	assert.ErrorIs(t, err, ierr.ErrInvalidCredentials)
+	assert.Nil(t, resp)
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestAuthService_Login_InactiveUser(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+	mockRevokedTokenRepo := new(mocks.MockRevokedTokenRepository)
+	authService := NewAuthService(mockUserRepo, mockRefreshTokenRepo, withSessions(), withoutMFA(), new(mocks.MockMFAChallengeRepository), NewRevocationService(mockRevokedTokenRepo), testKeys, testThrottle())
+
+	user := &model.User{ID: uuid.New(), Email: "test@example.com", IsActive: false}
+	mockUserRepo.On("GetByEmail", mock.Anything, user.Email).Return(user, hashPassword(t, "password"), nil)
+
+	resp, err := authService.Login(context.Background(), &model.LoginRequest{Email: user.Email, Password: "password"})
+
+	assert.ErrorIs(t, err, ierr.ErrUserInactive)
+	assert.Nil(t, resp)
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestAuthService_Login_UnverifiedEmail(t *testing.T) {
+	setTestJWTConfig(t)
+	config.App.EmailVerification.Required = true
+	defer func() { config.App.EmailVerification.Required = false }()
+
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+	mockRevokedTokenRepo := new(mocks.MockRevokedTokenRepository)
+	authService := NewAuthService(mockUserRepo, mockRefreshTokenRepo, withSessions(), withoutMFA(), new(mocks.MockMFAChallengeRepository), NewRevocationService(mockRevokedTokenRepo), testKeys, testThrottle())
+
+	user := &model.User{ID: uuid.New(), Email: "test@example.com", IsActive: true}
+	mockUserRepo.On("GetByEmail", mock.Anything, user.Email).Return(user, hashPassword(t, "password"), nil)
+
+	resp, err := authService.Login(context.Background(), &model.LoginRequest{Email: user.Email, Password: "password"})
+
+	assert.ErrorIs(t, err, ierr.ErrEmailNotVerified)
+	assert.Nil(t, resp)
+
+	// Once verified the user can sign in.
+	verifiedAt := time.Now()
+	user.EmailVerifiedAt = &verifiedAt
+	mockRefreshTokenRepo.On("Create", mock.Anything, mock.AnythingOfType("*model.RefreshToken")).Return(nil)
+
+	resp, err = authService.Login(context.Background(), &model.LoginRequest{Email: user.Email, Password: "password"})
+
+	assert.NoError(t, err)
+	assert.NotNil(t, resp)
+}
+
+func TestAuthService_Login_MFARequired(t *testing.T) {
+	setTestJWTConfig(t)
+	mfaConfig := config.App.MFA
+	t.Cleanup(func() { config.App.MFA = mfaConfig })
+	config.App.MFA.ChallengeTTL = 5 * time.Minute
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+	mockMFARepo := new(mocks.MockMFARepository)
+	mockChallengeRepo := new(mocks.MockMFAChallengeRepository)
+	authService := NewAuthService(mockUserRepo, mockRefreshTokenRepo, withSessions(), mockMFARepo, mockChallengeRepo, NewRevocationService(new(mocks.MockRevokedTokenRepository)), testKeys, testThrottle())
+
+	user := &model.User{ID: uuid.New(), Email: "test@example.com", IsActive: true, FailedLoginCount: 2}
+	confirmedAt := time.Now()
+	var stored *model.MFAChallenge
+	mockUserRepo.On("GetByEmail", mock.Anything, user.Email).Return(user, hashPassword(t, "password"), nil)
+	mockMFARepo.On("Get", mock.Anything, user.ID).Return(&model.MFA{UserID: user.ID, ConfirmedAt: &confirmedAt}, nil)
+	mockChallengeRepo.On("Create", mock.Anything, mock.AnythingOfType("*model.MFAChallenge")).Run(func(args mock.Arguments) {
+		stored = args.Get(1).(*model.MFAChallenge)
+	}).Return(nil)
+
+	resp, err := authService.Login(context.Background(), &model.LoginRequest{Email: user.Email, Password: "password"})
+
+	assert.NoError(t, err)
+	assert.True(t, resp.MFARequired)
+	assert.Equal(t, hashToken(resp.MFAToken), stored.TokenHash)
+	assert.Equal(t, user.ID, stored.UserID)
+	assert.WithinDuration(t, time.Now().Add(5*time.Minute), stored.ExpiresAt, time.Minute)
+	assert.Equal(t, int64(300), resp.ExpiresIn)
+	// No session is started until the second factor is verified.
+	assert.Empty(t, resp.Token)
+	assert.Empty(t, resp.RefreshToken)
+	mockRefreshTokenRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	// Nor are the failed logins forgotten before then.
+	mockUserRepo.AssertNotCalled(t, "ResetLoginFailures", mock.Anything, mock.Anything)
+}
+
+func TestAuthService_Refresh(t *testing.T) {
+	setTestJWTConfig(t)
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+	mockRevokedTokenRepo := new(mocks.MockRevokedTokenRepository)
+	mockSessionRepo := new(mocks.MockSessionRepository)
+	authService := NewAuthService(mockUserRepo, mockRefreshTokenRepo, mockSessionRepo, withoutMFA(), new(mocks.MockMFAChallengeRepository), NewRevocationService(mockRevokedTokenRepo), testKeys, testThrottle())
+
+	user := &model.User{ID: uuid.New(), Email: "test@example.com", IsActive: true}
+	stored := &model.RefreshToken{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		FamilyID:  uuid.New(),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+
+	mockRefreshTokenRepo.On("GetByHash", mock.Anything, hashToken("refresh-token")).Return(stored, nil)
+	mockRefreshTokenRepo.On("Revoke", mock.Anything, stored.ID).Return(true, nil)
+	mockUserRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+	mockSessionRepo.On("Touch", mock.Anything, stored.FamilyID).Return(nil)
+	mockRefreshTokenRepo.On("Create", mock.Anything, mock.MatchedBy(func(token *model.RefreshToken) bool {
+		return token.FamilyID == stored.FamilyID && token.TokenHash != hashToken("refresh-token")
+	})).Return(nil)
+
+	resp, err := authService.Refresh(context.Background(), &model.RefreshRequest{RefreshToken: "refresh-token"})
+
+	assert.NoError(t, err)
+	assert.NotNil(t, resp)
+	assert.NotEqual(t, "refresh-token", resp.RefreshToken)
+	mockUserRepo.AssertExpectations(t)
+	mockRefreshTokenRepo.AssertExpectations(t)
+	mockSessionRepo.AssertExpectations(t)
+}
+
+func TestAuthService_Refresh_ReuseRevokesFamily(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+	mockRevokedTokenRepo := new(mocks.MockRevokedTokenRepository)
+	mockSessionRepo := new(mocks.MockSessionRepository)
+	authService := NewAuthService(mockUserRepo, mockRefreshTokenRepo, mockSessionRepo, withoutMFA(), new(mocks.MockMFAChallengeRepository), NewRevocationService(mockRevokedTokenRepo), testKeys, testThrottle())
+
+	revokedAt := time.Now().Add(-time.Minute)
+	stored := &model.RefreshToken{
+		ID:        uuid.New(),
+		UserID:    uuid.New(),
+		FamilyID:  uuid.New(),
+		ExpiresAt: time.Now().Add(time.Hour),
+		RevokedAt: &revokedAt,
+	}
+
+	mockRefreshTokenRepo.On("GetByHash", mock.Anything, hashToken("refresh-token")).Return(stored, nil)
+	mockRefreshTokenRepo.On("RevokeFamily", mock.Anything, stored.FamilyID).Return(nil)
+	mockSessionRepo.On("Revoke", mock.Anything, stored.FamilyID, stored.UserID).Return(true, nil)
+
+	resp, err := authService.Refresh(context.Background(), &model.RefreshRequest{RefreshToken: "refresh-token"})
+
+	assert.ErrorIs(t, err, ierr.ErrRefreshTokenReused)
+	assert.Nil(t, resp)
+	mockRefreshTokenRepo.AssertExpectations(t)
+	mockSessionRepo.AssertExpectations(t)
+	mockUserRepo.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything)
+}
+
+func TestAuthService_Refresh_Expired(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+	mockRevokedTokenRepo := new(mocks.MockRevokedTokenRepository)
+	authService := NewAuthService(mockUserRepo, mockRefreshTokenRepo, withSessions(), withoutMFA(), new(mocks.MockMFAChallengeRepository), NewRevocationService(mockRevokedTokenRepo), testKeys, testThrottle())
+
+	stored := &model.RefreshToken{
+		ID:        uuid.New(),
+		UserID:    uuid.New(),
+		FamilyID:  uuid.New(),
+		ExpiresAt: time.Now().Add(-time.Minute),
+	}
+
+	mockRefreshTokenRepo.On("GetByHash", mock.Anything, hashToken("refresh-token")).Return(stored, nil)
+
+	resp, err := authService.Refresh(context.Background(), &model.RefreshRequest{RefreshToken: "refresh-token"})
+
+	assert.ErrorIs(t, err, ierr.ErrInvalidRefreshToken)
+	assert.Nil(t, resp)
+	mockRefreshTokenRepo.AssertExpectations(t)
+}
+
+func TestAuthService_Logout(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+	mockRevokedTokenRepo := new(mocks.MockRevokedTokenRepository)
+	mockSessionRepo := new(mocks.MockSessionRepository)
+	authService := NewAuthService(mockUserRepo, mockRefreshTokenRepo, mockSessionRepo, withoutMFA(), new(mocks.MockMFAChallengeRepository), NewRevocationService(mockRevokedTokenRepo), testKeys, testThrottle())
+
+	sessionID, userID := uuid.New(), uuid.New()
+	expiresAt := time.Now().Add(time.Minute).Truncate(time.Second)
+	claims := &model.CustomClaims{
+		SessionID: sessionID.String(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "jti",
+			Subject:   userID.String(),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	mockRevokedTokenRepo.On("Revoke", mock.Anything, "jti", expiresAt).Return(nil)
+	mockRefreshTokenRepo.On("RevokeFamily", mock.Anything, sessionID).Return(nil)
+	mockSessionRepo.On("Revoke", mock.Anything, sessionID, userID).Return(true, nil)
+
+	err := authService.Logout(context.Background(), claims)
+
+	assert.NoError(t, err)
+	mockRevokedTokenRepo.AssertExpectations(t)
+	mockRefreshTokenRepo.AssertExpectations(t)
+	mockSessionRepo.AssertExpectations(t)
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/faizalom/go-api/internal/config"
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/faizalom/go-api/internal/repository"
+	"github.com/faizalom/go-api/pkg/mailer"
+)
+
+type IEmailVerificationService interface {
+	SendVerification(ctx context.Context, user *model.User) error
+	ResendVerification(ctx context.Context, req *model.ResendVerificationRequest) error
+	Verify(ctx context.Context, token string) error
+}
+
+type EmailVerificationService struct {
+	userRepo  repository.IUserRepository
+	tokenRepo repository.IEmailVerificationTokenRepository
+	mailer    mailer.Mailer
+}
+
+func NewEmailVerificationService(userRepo repository.IUserRepository, tokenRepo repository.IEmailVerificationTokenRepository, m mailer.Mailer) IEmailVerificationService {
+	return &EmailVerificationService{userRepo: userRepo, tokenRepo: tokenRepo, mailer: m}
+}
+
+// SendVerification mails a verification link for the user's current email.
+// Links sent earlier stop working.
+func (s *EmailVerificationService) SendVerification(ctx context.Context, user *model.User) error {
+	if err := s.tokenRepo.InvalidateForUser(ctx, user.ID); err != nil {
+		return err
+	}
+
+	token, tokenHash, err := generateOpaqueToken()
+	if err != nil {
+		return err
+	}
+	err = s.tokenRepo.Create(ctx, &model.EmailVerificationToken{
+		UserID:    user.ID,
+		Email:     user.Email,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(config.App.EmailVerification.TokenTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	link, err := tokenLink(config.App.EmailVerification.URL, token)
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hello %s,\n\nUse the link below to confirm this is your email address. It expires in %s.\n\n%s\n\nIf you did not sign up, you can ignore this email.\n",
+			user.Name, config.App.EmailVerification.TokenTTL, link),
+	})
+}
+
+// ResendVerification mails a new verification link. Unknown, inactive and
+// already verified accounts are silently ignored so callers cannot tell which
+// emails are registered.
+func (s *EmailVerificationService) ResendVerification(ctx context.Context, req *model.ResendVerificationRequest) error {
+	user, _, err := s.userRepo.GetByEmail(ctx, req.Email)
+	if err != nil {
+		if errors.Is(err, ierr.ErrUserNotFound) {
+			return nil
+		}
+		return err
+	}
+	if !user.IsActive || user.EmailVerifiedAt != nil {
+		return nil
+	}
+	return s.SendVerification(ctx, user)
+}
+
+// Verify redeems a verification token, marking the address it was sent to as
+// verified if it is still the user's email.
+func (s *EmailVerificationService) Verify(ctx context.Context, token string) error {
+	stored, err := s.tokenRepo.GetByHash(ctx, hashToken(token))
+	if err != nil {
+		if errors.Is(err, ierr.ErrVerificationTokenNotFound) {
+			return ierr.ErrInvalidVerificationToken
+		}
+		return err
+	}
+	if stored.UsedAt != nil || time.Now().After(stored.ExpiresAt) {
+		return ierr.ErrInvalidVerificationToken
+	}
+
+	used, err := s.tokenRepo.MarkUsed(ctx, stored.ID)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ierr.ErrInvalidVerificationToken
+	}
+
+	verified, err := s.userRepo.MarkEmailVerified(ctx, stored.UserID, stored.Email)
+	if err != nil {
+		return err
+	}
+	if !verified {
+		return ierr.ErrInvalidVerificationToken
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/faizalom/go-api/internal/config"
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/faizalom/go-api/internal/repository/mocks"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmailVerificationService_SendVerification(t *testing.T) {
+	verificationConfig := config.App.EmailVerification
+	t.Cleanup(func() { config.App.EmailVerification = verificationConfig })
+	config.App.EmailVerification.TokenTTL = 24 * time.Hour
+	config.App.EmailVerification.URL = "https://api.example.com/api/v1/auth/verify"
+
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockTokenRepo := new(mocks.MockEmailVerificationTokenRepository)
+	sender := &recordingMailer{}
+	verificationService := NewEmailVerificationService(mockUserRepo, mockTokenRepo, sender)
+
+	user := &model.User{ID: uuid.New(), Name: "test user", Email: "test@example.com"}
+
+	var stored *model.EmailVerificationToken
+	mockTokenRepo.On("InvalidateForUser", mock.Anything, user.ID).Return(nil)
+	mockTokenRepo.On("Create", mock.Anything, mock.AnythingOfType("*model.EmailVerificationToken")).Run(func(args mock.Arguments) {
+		stored = args.Get(1).(*model.EmailVerificationToken)
+	}).Return(nil)
+
+	err := verificationService.SendVerification(context.Background(), user)
+
+	require.NoError(t, err)
+	require.Len(t, sender.sent, 1)
+	assert.Equal(t, user.Email, sender.sent[0].To)
+
+	// The mailed link carries the token whose hash was stored.
+	var link string
+	for _, line := range strings.Split(sender.sent[0].Body, "\n") {
+		if strings.HasPrefix(line, "https://") {
+			link = line
+		}
+	}
+	u, err := url.Parse(link)
+	require.NoError(t, err)
+	assert.Equal(t, "/api/v1/auth/verify", u.Path)
+	assert.Equal(t, stored.TokenHash, hashToken(u.Query().Get("token")))
+	assert.Equal(t, user.ID, stored.UserID)
+	assert.Equal(t, user.Email, stored.Email)
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), stored.ExpiresAt, time.Minute)
+	mockTokenRepo.AssertExpectations(t)
+}
+
+func TestEmailVerificationService_ResendVerification_Ignored(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockTokenRepo := new(mocks.MockEmailVerificationTokenRepository)
+	sender := &recordingMailer{}
+	verificationService := NewEmailVerificationService(mockUserRepo, mockTokenRepo, sender)
+
+	verifiedAt := time.Now()
+
+	mockUserRepo.On("GetByEmail", mock.Anything, "nobody@example.com").Return((*model.User)(nil), "", ierr.ErrUserNotFound)
+	mockUserRepo.On("GetByEmail", mock.Anything, "inactive@example.com").Return(&model.User{ID: uuid.New()}, "hash", nil)
+	mockUserRepo.On("GetByEmail", mock.Anything, "verified@example.com").Return(&model.User{ID: uuid.New(), IsActive: true, EmailVerifiedAt: &verifiedAt}, "hash", nil)
+
+	for _, email := range []string{"nobody@example.com", "inactive@example.com", "verified@example.com"} {
+		assert.NoError(t, verificationService.ResendVerification(context.Background(), &model.ResendVerificationRequest{Email: email}), email)
+	}
+	assert.Empty(t, sender.sent)
+	mockTokenRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestEmailVerificationService_Verify(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockTokenRepo := new(mocks.MockEmailVerificationTokenRepository)
+	sender := &recordingMailer{}
+	verificationService := NewEmailVerificationService(mockUserRepo, mockTokenRepo, sender)
+
+	stored := &model.EmailVerificationToken{ID: uuid.New(), UserID: uuid.New(), Email: "test@example.com", ExpiresAt: time.Now().Add(time.Hour)}
+
+	mockTokenRepo.On("GetByHash", mock.Anything, hashToken("verify-token")).Return(stored, nil)
+	mockTokenRepo.On("MarkUsed", mock.Anything, stored.ID).Return(true, nil)
+	mockUserRepo.On("MarkEmailVerified", mock.Anything, stored.UserID, stored.Email).Return(true, nil)
+
+	err := verificationService.Verify(context.Background(), "verify-token")
+
+	assert.NoError(t, err)
+	mockTokenRepo.AssertExpectations(t)
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestEmailVerificationService_Verify_Rejected(t *testing.T) {
+	usedAt := time.Now()
+
+	tests := []struct {
+		name         string
+		stored       *model.EmailVerificationToken
+		err          error
+		claimable    bool
+		emailChanged bool
+	}{
+		{name: "unknown token", err: ierr.ErrVerificationTokenNotFound},
+		{name: "expired token", stored: &model.EmailVerificationToken{ID: uuid.New(), ExpiresAt: time.Now().Add(-time.Minute)}},
+		{name: "used token", stored: &model.EmailVerificationToken{ID: uuid.New(), ExpiresAt: time.Now().Add(time.Hour), UsedAt: &usedAt}},
+		{name: "redeemed concurrently", stored: &model.EmailVerificationToken{ID: uuid.New(), ExpiresAt: time.Now().Add(time.Hour)}, claimable: true},
+		{name: "email changed since", stored: &model.EmailVerificationToken{ID: uuid.New(), UserID: uuid.New(), Email: "old@example.com", ExpiresAt: time.Now().Add(time.Hour)}, emailChanged: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockUserRepo := new(mocks.MockUserRepository)
+			mockTokenRepo := new(mocks.MockEmailVerificationTokenRepository)
+			sender := &recordingMailer{}
+			verificationService := NewEmailVerificationService(mockUserRepo, mockTokenRepo, sender)
+
+			mockTokenRepo.On("GetByHash", mock.Anything, mock.Anything).Return(tt.stored, tt.err)
+			if tt.claimable {
+				mockTokenRepo.On("MarkUsed", mock.Anything, tt.stored.ID).Return(false, nil)
+			}
+			if tt.emailChanged {
+				mockTokenRepo.On("MarkUsed", mock.Anything, tt.stored.ID).Return(true, nil)
+				mockUserRepo.On("MarkEmailVerified", mock.Anything, tt.stored.UserID, tt.stored.Email).Return(false, nil)
+			}
+
+			err := verificationService.Verify(context.Background(), "verify-token")
+
+			assert.ErrorIs(t, err, ierr.ErrInvalidVerificationToken)
+			if !tt.emailChanged {
+				mockUserRepo.AssertNotCalled(t, "MarkEmailVerified", mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/faizalom/go-api/internal/config"
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/faizalom/go-api/internal/repository/mocks"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func adminClaims(id uuid.UUID) *model.CustomClaims {
+	return &model.CustomClaims{
+		Email:            "admin@example.com",
+		Roles:            []string{model.RoleAdmin},
+		SessionID:        uuid.NewString(),
+		RegisteredClaims: jwt.RegisteredClaims{Subject: id.String()},
+	}
+}
+
+func TestImpersonationService_Impersonate(t *testing.T) {
+	setTestJWTConfig(t)
+	config.App.Impersonation.TokenTTL = 10 * time.Minute
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockAuditRepo := new(mocks.MockImpersonationAuditRepository)
+	impersonationService := NewImpersonationService(mockUserRepo, mockAuditRepo, testKeys)
+
+	adminID := uuid.New()
+	target := &model.User{ID: uuid.New(), Name: "test user", Email: "test@example.com", Roles: []string{model.RoleAthlete}, IsActive: true}
+	var audit *model.ImpersonationAudit
+	mockUserRepo.On("GetByID", mock.Anything, target.ID).Return(target, nil)
+	mockAuditRepo.On("Create", mock.Anything, mock.MatchedBy(func(entry *model.ImpersonationAudit) bool {
+		return entry.ActorID == adminID && entry.TargetID == target.ID && entry.Reason == "ticket 4711" && entry.IPAddress == "192.0.2.1"
+	})).Run(func(args mock.Arguments) {
+		audit = args.Get(1).(*model.ImpersonationAudit)
+	}).Return(nil)
+
+	resp, err := impersonationService.Impersonate(context.Background(), adminClaims(adminID), &model.ImpersonationRequest{
+		Reason:   "ticket 4711",
+		TargetID: target.ID,
+		Client:   model.ClientInfo{IP: "192.0.2.1"},
+	})
+
+	assert.NoError(t, err)
+	assert.Empty(t, resp.RefreshToken)
+	assert.Equal(t, int64(600), resp.ExpiresIn)
+
+	claims := &model.CustomClaims{}
+	_, err = jwt.ParseWithClaims(resp.Token, claims, testKeys.Keyfunc)
+	assert.NoError(t, err)
+	assert.Equal(t, target.ID.String(), claims.Subject)
+	assert.Equal(t, target.Roles, claims.Roles)
+	assert.Equal(t, &model.ActorClaim{Subject: adminID.String(), Email: "admin@example.com"}, claims.Actor)
+	assert.Empty(t, claims.SessionID)
+	assert.Equal(t, audit.TokenID, claims.ID)
+	assert.WithinDuration(t, time.Now().Add(10*time.Minute), claims.ExpiresAt.Time, 5*time.Second)
+	mockAuditRepo.AssertExpectations(t)
+}
+
+func TestImpersonationService_Impersonate_NotAllowed(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockAuditRepo := new(mocks.MockImpersonationAuditRepository)
+	impersonationService := NewImpersonationService(mockUserRepo, mockAuditRepo, testKeys)
+
+	adminID, targetID := uuid.New(), uuid.New()
+	alreadyImpersonating := adminClaims(uuid.New())
+	alreadyImpersonating.Actor = &model.ActorClaim{Subject: adminID.String()}
+	apiKey := adminClaims(adminID)
+	apiKey.Scopes = []string{"user:impersonate"}
+
+	tests := []struct {
+		name   string
+		actor  *model.CustomClaims
+		target uuid.UUID
+	}{
+		{name: "self", actor: adminClaims(adminID), target: adminID},
+		{name: "already impersonating", actor: alreadyImpersonating, target: targetID},
+		{name: "api key", actor: apiKey, target: targetID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := impersonationService.Impersonate(context.Background(), tt.actor, &model.ImpersonationRequest{Reason: "test", TargetID: tt.target})
+			assert.ErrorIs(t, err, ierr.ErrImpersonationNotAllowed)
+		})
+	}
+	mockUserRepo.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything)
+	mockAuditRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestImpersonationService_Impersonate_InactiveUser(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockAuditRepo := new(mocks.MockImpersonationAuditRepository)
+	impersonationService := NewImpersonationService(mockUserRepo, mockAuditRepo, testKeys)
+
+	target := &model.User{ID: uuid.New(), IsActive: false}
+	mockUserRepo.On("GetByID", mock.Anything, target.ID).Return(target, nil)
+
+	_, err := impersonationService.Impersonate(context.Background(), adminClaims(uuid.New()), &model.ImpersonationRequest{Reason: "test", TargetID: target.ID})
+
+	assert.ErrorIs(t, err, ierr.ErrUserInactive)
+	mockAuditRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestImpersonationService_Impersonate_Admin(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockAuditRepo := new(mocks.MockImpersonationAuditRepository)
+	impersonationService := NewImpersonationService(mockUserRepo, mockAuditRepo, testKeys)
+
+	target := &model.User{ID: uuid.New(), Roles: []string{model.RoleCoach, model.RoleAdmin}, IsActive: true}
+	mockUserRepo.On("GetByID", mock.Anything, target.ID).Return(target, nil)
+
+	_, err := impersonationService.Impersonate(context.Background(), adminClaims(uuid.New()), &model.ImpersonationRequest{Reason: "test", TargetID: target.ID})
+
+	assert.ErrorIs(t, err, ierr.ErrImpersonationNotAllowed)
+	mockAuditRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
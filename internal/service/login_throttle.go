@@ -0,0 +1,73 @@
+package service
+
+import (
+	"expvar"
+	"strings"
+	"time"
+
+	"github.com/faizalom/go-api/internal/config"
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/throttle"
+	"github.com/faizalom/go-api/pkg/logger"
+)
+
+// loginMetrics counts failed, throttled and locked out logins and unlocks.
+// It is published with the other expvar variables at /debug/vars.
+var loginMetrics = expvar.NewMap("login")
+
+// LoginThrottle slows down password guessing against one email, and from
+// one client IP, with exponential backoff. Its state is kept in memory, per
+// server instance; account lockouts are persisted separately.
+type LoginThrottle struct {
+	emails *throttle.Backoff
+	ips    *throttle.Backoff
+}
+
+func NewLoginThrottle(cfg config.LoginProtectionConfig) *LoginThrottle {
+	return &LoginThrottle{
+		emails: throttle.NewBackoff(cfg.EmailFreeAttempts, cfg.BaseBackoff, cfg.MaxBackoff, cfg.ResetAfter),
+		ips:    throttle.NewBackoff(cfg.IPFreeAttempts, cfg.BaseBackoff, cfg.MaxBackoff, cfg.ResetAfter),
+	}
+}
+
+// Allow returns an error wrapping ierr.ErrTooManyLoginAttempts while the
+// email or the IP has to wait before trying again. An empty IP is not
+// throttled.
+func (t *LoginThrottle) Allow(email, ip string) error {
+	emailWait, emailOK := t.emails.Allow(emailKey(email))
+	ipWait, ipOK := time.Duration(0), true
+	if ip != "" {
+		ipWait, ipOK = t.ips.Allow(ip)
+	}
+	if emailOK && ipOK {
+		return nil
+	}
+	loginMetrics.Add("throttled", 1)
+	return &ierr.RetryAfterError{Err: ierr.ErrTooManyLoginAttempts, RetryAfter: max(emailWait, ipWait)}
+}
+
+// Fail records a failed login.
+func (t *LoginThrottle) Fail(email, ip string) {
+	loginMetrics.Add("failures", 1)
+	if delay := t.emails.Fail(emailKey(email)); delay > 0 {
+		logger.Error.Printf("Throttling logins for %s for %s after repeated failures", email, delay)
+	}
+	if ip == "" {
+		return
+	}
+	if delay := t.ips.Fail(ip); delay > 0 {
+		logger.Error.Printf("Throttling logins from %s for %s after repeated failures", ip, delay)
+	}
+}
+
+// Reset forgets the failures recorded for an email, after a successful login
+// or when an admin unlocks the account. Failures from an IP are only
+// forgotten with time, so that signing in to one account does not allow more
+// guesses against others.
+func (t *LoginThrottle) Reset(email string) {
+	t.emails.Reset(emailKey(email))
+}
+
+func emailKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
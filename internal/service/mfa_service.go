@@ -0,0 +1,248 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/faizalom/go-api/internal/config"
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/faizalom/go-api/internal/repository"
+	"github.com/faizalom/go-api/internal/totp"
+	"github.com/faizalom/go-api/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// recoveryCodeCount is how many recovery codes a user gets.
+	recoveryCodeCount = 10
+	// totpSkew is how many periods either side of now a code is accepted,
+	// to allow for clock drift on the user's device.
+	totpSkew = 1
+)
+
+type IMFAService interface {
+	Enroll(ctx context.Context, userID uuid.UUID) (*model.MFAEnrollment, error)
+	Confirm(ctx context.Context, userID uuid.UUID, req *model.MFACodeRequest) (*model.MFARecoveryCodes, error)
+	Disable(ctx context.Context, userID uuid.UUID, req *model.MFACodeRequest) error
+	Verify(ctx context.Context, req *model.MFAVerifyRequest) (*model.TokenResponse, error)
+}
+
+type MFAService struct {
+	mfaRepo       repository.IMFARepository
+	recoveryRepo  repository.IMFARecoveryCodeRepository
+	challengeRepo repository.IMFAChallengeRepository
+	userRepo      repository.IUserRepository
+	auth          IAuthService
+}
+
+func NewMFAService(mfaRepo repository.IMFARepository, recoveryRepo repository.IMFARecoveryCodeRepository, challengeRepo repository.IMFAChallengeRepository, userRepo repository.IUserRepository, auth IAuthService) IMFAService {
+	return &MFAService{mfaRepo: mfaRepo, recoveryRepo: recoveryRepo, challengeRepo: challengeRepo, userRepo: userRepo, auth: auth}
+}
+
+// Enroll generates a new TOTP secret for the user. It has no effect on login
+// until the user confirms it with a code from their authenticator app.
+func (s *MFAService) Enroll(ctx context.Context, userID uuid.UUID) (*model.MFAEnrollment, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	enrolled, err := s.mfaRepo.Enroll(ctx, userID, secret)
+	if err != nil {
+		return nil, err
+	}
+	if !enrolled {
+		return nil, ierr.ErrMFAAlreadyEnabled
+	}
+
+	return &model.MFAEnrollment{
+		Secret: secret,
+		URI:    totp.URI(config.App.MFA.Issuer, user.Email, secret),
+	}, nil
+}
+
+// Confirm turns on two-factor authentication once the user proves their
+// authenticator app works, and returns their recovery codes. Only hashes of
+// the codes are kept, so this is the only time they can be shown.
+func (s *MFAService) Confirm(ctx context.Context, userID uuid.UUID, req *model.MFACodeRequest) (*model.MFARecoveryCodes, error) {
+	mfa, err := s.mfaRepo.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa.Enabled() {
+		return nil, ierr.ErrMFAAlreadyEnabled
+	}
+
+	if err := s.checkTOTP(ctx, mfa, req.Code); err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		if codes[i], err = generateRecoveryCode(); err != nil {
+			return nil, err
+		}
+		hashes[i] = hashToken(normalizeRecoveryCode(codes[i]))
+	}
+	if err := s.recoveryRepo.ReplaceForUser(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	if err := s.mfaRepo.Confirm(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	logger.Info.Printf("Two-factor authentication enabled for user %s", userID)
+	return &model.MFARecoveryCodes{RecoveryCodes: codes}, nil
+}
+
+// Disable turns off two-factor authentication. It takes a current code, or a
+// recovery code, so a stolen access token alone cannot remove the factor.
+func (s *MFAService) Disable(ctx context.Context, userID uuid.UUID, req *model.MFACodeRequest) error {
+	mfa, err := s.mfaRepo.Get(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !mfa.Enabled() {
+		return ierr.ErrMFANotEnrolled
+	}
+
+	if err := s.checkCode(ctx, mfa, req.Code); err != nil {
+		return err
+	}
+	if err := s.mfaRepo.Delete(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.recoveryRepo.DeleteForUser(ctx, userID); err != nil {
+		return err
+	}
+
+	logger.Info.Printf("Two-factor authentication disabled for user %s", userID)
+	return nil
+}
+
+// Verify completes a login: it exchanges the MFA token returned by Login and
+// a code for an access and refresh token. Each MFA token can be redeemed once
+// and only config.App.MFA.MaxAttempts codes can be tried against it. Wrong
+// codes also count as failed logins, so that they are throttled and lock the
+// account like wrong passwords, however many MFA tokens are used.
+func (s *MFAService) Verify(ctx context.Context, req *model.MFAVerifyRequest) (*model.TokenResponse, error) {
+	challenge, err := s.challengeRepo.GetByHash(ctx, hashToken(req.MFAToken))
+	if err != nil {
+		if errors.Is(err, ierr.ErrMFAChallengeNotFound) {
+			return nil, ierr.ErrInvalidMFAToken
+		}
+		return nil, err
+	}
+	if challenge.UsedAt != nil || time.Now().After(challenge.ExpiresAt) {
+		return nil, ierr.ErrInvalidMFAToken
+	}
+
+	user, err := s.userRepo.GetByID(ctx, challenge.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.auth.AllowLogin(user, req.Client); err != nil {
+		return nil, err
+	}
+
+	attempts, err := s.challengeRepo.RecordAttempt(ctx, challenge.ID)
+	if err != nil {
+		return nil, err
+	}
+	if attempts > config.App.MFA.MaxAttempts {
+		logger.Error.Printf("Too many two-factor codes tried for user %s, discarding the login", challenge.UserID)
+		if _, err := s.challengeRepo.MarkUsed(ctx, challenge.ID); err != nil {
+			return nil, err
+		}
+		return nil, ierr.ErrInvalidMFAToken
+	}
+
+	mfa, err := s.mfaRepo.Get(ctx, challenge.UserID)
+	if err != nil {
+		if errors.Is(err, ierr.ErrMFANotEnrolled) {
+			// Disabled since the login started; have them sign in again.
+			return nil, ierr.ErrInvalidMFAToken
+		}
+		return nil, err
+	}
+	if err := s.checkCode(ctx, mfa, req.Code); err != nil {
+		if errors.Is(err, ierr.ErrInvalidMFACode) {
+			if err := s.auth.FailLogin(ctx, user, req.Client); err != nil {
+				return nil, err
+			}
+		}
+		return nil, err
+	}
+
+	claimed, err := s.challengeRepo.MarkUsed(ctx, challenge.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, ierr.ErrInvalidMFAToken
+	}
+
+	return s.auth.CompleteLogin(ctx, user, req.Client)
+}
+
+// checkCode accepts either a code from the authenticator app or an unused
+// recovery code, which is consumed.
+func (s *MFAService) checkCode(ctx context.Context, mfa *model.MFA, code string) error {
+	if len(code) == totp.Digits {
+		return s.checkTOTP(ctx, mfa, code)
+	}
+
+	used, err := s.recoveryRepo.Use(ctx, mfa.UserID, hashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ierr.ErrInvalidMFACode
+	}
+	logger.Info.Printf("Recovery code used by user %s", mfa.UserID)
+	return nil
+}
+
+// checkTOTP accepts a code from the authenticator app, at most once.
+func (s *MFAService) checkTOTP(ctx context.Context, mfa *model.MFA, code string) error {
+	step, ok := totp.Validate(mfa.Secret, code, time.Now(), totpSkew)
+	if !ok {
+		return ierr.ErrInvalidMFACode
+	}
+	fresh, err := s.mfaRepo.UseStep(ctx, mfa.UserID, step)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ierr.ErrInvalidMFACode
+	}
+	return nil
+}
+
+// generateRecoveryCode returns a random code formatted for reading aloud or
+// writing down, e.g. "k7qd-3mzp-x2fa-6hwt".
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+	return code[0:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:16], nil
+}
+
+// normalizeRecoveryCode ignores case, spaces and dashes in what the user typed.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,364 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/faizalom/go-api/internal/config"
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/faizalom/go-api/internal/repository/mocks"
+	"github.com/faizalom/go-api/internal/totp"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const testTOTPSecret = "JBSWY3DPEHPK3PXP"
+
+func currentCode(t *testing.T) string {
+	t.Helper()
+	code, err := totp.Code(testTOTPSecret, time.Now())
+	require.NoError(t, err)
+	return code
+}
+
+func enabledMFA(userID uuid.UUID) *model.MFA {
+	confirmedAt := time.Now()
+	return &model.MFA{UserID: userID, Secret: testTOTPSecret, ConfirmedAt: &confirmedAt}
+}
+
+func TestMFAService_Enroll(t *testing.T) {
+	setTestJWTConfig(t)
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+	mockMFARepo := new(mocks.MockMFARepository)
+	mockRecoveryRepo := new(mocks.MockMFARecoveryCodeRepository)
+	mockChallengeRepo := new(mocks.MockMFAChallengeRepository)
+	authService := NewAuthService(mockUserRepo, mockRefreshTokenRepo, withSessions(), mockMFARepo, mockChallengeRepo, NewRevocationService(new(mocks.MockRevokedTokenRepository)), testKeys, testThrottle())
+	mfaService := NewMFAService(mockMFARepo, mockRecoveryRepo, mockChallengeRepo, mockUserRepo, authService)
+
+	user := &model.User{ID: uuid.New(), Email: "test@example.com"}
+
+	var secret string
+	mockUserRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+	mockMFARepo.On("Enroll", mock.Anything, user.ID, mock.AnythingOfType("string")).Run(func(args mock.Arguments) {
+		secret = args.String(2)
+	}).Return(true, nil)
+
+	enrollment, err := mfaService.Enroll(context.Background(), user.ID)
+
+	require.NoError(t, err)
+	assert.Equal(t, secret, enrollment.Secret)
+	u, err := url.Parse(enrollment.URI)
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", u.Scheme)
+	assert.Equal(t, secret, u.Query().Get("secret"))
+	assert.Contains(t, u.Path, user.Email)
+}
+
+func TestMFAService_Enroll_AlreadyEnabled(t *testing.T) {
+	setTestJWTConfig(t)
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+	mockMFARepo := new(mocks.MockMFARepository)
+	mockRecoveryRepo := new(mocks.MockMFARecoveryCodeRepository)
+	mockChallengeRepo := new(mocks.MockMFAChallengeRepository)
+	authService := NewAuthService(mockUserRepo, mockRefreshTokenRepo, withSessions(), mockMFARepo, mockChallengeRepo, NewRevocationService(new(mocks.MockRevokedTokenRepository)), testKeys, testThrottle())
+	mfaService := NewMFAService(mockMFARepo, mockRecoveryRepo, mockChallengeRepo, mockUserRepo, authService)
+
+	userID := uuid.New()
+
+	mockUserRepo.On("GetByID", mock.Anything, userID).Return(&model.User{ID: userID}, nil)
+	mockMFARepo.On("Enroll", mock.Anything, userID, mock.Anything).Return(false, nil)
+
+	enrollment, err := mfaService.Enroll(context.Background(), userID)
+
+	assert.ErrorIs(t, err, ierr.ErrMFAAlreadyEnabled)
+	assert.Nil(t, enrollment)
+}
+
+func TestMFAService_Confirm(t *testing.T) {
+	setTestJWTConfig(t)
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+	mockMFARepo := new(mocks.MockMFARepository)
+	mockRecoveryRepo := new(mocks.MockMFARecoveryCodeRepository)
+	mockChallengeRepo := new(mocks.MockMFAChallengeRepository)
+	authService := NewAuthService(mockUserRepo, mockRefreshTokenRepo, withSessions(), mockMFARepo, mockChallengeRepo, NewRevocationService(new(mocks.MockRevokedTokenRepository)), testKeys, testThrottle())
+	mfaService := NewMFAService(mockMFARepo, mockRecoveryRepo, mockChallengeRepo, mockUserRepo, authService)
+
+	userID := uuid.New()
+
+	var hashes []string
+	mockMFARepo.On("Get", mock.Anything, userID).Return(&model.MFA{UserID: userID, Secret: testTOTPSecret}, nil)
+	mockMFARepo.On("UseStep", mock.Anything, userID, mock.AnythingOfType("int64")).Return(true, nil)
+	mockRecoveryRepo.On("ReplaceForUser", mock.Anything, userID, mock.Anything).Run(func(args mock.Arguments) {
+		hashes = args.Get(2).([]string)
+	}).Return(nil)
+	mockMFARepo.On("Confirm", mock.Anything, userID).Return(nil)
+
+	codes, err := mfaService.Confirm(context.Background(), userID, &model.MFACodeRequest{Code: currentCode(t)})
+
+	require.NoError(t, err)
+	require.Len(t, codes.RecoveryCodes, recoveryCodeCount)
+	require.Len(t, hashes, recoveryCodeCount)
+	for i, code := range codes.RecoveryCodes {
+		assert.Len(t, code, 19)
+		// Only hashes are stored, and they match however the code is typed.
+		assert.Equal(t, hashes[i], hashToken(normalizeRecoveryCode(strings.ToUpper(code))))
+	}
+	mockMFARepo.AssertExpectations(t)
+}
+
+func TestMFAService_Confirm_WrongCode(t *testing.T) {
+	setTestJWTConfig(t)
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+	mockMFARepo := new(mocks.MockMFARepository)
+	mockRecoveryRepo := new(mocks.MockMFARecoveryCodeRepository)
+	mockChallengeRepo := new(mocks.MockMFAChallengeRepository)
+	authService := NewAuthService(mockUserRepo, mockRefreshTokenRepo, withSessions(), mockMFARepo, mockChallengeRepo, NewRevocationService(new(mocks.MockRevokedTokenRepository)), testKeys, testThrottle())
+	mfaService := NewMFAService(mockMFARepo, mockRecoveryRepo, mockChallengeRepo, mockUserRepo, authService)
+
+	userID := uuid.New()
+
+	mockMFARepo.On("Get", mock.Anything, userID).Return(&model.MFA{UserID: userID, Secret: testTOTPSecret}, nil)
+
+	codes, err := mfaService.Confirm(context.Background(), userID, &model.MFACodeRequest{Code: "000000"})
+
+	assert.ErrorIs(t, err, ierr.ErrInvalidMFACode)
+	assert.Nil(t, codes)
+	mockMFARepo.AssertNotCalled(t, "Confirm", mock.Anything, mock.Anything)
+}
+
+func TestMFAService_Disable_RecoveryCode(t *testing.T) {
+	setTestJWTConfig(t)
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+	mockMFARepo := new(mocks.MockMFARepository)
+	mockRecoveryRepo := new(mocks.MockMFARecoveryCodeRepository)
+	mockChallengeRepo := new(mocks.MockMFAChallengeRepository)
+	authService := NewAuthService(mockUserRepo, mockRefreshTokenRepo, withSessions(), mockMFARepo, mockChallengeRepo, NewRevocationService(new(mocks.MockRevokedTokenRepository)), testKeys, testThrottle())
+	mfaService := NewMFAService(mockMFARepo, mockRecoveryRepo, mockChallengeRepo, mockUserRepo, authService)
+
+	userID := uuid.New()
+
+	mockMFARepo.On("Get", mock.Anything, userID).Return(enabledMFA(userID), nil)
+	mockRecoveryRepo.On("Use", mock.Anything, userID, hashToken("abcdefghijklmnop")).Return(true, nil)
+	mockMFARepo.On("Delete", mock.Anything, userID).Return(nil)
+	mockRecoveryRepo.On("DeleteForUser", mock.Anything, userID).Return(nil)
+
+	err := mfaService.Disable(context.Background(), userID, &model.MFACodeRequest{Code: "ABCD-EFGH-IJKL-MNOP"})
+
+	assert.NoError(t, err)
+	mockMFARepo.AssertExpectations(t)
+	mockRecoveryRepo.AssertExpectations(t)
+}
+
+func TestMFAService_Disable_NotEnabled(t *testing.T) {
+	setTestJWTConfig(t)
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+	mockMFARepo := new(mocks.MockMFARepository)
+	mockRecoveryRepo := new(mocks.MockMFARecoveryCodeRepository)
+	mockChallengeRepo := new(mocks.MockMFAChallengeRepository)
+	authService := NewAuthService(mockUserRepo, mockRefreshTokenRepo, withSessions(), mockMFARepo, mockChallengeRepo, NewRevocationService(new(mocks.MockRevokedTokenRepository)), testKeys, testThrottle())
+	mfaService := NewMFAService(mockMFARepo, mockRecoveryRepo, mockChallengeRepo, mockUserRepo, authService)
+
+	userID := uuid.New()
+
+	mockMFARepo.On("Get", mock.Anything, userID).Return(&model.MFA{UserID: userID, Secret: testTOTPSecret}, nil)
+
+	err := mfaService.Disable(context.Background(), userID, &model.MFACodeRequest{Code: currentCode(t)})
+
+	assert.ErrorIs(t, err, ierr.ErrMFANotEnrolled)
+	mockMFARepo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+}
+
+func TestMFAService_Verify(t *testing.T) {
+	mfaConfig := config.App.MFA
+	t.Cleanup(func() { config.App.MFA = mfaConfig })
+	config.App.MFA.MaxAttempts = 5
+
+	setTestJWTConfig(t)
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+	mockMFARepo := new(mocks.MockMFARepository)
+	mockRecoveryRepo := new(mocks.MockMFARecoveryCodeRepository)
+	mockChallengeRepo := new(mocks.MockMFAChallengeRepository)
+	authService := NewAuthService(mockUserRepo, mockRefreshTokenRepo, withSessions(), mockMFARepo, mockChallengeRepo, NewRevocationService(new(mocks.MockRevokedTokenRepository)), testKeys, testThrottle())
+	mfaService := NewMFAService(mockMFARepo, mockRecoveryRepo, mockChallengeRepo, mockUserRepo, authService)
+
+	user := &model.User{ID: uuid.New(), Email: "test@example.com", IsActive: true, FailedLoginCount: 2}
+	challenge := &model.MFAChallenge{ID: uuid.New(), UserID: user.ID, ExpiresAt: time.Now().Add(time.Minute)}
+
+	mockChallengeRepo.On("GetByHash", mock.Anything, hashToken("mfa-token")).Return(challenge, nil)
+	mockChallengeRepo.On("RecordAttempt", mock.Anything, challenge.ID).Return(1, nil)
+	mockMFARepo.On("Get", mock.Anything, user.ID).Return(enabledMFA(user.ID), nil)
+	mockMFARepo.On("UseStep", mock.Anything, user.ID, mock.AnythingOfType("int64")).Return(true, nil)
+	mockChallengeRepo.On("MarkUsed", mock.Anything, challenge.ID).Return(true, nil)
+	mockUserRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+	mockUserRepo.On("ResetLoginFailures", mock.Anything, user.ID).Return(nil)
+	mockRefreshTokenRepo.On("Create", mock.Anything, mock.AnythingOfType("*model.RefreshToken")).Return(nil)
+
+	resp, err := mfaService.Verify(context.Background(), &model.MFAVerifyRequest{MFAToken: "mfa-token", Code: currentCode(t)})
+
+	require.NoError(t, err)
+	assert.NotEmpty(t, resp.Token)
+	assert.NotEmpty(t, resp.RefreshToken)
+	assert.False(t, resp.MFARequired)
+	mockChallengeRepo.AssertExpectations(t)
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestMFAService_Verify_ReplayedCode(t *testing.T) {
+	mfaConfig := config.App.MFA
+	t.Cleanup(func() { config.App.MFA = mfaConfig })
+	config.App.MFA.MaxAttempts = 5
+
+	setTestJWTConfig(t)
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+	mockMFARepo := new(mocks.MockMFARepository)
+	mockRecoveryRepo := new(mocks.MockMFARecoveryCodeRepository)
+	mockChallengeRepo := new(mocks.MockMFAChallengeRepository)
+	authService := NewAuthService(mockUserRepo, mockRefreshTokenRepo, withSessions(), mockMFARepo, mockChallengeRepo, NewRevocationService(new(mocks.MockRevokedTokenRepository)), testKeys, testThrottle())
+	mfaService := NewMFAService(mockMFARepo, mockRecoveryRepo, mockChallengeRepo, mockUserRepo, authService)
+
+	userID := uuid.New()
+	challenge := &model.MFAChallenge{ID: uuid.New(), UserID: userID, ExpiresAt: time.Now().Add(time.Minute)}
+
+	mockChallengeRepo.On("GetByHash", mock.Anything, mock.Anything).Return(challenge, nil)
+	mockChallengeRepo.On("RecordAttempt", mock.Anything, challenge.ID).Return(1, nil)
+	mockUserRepo.On("GetByID", mock.Anything, userID).Return(&model.User{ID: userID, Email: "test@example.com", IsActive: true}, nil)
+	mockMFARepo.On("Get", mock.Anything, userID).Return(enabledMFA(userID), nil)
+	mockMFARepo.On("UseStep", mock.Anything, userID, mock.Anything).Return(false, nil)
+	mockUserRepo.On("RecordLoginFailure", mock.Anything, userID).Return(1, nil)
+
+	resp, err := mfaService.Verify(context.Background(), &model.MFAVerifyRequest{MFAToken: "mfa-token", Code: currentCode(t)})
+
+	assert.ErrorIs(t, err, ierr.ErrInvalidMFACode)
+	assert.Nil(t, resp)
+	mockChallengeRepo.AssertNotCalled(t, "MarkUsed", mock.Anything, mock.Anything)
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestMFAService_Verify_WrongCodesAreThrottled(t *testing.T) {
+	mfaConfig := config.App.MFA
+	t.Cleanup(func() { config.App.MFA = mfaConfig })
+	config.App.MFA.MaxAttempts = 5
+
+	setTestJWTConfig(t)
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+	mockMFARepo := new(mocks.MockMFARepository)
+	mockRecoveryRepo := new(mocks.MockMFARecoveryCodeRepository)
+	mockChallengeRepo := new(mocks.MockMFAChallengeRepository)
+	throttle := NewLoginThrottle(config.LoginProtectionConfig{EmailFreeAttempts: 2, IPFreeAttempts: 100, BaseBackoff: time.Minute, MaxBackoff: time.Hour, ResetAfter: time.Hour})
+	authService := NewAuthService(mockUserRepo, mockRefreshTokenRepo, withSessions(), mockMFARepo, mockChallengeRepo, NewRevocationService(new(mocks.MockRevokedTokenRepository)), testKeys, throttle)
+	mfaService := NewMFAService(mockMFARepo, mockRecoveryRepo, mockChallengeRepo, mockUserRepo, authService)
+
+	user := &model.User{ID: uuid.New(), Email: "test@example.com", IsActive: true}
+	challenge := &model.MFAChallenge{ID: uuid.New(), UserID: user.ID, ExpiresAt: time.Now().Add(time.Minute)}
+
+	mockChallengeRepo.On("GetByHash", mock.Anything, mock.Anything).Return(challenge, nil)
+	// Each code is the first tried against its MFA token, as if the password
+	// was entered again for every guess.
+	mockChallengeRepo.On("RecordAttempt", mock.Anything, challenge.ID).Return(1, nil)
+	mockUserRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+	mockMFARepo.On("Get", mock.Anything, user.ID).Return(enabledMFA(user.ID), nil)
+	mockUserRepo.On("RecordLoginFailure", mock.Anything, user.ID).Return(1, nil)
+
+	for i := 0; i < 3; i++ {
+		_, err := mfaService.Verify(context.Background(), &model.MFAVerifyRequest{MFAToken: "mfa-token", Code: "000000"})
+		assert.ErrorIs(t, err, ierr.ErrInvalidMFACode)
+	}
+
+	// Even the right code is refused until the backoff has passed.
+	_, err := mfaService.Verify(context.Background(), &model.MFAVerifyRequest{MFAToken: "mfa-token", Code: currentCode(t)})
+	assert.ErrorIs(t, err, ierr.ErrTooManyLoginAttempts)
+	mockUserRepo.AssertNumberOfCalls(t, "RecordLoginFailure", 3)
+	mockMFARepo.AssertNotCalled(t, "UseStep", mock.Anything, mock.Anything, mock.Anything)
+	mockRefreshTokenRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestMFAService_Verify_Locked(t *testing.T) {
+	mfaConfig := config.App.MFA
+	t.Cleanup(func() { config.App.MFA = mfaConfig })
+	config.App.MFA.MaxAttempts = 5
+
+	setTestJWTConfig(t)
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+	mockMFARepo := new(mocks.MockMFARepository)
+	mockRecoveryRepo := new(mocks.MockMFARecoveryCodeRepository)
+	mockChallengeRepo := new(mocks.MockMFAChallengeRepository)
+	authService := NewAuthService(mockUserRepo, mockRefreshTokenRepo, withSessions(), mockMFARepo, mockChallengeRepo, NewRevocationService(new(mocks.MockRevokedTokenRepository)), testKeys, testThrottle())
+	mfaService := NewMFAService(mockMFARepo, mockRecoveryRepo, mockChallengeRepo, mockUserRepo, authService)
+
+	lockedUntil := time.Now().Add(time.Hour)
+	user := &model.User{ID: uuid.New(), Email: "test@example.com", IsActive: true, FailedLoginCount: 5, LockedUntil: &lockedUntil}
+	challenge := &model.MFAChallenge{ID: uuid.New(), UserID: user.ID, ExpiresAt: time.Now().Add(time.Minute)}
+
+	mockChallengeRepo.On("GetByHash", mock.Anything, mock.Anything).Return(challenge, nil)
+	mockUserRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+
+	resp, err := mfaService.Verify(context.Background(), &model.MFAVerifyRequest{MFAToken: "mfa-token", Code: currentCode(t)})
+
+	assert.ErrorIs(t, err, ierr.ErrTooManyLoginAttempts)
+	assert.Nil(t, resp)
+	mockChallengeRepo.AssertNotCalled(t, "RecordAttempt", mock.Anything, mock.Anything)
+	mockRefreshTokenRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestMFAService_Verify_Rejected(t *testing.T) {
+	mfaConfig := config.App.MFA
+	t.Cleanup(func() { config.App.MFA = mfaConfig })
+	config.App.MFA.MaxAttempts = 5
+
+	usedAt := time.Now()
+
+	tests := []struct {
+		name     string
+		stored   *model.MFAChallenge
+		err      error
+		attempts int
+	}{
+		{name: "unknown token", err: ierr.ErrMFAChallengeNotFound},
+		{name: "expired token", stored: &model.MFAChallenge{ID: uuid.New(), ExpiresAt: time.Now().Add(-time.Minute)}},
+		{name: "used token", stored: &model.MFAChallenge{ID: uuid.New(), ExpiresAt: time.Now().Add(time.Minute), UsedAt: &usedAt}},
+		{name: "too many attempts", stored: &model.MFAChallenge{ID: uuid.New(), ExpiresAt: time.Now().Add(time.Minute)}, attempts: 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestJWTConfig(t)
+			mockUserRepo := new(mocks.MockUserRepository)
+			mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+			mockMFARepo := new(mocks.MockMFARepository)
+			mockRecoveryRepo := new(mocks.MockMFARecoveryCodeRepository)
+			mockChallengeRepo := new(mocks.MockMFAChallengeRepository)
+			authService := NewAuthService(mockUserRepo, mockRefreshTokenRepo, withSessions(), mockMFARepo, mockChallengeRepo, NewRevocationService(new(mocks.MockRevokedTokenRepository)), testKeys, testThrottle())
+			mfaService := NewMFAService(mockMFARepo, mockRecoveryRepo, mockChallengeRepo, mockUserRepo, authService)
+
+			mockChallengeRepo.On("GetByHash", mock.Anything, mock.Anything).Return(tt.stored, tt.err)
+			if tt.attempts > 0 {
+				mockUserRepo.On("GetByID", mock.Anything, tt.stored.UserID).Return(&model.User{ID: tt.stored.UserID, Email: "test@example.com", IsActive: true}, nil)
+				mockChallengeRepo.On("RecordAttempt", mock.Anything, tt.stored.ID).Return(tt.attempts, nil)
+				mockChallengeRepo.On("MarkUsed", mock.Anything, tt.stored.ID).Return(true, nil)
+			}
+
+			resp, err := mfaService.Verify(context.Background(), &model.MFAVerifyRequest{MFAToken: "mfa-token", Code: currentCode(t)})
+
+			assert.ErrorIs(t, err, ierr.ErrInvalidMFAToken)
+			assert.Nil(t, resp)
+			mockMFARepo.AssertNotCalled(t, "UseStep", mock.Anything, mock.Anything, mock.Anything)
+			mockRefreshTokenRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockAuthService struct {
+	mock.Mock
+}
+
+func (m *MockAuthService) Login(ctx context.Context, req *model.LoginRequest) (*model.TokenResponse, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.TokenResponse), args.Error(1)
+}
+
+func (m *MockAuthService) Refresh(ctx context.Context, req *model.RefreshRequest) (*model.TokenResponse, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.TokenResponse), args.Error(1)
+}
+
+func (m *MockAuthService) Logout(ctx context.Context, claims *model.CustomClaims) error {
+	args := m.Called(ctx, claims)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) IssueTokens(ctx context.Context, user *model.User, client model.ClientInfo) (*model.TokenResponse, error) {
+	args := m.Called(ctx, user, client)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.TokenResponse), args.Error(1)
+}
+
+func (m *MockAuthService) CompleteLogin(ctx context.Context, user *model.User, client model.ClientInfo) (*model.TokenResponse, error) {
+	args := m.Called(ctx, user, client)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.TokenResponse), args.Error(1)
+}
+
+func (m *MockAuthService) Unlock(ctx context.Context, userID uuid.UUID) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) AllowLogin(user *model.User, client model.ClientInfo) error {
+	args := m.Called(user, client)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) FailLogin(ctx context.Context, user *model.User, client model.ClientInfo) error {
+	args := m.Called(ctx, user, client)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) ResetLoginFailures(ctx context.Context, user *model.User) error {
+	args := m.Called(ctx, user)
+	return args.Error(0)
+}
@@ -0,0 +1,20 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockImpersonationService struct {
+	mock.Mock
+}
+
+func (m *MockImpersonationService) Impersonate(ctx context.Context, actor *model.CustomClaims, req *model.ImpersonationRequest) (*model.TokenResponse, error) {
+	args := m.Called(ctx, actor, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.TokenResponse), args.Error(1)
+}
@@ -0,0 +1,42 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockMFAService struct {
+	mock.Mock
+}
+
+func (m *MockMFAService) Enroll(ctx context.Context, userID uuid.UUID) (*model.MFAEnrollment, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.MFAEnrollment), args.Error(1)
+}
+
+func (m *MockMFAService) Confirm(ctx context.Context, userID uuid.UUID, req *model.MFACodeRequest) (*model.MFARecoveryCodes, error) {
+	args := m.Called(ctx, userID, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.MFARecoveryCodes), args.Error(1)
+}
+
+func (m *MockMFAService) Disable(ctx context.Context, userID uuid.UUID, req *model.MFACodeRequest) error {
+	args := m.Called(ctx, userID, req)
+	return args.Error(0)
+}
+
+func (m *MockMFAService) Verify(ctx context.Context, req *model.MFAVerifyRequest) (*model.TokenResponse, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.TokenResponse), args.Error(1)
+}
@@ -0,0 +1,20 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockOIDCService struct {
+	mock.Mock
+}
+
+func (m *MockOIDCService) Login(ctx context.Context, identity *model.ExternalIdentity, client model.ClientInfo) (*model.TokenResponse, error) {
+	args := m.Called(ctx, identity, client)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.TokenResponse), args.Error(1)
+}
@@ -0,0 +1,38 @@
+package mocks
+
+import (
+	"context"
+	"io"
+
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockUserBulkService struct {
+	mock.Mock
+}
+
+func (m *MockUserBulkService) ImportUsers(ctx context.Context, req *model.UserImportRequest) (*model.UserImportReport, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.UserImportReport), args.Error(1)
+}
+
+// ExportUsers writes the mock's first return value, if it is a string, to w.
+func (m *MockUserBulkService) ExportUsers(ctx context.Context, format string, w io.Writer) error {
+	args := m.Called(ctx, format, w)
+	if out, ok := args.Get(0).(string); ok {
+		io.WriteString(w, out)
+	}
+	return args.Error(1)
+}
+
+func (m *MockUserBulkService) RunBatch(ctx context.Context, req *model.UserBatchRequest) (*model.UserBatchResponse, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.UserBatchResponse), args.Error(1)
+}
@@ -22,17 +22,47 @@ func (m *MockUserService) GetUserByID(ctx context.Context, id uuid.UUID) (*model
 	return args.Get(0).(*model.User), args.Error(1)
 }
 
-func (m *MockUserService) UpdateUser(ctx context.Context, id uuid.UUID, req *model.UpdateUserRequest) (*model.User, error) {
-	args := m.Called(ctx, id, req)
+func (m *MockUserService) UpdateUser(ctx context.Context, id uuid.UUID, version int, req *model.UpdateUserRequest, rights model.UserEditRights) (*model.User, error) {
+	args := m.Called(ctx, id, version, req, rights)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
 	return args.Get(0).(*model.User), args.Error(1)
 }
 
-func (m *MockUserService) DeleteUser(ctx context.Context, id uuid.UUID) error {
-	args := m.Called(ctx, id)
+func (m *MockUserService) PatchUser(ctx context.Context, id uuid.UUID, version int, patch *model.UserPatch, rights model.UserEditRights) (*model.User, error) {
+	args := m.Called(ctx, id, version, patch, rights)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+func (m *MockUserService) DeleteUser(ctx context.Context, id uuid.UUID, version int) error {
+	args := m.Called(ctx, id, version)
 	return args.Error(0)
 }
 
-func (m *MockUserService) ListUsers(ctx context.Context) ([]*model.User, error) {
-	args := m.Called(ctx)
-	return args.Get(0).([]*model.User), args.Error(1)
+func (m *MockUserService) ListUsers(ctx context.Context, req *model.UserListRequest) (*model.UserPage, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.UserPage), args.Error(1)
+}
+
+func (m *MockUserService) SearchUsers(ctx context.Context, req *model.UserSearchRequest) (*model.UserSearchPage, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.UserSearchPage), args.Error(1)
+}
+
+func (m *MockUserService) RestoreUser(ctx context.Context, id uuid.UUID) (*model.User, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
 }
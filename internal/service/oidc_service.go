@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/faizalom/go-api/internal/repository"
+)
+
+type IOIDCService interface {
+	Login(ctx context.Context, identity *model.ExternalIdentity, client model.ClientInfo) (*model.TokenResponse, error)
+}
+
+type OIDCService struct {
+	identityRepo repository.IUserIdentityRepository
+	userRepo     repository.IUserRepository
+	users        IUserService
+	auth         IAuthService
+}
+
+func NewOIDCService(identityRepo repository.IUserIdentityRepository, userRepo repository.IUserRepository, users IUserService, auth IAuthService) IOIDCService {
+	return &OIDCService{identityRepo: identityRepo, userRepo: userRepo, users: users, auth: auth}
+}
+
+// Login signs in the user an external identity belongs to, linking the
+// identity to an existing account or creating one on first sign-in, and
+// issues our own token pair.
+func (s *OIDCService) Login(ctx context.Context, identity *model.ExternalIdentity, client model.ClientInfo) (*model.TokenResponse, error) {
+	user, err := s.resolveUser(ctx, identity)
+	if errors.Is(err, ierr.ErrUserAlreadyExists) {
+		// A concurrent first sign-in created the account meanwhile; link to it.
+		user, err = s.resolveUser(ctx, identity)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.auth.IssueTokens(ctx, user, client)
+}
+
+func (s *OIDCService) resolveUser(ctx context.Context, identity *model.ExternalIdentity) (*model.User, error) {
+	user, err := s.linkedUser(ctx, identity)
+	if !errors.Is(err, ierr.ErrIdentityNotFound) {
+		return user, err
+	}
+
+	if identity.Email == "" {
+		return nil, ierr.ErrIdentityNoEmail
+	}
+
+	user, _, err = s.userRepo.GetByEmail(ctx, identity.Email)
+	switch {
+	case err == nil:
+		// Only link to an existing account when the provider vouches for the
+		// address, or anyone could register it there and take the account over.
+		if !identity.EmailVerified {
+			return nil, ierr.ErrIdentityUnverified
+		}
+		if user.EmailVerifiedAt == nil {
+			if _, err := s.userRepo.MarkEmailVerified(ctx, user.ID, identity.Email); err != nil {
+				return nil, err
+			}
+			now := time.Now()
+			user.EmailVerifiedAt = &now
+		}
+	case errors.Is(err, ierr.ErrUserNotFound):
+		if user, err = s.createUser(ctx, identity); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	err = s.identityRepo.Create(ctx, &model.UserIdentity{
+		UserID:   user.ID,
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	})
+	if errors.Is(err, ierr.ErrIdentityLinked) {
+		// A concurrent sign-in linked the identity first.
+		return s.linkedUser(ctx, identity)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// linkedUser returns the user the identity is linked to, or fails with
+// ierr.ErrIdentityNotFound.
+func (s *OIDCService) linkedUser(ctx context.Context, identity *model.ExternalIdentity) (*model.User, error) {
+	linked, err := s.identityRepo.GetByProviderSubject(ctx, identity.Provider, identity.Subject)
+	if err != nil {
+		return nil, err
+	}
+	return s.userRepo.GetByID(ctx, linked.UserID)
+}
+
+// createUser creates the account for a first-time sign-in. It gets a random
+// password nobody knows, so it can only be signed into through the provider.
+func (s *OIDCService) createUser(ctx context.Context, identity *model.ExternalIdentity) (*model.User, error) {
+	password, _, err := generateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	// Make sure it has every character class the password policy may require.
+	password += "aA1!"
+
+	name := identity.Name
+	if name == "" {
+		name = identity.Email
+	}
+
+	return s.users.CreateUser(ctx, &model.NewUserRequest{
+		Name:     name,
+		Email:    identity.Email,
+		Password: password,
+		// The provider vouches for the address, so we need not mail a link.
+		EmailVerified: identity.EmailVerified,
+	})
+}
@@ -0,0 +1,262 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/faizalom/go-api/internal/config"
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/faizalom/go-api/internal/repository/mocks"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func testIdentity() *model.ExternalIdentity {
+	return &model.ExternalIdentity{Provider: "test", Subject: "1234", Email: "oidc@example.com", EmailVerified: true, Name: "OIDC User"}
+}
+
+func TestOIDCService_Login_LinkedIdentity(t *testing.T) {
+	setTestJWTConfig(t)
+	mockIdentityRepo := new(mocks.MockUserIdentityRepository)
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+	verifier := &recordingVerifier{}
+	authService := NewAuthService(mockUserRepo, mockRefreshTokenRepo, withSessions(), withoutMFA(), new(mocks.MockMFAChallengeRepository), NewRevocationService(new(mocks.MockRevokedTokenRepository)), testKeys, testThrottle())
+	oidcService := NewOIDCService(mockIdentityRepo, mockUserRepo, NewUserService(mockUserRepo, withTx(), testPasswords, verifier), authService)
+
+	user := &model.User{ID: uuid.New(), Email: "oidc@example.com", IsActive: true}
+
+	mockIdentityRepo.On("GetByProviderSubject", mock.Anything, "test", "1234").Return(&model.UserIdentity{UserID: user.ID}, nil)
+	mockUserRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+	mockRefreshTokenRepo.On("Create", mock.Anything, mock.AnythingOfType("*model.RefreshToken")).Return(nil)
+
+	resp, err := oidcService.Login(context.Background(), testIdentity(), model.ClientInfo{})
+
+	require.NoError(t, err)
+	assert.NotEmpty(t, resp.Token)
+	mockIdentityRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestOIDCService_Login_CreatesUser(t *testing.T) {
+	setTestJWTConfig(t)
+	mockIdentityRepo := new(mocks.MockUserIdentityRepository)
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+	verifier := &recordingVerifier{}
+	authService := NewAuthService(mockUserRepo, mockRefreshTokenRepo, withSessions(), withoutMFA(), new(mocks.MockMFAChallengeRepository), NewRevocationService(new(mocks.MockRevokedTokenRepository)), testKeys, testThrottle())
+	oidcService := NewOIDCService(mockIdentityRepo, mockUserRepo, NewUserService(mockUserRepo, withTx(), testPasswords, verifier), authService)
+
+	identity := testIdentity()
+	newID := uuid.New()
+
+	mockIdentityRepo.On("GetByProviderSubject", mock.Anything, "test", "1234").Return(nil, ierr.ErrIdentityNotFound)
+	mockUserRepo.On("GetByEmail", mock.Anything, identity.Email).Return((*model.User)(nil), "", ierr.ErrUserNotFound)
+	mockUserRepo.On("Create", mock.Anything, mock.MatchedBy(func(u *model.User) bool {
+		return u.Email == identity.Email && u.Name == identity.Name && u.EmailVerifiedAt != nil
+	}), mock.AnythingOfType("string")).Return(&model.User{ID: newID, Name: identity.Name, Email: identity.Email, IsActive: true}, nil)
+	mockIdentityRepo.On("Create", mock.Anything, mock.MatchedBy(func(i *model.UserIdentity) bool {
+		return i.UserID == newID && i.Provider == "test" && i.Subject == "1234"
+	})).Return(nil)
+	mockRefreshTokenRepo.On("Create", mock.Anything, mock.AnythingOfType("*model.RefreshToken")).Return(nil)
+
+	resp, err := oidcService.Login(context.Background(), identity, model.ClientInfo{})
+
+	require.NoError(t, err)
+	assert.NotEmpty(t, resp.Token)
+	// The provider verified the email, so no verification link is sent.
+	assert.Empty(t, verifier.sent)
+	mockIdentityRepo.AssertExpectations(t)
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestOIDCService_Login_LinksExistingUser(t *testing.T) {
+	setTestJWTConfig(t)
+	verificationConfig := config.App.EmailVerification
+	t.Cleanup(func() { config.App.EmailVerification = verificationConfig })
+	// The provider verifies the email the user had not yet verified with us.
+	config.App.EmailVerification.Required = true
+	mockIdentityRepo := new(mocks.MockUserIdentityRepository)
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+	verifier := &recordingVerifier{}
+	authService := NewAuthService(mockUserRepo, mockRefreshTokenRepo, withSessions(), withoutMFA(), new(mocks.MockMFAChallengeRepository), NewRevocationService(new(mocks.MockRevokedTokenRepository)), testKeys, testThrottle())
+	oidcService := NewOIDCService(mockIdentityRepo, mockUserRepo, NewUserService(mockUserRepo, withTx(), testPasswords, verifier), authService)
+
+	identity := testIdentity()
+	existing := &model.User{ID: uuid.New(), Email: identity.Email, IsActive: true}
+
+	mockIdentityRepo.On("GetByProviderSubject", mock.Anything, "test", "1234").Return(nil, ierr.ErrIdentityNotFound)
+	mockUserRepo.On("GetByEmail", mock.Anything, identity.Email).Return(existing, "hash", nil)
+	mockUserRepo.On("MarkEmailVerified", mock.Anything, existing.ID, identity.Email).Return(true, nil)
+	mockIdentityRepo.On("Create", mock.Anything, mock.MatchedBy(func(i *model.UserIdentity) bool {
+		return i.UserID == existing.ID
+	})).Return(nil)
+	mockRefreshTokenRepo.On("Create", mock.Anything, mock.AnythingOfType("*model.RefreshToken")).Return(nil)
+
+	_, err := oidcService.Login(context.Background(), identity, model.ClientInfo{})
+
+	require.NoError(t, err)
+	mockUserRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything, mock.Anything)
+	mockUserRepo.AssertExpectations(t)
+	mockIdentityRepo.AssertExpectations(t)
+}
+
+func TestOIDCService_Login_Concurrent(t *testing.T) {
+	setTestJWTConfig(t)
+	mockIdentityRepo := new(mocks.MockUserIdentityRepository)
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+	authService := NewAuthService(mockUserRepo, mockRefreshTokenRepo, withSessions(), withoutMFA(), new(mocks.MockMFAChallengeRepository), NewRevocationService(new(mocks.MockRevokedTokenRepository)), testKeys, testThrottle())
+	oidcService := NewOIDCService(mockIdentityRepo, mockUserRepo, NewUserService(mockUserRepo, withTx(), testPasswords, &recordingVerifier{}), authService)
+
+	identity := testIdentity()
+	verifiedAt := time.Now()
+	other := &model.User{ID: uuid.New(), Email: identity.Email, EmailVerifiedAt: &verifiedAt, IsActive: true}
+
+	// Another sign-in of the same identity creates the account, then links it,
+	// each just before this one does.
+	mockIdentityRepo.On("GetByProviderSubject", mock.Anything, "test", "1234").Return(nil, ierr.ErrIdentityNotFound).Twice()
+	mockUserRepo.On("GetByEmail", mock.Anything, identity.Email).Return((*model.User)(nil), "", ierr.ErrUserNotFound).Times(3)
+	mockUserRepo.On("Create", mock.Anything, mock.Anything, mock.AnythingOfType("string")).Return((*model.User)(nil), ierr.ErrUserAlreadyExists)
+	mockUserRepo.On("GetByEmail", mock.Anything, identity.Email).Return(other, "hash", nil).Once()
+	mockIdentityRepo.On("Create", mock.Anything, mock.Anything).Return(ierr.ErrIdentityLinked)
+	mockIdentityRepo.On("GetByProviderSubject", mock.Anything, "test", "1234").Return(&model.UserIdentity{UserID: other.ID}, nil).Once()
+	mockUserRepo.On("GetByID", mock.Anything, other.ID).Return(other, nil)
+	mockRefreshTokenRepo.On("Create", mock.Anything, mock.AnythingOfType("*model.RefreshToken")).Return(nil)
+
+	resp, err := oidcService.Login(context.Background(), identity, model.ClientInfo{})
+
+	require.NoError(t, err)
+	assert.NotEmpty(t, resp.Token)
+	mockIdentityRepo.AssertExpectations(t)
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestOIDCService_Login_MFARequired(t *testing.T) {
+	setTestJWTConfig(t)
+	mfaConfig := config.App.MFA
+	t.Cleanup(func() { config.App.MFA = mfaConfig })
+	config.App.MFA.ChallengeTTL = 5 * time.Minute
+	mockIdentityRepo := new(mocks.MockUserIdentityRepository)
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+	mockMFARepo := new(mocks.MockMFARepository)
+	mockChallengeRepo := new(mocks.MockMFAChallengeRepository)
+	verifier := &recordingVerifier{}
+	authService := NewAuthService(mockUserRepo, mockRefreshTokenRepo, withSessions(), mockMFARepo, mockChallengeRepo, NewRevocationService(new(mocks.MockRevokedTokenRepository)), testKeys, testThrottle())
+	oidcService := NewOIDCService(mockIdentityRepo, mockUserRepo, NewUserService(mockUserRepo, withTx(), testPasswords, verifier), authService)
+
+	user := &model.User{ID: uuid.New(), Email: "oidc@example.com", IsActive: true}
+
+	mockIdentityRepo.On("GetByProviderSubject", mock.Anything, "test", "1234").Return(&model.UserIdentity{UserID: user.ID}, nil)
+	mockUserRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+	mockMFARepo.On("Get", mock.Anything, user.ID).Return(enabledMFA(user.ID), nil)
+	mockChallengeRepo.On("Create", mock.Anything, mock.MatchedBy(func(c *model.MFAChallenge) bool {
+		return c.UserID == user.ID
+	})).Return(nil)
+
+	resp, err := oidcService.Login(context.Background(), testIdentity(), model.ClientInfo{})
+
+	// The provider stands in for the password, not for the second factor.
+	require.NoError(t, err)
+	assert.True(t, resp.MFARequired)
+	assert.NotEmpty(t, resp.MFAToken)
+	assert.Empty(t, resp.Token)
+	assert.Empty(t, resp.RefreshToken)
+	mockChallengeRepo.AssertExpectations(t)
+	mockRefreshTokenRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestOIDCService_Login_UnverifiedEmail(t *testing.T) {
+	setTestJWTConfig(t)
+	verificationConfig := config.App.EmailVerification
+	t.Cleanup(func() { config.App.EmailVerification = verificationConfig })
+	config.App.EmailVerification.Required = true
+	mockIdentityRepo := new(mocks.MockUserIdentityRepository)
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+	verifier := &recordingVerifier{}
+	authService := NewAuthService(mockUserRepo, mockRefreshTokenRepo, withSessions(), withoutMFA(), new(mocks.MockMFAChallengeRepository), NewRevocationService(new(mocks.MockRevokedTokenRepository)), testKeys, testThrottle())
+	oidcService := NewOIDCService(mockIdentityRepo, mockUserRepo, NewUserService(mockUserRepo, withTx(), testPasswords, verifier), authService)
+
+	identity := testIdentity()
+	identity.EmailVerified = false
+	newID := uuid.New()
+
+	mockIdentityRepo.On("GetByProviderSubject", mock.Anything, "test", "1234").Return(nil, ierr.ErrIdentityNotFound)
+	mockUserRepo.On("GetByEmail", mock.Anything, identity.Email).Return((*model.User)(nil), "", ierr.ErrUserNotFound)
+	mockUserRepo.On("Create", mock.Anything, mock.MatchedBy(func(u *model.User) bool {
+		return u.Email == identity.Email && u.EmailVerifiedAt == nil
+	}), mock.AnythingOfType("string")).Return(&model.User{ID: newID, Name: identity.Name, Email: identity.Email, IsActive: true}, nil)
+	mockIdentityRepo.On("Create", mock.Anything, mock.AnythingOfType("*model.UserIdentity")).Return(nil)
+
+	resp, err := oidcService.Login(context.Background(), identity, model.ClientInfo{})
+
+	// The account is created, but signing in waits for the emailed link.
+	assert.ErrorIs(t, err, ierr.ErrEmailNotVerified)
+	assert.Nil(t, resp)
+	assert.Len(t, verifier.sent, 1)
+	mockRefreshTokenRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestOIDCService_Login_Rejected(t *testing.T) {
+	t.Run("unverified email of existing user", func(t *testing.T) {
+		setTestJWTConfig(t)
+		mockIdentityRepo := new(mocks.MockUserIdentityRepository)
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+		verifier := &recordingVerifier{}
+		authService := NewAuthService(mockUserRepo, mockRefreshTokenRepo, withSessions(), withoutMFA(), new(mocks.MockMFAChallengeRepository), NewRevocationService(new(mocks.MockRevokedTokenRepository)), testKeys, testThrottle())
+		oidcService := NewOIDCService(mockIdentityRepo, mockUserRepo, NewUserService(mockUserRepo, withTx(), testPasswords, verifier), authService)
+
+		identity := testIdentity()
+		identity.EmailVerified = false
+
+		mockIdentityRepo.On("GetByProviderSubject", mock.Anything, "test", "1234").Return(nil, ierr.ErrIdentityNotFound)
+		mockUserRepo.On("GetByEmail", mock.Anything, identity.Email).Return(&model.User{ID: uuid.New()}, "hash", nil)
+
+		_, err := oidcService.Login(context.Background(), identity, model.ClientInfo{})
+		assert.ErrorIs(t, err, ierr.ErrIdentityUnverified)
+		mockIdentityRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("no email", func(t *testing.T) {
+		setTestJWTConfig(t)
+		mockIdentityRepo := new(mocks.MockUserIdentityRepository)
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+		verifier := &recordingVerifier{}
+		authService := NewAuthService(mockUserRepo, mockRefreshTokenRepo, withSessions(), withoutMFA(), new(mocks.MockMFAChallengeRepository), NewRevocationService(new(mocks.MockRevokedTokenRepository)), testKeys, testThrottle())
+		oidcService := NewOIDCService(mockIdentityRepo, mockUserRepo, NewUserService(mockUserRepo, withTx(), testPasswords, verifier), authService)
+
+		identity := testIdentity()
+		identity.Email = ""
+
+		mockIdentityRepo.On("GetByProviderSubject", mock.Anything, "test", "1234").Return(nil, ierr.ErrIdentityNotFound)
+
+		_, err := oidcService.Login(context.Background(), identity, model.ClientInfo{})
+		assert.ErrorIs(t, err, ierr.ErrIdentityNoEmail)
+	})
+
+	t.Run("inactive user", func(t *testing.T) {
+		setTestJWTConfig(t)
+		mockIdentityRepo := new(mocks.MockUserIdentityRepository)
+		mockUserRepo := new(mocks.MockUserRepository)
+		mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+		verifier := &recordingVerifier{}
+		authService := NewAuthService(mockUserRepo, mockRefreshTokenRepo, withSessions(), withoutMFA(), new(mocks.MockMFAChallengeRepository), NewRevocationService(new(mocks.MockRevokedTokenRepository)), testKeys, testThrottle())
+		oidcService := NewOIDCService(mockIdentityRepo, mockUserRepo, NewUserService(mockUserRepo, withTx(), testPasswords, verifier), authService)
+
+		user := &model.User{ID: uuid.New()}
+
+		mockIdentityRepo.On("GetByProviderSubject", mock.Anything, "test", "1234").Return(&model.UserIdentity{UserID: user.ID}, nil)
+		mockUserRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+
+		_, err := oidcService.Login(context.Background(), testIdentity(), model.ClientInfo{})
+		assert.ErrorIs(t, err, ierr.ErrUserInactive)
+	})
+}
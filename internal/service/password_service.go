@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/faizalom/go-api/internal/config"
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/faizalom/go-api/internal/password"
+	"github.com/faizalom/go-api/internal/repository"
+	"github.com/faizalom/go-api/pkg/logger"
+	"github.com/faizalom/go-api/pkg/mailer"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type IPasswordService interface {
+	ForgotPassword(ctx context.Context, req *model.ForgotPasswordRequest) error
+	ResetPassword(ctx context.Context, req *model.ResetPasswordRequest) error
+	ChangePassword(ctx context.Context, userID uuid.UUID, req *model.ChangePasswordRequest) error
+}
+
+type PasswordService struct {
+	userRepo       repository.IUserRepository
+	resetTokenRepo repository.IPasswordResetTokenRepository
+	sessions       ISessionService
+	auth           IAuthService
+	mailer         mailer.Mailer
+	passwords      *password.Policy
+	// pending counts the reset links still being sent.
+	pending sync.WaitGroup
+}
+
+func NewPasswordService(userRepo repository.IUserRepository, resetTokenRepo repository.IPasswordResetTokenRepository, sessions ISessionService, auth IAuthService, m mailer.Mailer, passwords *password.Policy) IPasswordService {
+	return &PasswordService{userRepo: userRepo, resetTokenRepo: resetTokenRepo, sessions: sessions, auth: auth, mailer: m, passwords: passwords}
+}
+
+// ForgotPassword mails a reset link to the user with the given email. Unknown
+// and inactive accounts are silently ignored so callers cannot tell which
+// emails are registered. For the same reason it returns at once and sends
+// the link in the background, logging any failure, so that a registered
+// email takes no longer to answer than an unknown one.
+func (s *PasswordService) ForgotPassword(ctx context.Context, req *model.ForgotPasswordRequest) error {
+	// The request may be over before the link has been sent.
+	ctx = context.WithoutCancel(ctx)
+	email := req.Email
+	s.pending.Add(1)
+	go func() {
+		defer s.pending.Done()
+		if err := s.sendResetLink(ctx, email); err != nil {
+			logger.Error.Printf("Could not send password reset email: %v", err)
+		}
+	}()
+	return nil
+}
+
+// sendResetLink stores a reset token for the user with the given email and
+// mails them a link to it.
+func (s *PasswordService) sendResetLink(ctx context.Context, email string) error {
+	user, _, err := s.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, ierr.ErrUserNotFound) {
+			return nil
+		}
+		return err
+	}
+	if !user.IsActive {
+		return nil
+	}
+
+	token, tokenHash, err := generateOpaqueToken()
+	if err != nil {
+		return err
+	}
+	err = s.resetTokenRepo.Create(ctx, &model.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(config.App.PasswordReset.TokenTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	link, err := tokenLink(config.App.PasswordReset.URL, token)
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hello %s,\n\nUse the link below to choose a new password. It expires in %s and can only be used once.\n\n%s\n\nIf you did not ask to reset your password, you can ignore this email.\n",
+			user.Name, config.App.PasswordReset.TokenTTL, link),
+	})
+}
+
+// ResetPassword redeems a reset token and sets the user's new password. Every
+// other outstanding reset token and every session of the user is revoked.
+func (s *PasswordService) ResetPassword(ctx context.Context, req *model.ResetPasswordRequest) error {
+	// Check the new password first so a rejected one does not use up the token.
+	if err := s.passwords.Validate(req.Password); err != nil {
+		return err
+	}
+
+	stored, err := s.resetTokenRepo.GetByHash(ctx, hashToken(req.Token))
+	if err != nil {
+		if errors.Is(err, ierr.ErrResetTokenNotFound) {
+			return ierr.ErrInvalidResetToken
+		}
+		return err
+	}
+	if stored.UsedAt != nil || time.Now().After(stored.ExpiresAt) {
+		return ierr.ErrInvalidResetToken
+	}
+
+	// Claim the token before changing anything so it cannot be redeemed twice.
+	used, err := s.resetTokenRepo.MarkUsed(ctx, stored.ID)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ierr.ErrInvalidResetToken
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := s.userRepo.UpdatePassword(ctx, stored.UserID, string(hashedPassword)); err != nil {
+		return err
+	}
+
+	if err := s.resetTokenRepo.InvalidateForUser(ctx, stored.UserID); err != nil {
+		return err
+	}
+	// Whoever knew the old password must not stay signed in with it.
+	return s.sessions.RevokeAll(ctx, stored.UserID)
+}
+
+// ChangePassword replaces a signed-in user's password after checking their
+// current one. Every session of the user is revoked, so other devices have to
+// sign in again with the new password. A wrong current password counts as a
+// failed login, so that a stolen access token cannot be used to guess the
+// password faster than logging in allows.
+func (s *PasswordService) ChangePassword(ctx context.Context, userID uuid.UUID, req *model.ChangePasswordRequest) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.auth.AllowLogin(user, req.Client); err != nil {
+		return err
+	}
+	currentHash, err := s.userRepo.GetPasswordHash(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(currentHash), []byte(req.CurrentPassword)); err != nil {
+		if err := s.auth.FailLogin(ctx, user, req.Client); err != nil {
+			return err
+		}
+		return ierr.ErrIncorrectPassword
+	}
+	if err := s.auth.ResetLoginFailures(ctx, user); err != nil {
+		return err
+	}
+	if err := s.passwords.Validate(req.NewPassword); err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := s.userRepo.UpdatePassword(ctx, userID, string(hashedPassword)); err != nil {
+		return err
+	}
+
+	// A reset link mailed before the change must not undo it.
+	if err := s.resetTokenRepo.InvalidateForUser(ctx, userID); err != nil {
+		return err
+	}
+	return s.sessions.RevokeAll(ctx, userID)
+}
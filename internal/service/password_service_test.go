@@ -0,0 +1,299 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/faizalom/go-api/internal/config"
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/faizalom/go-api/internal/repository/mocks"
+	"github.com/faizalom/go-api/pkg/mailer"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type recordingMailer struct {
+	sent []mailer.Message
+}
+
+func (m *recordingMailer) Send(ctx context.Context, msg mailer.Message) error {
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func TestPasswordService_ForgotPassword(t *testing.T) {
+	resetConfig := config.App.PasswordReset
+	t.Cleanup(func() { config.App.PasswordReset = resetConfig })
+	config.App.PasswordReset.TokenTTL = time.Hour
+	config.App.PasswordReset.URL = "https://app.example.com/reset-password"
+
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockResetTokenRepo := new(mocks.MockPasswordResetTokenRepository)
+	mockSessionRepo := new(mocks.MockSessionRepository)
+	mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+	sender := &recordingMailer{}
+	passwordService := NewPasswordService(mockUserRepo, mockResetTokenRepo, NewSessionService(mockSessionRepo, mockRefreshTokenRepo), NewAuthService(mockUserRepo, mockRefreshTokenRepo, mockSessionRepo, withoutMFA(), new(mocks.MockMFAChallengeRepository), NewRevocationService(new(mocks.MockRevokedTokenRepository)), testKeys, testThrottle()), sender, testPasswords)
+
+	user := &model.User{ID: uuid.New(), Name: "test user", Email: "test@example.com", IsActive: true}
+
+	var stored *model.PasswordResetToken
+	mockUserRepo.On("GetByEmail", mock.Anything, user.Email).Return(user, "hash", nil)
+	mockResetTokenRepo.On("Create", mock.Anything, mock.AnythingOfType("*model.PasswordResetToken")).Run(func(args mock.Arguments) {
+		stored = args.Get(1).(*model.PasswordResetToken)
+	}).Return(nil)
+
+	err := passwordService.ForgotPassword(context.Background(), &model.ForgotPasswordRequest{Email: user.Email})
+	passwordService.(*PasswordService).pending.Wait()
+
+	require.NoError(t, err)
+	require.Len(t, sender.sent, 1)
+	assert.Equal(t, user.Email, sender.sent[0].To)
+
+	// The mailed link carries the token whose hash was stored.
+	var link string
+	for _, line := range strings.Split(sender.sent[0].Body, "\n") {
+		if strings.HasPrefix(line, "https://") {
+			link = line
+		}
+	}
+	u, err := url.Parse(link)
+	require.NoError(t, err)
+	assert.Equal(t, "/reset-password", u.Path)
+	assert.Equal(t, stored.TokenHash, hashToken(u.Query().Get("token")))
+	assert.Equal(t, user.ID, stored.UserID)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), stored.ExpiresAt, time.Minute)
+}
+
+func TestPasswordService_ForgotPassword_UnknownOrInactive(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockResetTokenRepo := new(mocks.MockPasswordResetTokenRepository)
+	mockSessionRepo := new(mocks.MockSessionRepository)
+	mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+	sender := &recordingMailer{}
+	passwordService := NewPasswordService(mockUserRepo, mockResetTokenRepo, NewSessionService(mockSessionRepo, mockRefreshTokenRepo), NewAuthService(mockUserRepo, mockRefreshTokenRepo, mockSessionRepo, withoutMFA(), new(mocks.MockMFAChallengeRepository), NewRevocationService(new(mocks.MockRevokedTokenRepository)), testKeys, testThrottle()), sender, testPasswords)
+
+	mockUserRepo.On("GetByEmail", mock.Anything, "nobody@example.com").Return((*model.User)(nil), "", ierr.ErrUserNotFound)
+	mockUserRepo.On("GetByEmail", mock.Anything, "inactive@example.com").Return(&model.User{ID: uuid.New(), Email: "inactive@example.com"}, "hash", nil)
+
+	assert.NoError(t, passwordService.ForgotPassword(context.Background(), &model.ForgotPasswordRequest{Email: "nobody@example.com"}))
+	assert.NoError(t, passwordService.ForgotPassword(context.Background(), &model.ForgotPasswordRequest{Email: "inactive@example.com"}))
+	passwordService.(*PasswordService).pending.Wait()
+	assert.Empty(t, sender.sent)
+	mockResetTokenRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestPasswordService_ForgotPassword_ReturnsBeforeSending(t *testing.T) {
+	resetConfig := config.App.PasswordReset
+	t.Cleanup(func() { config.App.PasswordReset = resetConfig })
+	config.App.PasswordReset.URL = "https://app.example.com/reset-password"
+
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockResetTokenRepo := new(mocks.MockPasswordResetTokenRepository)
+	mockSessionRepo := new(mocks.MockSessionRepository)
+	mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+	sender := &recordingMailer{}
+	passwordService := NewPasswordService(mockUserRepo, mockResetTokenRepo, NewSessionService(mockSessionRepo, mockRefreshTokenRepo), NewAuthService(mockUserRepo, mockRefreshTokenRepo, mockSessionRepo, withoutMFA(), new(mocks.MockMFAChallengeRepository), NewRevocationService(new(mocks.MockRevokedTokenRepository)), testKeys, testThrottle()), sender, testPasswords)
+
+	user := &model.User{ID: uuid.New(), Name: "test user", Email: "test@example.com", IsActive: true}
+	lookedUp := make(chan time.Time)
+	mockUserRepo.On("GetByEmail", mock.Anything, user.Email).WaitUntil(lookedUp).Return(user, "hash", nil)
+	mockResetTokenRepo.On("Create", mock.MatchedBy(func(ctx context.Context) bool { return ctx.Err() == nil }), mock.AnythingOfType("*model.PasswordResetToken")).Return(nil)
+
+	// The caller's context ends with its request, before the link is sent.
+	ctx, cancel := context.WithCancel(context.Background())
+	err := passwordService.ForgotPassword(ctx, &model.ForgotPasswordRequest{Email: user.Email})
+	cancel()
+
+	require.NoError(t, err)
+	assert.Empty(t, sender.sent)
+	close(lookedUp)
+	passwordService.(*PasswordService).pending.Wait()
+	require.Len(t, sender.sent, 1)
+	assert.Equal(t, user.Email, sender.sent[0].To)
+}
+
+func TestPasswordService_ResetPassword(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockResetTokenRepo := new(mocks.MockPasswordResetTokenRepository)
+	mockSessionRepo := new(mocks.MockSessionRepository)
+	mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+	sender := &recordingMailer{}
+	passwordService := NewPasswordService(mockUserRepo, mockResetTokenRepo, NewSessionService(mockSessionRepo, mockRefreshTokenRepo), NewAuthService(mockUserRepo, mockRefreshTokenRepo, mockSessionRepo, withoutMFA(), new(mocks.MockMFAChallengeRepository), NewRevocationService(new(mocks.MockRevokedTokenRepository)), testKeys, testThrottle()), sender, testPasswords)
+
+	stored := &model.PasswordResetToken{ID: uuid.New(), UserID: uuid.New(), ExpiresAt: time.Now().Add(time.Hour)}
+
+	mockResetTokenRepo.On("GetByHash", mock.Anything, hashToken("reset-token")).Return(stored, nil)
+	mockResetTokenRepo.On("MarkUsed", mock.Anything, stored.ID).Return(true, nil)
+	mockUserRepo.On("UpdatePassword", mock.Anything, stored.UserID, mock.MatchedBy(func(hash string) bool {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte("new-password")) == nil
+	})).Return(nil)
+	mockResetTokenRepo.On("InvalidateForUser", mock.Anything, stored.UserID).Return(nil)
+	mockSessionRepo.On("RevokeAllForUser", mock.Anything, stored.UserID).Return([]uuid.UUID{uuid.New()}, nil)
+	mockRefreshTokenRepo.On("RevokeAllForUser", mock.Anything, stored.UserID).Return(nil)
+
+	err := passwordService.ResetPassword(context.Background(), &model.ResetPasswordRequest{Token: "reset-token", Password: "new-password"})
+
+	assert.NoError(t, err)
+	mockUserRepo.AssertExpectations(t)
+	mockResetTokenRepo.AssertExpectations(t)
+	mockSessionRepo.AssertExpectations(t)
+	mockRefreshTokenRepo.AssertExpectations(t)
+}
+
+func TestPasswordService_ResetPassword_Rejected(t *testing.T) {
+	usedAt := time.Now()
+
+	tests := []struct {
+		name      string
+		stored    *model.PasswordResetToken
+		err       error
+		claimable bool
+	}{
+		{name: "unknown token", err: ierr.ErrResetTokenNotFound},
+		{name: "expired token", stored: &model.PasswordResetToken{ID: uuid.New(), ExpiresAt: time.Now().Add(-time.Minute)}},
+		{name: "used token", stored: &model.PasswordResetToken{ID: uuid.New(), ExpiresAt: time.Now().Add(time.Hour), UsedAt: &usedAt}},
+		{name: "redeemed concurrently", stored: &model.PasswordResetToken{ID: uuid.New(), ExpiresAt: time.Now().Add(time.Hour)}, claimable: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockUserRepo := new(mocks.MockUserRepository)
+			mockResetTokenRepo := new(mocks.MockPasswordResetTokenRepository)
+			mockSessionRepo := new(mocks.MockSessionRepository)
+			mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+			sender := &recordingMailer{}
+			passwordService := NewPasswordService(mockUserRepo, mockResetTokenRepo, NewSessionService(mockSessionRepo, mockRefreshTokenRepo), NewAuthService(mockUserRepo, mockRefreshTokenRepo, mockSessionRepo, withoutMFA(), new(mocks.MockMFAChallengeRepository), NewRevocationService(new(mocks.MockRevokedTokenRepository)), testKeys, testThrottle()), sender, testPasswords)
+
+			mockResetTokenRepo.On("GetByHash", mock.Anything, mock.Anything).Return(tt.stored, tt.err)
+			if tt.claimable {
+				mockResetTokenRepo.On("MarkUsed", mock.Anything, tt.stored.ID).Return(false, nil)
+			}
+
+			err := passwordService.ResetPassword(context.Background(), &model.ResetPasswordRequest{Token: "reset-token", Password: "new-password"})
+
+			assert.ErrorIs(t, err, ierr.ErrInvalidResetToken)
+			mockUserRepo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestPasswordService_ResetPassword_WeakPassword(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockResetTokenRepo := new(mocks.MockPasswordResetTokenRepository)
+	mockSessionRepo := new(mocks.MockSessionRepository)
+	mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+	sender := &recordingMailer{}
+	passwordService := NewPasswordService(mockUserRepo, mockResetTokenRepo, NewSessionService(mockSessionRepo, mockRefreshTokenRepo), NewAuthService(mockUserRepo, mockRefreshTokenRepo, mockSessionRepo, withoutMFA(), new(mocks.MockMFAChallengeRepository), NewRevocationService(new(mocks.MockRevokedTokenRepository)), testKeys, testThrottle()), sender, testPasswords)
+
+	err := passwordService.ResetPassword(context.Background(), &model.ResetPasswordRequest{Token: "reset-token", Password: "short"})
+
+	assert.ErrorIs(t, err, ierr.ErrWeakPassword)
+	// The token is left for another attempt.
+	mockResetTokenRepo.AssertNotCalled(t, "MarkUsed", mock.Anything, mock.Anything)
+}
+
+func TestPasswordService_ChangePassword(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockResetTokenRepo := new(mocks.MockPasswordResetTokenRepository)
+	mockSessionRepo := new(mocks.MockSessionRepository)
+	mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+	sender := &recordingMailer{}
+	passwordService := NewPasswordService(mockUserRepo, mockResetTokenRepo, NewSessionService(mockSessionRepo, mockRefreshTokenRepo), NewAuthService(mockUserRepo, mockRefreshTokenRepo, mockSessionRepo, withoutMFA(), new(mocks.MockMFAChallengeRepository), NewRevocationService(new(mocks.MockRevokedTokenRepository)), testKeys, testThrottle()), sender, testPasswords)
+
+	userID := uuid.New()
+	currentHash, err := bcrypt.GenerateFromPassword([]byte("old-password"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	// The right current password clears earlier failures.
+	mockUserRepo.On("GetByID", mock.Anything, userID).Return(&model.User{ID: userID, Email: "test@example.com", FailedLoginCount: 2}, nil)
+	mockUserRepo.On("ResetLoginFailures", mock.Anything, userID).Return(nil)
+	mockUserRepo.On("GetPasswordHash", mock.Anything, userID).Return(string(currentHash), nil)
+	mockUserRepo.On("UpdatePassword", mock.Anything, userID, mock.MatchedBy(func(hash string) bool {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte("new-password")) == nil
+	})).Return(nil)
+	mockResetTokenRepo.On("InvalidateForUser", mock.Anything, userID).Return(nil)
+	mockSessionRepo.On("RevokeAllForUser", mock.Anything, userID).Return([]uuid.UUID{uuid.New()}, nil)
+	mockRefreshTokenRepo.On("RevokeAllForUser", mock.Anything, userID).Return(nil)
+
+	err = passwordService.ChangePassword(context.Background(), userID, &model.ChangePasswordRequest{CurrentPassword: "old-password", NewPassword: "new-password"})
+
+	assert.NoError(t, err)
+	mockUserRepo.AssertExpectations(t)
+	mockResetTokenRepo.AssertExpectations(t)
+	mockSessionRepo.AssertExpectations(t)
+	mockRefreshTokenRepo.AssertExpectations(t)
+}
+
+func TestPasswordService_ChangePassword_Throttled(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockResetTokenRepo := new(mocks.MockPasswordResetTokenRepository)
+	mockSessionRepo := new(mocks.MockSessionRepository)
+	mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+	throttle := NewLoginThrottle(config.LoginProtectionConfig{EmailFreeAttempts: 2, IPFreeAttempts: 100, BaseBackoff: time.Minute, MaxBackoff: time.Hour, ResetAfter: time.Hour})
+	authService := NewAuthService(mockUserRepo, mockRefreshTokenRepo, mockSessionRepo, withoutMFA(), new(mocks.MockMFAChallengeRepository), NewRevocationService(new(mocks.MockRevokedTokenRepository)), testKeys, throttle)
+	passwordService := NewPasswordService(mockUserRepo, mockResetTokenRepo, NewSessionService(mockSessionRepo, mockRefreshTokenRepo), authService, &recordingMailer{}, testPasswords)
+
+	userID := uuid.New()
+	currentHash, err := bcrypt.GenerateFromPassword([]byte("old-password"), bcrypt.MinCost)
+	require.NoError(t, err)
+	mockUserRepo.On("GetByID", mock.Anything, userID).Return(&model.User{ID: userID, Email: "test@example.com"}, nil)
+	mockUserRepo.On("GetPasswordHash", mock.Anything, userID).Return(string(currentHash), nil)
+	mockUserRepo.On("RecordLoginFailure", mock.Anything, userID).Return(1, nil)
+
+	for i := 0; i < 3; i++ {
+		err := passwordService.ChangePassword(context.Background(), userID, &model.ChangePasswordRequest{CurrentPassword: "wrong-password", NewPassword: "new-password"})
+		assert.ErrorIs(t, err, ierr.ErrIncorrectPassword)
+	}
+
+	// Even the right password is refused until the backoff has passed.
+	err = passwordService.ChangePassword(context.Background(), userID, &model.ChangePasswordRequest{CurrentPassword: "old-password", NewPassword: "new-password"})
+	assert.ErrorIs(t, err, ierr.ErrTooManyLoginAttempts)
+	mockUserRepo.AssertNumberOfCalls(t, "RecordLoginFailure", 3)
+	mockUserRepo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPasswordService_ChangePassword_Rejected(t *testing.T) {
+	currentHash, err := bcrypt.GenerateFromPassword([]byte("old-password"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		req    *model.ChangePasswordRequest
+		getErr error
+		want   error
+	}{
+		{name: "unknown user", req: &model.ChangePasswordRequest{CurrentPassword: "old-password", NewPassword: "new-password"}, getErr: ierr.ErrUserNotFound, want: ierr.ErrUserNotFound},
+		{name: "wrong current password", req: &model.ChangePasswordRequest{CurrentPassword: "wrong-password", NewPassword: "new-password"}, want: ierr.ErrIncorrectPassword},
+		{name: "weak new password", req: &model.ChangePasswordRequest{CurrentPassword: "old-password", NewPassword: "short"}, want: ierr.ErrWeakPassword},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockUserRepo := new(mocks.MockUserRepository)
+			mockResetTokenRepo := new(mocks.MockPasswordResetTokenRepository)
+			mockSessionRepo := new(mocks.MockSessionRepository)
+			mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+			sender := &recordingMailer{}
+			passwordService := NewPasswordService(mockUserRepo, mockResetTokenRepo, NewSessionService(mockSessionRepo, mockRefreshTokenRepo), NewAuthService(mockUserRepo, mockRefreshTokenRepo, mockSessionRepo, withoutMFA(), new(mocks.MockMFAChallengeRepository), NewRevocationService(new(mocks.MockRevokedTokenRepository)), testKeys, testThrottle()), sender, testPasswords)
+
+			userID := uuid.New()
+			mockUserRepo.On("GetByID", mock.Anything, userID).Return(&model.User{ID: userID, Email: "test@example.com"}, tt.getErr)
+			mockUserRepo.On("GetPasswordHash", mock.Anything, userID).Return(string(currentHash), nil)
+			mockUserRepo.On("RecordLoginFailure", mock.Anything, userID).Return(1, nil)
+
+			err := passwordService.ChangePassword(context.Background(), userID, tt.req)
+
+			assert.ErrorIs(t, err, tt.want)
+			mockUserRepo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+			mockSessionRepo.AssertNotCalled(t, "RevokeAllForUser", mock.Anything, mock.Anything)
+			mockRefreshTokenRepo.AssertNotCalled(t, "RevokeAllForUser", mock.Anything, mock.Anything)
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/faizalom/go-api/internal/config"
+	"github.com/faizalom/go-api/internal/repository"
+	"github.com/faizalom/go-api/pkg/cache"
+)
+
+type IRevocationService interface {
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+// RevocationService keeps track of revoked access tokens. Lookups are served
+// from an in-memory cache in front of the revoked_tokens table so that
+// AuthMiddleware does not hit the database on every request.
+type RevocationService struct {
+	repo  repository.IRevokedTokenRepository
+	cache *cache.TTL[string, bool]
+}
+
+func NewRevocationService(repo repository.IRevokedTokenRepository) IRevocationService {
+	return &RevocationService{repo: repo, cache: cache.NewTTL[string, bool]()}
+}
+
+// Revoke records a token ID as revoked until expiresAt.
+func (s *RevocationService) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	if err := s.repo.Revoke(ctx, jti, expiresAt); err != nil {
+		return err
+	}
+	s.cache.Set(jti, true, time.Until(expiresAt))
+	return nil
+}
+
+// IsRevoked reports whether a token ID has been revoked. Negative answers are
+// only cached briefly so revocations made by other instances are picked up.
+func (s *RevocationService) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	if revoked, ok := s.cache.Get(jti); ok {
+		return revoked, nil
+	}
+
+	revoked, err := s.repo.IsRevoked(ctx, jti)
+	if err != nil {
+		return false, err
+	}
+
+	if revoked {
+		s.cache.Set(jti, true, config.App.JWT.AccessTokenTTL)
+	} else {
+		s.cache.Set(jti, false, config.App.JWT.RevocationCacheTTL)
+	}
+	return revoked, nil
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/faizalom/go-api/internal/config"
+	"github.com/faizalom/go-api/internal/repository/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRevocationService_IsRevoked_CachesLookups(t *testing.T) {
+	jwtConfig := config.App.JWT
+	t.Cleanup(func() { config.App.JWT = jwtConfig })
+	config.App.JWT.RevocationCacheTTL = time.Minute
+	mockRevokedTokenRepo := new(mocks.MockRevokedTokenRepository)
+	revocationService := NewRevocationService(mockRevokedTokenRepo)
+
+	mockRevokedTokenRepo.On("IsRevoked", mock.Anything, "jti").Return(false, nil).Once()
+
+	for i := 0; i < 3; i++ {
+		revoked, err := revocationService.IsRevoked(context.Background(), "jti")
+		assert.NoError(t, err)
+		assert.False(t, revoked)
+	}
+	mockRevokedTokenRepo.AssertExpectations(t)
+}
+
+func TestRevocationService_Revoke_UpdatesCache(t *testing.T) {
+	jwtConfig := config.App.JWT
+	t.Cleanup(func() { config.App.JWT = jwtConfig })
+	config.App.JWT.RevocationCacheTTL = time.Minute
+	mockRevokedTokenRepo := new(mocks.MockRevokedTokenRepository)
+	revocationService := NewRevocationService(mockRevokedTokenRepo)
+
+	expiresAt := time.Now().Add(time.Minute)
+	mockRevokedTokenRepo.On("IsRevoked", mock.Anything, "jti").Return(false, nil).Once()
+	mockRevokedTokenRepo.On("Revoke", mock.Anything, "jti", expiresAt).Return(nil)
+
+	revoked, err := revocationService.IsRevoked(context.Background(), "jti")
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+
+	err = revocationService.Revoke(context.Background(), "jti", expiresAt)
+	assert.NoError(t, err)
+
+	revoked, err = revocationService.IsRevoked(context.Background(), "jti")
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+	mockRevokedTokenRepo.AssertExpectations(t)
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+
+	"github.com/faizalom/go-api/internal/config"
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/faizalom/go-api/internal/repository"
+	"github.com/faizalom/go-api/pkg/cache"
+	"github.com/faizalom/go-api/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+type ISessionService interface {
+	List(ctx context.Context, userID uuid.UUID, currentID string) ([]*model.Session, error)
+	Revoke(ctx context.Context, userID, sessionID uuid.UUID) error
+	RevokeAll(ctx context.Context, userID uuid.UUID) error
+	IsRevoked(ctx context.Context, sessionID string) (bool, error)
+}
+
+// SessionService lets users see where they are signed in and sign out
+// remotely. Like RevocationService, it caches revocation lookups so that
+// AuthMiddleware does not hit the database on every request.
+type SessionService struct {
+	sessionRepo      repository.ISessionRepository
+	refreshTokenRepo repository.IRefreshTokenRepository
+	cache            *cache.TTL[string, bool]
+}
+
+func NewSessionService(sessionRepo repository.ISessionRepository, refreshTokenRepo repository.IRefreshTokenRepository) ISessionService {
+	return &SessionService{sessionRepo: sessionRepo, refreshTokenRepo: refreshTokenRepo, cache: cache.NewTTL[string, bool]()}
+}
+
+// List returns the user's active sessions, marking the one with currentID.
+func (s *SessionService) List(ctx context.Context, userID uuid.UUID, currentID string) ([]*model.Session, error) {
+	sessions, err := s.sessionRepo.ListActive(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		session.Current = session.ID.String() == currentID
+	}
+	return sessions, nil
+}
+
+// Revoke signs the user out of one of their sessions. Its refresh tokens stop
+// working at once, and so do its access tokens, on this instance at once and
+// on others within config.App.JWT.RevocationCacheTTL.
+func (s *SessionService) Revoke(ctx context.Context, userID, sessionID uuid.UUID) error {
+	revoked, err := s.sessionRepo.Revoke(ctx, sessionID, userID)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ierr.ErrSessionNotFound
+	}
+	if err := s.refreshTokenRepo.RevokeFamily(ctx, sessionID); err != nil {
+		return err
+	}
+	s.cache.Set(sessionID.String(), true, config.App.JWT.AccessTokenTTL)
+
+	logger.Info.Printf("Session %s of user %s revoked", sessionID, userID)
+	return nil
+}
+
+// RevokeAll signs the user out everywhere, like Revoke does for one session.
+// Refresh tokens issued outside a session are revoked as well.
+func (s *SessionService) RevokeAll(ctx context.Context, userID uuid.UUID) error {
+	sessionIDs, err := s.sessionRepo.RevokeAllForUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.refreshTokenRepo.RevokeAllForUser(ctx, userID); err != nil {
+		return err
+	}
+	for _, id := range sessionIDs {
+		s.cache.Set(id.String(), true, config.App.JWT.AccessTokenTTL)
+	}
+
+	logger.Info.Printf("All %d sessions of user %s revoked", len(sessionIDs), userID)
+	return nil
+}
+
+// IsRevoked reports whether the session an access token was issued for has
+// been revoked. Negative answers are only cached briefly so revocations made
+// by other instances are picked up.
+func (s *SessionService) IsRevoked(ctx context.Context, sessionID string) (bool, error) {
+	if revoked, ok := s.cache.Get(sessionID); ok {
+		return revoked, nil
+	}
+
+	id, err := uuid.Parse(sessionID)
+	if err != nil {
+		// Not a session we issued, so there is nothing to revoke.
+		return false, nil
+	}
+	revoked, err := s.sessionRepo.IsRevoked(ctx, id)
+	if err != nil {
+		return false, err
+	}
+
+	ttl := config.App.JWT.RevocationCacheTTL
+	if revoked {
+		ttl = config.App.JWT.AccessTokenTTL
+	}
+	s.cache.Set(sessionID, revoked, ttl)
+	return revoked, nil
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/faizalom/go-api/internal/config"
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/faizalom/go-api/internal/repository/mocks"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSessionService_List_MarksCurrent(t *testing.T) {
+	mockSessionRepo := new(mocks.MockSessionRepository)
+	sessionService := NewSessionService(mockSessionRepo, new(mocks.MockRefreshTokenRepository))
+
+	userID := uuid.New()
+	current := &model.Session{ID: uuid.New(), UserID: userID}
+	other := &model.Session{ID: uuid.New(), UserID: userID}
+	mockSessionRepo.On("ListActive", mock.Anything, userID).Return([]*model.Session{current, other}, nil)
+
+	sessions, err := sessionService.List(context.Background(), userID, current.ID.String())
+
+	assert.NoError(t, err)
+	assert.Len(t, sessions, 2)
+	assert.True(t, sessions[0].Current)
+	assert.False(t, sessions[1].Current)
+}
+
+func TestSessionService_Revoke(t *testing.T) {
+	setTestJWTConfig(t)
+	config.App.JWT.RevocationCacheTTL = time.Minute
+	mockSessionRepo := new(mocks.MockSessionRepository)
+	mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+	sessionService := NewSessionService(mockSessionRepo, mockRefreshTokenRepo)
+
+	userID, sessionID := uuid.New(), uuid.New()
+	mockSessionRepo.On("IsRevoked", mock.Anything, sessionID).Return(false, nil).Once()
+	mockSessionRepo.On("Revoke", mock.Anything, sessionID, userID).Return(true, nil)
+	mockRefreshTokenRepo.On("RevokeFamily", mock.Anything, sessionID).Return(nil)
+
+	revoked, err := sessionService.IsRevoked(context.Background(), sessionID.String())
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+
+	assert.NoError(t, sessionService.Revoke(context.Background(), userID, sessionID))
+
+	// The cached answer is replaced without another lookup.
+	revoked, err = sessionService.IsRevoked(context.Background(), sessionID.String())
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+	mockSessionRepo.AssertExpectations(t)
+	mockRefreshTokenRepo.AssertExpectations(t)
+}
+
+func TestSessionService_RevokeAll(t *testing.T) {
+	setTestJWTConfig(t)
+	config.App.JWT.RevocationCacheTTL = time.Minute
+	mockSessionRepo := new(mocks.MockSessionRepository)
+	mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+	sessionService := NewSessionService(mockSessionRepo, mockRefreshTokenRepo)
+
+	userID, first, second := uuid.New(), uuid.New(), uuid.New()
+	mockSessionRepo.On("RevokeAllForUser", mock.Anything, userID).Return([]uuid.UUID{first, second}, nil)
+	mockRefreshTokenRepo.On("RevokeAllForUser", mock.Anything, userID).Return(nil)
+
+	assert.NoError(t, sessionService.RevokeAll(context.Background(), userID))
+
+	// Both sessions are known to be revoked without a lookup.
+	for _, id := range []uuid.UUID{first, second} {
+		revoked, err := sessionService.IsRevoked(context.Background(), id.String())
+		assert.NoError(t, err)
+		assert.True(t, revoked)
+	}
+	mockSessionRepo.AssertNotCalled(t, "IsRevoked", mock.Anything, mock.Anything)
+	mockSessionRepo.AssertExpectations(t)
+	mockRefreshTokenRepo.AssertExpectations(t)
+}
+
+func TestSessionService_Revoke_NotFound(t *testing.T) {
+	mockSessionRepo := new(mocks.MockSessionRepository)
+	mockRefreshTokenRepo := new(mocks.MockRefreshTokenRepository)
+	sessionService := NewSessionService(mockSessionRepo, mockRefreshTokenRepo)
+
+	// Sessions of other users cannot be revoked.
+	userID, sessionID := uuid.New(), uuid.New()
+	mockSessionRepo.On("Revoke", mock.Anything, sessionID, userID).Return(false, nil)
+
+	err := sessionService.Revoke(context.Background(), userID, sessionID)
+
+	assert.ErrorIs(t, err, ierr.ErrSessionNotFound)
+	mockRefreshTokenRepo.AssertNotCalled(t, "RevokeFamily", mock.Anything, mock.Anything)
+}
+
+func TestSessionService_IsRevoked_UnknownID(t *testing.T) {
+	mockSessionRepo := new(mocks.MockSessionRepository)
+	sessionService := NewSessionService(mockSessionRepo, new(mocks.MockRefreshTokenRepository))
+
+	revoked, err := sessionService.IsRevoked(context.Background(), "not-a-uuid")
+
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+	mockSessionRepo.AssertNotCalled(t, "IsRevoked", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/url"
+	"time"
+
+	"github.com/faizalom/go-api/internal/config"
+	"github.com/faizalom/go-api/internal/jwtkeys"
+	"github.com/faizalom/go-api/internal/model"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// signAccessToken creates a short-lived JWT carrying the user's identity and
+// the session it belongs to. Every token gets a unique ID so it can be revoked.
+func signAccessToken(keys *jwtkeys.KeySet, user *model.User, sessionID uuid.UUID) (string, error) {
+	claims := accessClaims(user, config.App.JWT.AccessTokenTTL)
+	claims.SessionID = sessionID.String()
+	return keys.Sign(claims)
+}
+
+// accessClaims returns the claims of an access token for user, valid for ttl.
+func accessClaims(user *model.User, ttl time.Duration) *model.CustomClaims {
+	now := time.Now()
+	return &model.CustomClaims{
+		Name:  user.Name,
+		Email: user.Email,
+		Roles: user.Roles,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Issuer:    config.App.JWT.Issuer,
+			Audience:  config.App.JWT.Audience,
+			Subject:   user.ID.String(),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+}
+
+// generateOpaqueToken returns a random URL-safe token and the hash to store for it.
+func generateOpaqueToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, hashToken(token), nil
+}
+
+// hashToken returns the hex-encoded SHA-256 of an opaque token.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// tokenLink adds an opaque token to a link as the "token" query parameter.
+func tokenLink(base, token string) (string, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
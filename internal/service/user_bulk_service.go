@@ -0,0 +1,468 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/faizalom/go-api/internal/config"
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/faizalom/go-api/internal/repository"
+	"github.com/faizalom/go-api/pkg/logger"
+)
+
+type IUserBulkService interface {
+	ImportUsers(ctx context.Context, req *model.UserImportRequest) (*model.UserImportReport, error)
+	ExportUsers(ctx context.Context, format string, w io.Writer) error
+	RunBatch(ctx context.Context, req *model.UserBatchRequest) (*model.UserBatchResponse, error)
+}
+
+// UserBulkService imports and exports users in bulk, one at a time, so that
+// neither has to hold all of them in memory, and runs batches of changes to
+// users.
+type UserBulkService struct {
+	users IUserService
+	tx    repository.ITxManager
+	cfg   config.UserImportConfig
+}
+
+func NewUserBulkService(users IUserService, tx repository.ITxManager, cfg config.UserImportConfig) IUserBulkService {
+	return &UserBulkService{users: users, tx: tx, cfg: cfg}
+}
+
+// errRollback makes WithinTx roll back an atomic import with failed rows.
+var errRollback = errors.New("import rolled back")
+
+// ImportUsers creates the users of req.Body through CreateUser, reporting
+// on every row. A row is skipped if its email is taken and fails if it is
+// invalid. A body that cannot be read as req.Format fails with
+// ierr.ErrInvalidImport.
+func (s *UserBulkService) ImportUsers(ctx context.Context, req *model.UserImportRequest) (*model.UserImportReport, error) {
+	rows, err := newUserRowReader(req.Format, req.Body)
+	if err != nil {
+		return nil, err
+	}
+	report := &model.UserImportReport{Atomic: req.Atomic, Rows: []model.UserImportRow{}}
+
+	if !req.Atomic {
+		if err := s.importRows(ctx, rows, report); err != nil {
+			return nil, err
+		}
+		report.Committed = true
+		return report, nil
+	}
+
+	// Serializable like CreateUser, whose own transaction this one takes
+	// the place of. The body cannot be read twice, so there are no retries.
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.importRows(ctx, rows, report); err != nil {
+			return err
+		}
+		if report.Failed > 0 {
+			return errRollback
+		}
+		return nil
+	}, repository.Isolation(sql.LevelSerializable), repository.MaxRetries(0))
+	if errors.Is(err, errRollback) {
+		for i := range report.Rows {
+			if report.Rows[i].Status == model.ImportRowCreated {
+				report.Rows[i].Status = model.ImportRowRolledBack
+				report.Rows[i].ID = nil
+			}
+		}
+		report.Created = 0
+		return report, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	report.Committed = true
+	return report, nil
+}
+
+// importRows creates the user of each row, stopping after cfg.MaxRows.
+// Unexpected errors end an atomic import, which would roll back anyway, but
+// only fail the row otherwise.
+func (s *UserBulkService) importRows(ctx context.Context, rows userRowReader, report *model.UserImportReport) error {
+	for n := 0; ; n++ {
+		line, req, err := rows.next()
+		if err == io.EOF {
+			return nil
+		}
+		if n == s.cfg.MaxRows {
+			report.Rows = append(report.Rows, model.UserImportRow{Line: line, Status: model.ImportRowFailed, Error: fmt.Sprintf("imports are limited to %d rows", s.cfg.MaxRows)})
+			report.Failed++
+			return nil
+		}
+		if err != nil && !errors.Is(err, ierr.ErrInvalidUser) {
+			return err
+		}
+
+		row := model.UserImportRow{Line: line}
+		if req != nil {
+			row.Email = req.Email
+		}
+		var user *model.User
+		if err == nil {
+			user, err = s.users.CreateUser(ctx, req)
+		}
+
+		switch {
+		case err == nil:
+			row.Status, row.ID = model.ImportRowCreated, &user.ID
+			report.Created++
+		case errors.Is(err, ierr.ErrUserAlreadyExists):
+			row.Status, row.Error = model.ImportRowSkipped, err.Error()
+			report.Skipped++
+		case errors.Is(err, ierr.ErrInvalidUser), errors.Is(err, ierr.ErrInvalidRole), errors.Is(err, ierr.ErrWeakPassword):
+			row.Status, row.Error = model.ImportRowFailed, err.Error()
+			report.Failed++
+		default:
+			if report.Atomic {
+				return err
+			}
+			logger.Error.Printf("Could not import user on line %d: %v", line, err)
+			row.Status, row.Error = model.ImportRowFailed, "internal error"
+			report.Failed++
+		}
+		report.Rows = append(report.Rows, row)
+	}
+}
+
+// ExportUsers writes every user to w in format, oldest first. Users are
+// read a page at a time, so changes made meanwhile may or may not show.
+func (s *UserBulkService) ExportUsers(ctx context.Context, format string, w io.Writer) error {
+	out, err := newUserRowWriter(format, w)
+	if err != nil {
+		return err
+	}
+
+	req := &model.UserListRequest{Sort: model.UserSortCreatedAt, Limit: model.MaxUserPageSize}
+	for {
+		page, err := s.users.ListUsers(ctx, req)
+		if err != nil {
+			return err
+		}
+		for _, user := range page.Users {
+			if err := out.write(user); err != nil {
+				return err
+			}
+		}
+		if err := out.flush(); err != nil {
+			return err
+		}
+		if page.NextCursor == "" {
+			return nil
+		}
+		req.Cursor = page.NextCursor
+	}
+}
+
+// RunBatch runs the operations of req in order through the user service, in
+// one transaction. It stops at the first operation that fails and rolls
+// back the ones before it. A batch that is empty or too large fails with
+// ierr.ErrInvalidBatch.
+func (s *UserBulkService) RunBatch(ctx context.Context, req *model.UserBatchRequest) (*model.UserBatchResponse, error) {
+	if n := len(req.Operations); n == 0 || n > model.MaxUserBatchSize {
+		return nil, fmt.Errorf("%w: a batch holds 1 to %d operations", ierr.ErrInvalidBatch, model.MaxUserBatchSize)
+	}
+	resp := &model.UserBatchResponse{Results: make([]model.UserBatchResult, len(req.Operations))}
+
+	// A retried transaction starts over with fresh results.
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		for i, op := range req.Operations {
+			resp.Results[i] = model.UserBatchResult{Index: i, Op: op.Op, Status: model.BatchOpNotRun}
+		}
+		for i := range req.Operations {
+			result := &resp.Results[i]
+			result.User, result.Err = s.runOperation(ctx, &req.Operations[i])
+			if result.Err != nil {
+				result.Status = model.BatchOpFailed
+				return errRollback
+			}
+			result.Status = model.BatchOpSucceeded
+		}
+		return nil
+	}, repository.Isolation(sql.LevelSerializable))
+	if errors.Is(err, errRollback) {
+		for i := range resp.Results {
+			if resp.Results[i].Status == model.BatchOpSucceeded {
+				resp.Results[i].Status = model.BatchOpRolledBack
+				resp.Results[i].User = nil
+			}
+		}
+		return resp, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	resp.Committed = true
+	return resp, nil
+}
+
+// runOperation runs one operation of a batch, returning the user it created
+// or updated.
+func (s *UserBulkService) runOperation(ctx context.Context, op *model.UserBatchOperation) (*model.User, error) {
+	switch op.Op {
+	case model.BatchOpCreate:
+		var req model.NewUserRequest
+		if err := decodeBatchUser(op.User, &req); err != nil {
+			return nil, err
+		}
+		return s.users.CreateUser(ctx, &req)
+	case model.BatchOpUpdate:
+		var req model.UpdateUserRequest
+		if err := decodeBatchUser(op.User, &req); err != nil {
+			return nil, err
+		}
+		return s.users.UpdateUser(ctx, op.ID, op.Version, &req, op.Rights)
+	case model.BatchOpDelete:
+		return nil, s.users.DeleteUser(ctx, op.ID, op.Version)
+	default:
+		return nil, fmt.Errorf("%w: unknown op %q", ierr.ErrInvalidBatch, op.Op)
+	}
+}
+
+// decodeBatchUser reads the user of a batch operation into req.
+func decodeBatchUser(data json.RawMessage, req any) error {
+	if len(data) == 0 {
+		return fmt.Errorf("%w: user is required", ierr.ErrInvalidUser)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(req); err != nil {
+		return fmt.Errorf("%w: %v", ierr.ErrInvalidUser, err)
+	}
+	return nil
+}
+
+// userRowReader reads the users to import one row at a time.
+type userRowReader interface {
+	// next returns the next user and the line its row starts on, or io.EOF
+	// after the last. A row that cannot be read fails with
+	// ierr.ErrInvalidUser, and the rows after it can still be.
+	next() (int, *model.NewUserRequest, error)
+}
+
+func newUserRowReader(format string, body io.Reader) (userRowReader, error) {
+	switch format {
+	case model.UserCSVType:
+		return newCSVUserReader(body)
+	case model.UserNDJSONType:
+		return newNDJSONUserReader(body), nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported format %q", ierr.ErrInvalidImport, format)
+	}
+}
+
+// csvImportColumns are the columns an imported CSV file may have, of which
+// roles is optional. The roles in a row are separated by ";".
+var csvImportColumns = []string{"name", "email", "password", "roles"}
+
+// csvUserReader reads users from CSV with a header row naming the columns.
+type csvUserReader struct {
+	r       *csv.Reader
+	columns []string
+}
+
+func newCSVUserReader(body io.Reader) (*csvUserReader, error) {
+	r := csv.NewReader(body)
+	r.TrimLeadingSpace = true
+
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("%w: missing header row", ierr.ErrInvalidImport)
+	}
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return nil, fmt.Errorf("%w: header row: %v", ierr.ErrInvalidImport, parseErr.Err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Spreadsheet apps like to start UTF-8 files with a byte order mark.
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	columns := make([]string, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !slices.Contains(csvImportColumns, name) {
+			return nil, fmt.Errorf("%w: unknown column %q", ierr.ErrInvalidImport, name)
+		}
+		if slices.Contains(columns[:i], name) {
+			return nil, fmt.Errorf("%w: duplicate column %q", ierr.ErrInvalidImport, name)
+		}
+		columns[i] = name
+	}
+	for _, name := range []string{"name", "email", "password"} {
+		if !slices.Contains(columns, name) {
+			return nil, fmt.Errorf("%w: missing column %q", ierr.ErrInvalidImport, name)
+		}
+	}
+	return &csvUserReader{r: r, columns: columns}, nil
+}
+
+func (c *csvUserReader) next() (int, *model.NewUserRequest, error) {
+	record, err := c.r.Read()
+	if err == io.EOF {
+		return 0, nil, io.EOF
+	}
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return parseErr.StartLine, nil, fmt.Errorf("%w: %v", ierr.ErrInvalidUser, parseErr.Err)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	line, _ := c.r.FieldPos(0)
+
+	req := &model.NewUserRequest{}
+	for i, column := range c.columns {
+		value := strings.TrimSpace(record[i])
+		switch column {
+		case "name":
+			req.Name = value
+		case "email":
+			req.Email = value
+		case "password":
+			req.Password = record[i]
+		case "roles":
+			for _, role := range strings.Split(value, ";") {
+				if role = strings.TrimSpace(role); role != "" {
+					req.Roles = append(req.Roles, role)
+				}
+			}
+		}
+	}
+	return line, req, nil
+}
+
+// ndjsonUserReader reads users from newline-delimited JSON, one
+// model.NewUserRequest per line. Blank lines are ignored.
+type ndjsonUserReader struct {
+	s    *bufio.Scanner
+	line int
+}
+
+func newNDJSONUserReader(body io.Reader) *ndjsonUserReader {
+	return &ndjsonUserReader{s: bufio.NewScanner(body)}
+}
+
+func (n *ndjsonUserReader) next() (int, *model.NewUserRequest, error) {
+	for n.s.Scan() {
+		n.line++
+		data := bytes.TrimSpace(n.s.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		req := &model.NewUserRequest{}
+		if err := dec.Decode(req); err != nil {
+			return n.line, nil, fmt.Errorf("%w: %v", ierr.ErrInvalidUser, err)
+		}
+		if dec.More() {
+			return n.line, nil, fmt.Errorf("%w: one user per line", ierr.ErrInvalidUser)
+		}
+		return n.line, req, nil
+	}
+	if errors.Is(n.s.Err(), bufio.ErrTooLong) {
+		return n.line + 1, nil, fmt.Errorf("%w: line %d is too long", ierr.ErrInvalidImport, n.line+1)
+	}
+	if err := n.s.Err(); err != nil {
+		return 0, nil, err
+	}
+	return 0, nil, io.EOF
+}
+
+// userRowWriter writes exported users one row at a time.
+type userRowWriter interface {
+	write(user *model.User) error
+	// flush sends what has been buffered to the underlying writer.
+	flush() error
+}
+
+func newUserRowWriter(format string, w io.Writer) (userRowWriter, error) {
+	switch format {
+	case model.UserCSVType:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(csvExportColumns); err != nil {
+			return nil, err
+		}
+		return &csvUserWriter{w: cw}, nil
+	case model.UserNDJSONType:
+		return &ndjsonUserWriter{enc: json.NewEncoder(w)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+// csvExportColumns are the columns of an exported CSV file. Roles are
+// separated by ";" as on import.
+var csvExportColumns = []string{"id", "name", "email", "roles", "is_active", "email_verified_at", "created_at", "updated_at"}
+
+type csvUserWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvUserWriter) write(user *model.User) error {
+	verifiedAt := ""
+	if user.EmailVerifiedAt != nil {
+		verifiedAt = user.EmailVerifiedAt.UTC().Format(time.RFC3339)
+	}
+	row := []string{
+		user.ID.String(),
+		user.Name,
+		user.Email,
+		strings.Join(user.Roles, ";"),
+		strconv.FormatBool(user.IsActive),
+		verifiedAt,
+		user.CreatedAt.UTC().Format(time.RFC3339),
+		user.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+	for i, cell := range row {
+		row[i] = escapeFormula(cell)
+	}
+	return c.w.Write(row)
+}
+
+// escapeFormula prefixes a cell that a spreadsheet would read as a formula
+// with a quote, so that an exported name cannot run one when the file is
+// opened.
+func escapeFormula(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+func (c *csvUserWriter) flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// ndjsonUserWriter writes each user as JSON on a line of its own, as the
+// user endpoints return it.
+type ndjsonUserWriter struct {
+	enc *json.Encoder
+}
+
+func (n *ndjsonUserWriter) write(user *model.User) error {
+	return n.enc.Encode(user)
+}
+
+func (n *ndjsonUserWriter) flush() error {
+	return nil
+}
@@ -0,0 +1,291 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/faizalom/go-api/internal/config"
+	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/model"
+	"github.com/faizalom/go-api/internal/repository/mocks"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var testUserImport = config.UserImportConfig{MaxRows: 100}
+
+func TestUserBulkService_ImportUsers_CSV(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	bulkService := NewUserBulkService(NewUserService(mockUserRepo, withTx(), testPasswords, &recordingVerifier{}), new(mocks.MockTxManager), testUserImport)
+
+	annID := uuid.New()
+	mockUserRepo.On("GetByEmail", mock.Anything, "ann@example.com").Return(&model.User{}, "", ierr.ErrUserNotFound)
+	mockUserRepo.On("GetByEmail", mock.Anything, "taken@example.com").Return(&model.User{ID: uuid.New()}, "hash", nil)
+	mockUserRepo.On("Create", mock.Anything, mock.MatchedBy(func(u *model.User) bool {
+		return u.Email == "ann@example.com" && strings.Join(u.Roles, ",") == "athlete,coach"
+	}), mock.AnythingOfType("string")).Return(&model.User{ID: annID}, nil)
+
+	body := "\ufeffName,Email,Password,Roles\n" +
+		"Ann,ann@example.com,correct-horse,athlete; coach\n" +
+		"Bob,taken@example.com,correct-horse,\n" +
+		"Cy,not-an-email,correct-horse,\n" +
+		"\"Dee\nDee\",dee@example.com,short,\n" +
+		"Eve,eve@example.com,correct-horse,pilot\n" +
+		"Fay,fay@example.com\n"
+	report, err := bulkService.ImportUsers(context.Background(), &model.UserImportRequest{Format: model.UserCSVType, Body: strings.NewReader(body)})
+
+	require.NoError(t, err)
+	assert.True(t, report.Committed)
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, 1, report.Skipped)
+	assert.Equal(t, 4, report.Failed)
+
+	want := []struct {
+		line   int
+		status string
+	}{
+		{2, model.ImportRowCreated},
+		{3, model.ImportRowSkipped},
+		{4, model.ImportRowFailed},
+		{5, model.ImportRowFailed},
+		{7, model.ImportRowFailed},
+		{8, model.ImportRowFailed},
+	}
+	require.Len(t, report.Rows, len(want))
+	for i, w := range want {
+		assert.Equal(t, w.line, report.Rows[i].Line, "row %d", i)
+		assert.Equal(t, w.status, report.Rows[i].Status, "row %d", i)
+	}
+	assert.Equal(t, &annID, report.Rows[0].ID)
+	assert.Contains(t, report.Rows[3].Error, ierr.ErrWeakPassword.Error())
+	assert.Contains(t, report.Rows[4].Error, ierr.ErrInvalidRole.Error())
+	mockUserRepo.AssertNumberOfCalls(t, "Create", 1)
+}
+
+func TestUserBulkService_ImportUsers_AtomicRollback(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockTx := new(mocks.MockTxManager)
+	bulkService := NewUserBulkService(NewUserService(mockUserRepo, withTx(), testPasswords, &recordingVerifier{}), mockTx, testUserImport)
+
+	mockTx.On("WithinTx", mock.Anything).Return(nil)
+	mockUserRepo.On("GetByEmail", mock.Anything, "ann@example.com").Return(&model.User{}, "", ierr.ErrUserNotFound)
+	mockUserRepo.On("Create", mock.Anything, mock.AnythingOfType("*model.User"), mock.AnythingOfType("string")).Return(&model.User{ID: uuid.New()}, nil)
+
+	body := `{"name":"Ann","email":"ann@example.com","password":"correct-horse"}
+
+{"name":"Bob","email":"bob@example.com","password":"correct-horse","admin":true}
+`
+	report, err := bulkService.ImportUsers(context.Background(), &model.UserImportRequest{Format: model.UserNDJSONType, Body: strings.NewReader(body), Atomic: true})
+
+	require.NoError(t, err)
+	assert.False(t, report.Committed)
+	assert.Equal(t, 0, report.Created)
+	assert.Equal(t, 1, report.Failed)
+	require.Len(t, report.Rows, 2)
+	assert.Equal(t, model.UserImportRow{Line: 1, Status: model.ImportRowRolledBack, Email: "ann@example.com"}, report.Rows[0])
+	assert.Equal(t, 3, report.Rows[1].Line)
+	assert.Equal(t, model.ImportRowFailed, report.Rows[1].Status)
+	mockTx.AssertExpectations(t)
+}
+
+func TestUserBulkService_ImportUsers_MaxRows(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	bulkService := NewUserBulkService(NewUserService(mockUserRepo, withTx(), testPasswords, &recordingVerifier{}), new(mocks.MockTxManager), config.UserImportConfig{MaxRows: 1})
+
+	mockUserRepo.On("GetByEmail", mock.Anything, "ann@example.com").Return(&model.User{}, "", ierr.ErrUserNotFound)
+	mockUserRepo.On("Create", mock.Anything, mock.AnythingOfType("*model.User"), mock.AnythingOfType("string")).Return(&model.User{ID: uuid.New()}, nil)
+
+	body := "name,email,password\nAnn,ann@example.com,correct-horse\nBob,bob@example.com,correct-horse\nCy,cy@example.com,correct-horse\n"
+	report, err := bulkService.ImportUsers(context.Background(), &model.UserImportRequest{Format: model.UserCSVType, Body: strings.NewReader(body)})
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, 1, report.Failed)
+	require.Len(t, report.Rows, 2)
+	assert.Equal(t, 3, report.Rows[1].Line)
+	assert.Equal(t, "imports are limited to 1 rows", report.Rows[1].Error)
+}
+
+func TestUserBulkService_ImportUsers_Invalid(t *testing.T) {
+	bulkService := NewUserBulkService(NewUserService(new(mocks.MockUserRepository), withTx(), testPasswords, &recordingVerifier{}), new(mocks.MockTxManager), testUserImport)
+
+	tests := []struct {
+		name, format, body string
+	}{
+		{"empty csv", model.UserCSVType, ""},
+		{"unknown column", model.UserCSVType, "name,email,password,age\n"},
+		{"missing column", model.UserCSVType, "name,email\n"},
+		{"duplicate column", model.UserCSVType, "name,email,password,email\n"},
+		{"unknown format", "application/xml", "<users/>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := bulkService.ImportUsers(context.Background(), &model.UserImportRequest{Format: tt.format, Body: strings.NewReader(tt.body)})
+			assert.ErrorIs(t, err, ierr.ErrInvalidImport)
+		})
+	}
+}
+
+func TestUserBulkService_ExportUsers_CSV(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	bulkService := NewUserBulkService(NewUserService(mockUserRepo, withTx(), testPasswords, &recordingVerifier{}), new(mocks.MockTxManager), testUserImport)
+
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	user := &model.User{ID: uuid.New(), Name: "Smith, Ann", Email: "ann@example.com", Roles: []string{"athlete", "coach"}, IsActive: true, EmailVerifiedAt: &created, CreatedAt: created, UpdatedAt: created}
+	mockUserRepo.On("List", mock.Anything, mock.MatchedBy(func(q model.UserListQuery) bool {
+		return q.Sort == model.UserSortCreatedAt && !q.Desc && q.Limit == model.MaxUserPageSize+1
+	})).Return([]*model.User{user}, nil)
+
+	var out bytes.Buffer
+	err := bulkService.ExportUsers(context.Background(), model.UserCSVType, &out)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "id,name,email,roles,is_active,email_verified_at,created_at,updated_at\n"+
+		user.ID.String()+`,"Smith, Ann",ann@example.com,athlete;coach,true,2024-01-02T03:04:05Z,2024-01-02T03:04:05Z,2024-01-02T03:04:05Z`+"\n", out.String())
+}
+
+func TestUserBulkService_ExportUsers_CSVFormulas(t *testing.T) {
+	tests := []struct {
+		name     string
+		userName string
+		want     string
+	}{
+		{"equals", "=HYPERLINK(\"http://example.com\")", `"'=HYPERLINK(""http://example.com"")"`},
+		{"plus", "+1", "'+1"},
+		{"minus", "-1+1", "'-1+1"},
+		{"at", "@SUM(A1)", "'@SUM(A1)"},
+		{"tab", "\t=1", "'\t=1"},
+		{"carriage return", "\r=1", "\"'\r=1\""},
+		{"plain", "Ann-Marie", "Ann-Marie"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockUserRepo := new(mocks.MockUserRepository)
+			bulkService := NewUserBulkService(NewUserService(mockUserRepo, withTx(), testPasswords, &recordingVerifier{}), new(mocks.MockTxManager), testUserImport)
+			user := &model.User{ID: uuid.New(), Name: tt.userName, Email: "ann@example.com", Roles: []string{"athlete"}}
+			mockUserRepo.On("List", mock.Anything, mock.Anything).Return([]*model.User{user}, nil)
+
+			var out bytes.Buffer
+			err := bulkService.ExportUsers(context.Background(), model.UserCSVType, &out)
+
+			assert.NoError(t, err)
+			lines := strings.SplitN(out.String(), "\n", 2)
+			assert.True(t, strings.HasPrefix(lines[1], user.ID.String()+","+tt.want+",ann@example.com,"), lines[1])
+		})
+	}
+}
+
+func TestUserBulkService_ExportUsers_NDJSONPages(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	bulkService := NewUserBulkService(NewUserService(mockUserRepo, withTx(), testPasswords, &recordingVerifier{}), new(mocks.MockTxManager), testUserImport)
+
+	now := time.Now()
+	first := make([]*model.User, model.MaxUserPageSize+1)
+	for i := range first {
+		first[i] = &model.User{ID: uuid.New(), CreatedAt: now.Add(time.Duration(i) * time.Second)}
+	}
+	last := &model.User{ID: uuid.New(), CreatedAt: now.Add(time.Hour)}
+	mockUserRepo.On("List", mock.Anything, mock.MatchedBy(func(q model.UserListQuery) bool { return q.After == nil })).Return(first, nil).Once()
+	mockUserRepo.On("List", mock.Anything, mock.MatchedBy(func(q model.UserListQuery) bool {
+		return q.After != nil && q.After.ID == first[model.MaxUserPageSize-1].ID
+	})).Return([]*model.User{last}, nil).Once()
+
+	var out bytes.Buffer
+	err := bulkService.ExportUsers(context.Background(), model.UserNDJSONType, &out)
+
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	require.Len(t, lines, model.MaxUserPageSize+1)
+	var exported model.User
+	require.NoError(t, json.Unmarshal([]byte(lines[len(lines)-1]), &exported))
+	assert.Equal(t, last.ID, exported.ID)
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestUserBulkService_RunBatch(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockTx := new(mocks.MockTxManager)
+	bulkService := NewUserBulkService(NewUserService(mockUserRepo, withTx(), testPasswords, &recordingVerifier{}), mockTx, testUserImport)
+
+	userID, deletedID := uuid.New(), uuid.New()
+	mockTx.On("WithinTx", mock.Anything).Return(nil)
+	mockUserRepo.On("GetByEmail", mock.Anything, "ann@example.com").Return(&model.User{}, "", ierr.ErrUserNotFound)
+	mockUserRepo.On("Create", mock.Anything, mock.AnythingOfType("*model.User"), mock.AnythingOfType("string")).Return(&model.User{ID: uuid.New(), Email: "ann@example.com"}, nil)
+	mockUserRepo.On("GetByID", mock.Anything, userID).Return(&model.User{ID: userID, Name: "Bob", Email: "bob@example.com", Roles: []string{model.RoleAthlete}, Version: 2}, nil)
+	mockUserRepo.On("Update", mock.Anything, userID, mock.AnythingOfType("*model.User")).Return(true, nil)
+	mockUserRepo.On("GetByID", mock.Anything, deletedID).Return(&model.User{ID: deletedID, Version: 5}, nil)
+	mockUserRepo.On("Delete", mock.Anything, deletedID, 5).Return(true, nil)
+
+	req := &model.UserBatchRequest{Operations: []model.UserBatchOperation{
+		{Op: model.BatchOpCreate, User: []byte(`{"name":"Ann","email":"ann@example.com","password":"correct-horse"}`)},
+		{Op: model.BatchOpUpdate, ID: userID, Version: 2, User: []byte(`{"name":"Robert","email":"bob@example.com","roles":["athlete"]}`)},
+		{Op: model.BatchOpDelete, ID: deletedID, Version: 5},
+	}}
+	resp, err := bulkService.RunBatch(context.Background(), req)
+
+	require.NoError(t, err)
+	assert.True(t, resp.Committed)
+	require.Len(t, resp.Results, 3)
+	for i, result := range resp.Results {
+		assert.Equal(t, i, result.Index)
+		assert.Equal(t, model.BatchOpSucceeded, result.Status)
+		assert.NoError(t, result.Err)
+	}
+	assert.Equal(t, "ann@example.com", resp.Results[0].User.Email)
+	assert.Equal(t, "Robert", resp.Results[1].User.Name)
+	assert.Nil(t, resp.Results[2].User)
+	mockTx.AssertExpectations(t)
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestUserBulkService_RunBatch_Rollback(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockTx := new(mocks.MockTxManager)
+	bulkService := NewUserBulkService(NewUserService(mockUserRepo, withTx(), testPasswords, &recordingVerifier{}), mockTx, testUserImport)
+
+	userID := uuid.New()
+	mockTx.On("WithinTx", mock.Anything).Return(nil)
+	mockUserRepo.On("GetByEmail", mock.Anything, "ann@example.com").Return(&model.User{}, "", ierr.ErrUserNotFound)
+	mockUserRepo.On("Create", mock.Anything, mock.AnythingOfType("*model.User"), mock.AnythingOfType("string")).Return(&model.User{ID: uuid.New()}, nil)
+	mockUserRepo.On("GetByID", mock.Anything, userID).Return(&model.User{ID: userID, Version: 3}, nil)
+
+	req := &model.UserBatchRequest{Operations: []model.UserBatchOperation{
+		{Op: model.BatchOpCreate, User: []byte(`{"name":"Ann","email":"ann@example.com","password":"correct-horse"}`)},
+		{Op: model.BatchOpDelete, ID: userID, Version: 2},
+		{Op: model.BatchOpDelete, ID: uuid.New(), Version: 1},
+	}}
+	resp, err := bulkService.RunBatch(context.Background(), req)
+
+	require.NoError(t, err)
+	assert.False(t, resp.Committed)
+	assert.Equal(t, model.BatchOpRolledBack, resp.Results[0].Status)
+	assert.Nil(t, resp.Results[0].User)
+	assert.Equal(t, model.BatchOpFailed, resp.Results[1].Status)
+	assert.ErrorIs(t, resp.Results[1].Err, ierr.ErrVersionMismatch)
+	assert.Equal(t, model.BatchOpNotRun, resp.Results[2].Status)
+	mockUserRepo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUserBulkService_RunBatch_Invalid(t *testing.T) {
+	mockTx := new(mocks.MockTxManager)
+	bulkService := NewUserBulkService(NewUserService(new(mocks.MockUserRepository), withTx(), testPasswords, &recordingVerifier{}), mockTx, testUserImport)
+
+	_, err := bulkService.RunBatch(context.Background(), &model.UserBatchRequest{})
+	assert.ErrorIs(t, err, ierr.ErrInvalidBatch)
+
+	_, err = bulkService.RunBatch(context.Background(), &model.UserBatchRequest{Operations: make([]model.UserBatchOperation, model.MaxUserBatchSize+1)})
+	assert.ErrorIs(t, err, ierr.ErrInvalidBatch)
+
+	mockTx.On("WithinTx", mock.Anything).Return(nil)
+	resp, err := bulkService.RunBatch(context.Background(), &model.UserBatchRequest{Operations: []model.UserBatchOperation{
+		{Op: model.BatchOpCreate, User: []byte(`{"name":"Ann","email":"ann@example.com","admin":true}`)},
+	}})
+	require.NoError(t, err)
+	assert.ErrorIs(t, resp.Results[0].Err, ierr.ErrInvalidUser)
+}
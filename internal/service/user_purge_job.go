@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/faizalom/go-api/internal/config"
+	"github.com/faizalom/go-api/internal/repository"
+	"github.com/faizalom/go-api/pkg/logger"
+)
+
+// DeletedUserPurger permanently deletes users once they have been
+// soft-deleted for longer than the retention period, after which they can no
+// longer be restored.
+type DeletedUserPurger struct {
+	repo      repository.IUserRepository
+	retention time.Duration
+	interval  time.Duration
+}
+
+func NewDeletedUserPurger(repo repository.IUserRepository, cfg config.DeletedUsersConfig) *DeletedUserPurger {
+	return &DeletedUserPurger{
+		repo:      repo,
+		retention: time.Duration(cfg.RetentionDays) * 24 * time.Hour,
+		interval:  cfg.PurgeInterval,
+	}
+}
+
+// Purge deletes the users past retention and returns how many there were.
+func (p *DeletedUserPurger) Purge(ctx context.Context) (int64, error) {
+	return p.repo.PurgeDeleted(ctx, time.Now().Add(-p.retention))
+}
+
+// Run purges at once and then every interval, until ctx is done. It returns
+// straight away when deleted users are kept forever.
+func (p *DeletedUserPurger) Run(ctx context.Context) {
+	if p.retention < 0 {
+		return
+	}
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		purged, err := p.Purge(ctx)
+		if err != nil {
+			logger.Error.Printf("Could not purge deleted users: %v", err)
+		} else if purged > 0 {
+			logger.Info.Printf("Purged %d users deleted more than %s ago", purged, p.retention)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/faizalom/go-api/internal/config"
+	"github.com/faizalom/go-api/internal/repository/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestDeletedUserPurger_Purge(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	purger := NewDeletedUserPurger(mockUserRepo, config.DeletedUsersConfig{RetentionDays: 30, PurgeInterval: time.Hour})
+
+	cutoff := time.Now().Add(-30 * 24 * time.Hour)
+	mockUserRepo.On("PurgeDeleted", mock.Anything, mock.MatchedBy(func(before time.Time) bool {
+		return before.Sub(cutoff).Abs() < time.Minute
+	})).Return(int64(2), nil)
+
+	purged, err := purger.Purge(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), purged)
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestDeletedUserPurger_Run(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	purger := NewDeletedUserPurger(mockUserRepo, config.DeletedUsersConfig{RetentionDays: 30, PurgeInterval: time.Hour})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	mockUserRepo.On("PurgeDeleted", mock.Anything, mock.Anything).Return(int64(0), nil).Run(func(mock.Arguments) { cancel() })
+
+	// Run purges straight away and returns once ctx is done.
+	purger.Run(ctx)
+	mockUserRepo.AssertNumberOfCalls(t, "PurgeDeleted", 1)
+
+	// Deleted users are kept forever with a negative retention.
+	keep := NewDeletedUserPurger(mockUserRepo, config.DeletedUsersConfig{RetentionDays: -1, PurgeInterval: time.Hour})
+	keep.Run(context.Background())
+	mockUserRepo.AssertNumberOfCalls(t, "PurgeDeleted", 1)
+}
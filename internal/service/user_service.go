@@ -2,10 +2,24 @@ package service
 
 import (
 	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/faizalom/go-api/internal/ierr"
+	"github.com/faizalom/go-api/internal/jsonpatch"
 	"github.com/faizalom/go-api/internal/model"
+	"github.com/faizalom/go-api/internal/password"
 	"github.com/faizalom/go-api/internal/repository"
+	"github.com/faizalom/go-api/pkg/logger"
 
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
@@ -14,24 +28,45 @@ import (
 type IUserService interface {
 	CreateUser(ctx context.Context, req *model.NewUserRequest) (*model.User, error)
 	GetUserByID(ctx context.Context, id uuid.UUID) (*model.User, error)
-	UpdateUser(ctx context.Context, id uuid.UUID, req *model.UpdateUserRequest) (*model.User, error)
-	DeleteUser(ctx context.Context, id uuid.UUID) error
-	ListUsers(ctx context.Context) ([]*model.User, error)
+	UpdateUser(ctx context.Context, id uuid.UUID, version int, req *model.UpdateUserRequest, rights model.UserEditRights) (*model.User, error)
+	PatchUser(ctx context.Context, id uuid.UUID, version int, patch *model.UserPatch, rights model.UserEditRights) (*model.User, error)
+	DeleteUser(ctx context.Context, id uuid.UUID, version int) error
+	RestoreUser(ctx context.Context, id uuid.UUID) (*model.User, error)
+	ListUsers(ctx context.Context, req *model.UserListRequest) (*model.UserPage, error)
+	SearchUsers(ctx context.Context, req *model.UserSearchRequest) (*model.UserSearchPage, error)
 }
 
 type UserService struct {
-	repo repository.IUserRepository
+	repo      repository.IUserRepository
+	tx        repository.ITxManager
+	passwords *password.Policy
+	verifier  IEmailVerificationService
 }
 
-func NewUserService(repo repository.IUserRepository) IUserService {
-	return &UserService{repo: repo}
+func NewUserService(repo repository.IUserRepository, tx repository.ITxManager, passwords *password.Policy, verifier IEmailVerificationService) IUserService {
+	return &UserService{repo: repo, tx: tx, passwords: passwords, verifier: verifier}
 }
 
-// CreateUser handles the business logic for creating a new user.
+// CreateUser handles the business logic for creating a new user. It fails
+// with ierr.ErrInvalidUser without a name and an email address.
 func (s *UserService) CreateUser(ctx context.Context, req *model.NewUserRequest) (*model.User, error) {
-	// Check if user already exists
-	if _, _, err := s.repo.GetByEmail(ctx, req.Email); err == nil {
-		return nil, ierr.ErrUserAlreadyExists
+	if err := validateNewUser(req); err != nil {
+		return nil, err
+	}
+	roles := req.Roles
+	if len(roles) == 0 {
+		roles = model.DefaultRoles
+	}
+	if err := validateRoles(roles); err != nil {
+		return nil, err
+	}
+	if err := s.passwords.Validate(req.Password); err != nil {
+		return nil, err
+	}
+
+	// Check if user already exists, before spending time on the hash
+	if err := s.checkEmailFree(ctx, req.Email); err != nil {
+		return nil, err
 	}
 
 	// Hash the password
@@ -41,64 +76,422 @@ func (s *UserService) CreateUser(ctx context.Context, req *model.NewUserRequest)
 	}
 
 	newUser := &model.User{
-		Name:  req.Name,
-		Email: req.Email,
+		Name:     req.Name,
+		Email:    req.Email,
+		Roles:    roles,
+		IsActive: true,
+	}
+	if req.EmailVerified {
+		now := time.Now()
+		newUser.EmailVerifiedAt = &now
 	}
 
-	// Call the repository to create the user
-	createdUser, err := s.repo.Create(ctx, newUser, string(hashedPassword))
+	// Check again and insert in one serializable transaction, so that of
+	// two concurrent requests for one email only the first creates a user;
+	// the second is retried and finds it.
+	var createdUser *model.User
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.checkEmailFree(ctx, req.Email); err != nil {
+			return err
+		}
+		createdUser, err = s.repo.Create(ctx, newUser, string(hashedPassword))
+		return err
+	}, repository.Isolation(sql.LevelSerializable))
 	if err != nil {
 		return nil, err
 	}
 
+	if !req.EmailVerified {
+		s.sendVerification(ctx, createdUser)
+	}
 	return createdUser, nil
 }
 
+// checkEmailFree fails with ierr.ErrUserAlreadyExists if a current user has
+// the email, and with the repository's error if that cannot be told.
+func (s *UserService) checkEmailFree(ctx context.Context, email string) error {
+	_, _, err := s.repo.GetByEmail(ctx, email)
+	switch {
+	case err == nil:
+		return ierr.ErrUserAlreadyExists
+	case errors.Is(err, ierr.ErrUserNotFound):
+		return nil
+	default:
+		return err
+	}
+}
+
 // GetUserByID retrieves a user by their ID.
 func (s *UserService) GetUserByID(ctx context.Context, id uuid.UUID) (*model.User, error) {
-	user, err := s.repo.GetByID(ctx, id)
+	return s.repo.GetByID(ctx, id)
+}
+
+// UpdateUser replaces the editable fields of a user with req. It fails with
+// ierr.ErrVersionMismatch unless the user is still at the given version, or
+// version is model.AnyVersion and the user did not change meanwhile, and
+// with ierr.ErrRolesNotAllowed or ierr.ErrEmailNotAllowed if it would change
+// the user's roles or email without the rights to.
+func (s *UserService) UpdateUser(ctx context.Context, id uuid.UUID, version int, req *model.UpdateUserRequest, rights model.UserEditRights) (*model.User, error) {
+	return s.editUser(ctx, id, version, rights, func(*model.User) (*model.UpdateUserRequest, error) {
+		return req, nil
+	})
+}
+
+// PatchUser applies a patch to the JSON representation of a user, which may
+// only change its editable fields. It fails like UpdateUser, and with
+// ierr.ErrInvalidPatch or ierr.ErrPatchTestFailed if the patch does not
+// apply.
+func (s *UserService) PatchUser(ctx context.Context, id uuid.UUID, version int, patch *model.UserPatch, rights model.UserEditRights) (*model.User, error) {
+	return s.editUser(ctx, id, version, rights, func(user *model.User) (*model.UpdateUserRequest, error) {
+		return applyUserPatch(user, patch)
+	})
+}
+
+// editUser replaces the editable fields of a user with those edit computes
+// from the current user, once they have been validated.
+func (s *UserService) editUser(ctx context.Context, id uuid.UUID, version int, rights model.UserEditRights, edit func(*model.User) (*model.UpdateUserRequest, error)) (*model.User, error) {
+	// Serializable, so that a changed email cannot be taken by someone else
+	// between the check and the update.
+	var user *model.User
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		user, err = s.repo.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if version != model.AnyVersion && user.Version != version {
+			return ierr.ErrVersionMismatch
+		}
+
+		req, err := edit(user)
+		if err != nil {
+			return err
+		}
+		if err := validateUserUpdate(req); err != nil {
+			return err
+		}
+		if !sameRoles(req.Roles, user.Roles) && !rights.Roles {
+			return ierr.ErrRolesNotAllowed
+		}
+		emailChanged := req.Email != user.Email
+		if emailChanged && !rights.Email {
+			return ierr.ErrEmailNotAllowed
+		}
+		if emailChanged {
+			if err := s.checkEmailFree(ctx, req.Email); err != nil {
+				return err
+			}
+			// The repository clears the verification of a changed email.
+			user.EmailVerifiedAt = nil
+		}
+		user.Name, user.Email, user.Roles = req.Name, req.Email, req.Roles
+
+		// Someone else may have changed the user since it was read.
+		updated, err := s.repo.Update(ctx, id, user)
+		if err != nil {
+			return err
+		}
+		if !updated {
+			return ierr.ErrVersionMismatch
+		}
+
+		if emailChanged {
+			s.sendVerification(ctx, user)
+		}
+		return nil
+	}, repository.Isolation(sql.LevelSerializable))
 	if err != nil {
-		// In a real app, you'd check for sql.ErrNoRows and return a custom error
-		return nil, ierr.ErrUserNotFound
+		return nil, err
 	}
 	return user, nil
 }
 
-// UpdateUser handles the business logic for updating a user.
-func (s *UserService) UpdateUser(ctx context.Context, id uuid.UUID, req *model.UpdateUserRequest) (*model.User, error) {
+// DeleteUser handles the business logic for deleting a user. It fails like
+// UpdateUser with ierr.ErrVersionMismatch.
+func (s *UserService) DeleteUser(ctx context.Context, id uuid.UUID, version int) error {
 	user, err := s.repo.GetByID(ctx, id)
 	if err != nil {
+		return err
+	}
+	if version != model.AnyVersion && user.Version != version {
+		return ierr.ErrVersionMismatch
+	}
+
+	deleted, err := s.repo.Delete(ctx, id, user.Version)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ierr.ErrVersionMismatch
+	}
+	return nil
+}
+
+// RestoreUser undoes the soft delete of a user. It fails with
+// ierr.ErrUserAlreadyExists when someone has registered their email since.
+func (s *UserService) RestoreUser(ctx context.Context, id uuid.UUID) (*model.User, error) {
+	user, err := s.repo.GetDeletedByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	restored, err := s.repo.Restore(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !restored {
+		// Either the email has been taken, or the user was restored or
+		// purged meanwhile.
+		if err := s.checkEmailFree(ctx, user.Email); err != nil {
+			return nil, err
+		}
 		return nil, ierr.ErrUserNotFound
 	}
+	logger.Info.Printf("Restored deleted user %s", id)
+	return s.repo.GetByID(ctx, id)
+}
 
-	if req.Name != nil {
-		user.Name = *req.Name
+// ListUsers retrieves a page of users. The next page, if any, starts after
+// the page's NextCursor.
+func (s *UserService) ListUsers(ctx context.Context, req *model.UserListRequest) (*model.UserPage, error) {
+	q := model.UserListQuery{UserListRequest: *req}
+	if q.Sort == "" {
+		q.Sort = model.UserSortCreatedAt
 	}
-	if req.Email != nil {
-		user.Email = *req.Email
+	if q.Limit <= 0 {
+		q.Limit = model.DefaultUserPageSize
+	}
+	q.Limit = min(q.Limit, model.MaxUserPageSize)
+	if q.Cursor != "" {
+		after, err := decodeUserCursor(q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		// A cursor is only a position in the order it was issued for.
+		if after.Sort != q.Sort || after.Desc != q.Desc {
+			return nil, ierr.ErrInvalidCursor
+		}
+		q.After = after
 	}
 
-	if err := s.repo.Update(ctx, id, user); err != nil {
+	// Fetch one extra user to learn whether there is a next page.
+	limit := q.Limit
+	q.Limit++
+	users, err := s.repo.List(ctx, q)
+	if err != nil {
 		return nil, err
 	}
 
-	return user, nil
+	page := &model.UserPage{Users: users}
+	if len(users) > limit {
+		page.Users = users[:limit]
+		last := page.Users[limit-1]
+		page.NextCursor = encodeUserCursor(&model.UserCursor{Sort: q.Sort, Desc: q.Desc, Value: userSortValue(last, q.Sort), ID: last.ID})
+	}
+	if page.Users == nil {
+		page.Users = []*model.User{}
+	}
+	return page, nil
 }
 
-// DeleteUser handles the business logic for deleting a user.
-func (s *UserService) DeleteUser(ctx context.Context, id uuid.UUID) error {
-	if _, err := s.repo.GetByID(ctx, id); err != nil {
-		return ierr.ErrUserNotFound
+// maxSearchLength bounds search queries, which are compared with every
+// user's name and email.
+const maxSearchLength = 200
+
+// SearchUsers retrieves a page of users matching a search, most relevant
+// first. The next page, if any, starts after the page's NextCursor.
+func (s *UserService) SearchUsers(ctx context.Context, req *model.UserSearchRequest) (*model.UserSearchPage, error) {
+	q := model.UserSearchQuery{UserSearchRequest: *req}
+	q.Query = strings.TrimSpace(q.Query)
+	if q.Query == "" || utf8.RuneCountInString(q.Query) > maxSearchLength {
+		return nil, ierr.ErrInvalidSearch
+	}
+	if q.Limit <= 0 {
+		q.Limit = model.DefaultUserPageSize
+	}
+	q.Limit = min(q.Limit, model.MaxUserPageSize)
+	if q.Cursor != "" {
+		after, err := decodeUserCursor(q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if after.Sort != model.UserSortRank {
+			return nil, ierr.ErrInvalidCursor
+		}
+		q.After = after
 	}
 
-	return s.repo.Delete(ctx, id)
+	// Fetch one extra result to learn whether there is a next page.
+	limit := q.Limit
+	q.Limit++
+	results, err := s.repo.Search(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &model.UserSearchPage{Results: results}
+	if len(results) > limit {
+		page.Results = results[:limit]
+		last := page.Results[limit-1]
+		page.NextCursor = encodeUserCursor(&model.UserCursor{Sort: model.UserSortRank, Desc: true, Value: strconv.FormatFloat(last.Rank, 'g', -1, 64), ID: last.User.ID})
+	}
+	if page.Results == nil {
+		page.Results = []*model.UserSearchResult{}
+	}
+	return page, nil
+}
+
+// sendVerification mails the user a link to verify their email. A failure is
+// only logged, since the user can ask for another link.
+func (s *UserService) sendVerification(ctx context.Context, user *model.User) {
+	// Within a transaction, the user may yet be rolled back.
+	repository.AfterCommit(ctx, func(ctx context.Context) {
+		if err := s.verifier.SendVerification(ctx, user); err != nil {
+			logger.Error.Printf("Could not send verification email to user %s: %v", user.ID, err)
+		}
+	})
 }
 
-// ListUsers retrieves a list of all users.
-func (s *UserService) ListUsers(ctx context.Context) ([]*model.User, error) {
-	users, err := s.repo.List(ctx)
+// editableUserFields are the members of a user's JSON representation that
+// a patch may change, those of model.UpdateUserRequest.
+var editableUserFields = []string{"name", "email", "roles"}
+
+// applyUserPatch patches the JSON representation of user and returns its
+// editable fields, after checking that no other field was changed.
+func applyUserPatch(user *model.User, patch *model.UserPatch) (*model.UpdateUserRequest, error) {
+	doc, err := json.Marshal(user)
 	if err != nil {
 		return nil, err
 	}
-	return users, nil
+
+	var patched []byte
+	switch patch.Type {
+	case model.MergePatchType:
+		patched, err = jsonpatch.MergePatch(doc, patch.Patch)
+	case model.JSONPatchType:
+		patched, err = jsonpatch.Apply(doc, patch.Patch)
+	default:
+		return nil, fmt.Errorf("%w: unsupported patch type %q", ierr.ErrInvalidPatch, patch.Type)
+	}
+	if errors.Is(err, jsonpatch.ErrTestFailed) {
+		return nil, fmt.Errorf("%w: %v", ierr.ErrPatchTestFailed, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ierr.ErrInvalidPatch, err)
+	}
+
+	var before, after map[string]any
+	if err := json.Unmarshal(doc, &before); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(patched, &after); err != nil {
+		return nil, fmt.Errorf("%w: the user must remain an object", ierr.ErrInvalidPatch)
+	}
+	for _, field := range editableUserFields {
+		delete(before, field)
+		delete(after, field)
+	}
+	for field := range mergeKeys(before, after) {
+		if !reflect.DeepEqual(before[field], after[field]) {
+			return nil, fmt.Errorf("%w: %s is read-only", ierr.ErrInvalidPatch, field)
+		}
+	}
+
+	req := &model.UpdateUserRequest{}
+	if err := json.Unmarshal(patched, req); err != nil {
+		return nil, fmt.Errorf("%w: %v", ierr.ErrInvalidUser, err)
+	}
+	return req, nil
+}
+
+func mergeKeys(a, b map[string]any) map[string]struct{} {
+	keys := make(map[string]struct{}, len(a)+len(b))
+	for k := range a {
+		keys[k] = struct{}{}
+	}
+	for k := range b {
+		keys[k] = struct{}{}
+	}
+	return keys
+}
+
+// validateNewUser checks the name and email of a new user. Its roles and
+// password are checked by CreateUser.
+func validateNewUser(req *model.NewUserRequest) error {
+	if strings.TrimSpace(req.Name) == "" {
+		return fmt.Errorf("%w: name is required", ierr.ErrInvalidUser)
+	}
+	if !strings.Contains(req.Email, "@") {
+		return fmt.Errorf("%w: email must be an email address", ierr.ErrInvalidUser)
+	}
+	return nil
+}
+
+// validateUserUpdate checks the editable fields of a user, all of which are
+// required.
+func validateUserUpdate(req *model.UpdateUserRequest) error {
+	if strings.TrimSpace(req.Name) == "" {
+		return fmt.Errorf("%w: name is required", ierr.ErrInvalidUser)
+	}
+	if !strings.Contains(req.Email, "@") {
+		return fmt.Errorf("%w: email must be an email address", ierr.ErrInvalidUser)
+	}
+	if len(req.Roles) == 0 {
+		return fmt.Errorf("%w: at least one role is required", ierr.ErrInvalidUser)
+	}
+	return validateRoles(req.Roles)
+}
+
+// sameRoles reports whether a and b hold the same roles, in any order.
+func sameRoles(a, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(slices.Compact(a), slices.Compact(b))
+}
+
+// validateRoles checks that every role is a known one.
+func validateRoles(roles []string) error {
+	for _, role := range roles {
+		if !model.ValidRole(role) {
+			return fmt.Errorf("%w: %q", ierr.ErrInvalidRole, role)
+		}
+	}
+	return nil
+}
+
+// encodeUserCursor makes an opaque, URL-safe cursor.
+func encodeUserCursor(c *model.UserCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeUserCursor(cursor string) (*model.UserCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ierr.ErrInvalidCursor
+	}
+	var c model.UserCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == uuid.Nil {
+		return nil, ierr.ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// userSortValue is the value of the user's sort field, as stored in a cursor.
+func userSortValue(user *model.User, sort string) string {
+	switch sort {
+	case model.UserSortUpdatedAt:
+		return user.UpdatedAt.UTC().Format(time.RFC3339Nano)
+	case model.UserSortName:
+		return user.Name
+	case model.UserSortEmail:
+		return user.Email
+	case model.UserSortDeletedAt:
+		if user.DeletedAt == nil {
+			return ""
+		}
+		return user.DeletedAt.UTC().Format(time.RFC3339Nano)
+	default:
+		return user.CreatedAt.UTC().Format(time.RFC3339Nano)
+	}
 }
@@ -2,18 +2,53 @@ package service
 
 import (
 	"context"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/faizalom/go-api/internal/config"
+	"github.com/faizalom/go-api/internal/ierr"
 	"github.com/faizalom/go-api/internal/model"
+	"github.com/faizalom/go-api/internal/password"
 	"github.com/faizalom/go-api/internal/repository/mocks"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
+// testPasswords is a lenient policy; the rules themselves are tested in the
+// password package.
+var testPasswords = password.NewPolicy(config.PasswordPolicyConfig{MinLength: 8}, "password123")
+
+// recordingVerifier records the users it was asked to send a verification to.
+type recordingVerifier struct {
+	sent []*model.User
+}
+
+func (v *recordingVerifier) SendVerification(ctx context.Context, user *model.User) error {
+	v.sent = append(v.sent, user)
+	return nil
+}
+
+func (v *recordingVerifier) ResendVerification(ctx context.Context, req *model.ResendVerificationRequest) error {
+	return nil
+}
+
+func (v *recordingVerifier) Verify(ctx context.Context, token string) error {
+	return nil
+}
+
+// withTx returns a transaction manager that runs every function it is given.
+func withTx() *mocks.MockTxManager {
+	tx := new(mocks.MockTxManager)
+	tx.On("WithinTx", mock.Anything).Return(nil)
+	return tx
+}
+
 func TestUserService_CreateUser(t *testing.T) {
 	mockUserRepo := new(mocks.MockUserRepository)
-	userService := NewUserService(mockUserRepo)
+	verifier := &recordingVerifier{}
+	userService := NewUserService(mockUserRepo, withTx(), testPasswords, verifier)
 
 	req := &model.NewUserRequest{
 		Name:     "test user",
@@ -21,19 +56,93 @@ func TestUserService_CreateUser(t *testing.T) {
 		Password: "password",
 	}
 
-	mockUserRepo.On("GetByEmail", mock.Anything, req.Email).Return(&model.User{}, "", assert.AnError)
-	mockUserRepo.On("Create", mock.Anything, mock.AnythingOfType("*model.User"), mock.AnythingOfType("string")).Return(&model.User{}, nil)
+	mockUserRepo.On("GetByEmail", mock.Anything, req.Email).Return(&model.User{}, "", ierr.ErrUserNotFound)
+	mockUserRepo.On("Create", mock.Anything, mock.MatchedBy(func(user *model.User) bool {
+		return user.EmailVerifiedAt == nil
+	}), mock.AnythingOfType("string")).Return(&model.User{Email: req.Email}, nil)
 
 	createdUser, err := userService.CreateUser(context.Background(), req)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, createdUser)
+	assert.Equal(t, []*model.User{createdUser}, verifier.sent)
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestUserService_CreateUser_DefaultRoles(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	userService := NewUserService(mockUserRepo, withTx(), testPasswords, &recordingVerifier{})
+
+	req := &model.NewUserRequest{
+		Name:     "test user",
+		Email:    "test@example.com",
+		Password: "password",
+	}
+
+	mockUserRepo.On("GetByEmail", mock.Anything, req.Email).Return(&model.User{}, "", ierr.ErrUserNotFound)
+	mockUserRepo.On("Create", mock.Anything, mock.MatchedBy(func(user *model.User) bool {
+		return assert.ObjectsAreEqual(model.DefaultRoles, user.Roles)
+	}), mock.AnythingOfType("string")).Return(&model.User{}, nil)
+
+	_, err := userService.CreateUser(context.Background(), req)
+
+	assert.NoError(t, err)
 	mockUserRepo.AssertExpectations(t)
 }
 
+func TestUserService_CreateUser_InvalidRole(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	userService := NewUserService(mockUserRepo, withTx(), testPasswords, &recordingVerifier{})
+
+	req := &model.NewUserRequest{
+		Name:     "test user",
+		Email:    "test@example.com",
+		Password: "password",
+		Roles:    []string{"superuser"},
+	}
+
+	createdUser, err := userService.CreateUser(context.Background(), req)
+
+	assert.ErrorIs(t, err, ierr.ErrInvalidRole)
+	assert.Nil(t, createdUser)
+	mockUserRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUserService_CreateUser_Invalid(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	userService := NewUserService(mockUserRepo, withTx(), testPasswords, &recordingVerifier{})
+
+	for _, req := range []*model.NewUserRequest{
+		{Name: " ", Email: "test@example.com", Password: "password123"},
+		{Name: "test user", Email: "not an email", Password: "password123"},
+	} {
+		createdUser, err := userService.CreateUser(context.Background(), req)
+
+		assert.ErrorIs(t, err, ierr.ErrInvalidUser)
+		assert.Nil(t, createdUser)
+	}
+	mockUserRepo.AssertNotCalled(t, "GetByEmail", mock.Anything, mock.Anything)
+	mockUserRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUserService_CreateUser_WeakPassword(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	userService := NewUserService(mockUserRepo, withTx(), testPasswords, &recordingVerifier{})
+
+	for _, pw := range []string{"short", "Password123"} {
+		req := &model.NewUserRequest{Name: "test user", Email: "test@example.com", Password: pw}
+
+		createdUser, err := userService.CreateUser(context.Background(), req)
+
+		assert.ErrorIs(t, err, ierr.ErrWeakPassword)
+		assert.Nil(t, createdUser)
+	}
+	mockUserRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything, mock.Anything)
+}
+
 func TestUserService_GetUserByID(t *testing.T) {
 	mockUserRepo := new(mocks.MockUserRepository)
-	userService := NewUserService(mockUserRepo)
+	userService := NewUserService(mockUserRepo, withTx(), testPasswords, &recordingVerifier{})
 
 	user := &model.User{
 		ID: uuid.New(),
@@ -49,68 +158,430 @@ func TestUserService_GetUserByID(t *testing.T) {
 	mockUserRepo.AssertExpectations(t)
 }
 
+func TestUserService_GetUserByID_Error(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	userService := NewUserService(mockUserRepo, withTx(), testPasswords, &recordingVerifier{})
+
+	userID := uuid.New()
+	dbErr := &ierr.DBError{Kind: ierr.ErrQueryTimeout, Err: assert.AnError}
+	mockUserRepo.On("GetByID", mock.Anything, userID).Return((*model.User)(nil), dbErr)
+
+	_, err := userService.GetUserByID(context.Background(), userID)
+
+	assert.ErrorIs(t, err, ierr.ErrQueryTimeout)
+	assert.NotErrorIs(t, err, ierr.ErrUserNotFound)
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestUserService_CreateUser_LookupError(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	userService := NewUserService(mockUserRepo, withTx(), testPasswords, &recordingVerifier{})
+
+	req := &model.NewUserRequest{Name: "test user", Email: "test@example.com", Password: "correct-horse"}
+	mockUserRepo.On("GetByEmail", mock.Anything, req.Email).Return(&model.User{}, "", assert.AnError)
+
+	_, err := userService.CreateUser(context.Background(), req)
+
+	// A failed lookup does not count as a free email.
+	assert.ErrorIs(t, err, assert.AnError)
+	mockUserRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything, mock.Anything)
+}
+
 func TestUserService_UpdateUser(t *testing.T) {
 	mockUserRepo := new(mocks.MockUserRepository)
-	userService := NewUserService(mockUserRepo)
+	verifier := &recordingVerifier{}
+	userService := NewUserService(mockUserRepo, withTx(), testPasswords, verifier)
 
 	userID := uuid.New()
 	req := &model.UpdateUserRequest{
-		Name:  stringPtr("updated name"),
-		Email: stringPtr("updated@example.com"),
+		Name:  "updated name",
+		Email: "updated@example.com",
+		Roles: []string{model.RoleAthlete},
 	}
 
+	verifiedAt := time.Now()
 	user := &model.User{
-		ID:    userID,
-		Name:  "original name",
-		Email: "original@example.com",
+		ID:              userID,
+		Name:            "original name",
+		Email:           "original@example.com",
+		EmailVerifiedAt: &verifiedAt,
+		Roles:           []string{model.RoleAthlete},
+		Version:         2,
 	}
 
 	mockUserRepo.On("GetByID", mock.Anything, userID).Return(user, nil)
-	mockUserRepo.On("Update", mock.Anything, userID, mock.AnythingOfType("*model.User")).Return(nil)
+	mockUserRepo.On("GetByEmail", mock.Anything, req.Email).Return(&model.User{}, "", ierr.ErrUserNotFound)
+	mockUserRepo.On("Update", mock.Anything, userID, mock.AnythingOfType("*model.User")).Return(true, nil)
 
-	updatedUser, err := userService.UpdateUser(context.Background(), userID, req)
+	updatedUser, err := userService.UpdateUser(context.Background(), userID, 2, req, model.UserEditRights{Email: true})
 
 	assert.NoError(t, err)
 	assert.NotNil(t, updatedUser)
-	assert.Equal(t, *req.Name, updatedUser.Name)
-	assert.Equal(t, *req.Email, updatedUser.Email)
+	assert.Equal(t, req.Name, updatedUser.Name)
+	assert.Equal(t, req.Email, updatedUser.Email)
+	// The new email has to be verified again.
+	assert.Nil(t, updatedUser.EmailVerifiedAt)
+	assert.Equal(t, []*model.User{updatedUser}, verifier.sent)
 	mockUserRepo.AssertExpectations(t)
 }
 
+func TestUserService_UpdateUser_SameEmail(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	verifier := &recordingVerifier{}
+	userService := NewUserService(mockUserRepo, withTx(), testPasswords, verifier)
+
+	userID := uuid.New()
+	verifiedAt := time.Now()
+	user := &model.User{ID: userID, Name: "original name", Email: "original@example.com", EmailVerifiedAt: &verifiedAt, Roles: []string{model.RoleAthlete}}
+
+	mockUserRepo.On("GetByID", mock.Anything, userID).Return(user, nil)
+	mockUserRepo.On("Update", mock.Anything, userID, mock.AnythingOfType("*model.User")).Return(true, nil)
+
+	req := &model.UpdateUserRequest{Name: "new name", Email: "original@example.com", Roles: []string{model.RoleAthlete}}
+	updatedUser, err := userService.UpdateUser(context.Background(), userID, 0, req, model.UserEditRights{})
+
+	assert.NoError(t, err)
+	assert.NotNil(t, updatedUser.EmailVerifiedAt)
+	assert.Empty(t, verifier.sent)
+}
+
+func TestUserService_UpdateUser_Invalid(t *testing.T) {
+	userID := uuid.New()
+	user := &model.User{ID: userID, Name: "name", Email: "user@example.com", Roles: []string{model.RoleAthlete}}
+
+	tests := []struct {
+		name   string
+		req    model.UpdateUserRequest
+		rights model.UserEditRights
+		want   error
+	}{
+		{"missing name", model.UpdateUserRequest{Email: "user@example.com", Roles: []string{model.RoleAthlete}}, model.UserEditRights{}, ierr.ErrInvalidUser},
+		{"missing email", model.UpdateUserRequest{Name: "name", Roles: []string{model.RoleAthlete}}, model.UserEditRights{}, ierr.ErrInvalidUser},
+		{"missing roles", model.UpdateUserRequest{Name: "name", Email: "user@example.com"}, model.UserEditRights{Roles: true}, ierr.ErrInvalidUser},
+		{"unknown role", model.UpdateUserRequest{Name: "name", Email: "user@example.com", Roles: []string{"pilot"}}, model.UserEditRights{Roles: true}, ierr.ErrInvalidRole},
+		{"roles changed", model.UpdateUserRequest{Name: "name", Email: "user@example.com", Roles: []string{model.RoleAdmin}}, model.UserEditRights{}, ierr.ErrRolesNotAllowed},
+		{"email changed", model.UpdateUserRequest{Name: "name", Email: "other@example.com", Roles: []string{model.RoleAthlete}}, model.UserEditRights{Roles: true}, ierr.ErrEmailNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockUserRepo := new(mocks.MockUserRepository)
+			userService := NewUserService(mockUserRepo, withTx(), testPasswords, &recordingVerifier{})
+			mockUserRepo.On("GetByID", mock.Anything, userID).Return(user, nil)
+
+			_, err := userService.UpdateUser(context.Background(), userID, 0, &tt.req, tt.rights)
+
+			assert.ErrorIs(t, err, tt.want)
+			mockUserRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestUserService_PatchUser(t *testing.T) {
+	userID := uuid.New()
+
+	tests := []struct {
+		name  string
+		patch model.UserPatch
+		want  model.User
+	}{
+		{
+			name:  "merge patch",
+			patch: model.UserPatch{Type: model.MergePatchType, Patch: []byte(`{"name":"new name"}`)},
+			want:  model.User{Name: "new name", Email: "user@example.com", Roles: []string{model.RoleAthlete}},
+		},
+		{
+			name:  "json patch",
+			patch: model.UserPatch{Type: model.JSONPatchType, Patch: []byte(`[{"op":"test","path":"/name","value":"name"},{"op":"add","path":"/roles/-","value":"coach"}]`)},
+			want:  model.User{Name: "name", Email: "user@example.com", Roles: []string{model.RoleAthlete, model.RoleCoach}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockUserRepo := new(mocks.MockUserRepository)
+			userService := NewUserService(mockUserRepo, withTx(), testPasswords, &recordingVerifier{})
+
+			user := &model.User{ID: userID, Name: "name", Email: "user@example.com", Roles: []string{model.RoleAthlete}, Version: 1}
+			mockUserRepo.On("GetByID", mock.Anything, userID).Return(user, nil)
+			mockUserRepo.On("Update", mock.Anything, userID, mock.AnythingOfType("*model.User")).Return(true, nil)
+
+			patched, err := userService.PatchUser(context.Background(), userID, 1, &tt.patch, model.UserEditRights{Roles: true})
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want.Name, patched.Name)
+			assert.Equal(t, tt.want.Email, patched.Email)
+			assert.Equal(t, tt.want.Roles, patched.Roles)
+		})
+	}
+}
+
+func TestUserService_PatchUser_Errors(t *testing.T) {
+	userID := uuid.New()
+
+	tests := []struct {
+		name   string
+		patch  model.UserPatch
+		rights model.UserEditRights
+		want   error
+	}{
+		{"read-only field", model.UserPatch{Type: model.MergePatchType, Patch: []byte(`{"is_active":false}`)}, model.UserEditRights{Roles: true}, ierr.ErrInvalidPatch},
+		{"read-only id", model.UserPatch{Type: model.JSONPatchType, Patch: []byte(`[{"op":"replace","path":"/id","value":"x"}]`)}, model.UserEditRights{Roles: true}, ierr.ErrInvalidPatch},
+		{"malformed patch", model.UserPatch{Type: model.JSONPatchType, Patch: []byte(`{"op":"add"}`)}, model.UserEditRights{Roles: true}, ierr.ErrInvalidPatch},
+		{"not an object", model.UserPatch{Type: model.MergePatchType, Patch: []byte(`["name"]`)}, model.UserEditRights{Roles: true}, ierr.ErrInvalidPatch},
+		{"test fails", model.UserPatch{Type: model.JSONPatchType, Patch: []byte(`[{"op":"test","path":"/name","value":"other"}]`)}, model.UserEditRights{Roles: true}, ierr.ErrPatchTestFailed},
+		{"removes name", model.UserPatch{Type: model.MergePatchType, Patch: []byte(`{"name":null}`)}, model.UserEditRights{Roles: true}, ierr.ErrInvalidUser},
+		{"wrong type", model.UserPatch{Type: model.MergePatchType, Patch: []byte(`{"roles":"admin"}`)}, model.UserEditRights{Roles: true}, ierr.ErrInvalidUser},
+		{"roles not allowed", model.UserPatch{Type: model.MergePatchType, Patch: []byte(`{"roles":["admin"]}`)}, model.UserEditRights{}, ierr.ErrRolesNotAllowed},
+		{"email not allowed", model.UserPatch{Type: model.MergePatchType, Patch: []byte(`{"email":"other@example.com"}`)}, model.UserEditRights{Roles: true}, ierr.ErrEmailNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockUserRepo := new(mocks.MockUserRepository)
+			userService := NewUserService(mockUserRepo, withTx(), testPasswords, &recordingVerifier{})
+
+			user := &model.User{ID: userID, Name: "name", Email: "user@example.com", Roles: []string{model.RoleAthlete}, IsActive: true}
+			mockUserRepo.On("GetByID", mock.Anything, userID).Return(user, nil)
+
+			_, err := userService.PatchUser(context.Background(), userID, 0, &tt.patch, tt.rights)
+
+			assert.ErrorIs(t, err, tt.want)
+			mockUserRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
 func TestUserService_DeleteUser(t *testing.T) {
 	mockUserRepo := new(mocks.MockUserRepository)
-	userService := NewUserService(mockUserRepo)
+	userService := NewUserService(mockUserRepo, withTx(), testPasswords, &recordingVerifier{})
+
+	userID := uuid.New()
+
+	mockUserRepo.On("GetByID", mock.Anything, userID).Return(&model.User{Version: 3}, nil)
+	mockUserRepo.On("Delete", mock.Anything, userID, 3).Return(true, nil)
+
+	err := userService.DeleteUser(context.Background(), userID, 3)
+
+	assert.NoError(t, err)
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestUserService_AnyVersion(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	userService := NewUserService(mockUserRepo, withTx(), testPasswords, &recordingVerifier{})
 
 	userID := uuid.New()
+	req := &model.UpdateUserRequest{Name: "new name", Email: "user@example.com", Roles: []string{model.RoleAthlete}}
 
-	mockUserRepo.On("GetByID", mock.Anything, userID).Return(&model.User{}, nil)
-	mockUserRepo.On("Delete", mock.Anything, userID).Return(nil)
+	// The version read is still checked when writing, so that a concurrent
+	// change is not overwritten.
+	mockUserRepo.On("GetByID", mock.Anything, userID).Return(&model.User{ID: userID, Email: "user@example.com", Roles: []string{model.RoleAthlete}, Version: 5}, nil)
+	mockUserRepo.On("Update", mock.Anything, userID, mock.MatchedBy(func(user *model.User) bool { return user.Version == 5 })).Return(true, nil)
+	mockUserRepo.On("Delete", mock.Anything, userID, 5).Return(true, nil)
 
-	err := userService.DeleteUser(context.Background(), userID)
+	_, err := userService.UpdateUser(context.Background(), userID, model.AnyVersion, req, model.UserEditRights{})
+	assert.NoError(t, err)
 
+	err = userService.DeleteUser(context.Background(), userID, model.AnyVersion)
 	assert.NoError(t, err)
 	mockUserRepo.AssertExpectations(t)
 }
 
+func TestUserService_VersionMismatch(t *testing.T) {
+	userID := uuid.New()
+	req := &model.UpdateUserRequest{Name: "new name", Email: "user@example.com", Roles: []string{model.RoleAthlete}}
+
+	t.Run("stale version", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		verifier := &recordingVerifier{}
+		userService := NewUserService(mockUserRepo, withTx(), testPasswords, verifier)
+
+		mockUserRepo.On("GetByID", mock.Anything, userID).Return(&model.User{ID: userID, Email: "user@example.com", Roles: []string{model.RoleAthlete}, Version: 5}, nil)
+
+		_, err := userService.UpdateUser(context.Background(), userID, 4, req, model.UserEditRights{})
+		assert.ErrorIs(t, err, ierr.ErrVersionMismatch)
+
+		err = userService.DeleteUser(context.Background(), userID, 4)
+		assert.ErrorIs(t, err, ierr.ErrVersionMismatch)
+
+		mockUserRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything, mock.Anything)
+		mockUserRepo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("changed meanwhile", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		userService := NewUserService(mockUserRepo, withTx(), testPasswords, &recordingVerifier{})
+
+		mockUserRepo.On("GetByID", mock.Anything, userID).Return(&model.User{ID: userID, Email: "user@example.com", Roles: []string{model.RoleAthlete}, Version: 5}, nil)
+		mockUserRepo.On("Update", mock.Anything, userID, mock.AnythingOfType("*model.User")).Return(false, nil)
+		mockUserRepo.On("Delete", mock.Anything, userID, 5).Return(false, nil)
+
+		_, err := userService.UpdateUser(context.Background(), userID, 5, req, model.UserEditRights{})
+		assert.ErrorIs(t, err, ierr.ErrVersionMismatch)
+
+		err = userService.DeleteUser(context.Background(), userID, 5)
+		assert.ErrorIs(t, err, ierr.ErrVersionMismatch)
+	})
+}
+
 func TestUserService_ListUsers(t *testing.T) {
 	mockUserRepo := new(mocks.MockUserRepository)
-	userService := NewUserService(mockUserRepo)
+	userService := NewUserService(mockUserRepo, withTx(), testPasswords, &recordingVerifier{})
 
+	now := time.Now()
 	users := []*model.User{
-		{ID: uuid.New()},
-		{ID: uuid.New()},
+		{ID: uuid.New(), CreatedAt: now},
+		{ID: uuid.New(), CreatedAt: now.Add(-time.Second)},
+		{ID: uuid.New(), CreatedAt: now.Add(-2 * time.Second)},
+	}
+
+	// One more user than the page holds means there is a next page.
+	mockUserRepo.On("List", mock.Anything, mock.MatchedBy(func(q model.UserListQuery) bool {
+		return q.Limit == 3 && q.After == nil && q.Sort == model.UserSortCreatedAt && q.Desc
+	})).Return(users, nil).Once()
+
+	page, err := userService.ListUsers(context.Background(), &model.UserListRequest{Sort: model.UserSortCreatedAt, Desc: true, Limit: 2})
+
+	assert.NoError(t, err)
+	assert.Equal(t, users[:2], page.Users)
+	assert.NotEmpty(t, page.NextCursor)
+
+	// The cursor resumes after the last user on the page.
+	mockUserRepo.On("List", mock.Anything, mock.MatchedBy(func(q model.UserListQuery) bool {
+		return q.After != nil && q.After.ID == users[1].ID && q.After.Value == users[1].CreatedAt.UTC().Format(time.RFC3339Nano)
+	})).Return(users[2:], nil).Once()
+
+	page, err = userService.ListUsers(context.Background(), &model.UserListRequest{Sort: model.UserSortCreatedAt, Desc: true, Limit: 2, Cursor: page.NextCursor})
+
+	assert.NoError(t, err)
+	assert.Equal(t, users[2:], page.Users)
+	assert.Empty(t, page.NextCursor)
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestUserService_ListUsers_Defaults(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	userService := NewUserService(mockUserRepo, withTx(), testPasswords, &recordingVerifier{})
+
+	mockUserRepo.On("List", mock.Anything, mock.MatchedBy(func(q model.UserListQuery) bool {
+		return q.Limit == model.MaxUserPageSize+1 && q.Sort == model.UserSortCreatedAt
+	})).Return([]*model.User(nil), nil)
+
+	page, err := userService.ListUsers(context.Background(), &model.UserListRequest{Limit: 10000})
+
+	assert.NoError(t, err)
+	assert.NotNil(t, page.Users)
+	assert.Empty(t, page.NextCursor)
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestUserService_ListUsers_InvalidCursor(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	userService := NewUserService(mockUserRepo, withTx(), testPasswords, &recordingVerifier{})
+
+	cursor := encodeUserCursor(&model.UserCursor{Sort: model.UserSortName, Value: "Ann", ID: uuid.New()})
+
+	for name, req := range map[string]*model.UserListRequest{
+		"garbage":        {Cursor: "not a cursor"},
+		"different sort": {Sort: model.UserSortEmail, Cursor: cursor},
+		"different dir":  {Sort: model.UserSortName, Desc: true, Cursor: cursor},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := userService.ListUsers(context.Background(), req)
+			assert.ErrorIs(t, err, ierr.ErrInvalidCursor)
+		})
 	}
+	mockUserRepo.AssertNotCalled(t, "List", mock.Anything, mock.Anything)
+}
+
+func TestUserService_SearchUsers(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	userService := NewUserService(mockUserRepo, withTx(), testPasswords, &recordingVerifier{})
+
+	results := []*model.UserSearchResult{
+		{User: &model.User{ID: uuid.New()}, Rank: 0.9},
+		{User: &model.User{ID: uuid.New()}, Rank: 0.5},
+	}
+
+	mockUserRepo.On("Search", mock.Anything, mock.MatchedBy(func(q model.UserSearchQuery) bool {
+		return q.Query == "ann" && q.Limit == 2 && q.After == nil
+	})).Return(results, nil).Once()
 
-	mockUserRepo.On("List", mock.Anything).Return(users, nil)
+	page, err := userService.SearchUsers(context.Background(), &model.UserSearchRequest{Query: "  ann ", Limit: 1})
 
-	foundUsers, err := userService.ListUsers(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, results[:1], page.Results)
+	assert.NotEmpty(t, page.NextCursor)
+
+	mockUserRepo.On("Search", mock.Anything, mock.MatchedBy(func(q model.UserSearchQuery) bool {
+		return q.After != nil && q.After.ID == results[0].User.ID && q.After.Value == "0.9"
+	})).Return(results[1:], nil).Once()
+
+	page, err = userService.SearchUsers(context.Background(), &model.UserSearchRequest{Query: "ann", Limit: 1, Cursor: page.NextCursor})
 
 	assert.NoError(t, err)
-	assert.NotNil(t, foundUsers)
-	assert.Equal(t, users, foundUsers)
+	assert.Equal(t, results[1:], page.Results)
+	assert.Empty(t, page.NextCursor)
 	mockUserRepo.AssertExpectations(t)
 }
 
-func stringPtr(s string) *string {
-	return &s
+func TestUserService_SearchUsers_Invalid(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	userService := NewUserService(mockUserRepo, withTx(), testPasswords, &recordingVerifier{})
+
+	_, err := userService.SearchUsers(context.Background(), &model.UserSearchRequest{Query: "   "})
+	assert.ErrorIs(t, err, ierr.ErrInvalidSearch)
+
+	_, err = userService.SearchUsers(context.Background(), &model.UserSearchRequest{Query: strings.Repeat("a", maxSearchLength+1)})
+	assert.ErrorIs(t, err, ierr.ErrInvalidSearch)
+
+	// A cursor from listing users cannot page through search results.
+	cursor := encodeUserCursor(&model.UserCursor{Sort: model.UserSortCreatedAt, Value: "2024-01-01T00:00:00Z", ID: uuid.New()})
+	_, err = userService.SearchUsers(context.Background(), &model.UserSearchRequest{Query: "ann", Cursor: cursor})
+	assert.ErrorIs(t, err, ierr.ErrInvalidCursor)
+
+	mockUserRepo.AssertNotCalled(t, "Search", mock.Anything, mock.Anything)
+}
+
+func TestUserService_RestoreUser(t *testing.T) {
+	id := uuid.New()
+	deleted := &model.User{ID: id, Email: "back@example.com"}
+
+	t.Run("restored", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		userService := NewUserService(mockUserRepo, withTx(), testPasswords, &recordingVerifier{})
+
+		mockUserRepo.On("GetDeletedByID", mock.Anything, id).Return(deleted, nil)
+		mockUserRepo.On("Restore", mock.Anything, id).Return(true, nil)
+		mockUserRepo.On("GetByID", mock.Anything, id).Return(&model.User{ID: id, Email: deleted.Email}, nil)
+
+		user, err := userService.RestoreUser(context.Background(), id)
+
+		assert.NoError(t, err)
+		assert.Equal(t, id, user.ID)
+		mockUserRepo.AssertExpectations(t)
+	})
+
+	t.Run("email taken", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		userService := NewUserService(mockUserRepo, withTx(), testPasswords, &recordingVerifier{})
+
+		mockUserRepo.On("GetDeletedByID", mock.Anything, id).Return(deleted, nil)
+		mockUserRepo.On("Restore", mock.Anything, id).Return(false, nil)
+		mockUserRepo.On("GetByEmail", mock.Anything, deleted.Email).Return(&model.User{ID: uuid.New()}, "hash", nil)
+
+		_, err := userService.RestoreUser(context.Background(), id)
+
+		assert.ErrorIs(t, err, ierr.ErrUserAlreadyExists)
+	})
+
+	t.Run("not deleted", func(t *testing.T) {
+		mockUserRepo := new(mocks.MockUserRepository)
+		userService := NewUserService(mockUserRepo, withTx(), testPasswords, &recordingVerifier{})
+
+		mockUserRepo.On("GetDeletedByID", mock.Anything, id).Return(nil, ierr.ErrUserNotFound)
+
+		_, err := userService.RestoreUser(context.Background(), id)
+
+		assert.ErrorIs(t, err, ierr.ErrUserNotFound)
+		mockUserRepo.AssertNotCalled(t, "Restore", mock.Anything, mock.Anything)
+	})
 }
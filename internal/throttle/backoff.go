@@ -0,0 +1,94 @@
+// Package throttle slows down repeated failed attempts, such as password
+// guesses, with exponential backoff.
+package throttle
+
+import (
+	"sync"
+	"time"
+
+	"github.com/faizalom/go-api/pkg/cache"
+)
+
+// Backoff counts failed attempts per key. The first Free failures are
+// allowed; every further failure blocks the key for a delay that starts at
+// base and doubles each time, up to max. A key's failures are forgotten once
+// resetAfter passes without a new one, or when Reset is called.
+//
+// State is kept in memory, so each server instance throttles on its own.
+type Backoff struct {
+	free       int
+	base       time.Duration
+	max        time.Duration
+	resetAfter time.Duration
+	now        func() time.Time
+
+	// mu makes the read-modify-write in Fail atomic.
+	mu      sync.Mutex
+	entries *cache.TTL[string, state]
+}
+
+type state struct {
+	failures     int
+	blockedUntil time.Time
+}
+
+func NewBackoff(free int, base, max, resetAfter time.Duration) *Backoff {
+	return &Backoff{
+		free:       free,
+		base:       base,
+		max:        max,
+		resetAfter: resetAfter,
+		now:        time.Now,
+		entries:    cache.NewTTL[string, state](),
+	}
+}
+
+// Allow reports whether key may make an attempt now and, if not, how long it
+// has to wait.
+func (b *Backoff) Allow(key string) (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s, ok := b.entries.Get(key)
+	if !ok {
+		return 0, true
+	}
+	if wait := s.blockedUntil.Sub(b.now()); wait > 0 {
+		return wait, false
+	}
+	return 0, true
+}
+
+// Fail records a failed attempt for key and returns how long the key is now
+// blocked for, or zero while it is still within its free attempts.
+func (b *Backoff) Fail(key string) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s, _ := b.entries.Get(key)
+	s.failures++
+
+	var delay time.Duration
+	if s.failures > b.free {
+		delay = b.delay(s.failures - b.free)
+		s.blockedUntil = b.now().Add(delay)
+	}
+	b.entries.Set(key, s, max(b.resetAfter, delay))
+	return delay
+}
+
+// Reset forgets the failures of key, e.g. after a successful attempt.
+func (b *Backoff) Reset(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.entries.Delete(key)
+}
+
+// delay returns base doubled n-1 times, capped at max.
+func (b *Backoff) delay(n int) time.Duration {
+	d := b.base
+	for i := 1; i < n && d < b.max; i++ {
+		d *= 2
+	}
+	return min(d, b.max)
+}
@@ -0,0 +1,71 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock is a settable time source.
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func newTestBackoff(free int) (*Backoff, *fakeClock) {
+	clock := &fakeClock{t: time.Unix(1700000000, 0)}
+	b := NewBackoff(free, time.Second, 8*time.Second, time.Hour)
+	b.now = clock.now
+	return b, clock
+}
+
+func TestBackoff_FreeAttempts(t *testing.T) {
+	b, _ := newTestBackoff(3)
+
+	for i := 0; i < 3; i++ {
+		_, ok := b.Allow("key")
+		assert.True(t, ok)
+		assert.Zero(t, b.Fail("key"))
+	}
+
+	// The fourth failure starts the backoff.
+	assert.Equal(t, time.Second, b.Fail("key"))
+	wait, ok := b.Allow("key")
+	assert.False(t, ok)
+	assert.Equal(t, time.Second, wait)
+
+	// Other keys are unaffected.
+	_, ok = b.Allow("other")
+	assert.True(t, ok)
+}
+
+func TestBackoff_Doubles(t *testing.T) {
+	b, clock := newTestBackoff(0)
+
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 8 * time.Second}
+	for _, delay := range want {
+		assert.Equal(t, delay, b.Fail("key"))
+
+		clock.t = clock.t.Add(delay - time.Millisecond)
+		_, ok := b.Allow("key")
+		assert.False(t, ok)
+
+		clock.t = clock.t.Add(time.Millisecond)
+		_, ok = b.Allow("key")
+		assert.True(t, ok)
+	}
+}
+
+func TestBackoff_Reset(t *testing.T) {
+	b, _ := newTestBackoff(0)
+
+	b.Fail("key")
+	b.Reset("key")
+
+	_, ok := b.Allow("key")
+	assert.True(t, ok)
+	// Counting starts over.
+	assert.Equal(t, time.Second, b.Fail("key"))
+}
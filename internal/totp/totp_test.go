@@ -0,0 +1,101 @@
+package totp
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret is the SHA1 seed of the RFC 6238 test vectors, "12345678901234567890".
+var rfcSecret = encoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestHOTP_RFC4226(t *testing.T) {
+	// Appendix D of RFC 4226.
+	want := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+	for counter, code := range want {
+		assert.Equal(t, code, hotp([]byte("12345678901234567890"), uint64(counter), 6), "counter %d", counter)
+	}
+}
+
+func TestCode_RFC6238(t *testing.T) {
+	// Appendix B of RFC 6238 (SHA1), which uses 8 digit codes; our 6 digit
+	// codes are their last six digits.
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+
+	key, err := decodeSecret(rfcSecret)
+	require.NoError(t, err)
+	for _, v := range vectors {
+		at := time.Unix(v.unix, 0)
+		assert.Equal(t, v.code, hotp(key, uint64(Step(at)), 8), "time %d", v.unix)
+
+		code, err := Code(rfcSecret, at)
+		require.NoError(t, err)
+		assert.Equal(t, v.code[2:], code, "time %d", v.unix)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+	now := time.Unix(1700000000, 0)
+
+	code, err := Code(secret, now)
+	require.NoError(t, err)
+	step, ok := Validate(secret, code, now, 1)
+	assert.True(t, ok)
+	assert.Equal(t, Step(now), step)
+
+	// A code from the previous period is accepted within the skew.
+	prev, err := Code(secret, now.Add(-Period))
+	require.NoError(t, err)
+	step, ok = Validate(secret, prev, now, 1)
+	assert.True(t, ok)
+	assert.Equal(t, Step(now)-1, step)
+
+	// But not beyond it.
+	old, err := Code(secret, now.Add(-2*Period))
+	require.NoError(t, err)
+	_, ok = Validate(secret, old, now, 1)
+	assert.False(t, ok)
+
+	_, ok = Validate(secret, "12345", now, 1)
+	assert.False(t, ok)
+	_, ok = Validate("not base32!", code, now, 1)
+	assert.False(t, ok)
+}
+
+func TestGenerateSecret(t *testing.T) {
+	a, err := GenerateSecret()
+	require.NoError(t, err)
+	b, err := GenerateSecret()
+	require.NoError(t, err)
+
+	assert.Len(t, a, 32)
+	assert.NotEqual(t, a, b)
+}
+
+func TestURI(t *testing.T) {
+	u, err := url.Parse(URI("Workout API", "jane@example.com", "JBSWY3DPEHPK3PXP"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "otpauth", u.Scheme)
+	assert.Equal(t, "totp", u.Host)
+	assert.Equal(t, "/Workout API:jane@example.com", u.Path)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", u.Query().Get("secret"))
+	assert.Equal(t, "Workout API", u.Query().Get("issuer"))
+	assert.Equal(t, "6", u.Query().Get("digits"))
+	assert.Equal(t, "30", u.Query().Get("period"))
+}
@@ -0,0 +1,2 @@
+-- Drop the refresh_tokens table
+DROP TABLE IF EXISTS refresh_tokens;
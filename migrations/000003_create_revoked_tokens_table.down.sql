@@ -0,0 +1,2 @@
+-- Drop the revoked_tokens table
+DROP TABLE IF EXISTS revoked_tokens;
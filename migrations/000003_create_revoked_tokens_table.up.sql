@@ -0,0 +1,9 @@
+-- Create the revoked_tokens table holding the jti of access tokens revoked before they expire
+CREATE TABLE revoked_tokens (
+    jti VARCHAR(64) PRIMARY KEY,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Add an index on expires_at so expired entries can be purged cheaply
+CREATE INDEX idx_revoked_tokens_expires_at ON revoked_tokens(expires_at);
@@ -0,0 +1,2 @@
+-- Drop the roles column
+ALTER TABLE users DROP COLUMN IF EXISTS roles;
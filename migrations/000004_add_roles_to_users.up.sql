@@ -0,0 +1,2 @@
+-- Add the roles column; every existing and new user starts out as an athlete
+ALTER TABLE users ADD COLUMN roles TEXT[] NOT NULL DEFAULT '{athlete}';
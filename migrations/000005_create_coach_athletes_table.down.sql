@@ -0,0 +1,2 @@
+-- Drop the coach_athletes table
+DROP TABLE IF EXISTS coach_athletes;
@@ -0,0 +1,10 @@
+-- Create the coach_athletes table linking coaches to the athletes they train
+CREATE TABLE coach_athletes (
+    coach_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    athlete_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (coach_id, athlete_id)
+);
+
+-- Add an index for looking up an athlete's coaches
+CREATE INDEX idx_coach_athletes_athlete_id ON coach_athletes(athlete_id);
@@ -0,0 +1,2 @@
+-- Drop the user_identities table
+DROP TABLE IF EXISTS user_identities;
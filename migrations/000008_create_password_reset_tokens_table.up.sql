@@ -0,0 +1,12 @@
+-- Create the password_reset_tokens table for the forgotten password flow
+CREATE TABLE password_reset_tokens (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Add an index for invalidating a user's outstanding tokens
+CREATE INDEX idx_password_reset_tokens_user_id ON password_reset_tokens(user_id);
@@ -0,0 +1,5 @@
+-- Drop the email_verification_tokens table
+DROP TABLE IF EXISTS email_verification_tokens;
+
+-- Drop the email_verified_at column
+ALTER TABLE users DROP COLUMN IF EXISTS email_verified_at;
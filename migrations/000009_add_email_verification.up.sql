@@ -0,0 +1,17 @@
+-- Add the email_verified_at column; NULL until the user proves they own the address
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP WITH TIME ZONE;
+
+-- Create the email_verification_tokens table. A token verifies the address it
+-- was sent to, so it stops working if the user changes their email meanwhile.
+CREATE TABLE email_verification_tokens (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Add an index for invalidating a user's outstanding tokens
+CREATE INDEX idx_email_verification_tokens_user_id ON email_verification_tokens(user_id);
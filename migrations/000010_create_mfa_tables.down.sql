@@ -0,0 +1,8 @@
+-- Drop the mfa_challenges table
+DROP TABLE IF EXISTS mfa_challenges;
+
+-- Drop the mfa_recovery_codes table
+DROP TABLE IF EXISTS mfa_recovery_codes;
+
+-- Drop the user_mfa table
+DROP TABLE IF EXISTS user_mfa;
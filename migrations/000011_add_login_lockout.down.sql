@@ -0,0 +1,3 @@
+-- Drop the login lockout columns
+ALTER TABLE users DROP COLUMN IF EXISTS locked_until;
+ALTER TABLE users DROP COLUMN IF EXISTS failed_login_count;
@@ -0,0 +1,4 @@
+-- Add the columns tracking failed logins. locked_until is independent of
+-- is_active: a lockout is temporary and lifts by itself.
+ALTER TABLE users ADD COLUMN failed_login_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN locked_until TIMESTAMP WITH TIME ZONE;
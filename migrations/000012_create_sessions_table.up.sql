@@ -0,0 +1,14 @@
+-- Create the sessions table. A session is one login; its id is the family_id
+-- of the refresh tokens issued for it and the sid claim of its access tokens.
+CREATE TABLE sessions (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip_address VARCHAR(45) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Add an index for listing a user's sessions
+CREATE INDEX idx_sessions_user_id ON sessions(user_id);
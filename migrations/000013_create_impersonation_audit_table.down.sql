@@ -0,0 +1,2 @@
+-- Drop the impersonation_audit table
+DROP TABLE IF EXISTS impersonation_audit;
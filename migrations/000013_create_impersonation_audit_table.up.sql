@@ -0,0 +1,17 @@
+-- Create the impersonation_audit table, one row per impersonation token
+-- issued. There are no foreign keys so that the trail outlives the users.
+CREATE TABLE impersonation_audit (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    actor_id UUID NOT NULL,
+    target_id UUID NOT NULL,
+    token_id VARCHAR(255) NOT NULL,
+    reason TEXT NOT NULL,
+    ip_address VARCHAR(45) NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Add indexes for looking up who impersonated a user and whom an admin impersonated
+CREATE INDEX idx_impersonation_audit_target_id ON impersonation_audit(target_id);
+CREATE INDEX idx_impersonation_audit_actor_id ON impersonation_audit(actor_id);
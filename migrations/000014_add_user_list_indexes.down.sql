@@ -0,0 +1,6 @@
+-- Drop the user list indexes
+DROP INDEX IF EXISTS idx_users_email_domain;
+DROP INDEX IF EXISTS idx_users_email_id;
+DROP INDEX IF EXISTS idx_users_name_id;
+DROP INDEX IF EXISTS idx_users_updated_at_id;
+DROP INDEX IF EXISTS idx_users_created_at_id;
@@ -0,0 +1,9 @@
+-- Add indexes for paging through users in each sort order of GET /users.
+-- The id column breaks ties, so a page can start right after a cursor.
+CREATE INDEX idx_users_created_at_id ON users(created_at, id) WHERE deleted_at IS NULL;
+CREATE INDEX idx_users_updated_at_id ON users(updated_at, id) WHERE deleted_at IS NULL;
+CREATE INDEX idx_users_name_id ON users(name, id) WHERE deleted_at IS NULL;
+CREATE INDEX idx_users_email_id ON users(email, id) WHERE deleted_at IS NULL;
+
+-- Add an index for filtering users by email domain
+CREATE INDEX idx_users_email_domain ON users(lower(split_part(email, '@', 2))) WHERE deleted_at IS NULL;
@@ -0,0 +1,6 @@
+-- Drop the user search indexes and column. The pg_trgm extension is left
+-- installed, as other schemas may use it.
+DROP INDEX IF EXISTS idx_users_email_trgm;
+DROP INDEX IF EXISTS idx_users_name_trgm;
+DROP INDEX IF EXISTS idx_users_search_vector;
+ALTER TABLE users DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,13 @@
+-- Add trigram matching, for finding users by misspelt or partial names
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Add the full-text search vector of each user's name and email. The email is
+-- also split at "@" and "." so that its parts match on their own.
+ALTER TABLE users ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+    to_tsvector('simple', name || ' ' || email || ' ' || translate(email, '@.', '  '))
+) STORED;
+
+-- Add indexes for full-text and trigram search
+CREATE INDEX idx_users_search_vector ON users USING GIN (search_vector) WHERE deleted_at IS NULL;
+CREATE INDEX idx_users_name_trgm ON users USING GIN (name gin_trgm_ops) WHERE deleted_at IS NULL;
+CREATE INDEX idx_users_email_trgm ON users USING GIN (email gin_trgm_ops) WHERE deleted_at IS NULL;
@@ -0,0 +1,6 @@
+-- Restore the unique email constraint. This fails while a deleted user and a
+-- current user share an email; purge or rename one of them first.
+DROP INDEX IF EXISTS idx_users_deleted_at;
+DROP INDEX IF EXISTS idx_users_email_active;
+CREATE INDEX idx_users_email ON users(email);
+ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (email);
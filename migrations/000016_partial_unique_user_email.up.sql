@@ -0,0 +1,8 @@
+-- Replace the unique email constraint with a unique index on current users,
+-- so that the email of a deleted user can be registered again.
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key;
+DROP INDEX IF EXISTS idx_users_email;
+CREATE UNIQUE INDEX idx_users_email_active ON users(email) WHERE deleted_at IS NULL;
+
+-- Add an index for finding deleted users past retention
+CREATE INDEX idx_users_deleted_at ON users(deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,4 @@
+-- Drop the user version and its trigger
+DROP TRIGGER IF EXISTS increment_version ON users;
+DROP FUNCTION IF EXISTS trigger_increment_version();
+ALTER TABLE users DROP COLUMN IF EXISTS version;
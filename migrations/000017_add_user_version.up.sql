@@ -0,0 +1,24 @@
+-- Add a version to users, sent as their ETag, for optimistic concurrency
+-- control of updates and deletes
+ALTER TABLE users ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+-- Create a trigger function that bumps the version on every change, so that
+-- no write can leave a stale ETag matching. Failed logins and lockouts are
+-- left out, or a wrong password would make every open edit of the user fail.
+CREATE OR REPLACE FUNCTION trigger_increment_version()
+RETURNS TRIGGER AS $$
+DECLARE
+  ignored TEXT[] := ARRAY['failed_login_count', 'locked_until', 'updated_at', 'search_vector', 'version'];
+BEGIN
+  IF to_jsonb(NEW) - ignored IS DISTINCT FROM to_jsonb(OLD) - ignored THEN
+    NEW.version = OLD.version + 1;
+  END IF;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Apply the trigger to the users table
+CREATE TRIGGER increment_version
+BEFORE UPDATE ON users
+FOR EACH ROW
+EXECUTE FUNCTION trigger_increment_version();
@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often Set scans for and drops expired entries.
+const sweepInterval = time.Minute
+
+type entry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+// TTL is a concurrency-safe in-memory map whose entries expire after a per-entry duration.
+type TTL[K comparable, V any] struct {
+	mu        sync.Mutex
+	items     map[K]entry[V]
+	lastSweep time.Time
+}
+
+func NewTTL[K comparable, V any]() *TTL[K, V] {
+	return &TTL[K, V]{items: make(map[K]entry[V]), lastSweep: time.Now()}
+}
+
+// Get returns the value stored for key if it has not expired yet.
+func (c *TTL[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.items[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	if time.Now().After(e.expiresAt) {
+		delete(c.items, key)
+		var zero V
+		return zero, false
+	}
+	return e.value, true
+}
+
+// Set stores value for key until ttl has elapsed.
+func (c *TTL[K, V]) Set(key K, value V, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	c.items[key] = entry[V]{value: value, expiresAt: now.Add(ttl)}
+
+	if now.Sub(c.lastSweep) > sweepInterval {
+		for k, e := range c.items {
+			if now.After(e.expiresAt) {
+				delete(c.items, k)
+			}
+		}
+		c.lastSweep = now
+	}
+}
+
+// Delete removes key from the cache.
+func (c *TTL[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.items, key)
+}
+
+// Len returns the number of entries, including ones that have expired but not been swept yet.
+func (c *TTL[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items)
+}
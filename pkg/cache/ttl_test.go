@@ -0,0 +1,41 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTTL_GetSet(t *testing.T) {
+	c := NewTTL[string, int]()
+
+	c.Set("a", 1, time.Minute)
+
+	v, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+
+	_, ok = c.Get("missing")
+	assert.False(t, ok)
+}
+
+func TestTTL_Expiry(t *testing.T) {
+	c := NewTTL[string, int]()
+
+	c.Set("a", 1, -time.Second)
+
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestTTL_Delete(t *testing.T) {
+	c := NewTTL[string, int]()
+
+	c.Set("a", 1, time.Minute)
+	c.Delete("a")
+
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+}
@@ -10,6 +10,12 @@ var (
 	Error *log.Logger
 )
 
+// init sets up the loggers so packages that log are safe to use before Init
+// is called, e.g. in unit tests.
+func init() {
+	Init()
+}
+
 func Init() {
 	Info = log.New(os.Stdout, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
 	Error = log.New(os.Stderr, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile)